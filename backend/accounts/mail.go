@@ -0,0 +1,66 @@
+package accounts
+
+import (
+	"backend/mailer"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// appURL returns the address of the storefront that mailed links point to, configured by APP_URL
+func appURL() string {
+	if value := os.Getenv("APP_URL"); value != "" {
+		return strings.TrimRight(value, "/")
+	}
+	return "http://localhost:3000"
+}
+
+// RequireVerifiedEmail reports whether orders need a verified email, configured by REQUIRE_VERIFIED_EMAIL
+func RequireVerifiedEmail() bool {
+	return os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true"
+}
+
+// SendVerification mails a link that verifies the user's email address
+func SendVerification(db *sql.DB, userID int64, name, email string) error {
+	token, err := Issue(db, userID, PurposeVerifyEmail, email)
+	if err != nil {
+		return err
+	}
+
+	link := appURL() + "/auth/verify-email?token=" + url.QueryEscape(token)
+	return mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening this link:\n\n%s\n\n"+
+			"The link expires in %s. If you did not create an account, you can ignore this email.\n",
+			name, link, describe(TTL(PurposeVerifyEmail))),
+	})
+}
+
+// SendPasswordReset mails a link that sets a new password
+func SendPasswordReset(db *sql.DB, userID int64, name, email string) error {
+	token, err := Issue(db, userID, PurposeResetPassword, email)
+	if err != nil {
+		return err
+	}
+
+	link := appURL() + "/auth/reset-password?token=" + url.QueryEscape(token)
+	return mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nYou can choose a new password by opening this link:\n\n%s\n\n"+
+			"The link expires in %s and works once. If you did not ask for it, you can ignore this email.\n",
+			name, link, describe(TTL(PurposeResetPassword))),
+	})
+}
+
+// describe spells out a token lifetime in whole hours or minutes
+func describe(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d minutes", int(d.Minutes()))
+}
@@ -0,0 +1,105 @@
+package accounts
+
+import (
+	"backend/database"
+	"backend/utils"
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Token purposes
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+	PurposeTwoFactor     = "two_factor"
+)
+
+// ErrInvalidToken is returned for tokens that are unknown, expired or already used
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// TTL returns how long a token stays valid, configured by VERIFY_EMAIL_TTL_HOURS
+// (default 48), PASSWORD_RESET_TTL_MINUTES (default 60) and
+// TWO_FACTOR_CHALLENGE_TTL_MINUTES (default 5)
+func TTL(purpose string) time.Duration {
+	if purpose == PurposeTwoFactor {
+		minutes, err := strconv.Atoi(os.Getenv("TWO_FACTOR_CHALLENGE_TTL_MINUTES"))
+		if err != nil || minutes <= 0 {
+			minutes = 5
+		}
+		return time.Duration(minutes) * time.Minute
+	}
+	if purpose == PurposeVerifyEmail {
+		hours, err := strconv.Atoi(os.Getenv("VERIFY_EMAIL_TTL_HOURS"))
+		if err != nil || hours <= 0 {
+			hours = 48
+		}
+		return time.Duration(hours) * time.Hour
+	}
+
+	minutes, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_TTL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 60
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// Issue creates a token for a purpose, sent to an email address. Earlier
+// tokens of the user for the same purpose stop working.
+func Issue(db *sql.DB, userID int64, purpose, email string) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"UPDATE account_tokens SET used_at = CURRENT_TIMESTAMP WHERE user_id = ? AND purpose = ? AND used_at IS NULL",
+		userID, purpose)
+	if err != nil {
+		return "", err
+	}
+
+	token := utils.RandomToken(32)
+	expiresAt := time.Now().UTC().Add(TTL(purpose))
+	_, err = tx.Exec(
+		"INSERT INTO account_tokens (user_id, purpose, token_hash, email, expires_at) VALUES (?, ?, ?, ?, ?)",
+		userID, purpose, utils.HashToken(token), email, expiresAt.Format(database.TimestampFormat))
+	if err != nil {
+		return "", err
+	}
+
+	return token, tx.Commit()
+}
+
+// Lookup returns the user and email address a valid token was issued for,
+// without using it up
+func Lookup(q database.Querier, token, purpose string) (int64, string, error) {
+	var userID int64
+	var email string
+	err := q.QueryRow(`
+		SELECT user_id, email FROM account_tokens
+		WHERE token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP`,
+		utils.HashToken(token), purpose).Scan(&userID, &email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", ErrInvalidToken
+	}
+	return userID, email, err
+}
+
+// Consume uses up a token and returns the user and email address it was issued for
+func Consume(q database.Querier, token, purpose string) (int64, string, error) {
+	var userID int64
+	var email string
+	err := q.QueryRow(`
+		UPDATE account_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id, email`,
+		utils.HashToken(token), purpose).Scan(&userID, &email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", ErrInvalidToken
+	}
+	return userID, email, err
+}
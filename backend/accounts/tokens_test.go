@@ -0,0 +1,47 @@
+package accounts_test
+
+import (
+	"backend/accounts"
+	"backend/database/dbtest"
+	"backend/dialect"
+	"database/sql"
+	"testing"
+)
+
+func TestTokensAreSingleUse(t *testing.T) {
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		userID := dbtest.CreateUser(t, db, "customer").ID
+
+		token, err := accounts.Issue(db, userID, accounts.PurposeResetPassword, "ada@example.com")
+		if err != nil {
+			t.Fatalf("Issue: %v", err)
+		}
+		if _, _, err := accounts.Consume(db, token, accounts.PurposeVerifyEmail); err != accounts.ErrInvalidToken {
+			t.Errorf("Consume for another purpose = %v, want ErrInvalidToken", err)
+		}
+
+		gotUser, gotEmail, err := accounts.Consume(db, token, accounts.PurposeResetPassword)
+		if err != nil || gotUser != userID || gotEmail != "ada@example.com" {
+			t.Errorf("Consume = %d, %q, %v, want %d, ada@example.com", gotUser, gotEmail, err, userID)
+		}
+		if _, _, err := accounts.Consume(db, token, accounts.PurposeResetPassword); err != accounts.ErrInvalidToken {
+			t.Errorf("second Consume = %v, want ErrInvalidToken", err)
+		}
+
+		// A new token replaces the one sent before
+		older, err := accounts.Issue(db, userID, accounts.PurposeVerifyEmail, "ada@example.com")
+		if err != nil {
+			t.Fatalf("Issue: %v", err)
+		}
+		newer, err := accounts.Issue(db, userID, accounts.PurposeVerifyEmail, "ada@example.com")
+		if err != nil {
+			t.Fatalf("Issue: %v", err)
+		}
+		if _, _, err := accounts.Consume(db, older, accounts.PurposeVerifyEmail); err != accounts.ErrInvalidToken {
+			t.Errorf("Consume of a replaced token = %v, want ErrInvalidToken", err)
+		}
+		if _, _, err := accounts.Consume(db, newer, accounts.PurposeVerifyEmail); err != nil {
+			t.Errorf("Consume of the newest token = %v", err)
+		}
+	})
+}
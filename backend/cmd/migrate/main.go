@@ -0,0 +1,107 @@
+package main
+
+import (
+	"backend/database"
+	"backend/migrations"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = `Usage: go run ./cmd/migrate <command>
+
+Commands:
+  up             Apply all pending migrations
+  down [n]       Roll back the last n migrations (default 1)
+  status         List migrations and whether they are applied
+  create <name>  Write empty up and down files for a new migration, for every database`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using default environment")
+	}
+
+	switch os.Args[1] {
+	case "up":
+		database.Open()
+		defer database.CloseDatabase()
+
+		applied, err := migrations.Up(database.DB, database.Dialect)
+		for _, migration := range applied {
+			fmt.Printf("Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(os.Args) > 2 {
+			n, err := strconv.Atoi(os.Args[2])
+			if err != nil || n < 1 {
+				log.Fatalf("Invalid number of migrations: %s", os.Args[2])
+			}
+			steps = n
+		}
+
+		database.Open()
+		defer database.CloseDatabase()
+
+		for i := 0; i < steps; i++ {
+			migration, err := migrations.Down(database.DB, database.Dialect)
+			if err == migrations.ErrNothingToRollBack {
+				fmt.Println("No migrations left to roll back")
+				break
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("Rolled back %04d_%s\n", migration.Version, migration.Name)
+		}
+
+	case "status":
+		database.Open()
+		defer database.CloseDatabase()
+
+		statuses, err := migrations.List(database.DB, database.Dialect)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", status.Version, status.Name, state)
+		}
+
+	case "create":
+		if len(os.Args) < 3 {
+			log.Fatal("Migration name is required")
+		}
+		paths, err := migrations.Create(migrations.Dir, strings.Join(os.Args[2:], " "))
+		for _, path := range paths {
+			fmt.Printf("Created %s\n", path)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"backend/accounts"
+	"backend/models"
+	"backend/repository"
+	"backend/sessions"
+	"backend/utils"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ForgotPassword mails a password reset link. The response is the same
+// whether or not an account uses the email, so it cannot be used to find accounts.
+func (h *Handler) ForgotPassword(c *fiber.Ctx) error {
+	// Parse request body
+	var request models.ForgotPasswordRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if request.Email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Email is required",
+		})
+	}
+
+	// Find the account
+	user, err := h.Users.FindByEmail(request.Email)
+	if err != nil && err != repository.ErrNotFound {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if err == nil {
+		if err := accounts.SendPasswordReset(h.DB, user.ID, user.Name, user.Email); err != nil {
+			log.Printf("Failed to send password reset to user %d: %v", user.ID, err)
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "If an account uses this email, a password reset link has been sent to it",
+	})
+}
+
+// ResetPassword sets a new password with a token from a password reset email
+func (h *Handler) ResetPassword(c *fiber.Ctx) error {
+	// Parse request body
+	var request models.ResetPasswordRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if request.Token == "" || request.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Token and password are required",
+		})
+	}
+
+	// Hash the password
+	hashedPassword, err := utils.HashPassword(request.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to hash password",
+		})
+	}
+
+	// Use up the token
+	userID, _, err := accounts.Consume(h.DB, request.Token, accounts.PurposeResetPassword)
+	if err == accounts.ErrInvalidToken {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid or expired reset link",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	if err := h.Users.SetPassword(userID, hashedPassword); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update password",
+		})
+	}
+
+	// Whoever knew the old password is logged out
+	if err := sessions.RevokeAll(h.DB, userID); err != nil {
+		log.Printf("Failed to revoke sessions of user %d: %v", userID, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Password has been reset, please log in",
+	})
+}
+
+// VerifyEmail verifies an account's email with a token from a verification email
+func (h *Handler) VerifyEmail(c *fiber.Ctx) error {
+	// Parse request body
+	var request models.VerifyEmailRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if request.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Token is required",
+		})
+	}
+
+	// Use up the token
+	userID, email, err := accounts.Consume(h.DB, request.Token, accounts.PurposeVerifyEmail)
+	if err == accounts.ErrInvalidToken {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid or expired verification link",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	// The link only verifies the address it was sent to
+	err = h.Users.MarkEmailVerified(userID, email)
+	if err == repository.ErrNotFound {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The account's email has changed since this link was sent",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Email verified successfully",
+	})
+}
+
+// ResendVerification mails a new verification link to the current user
+func (h *Handler) ResendVerification(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	// Get the user from the database
+	user, err := h.Users.FindByID(userID)
+	if err == repository.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if user.EmailVerifiedAt != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Email is already verified",
+		})
+	}
+
+	if err := accounts.SendVerification(h.DB, user.ID, user.Name, user.Email); err != nil {
+		log.Printf("Failed to send verification to user %d: %v", user.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to send verification email",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Verification email sent",
+	})
+}
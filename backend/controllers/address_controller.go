@@ -1,17 +1,15 @@
 package controllers
 
 import (
-	"backend/database"
 	"backend/models"
-	"database/sql"
+	"backend/repository"
 	"strconv"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // CreateAddress creates a new address for the user
-func CreateAddress(c *fiber.Ctx) error {
+func (h *Handler) CreateAddress(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
@@ -30,102 +28,34 @@ func CreateAddress(c *fiber.Ctx) error {
 		})
 	}
 
-	// Start a transaction
-	tx, err := database.DB.Begin()
+	// Create the address, the first one becomes the default
+	address := addressFromRequest(userID, req)
+	addressID, err := h.Addresses.Create(address)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to start transaction",
-		})
-	}
-
-	// If this is the default address, update all other addresses to not be default
-	if req.IsDefault {
-		_, err = tx.Exec("UPDATE addresses SET is_default = 0 WHERE user_id = ?", userID)
-		if err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update existing addresses",
-			})
-		}
-	}
-
-	// Create the address
-	result, err := tx.Exec(
-		`INSERT INTO addresses (user_id, name, street, city, state, postal_code, country, phone, is_default, created_at, updated_at) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		userID, req.Name, req.Street, req.City, req.State, req.PostalCode, req.Country, req.Phone, req.IsDefault, time.Now(), time.Now())
-	if err != nil {
-		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create address",
 		})
 	}
 
-	// If no default address yet, make this the default
-	if !req.IsDefault {
-		var count int
-		err = database.DB.QueryRow("SELECT COUNT(*) FROM addresses WHERE user_id = ?", userID).Scan(&count)
-		if err == nil && count == 1 {
-			addressID, _ := result.LastInsertId()
-			_, err = tx.Exec("UPDATE addresses SET is_default = 1 WHERE id = ?", addressID)
-			if err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"error": "Failed to set default address",
-				})
-			}
-			req.IsDefault = true
-		}
-	}
-
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to commit transaction",
-		})
-	}
-
-	// Get the address ID
-	addressID, _ := result.LastInsertId()
-
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message":    "Address created successfully",
 		"id":         addressID,
-		"is_default": req.IsDefault,
+		"is_default": address.IsDefault,
 	})
 }
 
 // GetAllAddresses returns all addresses for the user
-func GetAllAddresses(c *fiber.Ctx) error {
+func (h *Handler) GetAllAddresses(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
-	// Query to get addresses
-	rows, err := database.DB.Query(`
-		SELECT id, user_id, name, street, city, state, postal_code, country, phone, is_default, created_at, updated_at
-		FROM addresses
-		WHERE user_id = ?
-		ORDER BY is_default DESC, id DESC`,
-		userID)
+	// Get the addresses
+	addresses, err := h.Addresses.List(userID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
 		})
 	}
-	defer rows.Close()
-
-	addresses := []models.Address{}
-	for rows.Next() {
-		var address models.Address
-		err := rows.Scan(
-			&address.ID, &address.UserID, &address.Name, &address.Street, &address.City,
-			&address.State, &address.PostalCode, &address.Country, &address.Phone,
-			&address.IsDefault, &address.CreatedAt, &address.UpdatedAt)
-		if err != nil {
-			continue
-		}
-		addresses = append(addresses, address)
-	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"addresses": addresses,
@@ -133,7 +63,7 @@ func GetAllAddresses(c *fiber.Ctx) error {
 }
 
 // GetAddress returns a specific address for the user
-func GetAddress(c *fiber.Ctx) error {
+func (h *Handler) GetAddress(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
@@ -147,17 +77,8 @@ func GetAddress(c *fiber.Ctx) error {
 	}
 
 	// Get the address
-	var address models.Address
-	err = database.DB.QueryRow(`
-		SELECT id, user_id, name, street, city, state, postal_code, country, phone, is_default, created_at, updated_at
-		FROM addresses
-		WHERE id = ? AND user_id = ?`,
-		addressID, userID).Scan(
-		&address.ID, &address.UserID, &address.Name, &address.Street, &address.City,
-		&address.State, &address.PostalCode, &address.Country, &address.Phone,
-		&address.IsDefault, &address.CreatedAt, &address.UpdatedAt)
-
-	if err == sql.ErrNoRows {
+	address, err := h.Addresses.Find(userID, addressID)
+	if err == repository.ErrNotFound {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Address not found",
 		})
@@ -174,7 +95,7 @@ func GetAddress(c *fiber.Ctx) error {
 }
 
 // UpdateAddress updates a specific address for the user
-func UpdateAddress(c *fiber.Ctx) error {
+func (h *Handler) UpdateAddress(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
@@ -188,12 +109,7 @@ func UpdateAddress(c *fiber.Ctx) error {
 	}
 
 	// Check if address exists and belongs to user
-	var exists bool
-	var currentIsDefault bool
-	err = database.DB.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM addresses WHERE id = ? AND user_id = ?), is_default FROM addresses WHERE id = ?",
-		addressID, userID, addressID).Scan(&exists, &currentIsDefault)
-	if err != nil || !exists {
+	if _, err := h.Addresses.Find(userID, addressID); err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Address not found",
 		})
@@ -214,87 +130,23 @@ func UpdateAddress(c *fiber.Ctx) error {
 		})
 	}
 
-	// Start a transaction
-	tx, err := database.DB.Begin()
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to start transaction",
-		})
-	}
-
-	// If this address will be the default, update all other addresses to not be default
-	if req.IsDefault && !currentIsDefault {
-		_, err = tx.Exec("UPDATE addresses SET is_default = 0 WHERE user_id = ?", userID)
-		if err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update existing addresses",
-			})
-		}
-	} else if !req.IsDefault && currentIsDefault {
-		// Check if this is the only address
-		var count int
-		err = database.DB.QueryRow("SELECT COUNT(*) FROM addresses WHERE user_id = ?", userID).Scan(&count)
-		if err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to count addresses",
-			})
-		}
-		// If this is the only address, it must remain the default
-		if count == 1 {
-			req.IsDefault = true
-		} else {
-			// Make another address the default
-			_, err = tx.Exec(
-				"UPDATE addresses SET is_default = 1 WHERE user_id = ? AND id != ? ORDER BY id DESC LIMIT 1",
-				userID, addressID)
-			if err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"error": "Failed to set new default address",
-				})
-			}
-		}
-	}
-
-	// Update the address
-	_, err = tx.Exec(
-		`UPDATE addresses SET 
-			name = ?, 
-			street = ?, 
-			city = ?, 
-			state = ?, 
-			postal_code = ?, 
-			country = ?, 
-			phone = ?, 
-			is_default = ?, 
-			updated_at = ? 
-		WHERE id = ? AND user_id = ?`,
-		req.Name, req.Street, req.City, req.State, req.PostalCode, req.Country, req.Phone, req.IsDefault, time.Now(),
-		addressID, userID)
-	if err != nil {
-		tx.Rollback()
+	// Update the address, the only address stays the default
+	address := addressFromRequest(userID, req)
+	address.ID = addressID
+	if err := h.Addresses.Update(address); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update address",
 		})
 	}
 
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to commit transaction",
-		})
-	}
-
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message":    "Address updated successfully",
-		"is_default": req.IsDefault,
+		"is_default": address.IsDefault,
 	})
 }
 
 // DeleteAddress deletes a specific address for the user
-func DeleteAddress(c *fiber.Ctx) error {
+func (h *Handler) DeleteAddress(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
@@ -307,51 +159,35 @@ func DeleteAddress(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if address exists and belongs to user
-	var exists bool
-	var isDefault bool
-	err = database.DB.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM addresses WHERE id = ? AND user_id = ?), is_default FROM addresses WHERE id = ?",
-		addressID, userID, addressID).Scan(&exists, &isDefault)
-	if err != nil || !exists {
+	// Delete the address, another one becomes the default
+	err = h.Addresses.Delete(userID, addressID)
+	if err == repository.ErrNotFound {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Address not found",
 		})
 	}
-
-	// Start a transaction
-	tx, err := database.DB.Begin()
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to start transaction",
-		})
-	}
-
-	// Delete the address
-	_, err = tx.Exec("DELETE FROM addresses WHERE id = ? AND user_id = ?", addressID, userID)
 	if err != nil {
-		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete address",
 		})
 	}
 
-	// If this was the default address, make another address the default
-	if isDefault {
-		_, err = tx.Exec(
-			"UPDATE addresses SET is_default = 1 WHERE user_id = ? ORDER BY id DESC LIMIT 1",
-			userID)
-		// It's ok if this fails (e.g. if there are no more addresses)
-	}
-
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to commit transaction",
-		})
-	}
-
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Address deleted successfully",
 	})
 }
+
+// addressFromRequest builds a user's address from a create or update request
+func addressFromRequest(userID int64, req models.AddressRequest) *models.Address {
+	return &models.Address{
+		UserID:     userID,
+		Name:       req.Name,
+		Street:     req.Street,
+		City:       req.City,
+		State:      req.State,
+		PostalCode: req.PostalCode,
+		Country:    req.Country,
+		Phone:      req.Phone,
+		IsDefault:  req.IsDefault,
+	}
+}
@@ -0,0 +1,344 @@
+package controllers
+
+import (
+	"backend/accounts"
+	"backend/logins"
+	"backend/models"
+	"backend/permissions"
+	"backend/repository"
+	"backend/sessions"
+	"backend/twofactor"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetUsers returns a page of accounts, optionally those whose name or email
+// contains the q query parameter (users:read)
+func (h *Handler) GetUsers(c *fiber.Ctx) error {
+	page, limit, offset := pageParams(c)
+
+	users, total, err := h.Users.Search(c.Query("q"), limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	responses := make([]models.UserResponse, 0, len(users))
+	for i := range users {
+		responses = append(responses, users[i].ToResponse())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"users": responses,
+		"meta": fiber.Map{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + limit - 1) / limit,
+		},
+	})
+}
+
+// GetUser returns an account (users:read)
+func (h *Handler) GetUser(c *fiber.Ctx) error {
+	user, status, message := h.userParam(c)
+	if message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"user": user.ToResponse(),
+	})
+}
+
+// GetUserOrders returns a page of an account's orders, newest first (users:read)
+func (h *Handler) GetUserOrders(c *fiber.Ctx) error {
+	user, status, message := h.userParam(c)
+	if message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	page, limit, offset := pageParams(c)
+	orders, total, err := h.Orders.List(user.ID, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"orders": orders,
+		"meta": fiber.Map{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + limit - 1) / limit,
+		},
+	})
+}
+
+// GetUserAddresses returns an account's addresses (users:read)
+func (h *Handler) GetUserAddresses(c *fiber.Ctx) error {
+	user, status, message := h.userParam(c)
+	if message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	addresses, err := h.Addresses.List(user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"addresses": addresses,
+	})
+}
+
+// AssignUserRole changes the role of a user (users:manage)
+func (h *Handler) AssignUserRole(c *fiber.Ctx) error {
+	// Parse request body
+	var req models.AssignRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	role, err := permissions.FindRoleByName(h.DB, strings.TrimSpace(req.Role))
+	if err == permissions.ErrNotFound {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unknown role",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	// The new role's permissions are handed out too
+	user, status, message := h.manageableUser(c, role.Permissions...)
+	if message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	if err := h.Users.SetRole(user.ID, role.Name); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update role",
+		})
+	}
+	user.Role = role.Name
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Role updated successfully",
+		"user":    user.ToResponse(),
+	})
+}
+
+// DisableUser stops an account from logging in and ends its sessions (users:manage)
+func (h *Handler) DisableUser(c *fiber.Ctx) error {
+	user, status, message := h.manageableUser(c)
+	if message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	if err := h.Users.SetDisabled(user.ID, true); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to disable user",
+		})
+	}
+	if user.DisabledAt == nil {
+		now := time.Now().UTC()
+		user.DisabledAt = &now
+	}
+
+	// Access tokens of disabled accounts are rejected, refresh tokens are revoked as well
+	if err := sessions.RevokeAll(h.DB, user.ID); err != nil {
+		log.Printf("Failed to revoke sessions of user %d: %v", user.ID, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "User disabled successfully",
+		"user":    user.ToResponse(),
+	})
+}
+
+// EnableUser allows a disabled account to log in again (users:manage)
+func (h *Handler) EnableUser(c *fiber.Ctx) error {
+	user, status, message := h.manageableUser(c)
+	if message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	if err := h.Users.SetDisabled(user.ID, false); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to enable user",
+		})
+	}
+	user.DisabledAt = nil
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "User enabled successfully",
+		"user":    user.ToResponse(),
+	})
+}
+
+// ForcePasswordReset blocks an account's password, ends its sessions and
+// mails the owner a link to choose a new one (users:manage)
+func (h *Handler) ForcePasswordReset(c *fiber.Ctx) error {
+	user, status, message := h.manageableUser(c)
+	if message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	if err := h.Users.RequirePasswordReset(user.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reset password",
+		})
+	}
+	user.PasswordResetRequired = true
+
+	if err := sessions.RevokeAll(h.DB, user.ID); err != nil {
+		log.Printf("Failed to revoke sessions of user %d: %v", user.ID, err)
+	}
+
+	// The owner can also ask for a new link through the forgot password form
+	if err := accounts.SendPasswordReset(h.DB, user.ID, user.Name, user.Email); err != nil {
+		log.Printf("Failed to send password reset to user %d: %v", user.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Password reset is required, but the reset email could not be sent",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Password reset required, a reset link has been sent to the user",
+		"user":    user.ToResponse(),
+	})
+}
+
+// UnlockUser clears an account's failed logins, lifting its lockout (users:manage)
+func (h *Handler) UnlockUser(c *fiber.Ctx) error {
+	user, status, message := h.userParam(c)
+	if message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	if err := logins.Unlock(h.DB, user.Email); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to unlock user",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "User unlocked successfully",
+	})
+}
+
+// ResetUserTwoFactor turns off an account's two-factor authentication, for
+// owners who lost both their authenticator app and recovery codes (users:manage)
+func (h *Handler) ResetUserTwoFactor(c *fiber.Ctx) error {
+	user, status, message := h.manageableUser(c)
+	if message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	if err := twofactor.Disable(h.DB, user.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reset two-factor authentication",
+		})
+	}
+	user.TwoFactorEnabled = false
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Two-factor authentication reset, the user can set it up again after logging in",
+		"user":    user.ToResponse(),
+	})
+}
+
+// userParam returns the user of the :id URL parameter, or an HTTP status and
+// error message
+func (h *Handler) userParam(c *fiber.Ctx) (*models.User, int, string) {
+	userID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return nil, fiber.StatusBadRequest, "Invalid user ID"
+	}
+
+	user, err := h.Users.FindByID(userID)
+	if err == repository.ErrNotFound {
+		return nil, fiber.StatusNotFound, "User not found"
+	}
+	if err != nil {
+		return nil, fiber.StatusInternalServerError, "Database error"
+	}
+	return user, 0, ""
+}
+
+// manageableUser returns the user of the :id URL parameter for the signed in
+// user to change. Nobody can change their own account, or an account whose role
+// grants permissions they do not hold; granted lists further permissions being handed out.
+func (h *Handler) manageableUser(c *fiber.Ctx, granted ...string) (*models.User, int, string) {
+	actorID := c.Locals("userID").(int64)
+
+	user, status, message := h.userParam(c)
+	if message != "" {
+		return nil, status, message
+	}
+	if user.ID == actorID {
+		return nil, fiber.StatusBadRequest, "You cannot change your own account"
+	}
+	if user.DeletedAt != nil {
+		return nil, fiber.StatusBadRequest, "This account has been deleted"
+	}
+
+	affected := granted
+	if current, err := permissions.FindRoleByName(h.DB, user.Role); err == nil {
+		affected = slices.Concat(current.Permissions, granted)
+	}
+	missing, err := permissions.Missing(h.DB, actorID, affected)
+	if err != nil {
+		return nil, fiber.StatusInternalServerError, "Database error"
+	}
+	if len(missing) > 0 {
+		return nil, fiber.StatusForbidden, "Forbidden: you do not hold " + strings.Join(missing, ", ")
+	}
+	return user, 0, ""
+}
+
+// pageParams reads the page and limit query parameters, 10 per page by default
+func pageParams(c *fiber.Ctx) (page, limit, offset int) {
+	page, _ = strconv.Atoi(c.Query("page", "1"))
+	limit, _ = strconv.Atoi(c.Query("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+	return page, limit, (page - 1) * limit
+}
@@ -1,16 +1,23 @@
 package controllers
 
 import (
-	"backend/database"
+	"backend/inventory"
 	"backend/models"
+	"backend/money"
+	"backend/pricing"
+	"backend/promotions"
+	"backend/repository"
+	"backend/shipping"
+	"backend/tax"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // AddToCart adds a product to the user's cart
-func AddToCart(c *fiber.Ctx) error {
+func (h *Handler) AddToCart(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
@@ -30,8 +37,7 @@ func AddToCart(c *fiber.Ctx) error {
 	}
 
 	// Check if product exists
-	var productExists bool
-	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)", req.ProductID).Scan(&productExists)
+	productExists, err := h.Products.Exists(req.ProductID)
 	if err != nil || !productExists {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Product not found",
@@ -39,8 +45,7 @@ func AddToCart(c *fiber.Ctx) error {
 	}
 
 	// Check if color exists for this product
-	var colorExists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM product_colors WHERE id = ? AND product_id = ?)", req.ColorID, req.ProductID).Scan(&colorExists)
+	colorExists, err := h.Products.HasColor(req.ProductID, req.ColorID)
 	if err != nil || !colorExists {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Color not found for this product",
@@ -48,20 +53,15 @@ func AddToCart(c *fiber.Ctx) error {
 	}
 
 	// Check if size exists for this product
-	var sizeExists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM product_sizes WHERE id = ? AND product_id = ?)", req.SizeID, req.ProductID).Scan(&sizeExists)
+	sizeExists, err := h.Products.HasSize(req.ProductID, req.SizeID)
 	if err != nil || !sizeExists {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Size not found for this product",
 		})
 	}
 
-	// Check if there's enough inventory
-	var availableQuantity int
-	err = database.DB.QueryRow(
-		"SELECT quantity FROM product_inventory WHERE product_id = ? AND color_id = ? AND size_id = ?",
-		req.ProductID, req.ColorID, req.SizeID).Scan(&availableQuantity)
-
+	// Check if there's enough inventory not held by other shoppers
+	availableQuantity, err := h.Products.Available(userID, req.ProductID, req.ColorID, req.SizeID)
 	if err != nil || availableQuantity < req.Quantity {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":     "Not enough inventory",
@@ -70,32 +70,23 @@ func AddToCart(c *fiber.Ctx) error {
 	}
 
 	// Check if item already exists in cart
-	var cartItemExists bool
-	var existingCartID int64
-	var existingQuantity int
-	err = database.DB.QueryRow(`
-		SELECT EXISTS(
-			SELECT 1 FROM cart 
-			WHERE user_id = ? AND product_id = ? AND color_id = ? AND size_id = ?
-		), id, quantity 
-		FROM cart 
-		WHERE user_id = ? AND product_id = ? AND color_id = ? AND size_id = ?`,
-		userID, req.ProductID, req.ColorID, req.SizeID,
-		userID, req.ProductID, req.ColorID, req.SizeID).Scan(
-		&cartItemExists, &existingCartID, &existingQuantity)
-
-	if err != nil && cartItemExists {
+	existing, err := h.Carts.FindVariant(userID, req.ProductID, req.ColorID, req.SizeID)
+	if err != nil && err != repository.ErrNotFound {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
 		})
 	}
 
+	// Changing the cart drops the holds of a checkout in progress
+	if err := h.Carts.ReleaseHolds(userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to release reservations",
+		})
+	}
+
 	// If item exists, update quantity
-	if cartItemExists {
-		_, err = database.DB.Exec(
-			"UPDATE cart SET quantity = ?, updated_at = ? WHERE id = ?",
-			req.Quantity, time.Now(), existingCartID)
-		if err != nil {
+	if existing != nil {
+		if err := h.Carts.SetQuantity(userID, existing.ID, req.Quantity); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to update cart",
 			})
@@ -103,23 +94,24 @@ func AddToCart(c *fiber.Ctx) error {
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
 			"message": "Cart updated successfully",
-			"id":      existingCartID,
+			"id":      existing.ID,
 		})
 	}
 
 	// Otherwise, add new item to cart
-	result, err := database.DB.Exec(
-		"INSERT INTO cart (user_id, product_id, color_id, size_id, quantity, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		userID, req.ProductID, req.ColorID, req.SizeID, req.Quantity, time.Now(), time.Now())
+	cartItemID, err := h.Carts.Add(&models.CartItem{
+		UserID:    userID,
+		ProductID: req.ProductID,
+		ColorID:   req.ColorID,
+		SizeID:    req.SizeID,
+		Quantity:  req.Quantity,
+	})
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to add to cart",
 		})
 	}
 
-	// Get the cart item ID
-	cartItemID, _ := result.LastInsertId()
-
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Added to cart successfully",
 		"id":      cartItemID,
@@ -127,87 +119,120 @@ func AddToCart(c *fiber.Ctx) error {
 }
 
 // GetCart retrieves the user's cart
-func GetCart(c *fiber.Ctx) error {
+func (h *Handler) GetCart(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
-	// Query to get cart items with product details
-	rows, err := database.DB.Query(`
-		SELECT 
-			c.id, c.product_id, c.color_id, c.size_id, c.quantity,
-			p.name, p.description, p.base_price, p.discount_percentage,
-			pc.color_name, pc.color_hex,
-			ps.size_name,
-			pi.quantity as in_stock,
-			(SELECT image_url FROM product_images WHERE product_id = p.id AND is_primary = 1 LIMIT 1) as image_url
-		FROM cart c
-		JOIN products p ON c.product_id = p.id
-		JOIN product_colors pc ON c.color_id = pc.id
-		JOIN product_sizes ps ON c.size_id = ps.id
-		LEFT JOIN product_inventory pi ON c.product_id = pi.product_id AND c.color_id = pi.color_id AND c.size_id = pi.size_id
-		WHERE c.user_id = ?
-		ORDER BY c.id DESC`,
-		userID)
+	// Prices are shown in the currency asked for
+	exchange, err := h.requestExchange(c)
+	if err != nil {
+		return exchangeError(c, err)
+	}
+
+	// Get cart items with product details
+	cartItems, err := h.Carts.Items(userID, exchange)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
 		})
 	}
-	defer rows.Close()
 
-	var cartItems []models.CartItemResponse
 	var totalItems int
-	var subTotal float64
-
-	for rows.Next() {
-		var item models.CartItemResponse
-		var basePrice, discountPercentage float64
-		var inStock int
-
-		err := rows.Scan(
-			&item.ID, &item.ProductID, &item.ColorID, &item.SizeID, &item.Quantity,
-			&item.ProductName, &item.ProductDescription, &basePrice, &discountPercentage,
-			&item.ColorName, &item.ColorHex,
-			&item.SizeName,
-			&inStock,
-			&item.ImageURL)
+	var subTotal money.Amount
+	for _, item := range cartItems {
+		totalItems += item.Quantity
+		subTotal += item.SubTotal
+	}
+
+	// Get the delivery address, which decides shipping and tax
+	address, err := h.cartAddress(c, userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid address",
+		})
+	}
+
+	// Apply the cart's coupon, an invalid one is reported but gives no discount
+	lines, err := promotions.CartLines(h.DB, userID, exchange)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	discount, coupon, err := h.cartDiscount(userID, lines, exchange)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to apply coupon",
+		})
+	}
+
+	// Calculate tax, it is only known once there is an address
+	taxes := &tax.Result{}
+	if address != nil {
+		taxes, err = tax.Calculate(h.DB, address.Country, address.State, taxLines(lines, discount))
 		if err != nil {
-			continue
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to calculate tax",
+			})
 		}
+	}
 
-		// Calculate final price and subtotal
-		item.BasePrice = basePrice
-		item.DiscountPercentage = discountPercentage
-		item.FinalPrice = basePrice * (1 - discountPercentage/100)
-		item.SubTotal = item.FinalPrice * float64(item.Quantity)
-		item.InStock = inStock
-
-		cartItems = append(cartItems, item)
-		totalItems += item.Quantity
-		subTotal += item.SubTotal
+	// Price the chosen shipping method, standard unless asked otherwise
+	var delivery *models.ShippingOption
+	var shippingErr error
+	if address != nil {
+		delivery, shippingErr = h.cartShipping(userID, address, c.Query("shipping_method", shipping.MethodStandard), subTotal-discount.Amount, discount.FreeShipping, exchange)
+		if shippingErr != nil && shippingErr != shipping.ErrNoZone && shippingErr != shipping.ErrMethodUnavailable {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to calculate shipping",
+			})
+		}
 	}
 
 	// Calculate cart summary
-	shippingCost := 0.0
-	taxRate := 0.1 // 10% tax
-	tax := subTotal * taxRate
+	var shippingCost money.Amount
+	shippingMethod := ""
+	if delivery != nil {
+		shippingCost, shippingMethod = delivery.Cost, delivery.Method
+	}
 
 	summary := models.CartSummary{
-		TotalItems:   totalItems,
-		SubTotal:     subTotal,
-		ShippingCost: shippingCost,
-		Tax:          tax,
-		Total:        subTotal + shippingCost + tax,
+		TotalItems:     totalItems,
+		SubTotal:       subTotal,
+		ShippingMethod: shippingMethod,
+		ShippingCost:   shippingCost,
+		Tax:            taxes.Added,
+		TaxIncluded:    taxes.Included,
+		Total:          subTotal - discount.Amount + shippingCost + taxes.Added,
+		DiscountAmount: discount.Amount,
+		FreeShipping:   discount.FreeShipping,
+		Currency:       exchange.Currency,
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+	response := fiber.Map{
 		"items":   cartItems,
 		"summary": summary,
-	})
+	}
+	if address != nil {
+		response["address_id"] = address.ID
+	}
+	if shippingErr != nil {
+		response["shipping_error"] = shippingError(shippingErr)
+	}
+	if coupon != nil {
+		response["coupon"] = coupon
+	}
+
+	// Report when the holds of a checkout in progress run out
+	if expiresAt, err := h.Carts.HoldsExpireAt(userID); err == nil && expiresAt != nil {
+		response["reservation_expires_at"] = *expiresAt
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
 }
 
 // UpdateCartItem updates the quantity of a cart item
-func UpdateCartItem(c *fiber.Ctx) error {
+func (h *Handler) UpdateCartItem(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
@@ -238,23 +263,15 @@ func UpdateCartItem(c *fiber.Ctx) error {
 	}
 
 	// Check if cart item exists and belongs to user
-	var exists bool
-	var productID, colorID, sizeID int64
-	err = database.DB.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM cart WHERE id = ? AND user_id = ?), product_id, color_id, size_id FROM cart WHERE id = ?",
-		cartItemID, userID, cartItemID).Scan(&exists, &productID, &colorID, &sizeID)
-	if err != nil || !exists {
+	item, err := h.Carts.Find(userID, cartItemID)
+	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Cart item not found",
 		})
 	}
 
-	// Check if there's enough inventory
-	var availableQuantity int
-	err = database.DB.QueryRow(
-		"SELECT quantity FROM product_inventory WHERE product_id = ? AND color_id = ? AND size_id = ?",
-		productID, colorID, sizeID).Scan(&availableQuantity)
-
+	// Check if there's enough inventory not held by other shoppers
+	availableQuantity, err := h.Products.Available(userID, item.ProductID, item.ColorID, item.SizeID)
 	if err != nil || availableQuantity < req.Quantity {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":     "Not enough inventory",
@@ -262,11 +279,15 @@ func UpdateCartItem(c *fiber.Ctx) error {
 		})
 	}
 
+	// Changing the cart drops the holds of a checkout in progress
+	if err := h.Carts.ReleaseHolds(userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to release reservations",
+		})
+	}
+
 	// Update cart item quantity
-	_, err = database.DB.Exec(
-		"UPDATE cart SET quantity = ?, updated_at = ? WHERE id = ? AND user_id = ?",
-		req.Quantity, time.Now(), cartItemID, userID)
-	if err != nil {
+	if err := h.Carts.SetQuantity(userID, cartItemID, req.Quantity); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update cart",
 		})
@@ -278,7 +299,7 @@ func UpdateCartItem(c *fiber.Ctx) error {
 }
 
 // RemoveFromCart removes an item from the user's cart
-func RemoveFromCart(c *fiber.Ctx) error {
+func (h *Handler) RemoveFromCart(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
@@ -292,17 +313,21 @@ func RemoveFromCart(c *fiber.Ctx) error {
 	}
 
 	// Check if cart item exists and belongs to user
-	var exists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM cart WHERE id = ? AND user_id = ?)", cartItemID, userID).Scan(&exists)
-	if err != nil || !exists {
+	if _, err := h.Carts.Find(userID, cartItemID); err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Cart item not found",
 		})
 	}
 
+	// Changing the cart drops the holds of a checkout in progress
+	if err := h.Carts.ReleaseHolds(userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to release reservations",
+		})
+	}
+
 	// Delete cart item
-	_, err = database.DB.Exec("DELETE FROM cart WHERE id = ? AND user_id = ?", cartItemID, userID)
-	if err != nil {
+	if err := h.Carts.Remove(userID, cartItemID); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to remove from cart",
 		})
@@ -314,13 +339,19 @@ func RemoveFromCart(c *fiber.Ctx) error {
 }
 
 // ClearCart removes all items from the user's cart
-func ClearCart(c *fiber.Ctx) error {
+func (h *Handler) ClearCart(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
+	// Changing the cart drops the holds of a checkout in progress
+	if err := h.Carts.ReleaseHolds(userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to release reservations",
+		})
+	}
+
 	// Delete all cart items for this user
-	_, err := database.DB.Exec("DELETE FROM cart WHERE user_id = ?", userID)
-	if err != nil {
+	if err := h.Carts.Clear(userID); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to clear cart",
 		})
@@ -330,3 +361,282 @@ func ClearCart(c *fiber.Ctx) error {
 		"message": "Cart cleared successfully",
 	})
 }
+
+// StartCheckout reserves the stock of every cart line for a limited time
+func (h *Handler) StartCheckout(c *fiber.Ctx) error {
+	// Get user ID from context (set by the Protected middleware)
+	userID := c.Locals("userID").(int64)
+
+	// Place the holds
+	expiresAt, shortages, err := inventory.Reserve(h.DB, h.Dialect, userID)
+	if err == inventory.ErrEmptyCart {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cart is empty",
+		})
+	}
+	if err == inventory.ErrInsufficientStock {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":     "Not enough inventory for one or more items",
+			"shortages": shortages,
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reserve inventory",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":    "Inventory reserved",
+		"expires_at": expiresAt,
+	})
+}
+
+// ApplyCoupon applies a coupon code to the user's cart
+func (h *Handler) ApplyCoupon(c *fiber.Ctx) error {
+	// Get user ID from context (set by the Protected middleware)
+	userID := c.Locals("userID").(int64)
+
+	// Parse request body
+	var req models.ApplyCouponRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Validate input
+	if strings.TrimSpace(req.Code) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Coupon code is required",
+		})
+	}
+
+	// Find the promotion
+	promotion, err := promotions.FindByCode(h.DB, req.Code)
+	if err == promotions.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Coupon not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	// Check that the cart qualifies, in the currency it is shown in
+	exchange, err := h.requestExchange(c)
+	if err != nil {
+		return exchangeError(c, err)
+	}
+	lines, err := promotions.CartLines(h.DB, userID, exchange)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if len(lines) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cart is empty",
+		})
+	}
+
+	discount, err := promotions.Evaluate(h.DB, promotion, userID, lines, exchange, time.Now())
+	if _, ok := err.(promotions.RuleError); ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to apply coupon",
+		})
+	}
+
+	// Attach the coupon to the cart
+	if err := promotions.Apply(h.DB, userID, promotion.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to apply coupon",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":         "Coupon applied successfully",
+		"code":            promotion.Code,
+		"discount_amount": discount.Amount,
+		"free_shipping":   discount.FreeShipping,
+		"currency":        exchange.Currency,
+	})
+}
+
+// RemoveCoupon removes the coupon from the user's cart
+func (h *Handler) RemoveCoupon(c *fiber.Ctx) error {
+	// Get user ID from context (set by the Protected middleware)
+	userID := c.Locals("userID").(int64)
+
+	if err := promotions.Remove(h.DB, userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to remove coupon",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Coupon removed successfully",
+	})
+}
+
+// cartDiscount evaluates the coupon applied to a user's cart. It returns an empty
+// discount when there is none, and describes the coupon for the cart response.
+func (h *Handler) cartDiscount(userID int64, lines []promotions.Line, exchange *pricing.Exchange) (*promotions.Discount, fiber.Map, error) {
+	promotion, err := promotions.AppliedPromotion(h.DB, userID)
+	if err != nil || promotion == nil {
+		return &promotions.Discount{}, nil, err
+	}
+
+	coupon := fiber.Map{
+		"code":        promotion.Code,
+		"description": promotion.Description,
+		"valid":       true,
+	}
+
+	discount, err := promotions.Evaluate(h.DB, promotion, userID, lines, exchange, time.Now())
+	if err == promotions.ErrNotFound {
+		err = promotions.RuleError("Coupon is no longer available")
+	}
+	if _, ok := err.(promotions.RuleError); ok {
+		coupon["valid"] = false
+		coupon["message"] = err.Error()
+		return &promotions.Discount{}, coupon, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return discount, coupon, nil
+}
+
+// cartAddress returns the address a cart is delivered to: the address_id query
+// parameter, or the user's default address. It returns nil if there is neither.
+func (h *Handler) cartAddress(c *fiber.Ctx, userID int64) (*models.Address, error) {
+	value := c.Query("address_id")
+	if value == "" {
+		address, err := h.Addresses.FindDefault(userID)
+		if err == repository.ErrNotFound {
+			return nil, nil
+		}
+		return address, err
+	}
+
+	addressID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return h.Addresses.Find(userID, addressID)
+}
+
+// GetShippingOptions lists the shipping methods available for the cart and an address
+func (h *Handler) GetShippingOptions(c *fiber.Ctx) error {
+	// Get user ID from context (set by the Protected middleware)
+	userID := c.Locals("userID").(int64)
+
+	// Get the delivery address
+	address, err := h.cartAddress(c, userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid address",
+		})
+	}
+	if address == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Address ID is required",
+		})
+	}
+
+	// Options are priced in the currency asked for
+	exchange, err := h.requestExchange(c)
+	if err != nil {
+		return exchangeError(c, err)
+	}
+
+	// Free-over-threshold pricing and free shipping coupons depend on the discounted subtotal
+	lines, err := promotions.CartLines(h.DB, userID, exchange)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	discount, _, err := h.cartDiscount(userID, lines, exchange)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to apply coupon",
+		})
+	}
+
+	// Price every method of the address's zone
+	weight, err := shipping.CartWeight(h.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to calculate shipping",
+		})
+	}
+	options, err := shipping.Options(h.DB, address.Country, address.PostalCode, shipping.Parcel{
+		Weight:       weight,
+		Value:        promotions.Subtotal(lines) - discount.Amount,
+		FreeShipping: discount.FreeShipping,
+		Exchange:     exchange,
+	})
+	if err == shipping.ErrNoZone {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": shippingError(err),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to calculate shipping",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"address_id": address.ID,
+		"weight":     weight,
+		"options":    options,
+		"currency":   exchange.Currency,
+	})
+}
+
+// cartShipping prices one shipping method for the user's cart in the exchange currency
+func (h *Handler) cartShipping(userID int64, address *models.Address, method string, value money.Amount, freeShipping bool, exchange *pricing.Exchange) (*models.ShippingOption, error) {
+	weight, err := shipping.CartWeight(h.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	return shipping.Quote(h.DB, address.Country, address.PostalCode, method, shipping.Parcel{
+		Weight:       weight,
+		Value:        value,
+		FreeShipping: freeShipping,
+		Exchange:     exchange,
+	})
+}
+
+// shippingError returns the message for an address or method that cannot be shipped to
+func shippingError(err error) string {
+	if err == shipping.ErrNoZone {
+		return "We do not ship to this address"
+	}
+	return "Shipping method is not available for this address"
+}
+
+// taxLines prepares cart lines for the tax calculation, net of their share of the discount
+func taxLines(lines []promotions.Line, discount *promotions.Discount) []tax.Line {
+	result := make([]tax.Line, len(lines))
+	for i, line := range lines {
+		result[i] = tax.Line{
+			CategoryID: line.CategoryID,
+			Quantity:   line.Quantity,
+			UnitPrice:  line.UnitPrice,
+			Discount:   discount.LineAmount(i),
+		}
+	}
+	return result
+}
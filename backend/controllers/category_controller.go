@@ -3,7 +3,9 @@ package controllers
 import (
 	"backend/database"
 	"backend/models"
+	"backend/search"
 	"database/sql"
+	"log"
 	"strconv"
 	"time"
 
@@ -11,7 +13,7 @@ import (
 )
 
 // CreateCategory creates a new product category
-func CreateCategory(c *fiber.Ctx) error {
+func (h *Handler) CreateCategory(c *fiber.Ctx) error {
 	// Parse request body
 	var req models.CreateCategoryRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -29,7 +31,7 @@ func CreateCategory(c *fiber.Ctx) error {
 
 	// Check if category with this name already exists
 	var exists bool
-	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE name = ?)", req.Name).Scan(&exists)
+	err := h.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE name = ?)", req.Name).Scan(&exists)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
@@ -41,35 +43,43 @@ func CreateCategory(c *fiber.Ctx) error {
 		})
 	}
 
+	// Generate a unique slug from the name
+	slug, err := database.UniqueSlug(h.DB, "categories", req.Name, 0)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate slug",
+		})
+	}
+
 	// Create the category
-	result, err := database.DB.Exec(
-		"INSERT INTO categories (name, description, image_url, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+	var categoryID int64
+	err = h.DB.QueryRow(
+		"INSERT INTO categories (name, slug, description, image_url, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING id",
 		req.Name,
+		slug,
 		req.Description,
 		req.ImageURL,
 		time.Now(),
 		time.Now(),
-	)
+	).Scan(&categoryID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create category",
 		})
 	}
 
-	// Get the category ID
-	categoryID, _ := result.LastInsertId()
-
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Category created successfully",
 		"id":      categoryID,
+		"slug":    slug,
 	})
 }
 
 // GetAllCategories returns all product categories
-func GetAllCategories(c *fiber.Ctx) error {
+func (h *Handler) GetAllCategories(c *fiber.Ctx) error {
 	// Query to get categories
-	rows, err := database.DB.Query(`
-		SELECT id, name, description, image_url, created_at, updated_at 
+	rows, err := h.DB.Query(`
+		SELECT id, name, COALESCE(slug, ''), description, image_url, created_at, updated_at 
 		FROM categories 
 		ORDER BY name ASC`)
 	if err != nil {
@@ -83,7 +93,7 @@ func GetAllCategories(c *fiber.Ctx) error {
 	for rows.Next() {
 		var category models.Category
 		err := rows.Scan(
-			&category.ID, &category.Name, &category.Description,
+			&category.ID, &category.Name, &category.Slug, &category.Description,
 			&category.ImageURL, &category.CreatedAt, &category.UpdatedAt)
 		if err != nil {
 			continue
@@ -97,7 +107,7 @@ func GetAllCategories(c *fiber.Ctx) error {
 }
 
 // GetCategoryByID returns a specific category by ID
-func GetCategoryByID(c *fiber.Ctx) error {
+func (h *Handler) GetCategoryByID(c *fiber.Ctx) error {
 	// Get the category ID from the URL parameter
 	id := c.Params("id")
 	categoryID, err := strconv.ParseInt(id, 10, 64)
@@ -107,14 +117,24 @@ func GetCategoryByID(c *fiber.Ctx) error {
 		})
 	}
 
+	return h.sendCategory(c, "id = ?", categoryID)
+}
+
+// GetCategoryBySlug returns a specific category by its slug
+func (h *Handler) GetCategoryBySlug(c *fiber.Ctx) error {
+	return h.sendCategory(c, "slug = ?", c.Params("slug"))
+}
+
+// sendCategory responds with the category matching the condition and its product count
+func (h *Handler) sendCategory(c *fiber.Ctx, condition string, value interface{}) error {
 	// Get the category from the database
 	var category models.Category
-	err = database.DB.QueryRow(`
-		SELECT id, name, description, image_url, created_at, updated_at 
+	err := h.DB.QueryRow(`
+		SELECT id, name, COALESCE(slug, ''), description, image_url, created_at, updated_at 
 		FROM categories 
-		WHERE id = ?`,
-		categoryID).Scan(
-		&category.ID, &category.Name, &category.Description,
+		WHERE `+condition,
+		value).Scan(
+		&category.ID, &category.Name, &category.Slug, &category.Description,
 		&category.ImageURL, &category.CreatedAt, &category.UpdatedAt)
 
 	if err == sql.ErrNoRows {
@@ -130,7 +150,7 @@ func GetCategoryByID(c *fiber.Ctx) error {
 
 	// Count products in this category
 	var productCount int
-	database.DB.QueryRow("SELECT COUNT(*) FROM products WHERE category_id = ?", categoryID).Scan(&productCount)
+	h.DB.QueryRow("SELECT COUNT(*) FROM products WHERE category_id = ?", category.ID).Scan(&productCount)
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"category": category,
@@ -139,7 +159,7 @@ func GetCategoryByID(c *fiber.Ctx) error {
 }
 
 // UpdateCategory updates a category
-func UpdateCategory(c *fiber.Ctx) error {
+func (h *Handler) UpdateCategory(c *fiber.Ctx) error {
 	// Get the category ID from the URL parameter
 	id := c.Params("id")
 	categoryID, err := strconv.ParseInt(id, 10, 64)
@@ -149,19 +169,19 @@ func UpdateCategory(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if category exists
-	var exists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE id = ?)", categoryID).Scan(&exists)
+	// Check if category exists and get its current name and slug
+	var currentName, currentSlug string
+	err = h.DB.QueryRow("SELECT name, COALESCE(slug, '') FROM categories WHERE id = ?", categoryID).Scan(&currentName, &currentSlug)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Category not found",
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
 		})
 	}
-	if !exists {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Category not found",
-		})
-	}
 
 	// Parse request body
 	var req models.CreateCategoryRequest
@@ -180,7 +200,7 @@ func UpdateCategory(c *fiber.Ctx) error {
 
 	// Check if another category with this name already exists
 	var nameExists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE name = ? AND id != ?)", req.Name, categoryID).Scan(&nameExists)
+	err = h.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE name = ? AND id != ?)", req.Name, categoryID).Scan(&nameExists)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
@@ -192,10 +212,22 @@ func UpdateCategory(c *fiber.Ctx) error {
 		})
 	}
 
+	// Renaming the category gives it a new slug
+	slug := currentSlug
+	if req.Name != currentName || slug == "" {
+		slug, err = database.UniqueSlug(h.DB, "categories", req.Name, categoryID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to generate slug",
+			})
+		}
+	}
+
 	// Update the category
-	_, err = database.DB.Exec(
-		"UPDATE categories SET name = ?, description = ?, image_url = ?, updated_at = ? WHERE id = ?",
+	_, err = h.DB.Exec(
+		"UPDATE categories SET name = ?, slug = ?, description = ?, image_url = ?, updated_at = ? WHERE id = ?",
 		req.Name,
+		slug,
 		req.Description,
 		req.ImageURL,
 		time.Now(),
@@ -207,13 +239,19 @@ func UpdateCategory(c *fiber.Ctx) error {
 		})
 	}
 
+	// Products are indexed with their category name
+	if err := search.IndexCategory(h.DB, categoryID); err != nil {
+		log.Printf("Failed to index category %d: %v", categoryID, err)
+	}
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Category updated successfully",
+		"slug":    slug,
 	})
 }
 
 // DeleteCategory deletes a category
-func DeleteCategory(c *fiber.Ctx) error {
+func (h *Handler) DeleteCategory(c *fiber.Ctx) error {
 	// Get the category ID from the URL parameter
 	id := c.Params("id")
 	categoryID, err := strconv.ParseInt(id, 10, 64)
@@ -225,7 +263,7 @@ func DeleteCategory(c *fiber.Ctx) error {
 
 	// Check if category exists
 	var exists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE id = ?)", categoryID).Scan(&exists)
+	err = h.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE id = ?)", categoryID).Scan(&exists)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
@@ -239,16 +277,34 @@ func DeleteCategory(c *fiber.Ctx) error {
 
 	// Check if there are products in this category
 	var productCount int
-	err = database.DB.QueryRow("SELECT COUNT(*) FROM products WHERE category_id = ?", categoryID).Scan(&productCount)
+	err = h.DB.QueryRow("SELECT COUNT(*) FROM products WHERE category_id = ?", categoryID).Scan(&productCount)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
 		})
 	}
 
+	// Remember the affected products so they can be re-indexed without the category
+	var productIDs []int64
+	if productCount > 0 {
+		rows, err := h.DB.Query("SELECT id FROM products WHERE category_id = ?", categoryID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+		for rows.Next() {
+			var productID int64
+			if err := rows.Scan(&productID); err == nil {
+				productIDs = append(productIDs, productID)
+			}
+		}
+		rows.Close()
+	}
+
 	// If there are products, update their category to NULL
 	if productCount > 0 {
-		_, err = database.DB.Exec("UPDATE products SET category_id = NULL WHERE category_id = ?", categoryID)
+		_, err = h.DB.Exec("UPDATE products SET category_id = NULL WHERE category_id = ?", categoryID)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to update products before category deletion",
@@ -257,13 +313,20 @@ func DeleteCategory(c *fiber.Ctx) error {
 	}
 
 	// Delete the category
-	_, err = database.DB.Exec("DELETE FROM categories WHERE id = ?", categoryID)
+	_, err = h.DB.Exec("DELETE FROM categories WHERE id = ?", categoryID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete category",
 		})
 	}
 
+	// Re-index the products that lost their category
+	for _, productID := range productIDs {
+		if err := search.IndexProduct(h.DB, productID); err != nil {
+			log.Printf("Failed to index product %d: %v", productID, err)
+		}
+	}
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Category deleted successfully",
 		"products_affected": productCount,
@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/money"
+	"backend/pricing"
+	"backend/repository"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetCurrencies returns the store currency and every currency prices can be shown in
+func (h *Handler) GetCurrencies(c *fiber.Ctx) error {
+	rates, err := pricing.Rates(h.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	// The store currency is always available at a rate of 1
+	store := money.StoreCurrency()
+	currencies := []models.ExchangeRate{{Currency: store, Rate: 1}}
+	for _, rate := range rates {
+		if rate.Currency != store {
+			currencies = append(currencies, rate)
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"store_currency": store,
+		"currencies":     currencies,
+	})
+}
+
+// SetExchangeRate sets the rate of a currency against the store currency (admin only)
+func (h *Handler) SetExchangeRate(c *fiber.Ctx) error {
+	// Get the currency from URL parameter
+	currency, err := money.ParseCurrency(c.Params("currency"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unsupported currency",
+		})
+	}
+	if currency == money.StoreCurrency() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The store currency has no exchange rate",
+		})
+	}
+
+	// Parse request body
+	var req models.ExchangeRateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.Rate <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Rate must be positive",
+		})
+	}
+
+	// Create or replace the rate, orders keep the rate they were placed at
+	_, err = h.DB.Exec(
+		`INSERT INTO exchange_rates (currency, rate, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(currency) DO UPDATE SET rate = excluded.rate, updated_at = excluded.updated_at`,
+		string(currency), req.Rate, time.Now())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to set exchange rate",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":  "Exchange rate set successfully",
+		"currency": currency,
+		"rate":     req.Rate,
+	})
+}
+
+// DeleteExchangeRate stops offering a currency (admin only)
+func (h *Handler) DeleteExchangeRate(c *fiber.Ctx) error {
+	currency, err := money.ParseCurrency(c.Params("currency"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unsupported currency",
+		})
+	}
+
+	result, err := h.DB.Exec("DELETE FROM exchange_rates WHERE currency = ?", string(currency))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete exchange rate",
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Exchange rate not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Exchange rate deleted successfully",
+	})
+}
+
+// GetProductPrices returns the prices set for a product in other currencies (admin only)
+func (h *Handler) GetProductPrices(c *fiber.Ctx) error {
+	// Get product ID from URL parameter
+	productID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	prices, err := h.Products.Prices(productID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"prices": prices,
+	})
+}
+
+// SetProductPrice sets a product's base price in a currency, used instead of
+// converting its store price (admin only)
+func (h *Handler) SetProductPrice(c *fiber.Ctx) error {
+	// Get product ID from URL parameter
+	productID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	// Parse request body
+	var req models.ProductPriceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Validate request
+	currency, err := money.ParseCurrency(req.Currency)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unsupported currency",
+		})
+	}
+	if currency == money.StoreCurrency() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Use the product's base price for the store currency",
+		})
+	}
+	if req.BasePrice <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Base price must be positive",
+		})
+	}
+
+	// Check if product exists
+	exists, err := h.Products.Exists(productID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found",
+		})
+	}
+
+	// Create or replace the price
+	if err := h.Products.SetPrice(productID, currency, req.BasePrice); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to set product price",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":    "Product price set successfully",
+		"currency":   currency,
+		"base_price": req.BasePrice,
+	})
+}
+
+// DeleteProductPrice removes a product's price in a currency, which is then
+// converted from the store price again (admin only)
+func (h *Handler) DeleteProductPrice(c *fiber.Ctx) error {
+	// Get product ID and currency from URL parameters
+	productID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+	currency, err := money.ParseCurrency(c.Params("currency"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unsupported currency",
+		})
+	}
+
+	err = h.Products.DeletePrice(productID, currency)
+	if err == repository.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product price not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete product price",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Product price deleted successfully",
+	})
+}
+
+// requestExchange returns the exchange into the currency asked for with the
+// currency query parameter or the X-Currency header, the store currency by default
+func (h *Handler) requestExchange(c *fiber.Ctx) (*pricing.Exchange, error) {
+	code := c.Query("currency", c.Get("X-Currency"))
+	if code == "" {
+		return pricing.Store(), nil
+	}
+
+	currency, err := money.ParseCurrency(code)
+	if err != nil {
+		return nil, err
+	}
+	return pricing.For(h.DB, currency)
+}
+
+// exchangeError writes the response for a currency that cannot be used
+func exchangeError(c *fiber.Ctx, err error) error {
+	if err == money.ErrUnknownCurrency || err == pricing.ErrNoRate {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unsupported currency",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Database error",
+	})
+}
@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"backend/dialect"
+	"backend/repository"
+	"database/sql"
+)
+
+// Handler serves the requests that read and write through the repositories.
+// Tests can build one around in-memory repositories.
+type Handler struct {
+	repository.Repositories
+
+	// DB is passed to the packages that take a database.Querier, such as
+	// promotions, tax and shipping, and runs the transactions that span them.
+	// The settings handlers for categories, roles and rates query it directly.
+	DB *sql.DB
+
+	// Dialect is the SQL dialect of DB
+	Dialect dialect.Dialect
+}
+
+// NewHandler creates a handler using the given database and repositories
+func NewHandler(db *sql.DB, d dialect.Dialect, repos repository.Repositories) *Handler {
+	return &Handler{Repositories: repos, DB: db, Dialect: d}
+}
@@ -0,0 +1,343 @@
+package controllers_test
+
+import (
+	"backend/controllers"
+	"backend/dialect"
+	"backend/models"
+	"backend/repository/repotest"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAddresses(t *testing.T) {
+	store := repotest.New()
+	h := controllers.NewHandler(nil, dialect.SQLite, store.Repositories())
+	app := newApp()
+	app.Post("/addresses", h.CreateAddress)
+	app.Get("/addresses", h.GetAllAddresses)
+	app.Get("/addresses/:id", h.GetAddress)
+	app.Put("/addresses/:id", h.UpdateAddress)
+	app.Delete("/addresses/:id", h.DeleteAddress)
+
+	// The first address becomes the default whatever was asked
+	status, body := send(t, app, 1, "POST", "/addresses", address("Home", false))
+	if status != fiber.StatusCreated || body["is_default"] != true {
+		t.Fatalf("creating the first address = %d %v, want 201 and the default", status, body)
+	}
+	home := int64(body["id"].(float64))
+
+	// A new default replaces the old one
+	status, body = send(t, app, 1, "POST", "/addresses", address("Work", true))
+	if status != fiber.StatusCreated || body["is_default"] != true {
+		t.Fatalf("creating a default address = %d %v, want 201 and the default", status, body)
+	}
+	work := int64(body["id"].(float64))
+	assertDefault(t, app, 1, work)
+
+	// The only other address takes over when the default is unset
+	if status, body := send(t, app, 1, "PUT", path("/addresses", work), address("Work", false)); status != fiber.StatusOK || body["is_default"] != false {
+		t.Fatalf("unsetting the default = %d %v, want 200 and not the default", status, body)
+	}
+	assertDefault(t, app, 1, home)
+
+	// Addresses of other users cannot be seen or changed
+	if status, _ := send(t, app, 2, "GET", path("/addresses", home), ""); status != fiber.StatusNotFound {
+		t.Errorf("getting another user's address = %d, want 404", status)
+	}
+	if status, _ := send(t, app, 2, "PUT", path("/addresses", home), address("Mine", true)); status != fiber.StatusNotFound {
+		t.Errorf("updating another user's address = %d, want 404", status)
+	}
+	if status, _ := send(t, app, 2, "DELETE", path("/addresses", home), ""); status != fiber.StatusNotFound {
+		t.Errorf("deleting another user's address = %d, want 404", status)
+	}
+
+	// Deleting the default moves it to the address left
+	if status, _ := send(t, app, 1, "DELETE", path("/addresses", home), ""); status != fiber.StatusOK {
+		t.Fatalf("deleting the default address = %d, want 200", status)
+	}
+	assertDefault(t, app, 1, work)
+
+	if status, _ := send(t, app, 1, "POST", "/addresses", `{"name": "Home"}`); status != fiber.StatusBadRequest {
+		t.Errorf("creating an incomplete address = %d, want 400", status)
+	}
+}
+
+func TestCart(t *testing.T) {
+	store := repotest.New()
+	repos := store.Repositories()
+	h := controllers.NewHandler(nil, dialect.SQLite, repos)
+	app := newApp()
+	app.Post("/cart", h.AddToCart)
+	app.Put("/cart/:id", h.UpdateCartItem)
+	app.Delete("/cart/:id", h.RemoveFromCart)
+
+	product := models.Product{Name: "Linen Shirt", BasePrice: 3000}
+	productID, _ := repos.Products.Create(&product)
+	blue, _ := repos.Products.AddColor(productID, "Blue", "#0000ff")
+	red, _ := repos.Products.AddColor(productID, "Red", "#ff0000")
+	medium, _ := repos.Products.AddSize(productID, "M")
+	repos.Products.SetStock(productID, blue, medium, 3)
+
+	line := func(colorID int64, quantity int) string {
+		return `{"product_id": ` + strconv.FormatInt(productID, 10) + `, "color_id": ` + strconv.FormatInt(colorID, 10) +
+			`, "size_id": ` + strconv.FormatInt(medium, 10) + `, "quantity": ` + strconv.Itoa(quantity) + `}`
+	}
+
+	status, body := send(t, app, 1, "POST", "/cart", line(blue, 2))
+	if status != fiber.StatusCreated {
+		t.Fatalf("adding to the cart = %d %v, want 201", status, body)
+	}
+	itemID := int64(body["id"].(float64))
+
+	// Adding the variant again sets the quantity of its line, within the stock
+	status, body = send(t, app, 1, "POST", "/cart", line(blue, 4))
+	if status != fiber.StatusBadRequest || body["available"] != 3.0 {
+		t.Errorf("adding more than the stock = %d %v, want 400 with 3 available", status, body)
+	}
+	status, body = send(t, app, 1, "POST", "/cart", line(blue, 3))
+	if status != fiber.StatusOK || int64(body["id"].(float64)) != itemID {
+		t.Errorf("adding the variant again = %d %v, want 200 updating line %d", status, body, itemID)
+	}
+	if item, err := repos.Carts.Find(1, itemID); err != nil || item.Quantity != 3 {
+		t.Errorf("cart line after adding again = %+v, %v, want a quantity of 3", item, err)
+	}
+
+	// A color without stock, and a color of no product, cannot be added
+	if status, body := send(t, app, 1, "POST", "/cart", line(red, 1)); status != fiber.StatusBadRequest || body["error"] != "Not enough inventory" {
+		t.Errorf("adding a variant without stock = %d %v, want 400", status, body)
+	}
+	if status, body := send(t, app, 1, "POST", "/cart", line(999, 1)); status != fiber.StatusBadRequest || body["error"] != "Color not found for this product" {
+		t.Errorf("adding an unknown color = %d %v, want 400", status, body)
+	}
+
+	// Lines of other users cannot be changed
+	if status, _ := send(t, app, 2, "PUT", path("/cart", itemID), `{"quantity": 1}`); status != fiber.StatusNotFound {
+		t.Errorf("updating another user's cart line = %d, want 404", status)
+	}
+	if status, _ := send(t, app, 2, "DELETE", path("/cart", itemID), ""); status != fiber.StatusNotFound {
+		t.Errorf("removing another user's cart line = %d, want 404", status)
+	}
+
+	if status, _ := send(t, app, 1, "PUT", path("/cart", itemID), `{"quantity": 1}`); status != fiber.StatusOK {
+		t.Errorf("updating a cart line = %d, want 200", status)
+	}
+	if status, _ := send(t, app, 1, "DELETE", path("/cart", itemID), ""); status != fiber.StatusOK {
+		t.Errorf("removing a cart line = %d, want 200", status)
+	}
+	if count, _ := repos.Carts.Count(1); count != 0 {
+		t.Errorf("cart has %d lines after removing the only one, want 0", count)
+	}
+}
+
+func TestProductSlugs(t *testing.T) {
+	store := repotest.New()
+	h := controllers.NewHandler(nil, dialect.SQLite, store.Repositories())
+	app := newApp()
+	app.Post("/products", h.CreateProduct)
+	app.Put("/products/:id", h.UpdateProduct)
+	app.Get("/products/:id", h.GetProductByID)
+	app.Get("/products/slug/:slug", h.GetProductBySlug)
+
+	status, body := send(t, app, 1, "POST", "/products", `{"name": "Linen Shirt", "base_price": 30}`)
+	if status != fiber.StatusCreated || body["slug"] != "linen-shirt" {
+		t.Fatalf("creating a product = %d %v, want 201 with slug linen-shirt", status, body)
+	}
+	productID := int64(body["id"].(float64))
+	if _, body := send(t, app, 1, "POST", "/products", `{"name": "Linen shirt!", "base_price": 25}`); body["slug"] != "linen-shirt-2" {
+		t.Errorf("creating a product with a taken slug = %v, want slug linen-shirt-2", body)
+	}
+
+	// Renaming the product keeps its old slug resolvable
+	status, body = send(t, app, 1, "PUT", path("/products", productID), `{"name": "Oxford Shirt", "base_price": 30}`)
+	if status != fiber.StatusOK || body["slug"] != "oxford-shirt" {
+		t.Fatalf("renaming a product = %d %v, want 200 with slug oxford-shirt", status, body)
+	}
+	status, body = send(t, app, 1, "GET", "/products/slug/linen-shirt", "")
+	if status != fiber.StatusOK || body["redirect_slug"] != "oxford-shirt" {
+		t.Errorf("getting a product by its old slug = %d %v, want 200 redirecting to oxford-shirt", status, body)
+	}
+
+	status, body = send(t, app, 1, "GET", path("/products", productID), "")
+	if product, _ := body["product"].(map[string]interface{}); status != fiber.StatusOK || product["final_price"] != 30.0 {
+		t.Errorf("getting a product = %d %v, want 200 priced 30", status, body)
+	}
+	if status, _ := send(t, app, 1, "GET", "/products/999", ""); status != fiber.StatusNotFound {
+		t.Errorf("getting a missing product = %d, want 404", status)
+	}
+	if status, _ := send(t, app, 1, "PUT", "/products/999", `{"name": "Tee", "base_price": 10}`); status != fiber.StatusNotFound {
+		t.Errorf("updating a missing product = %d, want 404", status)
+	}
+}
+
+func TestReviews(t *testing.T) {
+	store := repotest.New()
+	repos := store.Repositories()
+	h := controllers.NewHandler(nil, dialect.SQLite, repos)
+	app := newApp()
+	app.Post("/products/:id/reviews", h.CreateReview)
+	app.Get("/products/:id/reviews", h.GetProductReviews)
+	app.Put("/products/:id/reviews/:reviewId", h.UpdateReview)
+	app.Delete("/products/:id/reviews/:reviewId", h.DeleteReview)
+
+	productID, _ := repos.Products.Create(&models.Product{Name: "Linen Shirt", BasePrice: 3000})
+	reviews := path("/products", productID) + "/reviews"
+	var customers []int64
+	for _, email := range []string{"ada@example.com", "bob@example.com"} {
+		userID, _ := repos.Users.Create(&models.User{Name: "Customer", Email: email, Password: "hash", Role: "customer"})
+		customers = append(customers, userID)
+	}
+	ada, bob := customers[0], customers[1]
+
+	// Only customers who received the product can review it, once
+	if status, _ := send(t, app, ada, "POST", reviews, `{"rating": 4}`); status != fiber.StatusForbidden {
+		t.Errorf("reviewing a product never received = %d, want 403", status)
+	}
+	for _, userID := range customers {
+		store.AddOrder(models.Order{UserID: userID, OrderStatus: "delivered"}, models.OrderItemResponse{ProductID: productID, Quantity: 1})
+	}
+	status, body := send(t, app, ada, "POST", reviews, `{"rating": 4, "comment": "Fits well"}`)
+	if status != fiber.StatusCreated {
+		t.Fatalf("reviewing a received product = %d %v, want 201", status, body)
+	}
+	reviewID := int64(body["id"].(float64))
+	if status, _ := send(t, app, ada, "POST", reviews, `{"rating": 5}`); status != fiber.StatusConflict {
+		t.Errorf("reviewing a product again = %d, want 409", status)
+	}
+	if status, _ := send(t, app, bob, "POST", reviews, `{"rating": 1}`); status != fiber.StatusCreated {
+		t.Errorf("reviewing as another customer = %d, want 201", status)
+	}
+
+	status, body = send(t, app, ada, "GET", reviews+"?sort=highest", "")
+	listed, _ := body["reviews"].([]interface{})
+	summary, _ := body["summary"].(map[string]interface{})
+	if status != fiber.StatusOK || len(listed) != 2 || listed[0].(map[string]interface{})["id"] != float64(reviewID) ||
+		summary["average_rating"] != 2.5 || summary["review_count"] != 2.0 {
+		t.Errorf("listing reviews highest first = %d %v, want both with the 4 star review first and an average of 2.5", status, body)
+	}
+	if status, _ := send(t, app, ada, "GET", reviews+"?sort=best", ""); status != fiber.StatusBadRequest {
+		t.Errorf("listing reviews with an unknown sort = %d, want 400", status)
+	}
+
+	// Reviews of other users cannot be changed
+	if status, _ := send(t, app, bob, "PUT", path(reviews, reviewID), `{"rating": 1}`); status != fiber.StatusNotFound {
+		t.Errorf("updating another user's review = %d, want 404", status)
+	}
+	if status, _ := send(t, app, ada, "PUT", path(reviews, reviewID), `{"rating": 5}`); status != fiber.StatusOK {
+		t.Errorf("updating a review = %d, want 200", status)
+	}
+	if status, _ := send(t, app, ada, "DELETE", path(reviews, reviewID), ""); status != fiber.StatusOK {
+		t.Errorf("deleting a review = %d, want 200", status)
+	}
+	if status, _ := send(t, app, ada, "DELETE", path(reviews, reviewID), ""); status != fiber.StatusNotFound {
+		t.Errorf("deleting a deleted review = %d, want 404", status)
+	}
+}
+
+func TestWishlist(t *testing.T) {
+	store := repotest.New()
+	repos := store.Repositories()
+	h := controllers.NewHandler(nil, dialect.SQLite, repos)
+	app := newApp()
+	app.Post("/wishlist", h.AddToWishlist)
+	app.Get("/wishlist", h.GetWishlist)
+	app.Delete("/wishlist/:id", h.RemoveFromWishlist)
+
+	productID, _ := repos.Products.Create(&models.Product{Name: "Linen Shirt", BasePrice: 3000, DiscountPercentage: 10})
+	item := `{"product_id": ` + strconv.FormatInt(productID, 10) + `}`
+
+	status, body := send(t, app, 1, "POST", "/wishlist", item)
+	if status != fiber.StatusCreated {
+		t.Fatalf("adding to the wishlist = %d %v, want 201", status, body)
+	}
+	itemID := int64(body["id"].(float64))
+	if status, _ := send(t, app, 1, "POST", "/wishlist", item); status != fiber.StatusConflict {
+		t.Errorf("adding a product again = %d, want 409", status)
+	}
+	if status, _ := send(t, app, 1, "POST", "/wishlist", `{"product_id": 999}`); status != fiber.StatusBadRequest {
+		t.Errorf("adding an unknown product = %d, want 400", status)
+	}
+
+	status, body = send(t, app, 1, "GET", "/wishlist", "")
+	items, _ := body["items"].([]interface{})
+	if status != fiber.StatusOK || len(items) != 1 || items[0].(map[string]interface{})["final_price"] != 27.0 {
+		t.Errorf("getting the wishlist = %d %v, want the product at 27", status, body)
+	}
+
+	// Items of other users cannot be removed
+	if status, _ := send(t, app, 2, "DELETE", path("/wishlist", itemID), ""); status != fiber.StatusNotFound {
+		t.Errorf("removing another user's item = %d, want 404", status)
+	}
+	if status, _ := send(t, app, 1, "DELETE", path("/wishlist", itemID), ""); status != fiber.StatusOK {
+		t.Errorf("removing an item = %d, want 200", status)
+	}
+}
+
+// newApp returns an app that acts as the user whose ID is in the X-User-ID header
+func newApp() *fiber.App {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		userID, _ := strconv.ParseInt(c.Get("X-User-ID"), 10, 64)
+		c.Locals("userID", userID)
+		c.Locals("role", "customer")
+		return c.Next()
+	})
+	return app
+}
+
+// send makes a request as a user and decodes the JSON response
+func send(t *testing.T, app *fiber.App, userID int64, method, target, body string) (int, map[string]interface{}) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-ID", strconv.FormatInt(userID, 10))
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, target, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading the response to %s %s: %v", method, target, err)
+	}
+	decoded := map[string]interface{}{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("decoding the response to %s %s: %v: %s", method, target, err, data)
+	}
+	return resp.StatusCode, decoded
+}
+
+// assertDefault checks that a user's addresses are listed with the default first
+func assertDefault(t *testing.T, app *fiber.App, userID, addressID int64) {
+	t.Helper()
+
+	_, body := send(t, app, userID, "GET", "/addresses", "")
+	addresses, _ := body["addresses"].([]interface{})
+	defaults := 0
+	for _, address := range addresses {
+		if address.(map[string]interface{})["is_default"] == true {
+			defaults++
+		}
+	}
+	if len(addresses) == 0 || defaults != 1 || addresses[0].(map[string]interface{})["id"] != float64(addressID) {
+		t.Errorf("addresses = %v, want %d as the only default, listed first", addresses, addressID)
+	}
+}
+
+// address returns an address request
+func address(name string, isDefault bool) string {
+	return `{"name": "` + name + `", "street": "1 Main St", "city": "Austin", "state": "TX", "postal_code": "73301",
+		"country": "US", "phone": "555-0100", "is_default": ` + strconv.FormatBool(isDefault) + `}`
+}
+
+// path joins a path and an ID
+func path(prefix string, id int64) string {
+	return prefix + "/" + strconv.FormatInt(id, 10)
+}
@@ -1,20 +1,46 @@
 package controllers
 
 import (
-	"backend/database"
+	"backend/accounts"
+	"backend/inventory"
 	"backend/models"
-	"database/sql"
+	"backend/orderstatus"
+	"backend/payments"
+	"backend/permissions"
+	"backend/promotions"
+	"backend/shipments"
+	"backend/shipping"
+	"backend/tax"
+	"errors"
+	"log"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // PlaceOrder creates a new order from the user's cart
-func PlaceOrder(c *fiber.Ctx) error {
+func (h *Handler) PlaceOrder(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
+	// Optionally only accounts with a verified email can order
+	if accounts.RequireVerifiedEmail() {
+		user, err := h.Users.FindByID(userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+		if user.EmailVerifiedAt == nil {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Please verify your email address before placing an order",
+			})
+		}
+	}
+
 	// Parse request body
 	var req models.OrderRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -30,18 +56,35 @@ func PlaceOrder(c *fiber.Ctx) error {
 		})
 	}
 
+	// Check that the payment method is supported
+	if _, err := payments.Get(req.PaymentMethod); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unsupported payment method",
+		})
+	}
+
+	// Standard shipping unless another method was chosen
+	req.ShippingMethod = strings.ToLower(strings.TrimSpace(req.ShippingMethod))
+	if req.ShippingMethod == "" {
+		req.ShippingMethod = shipping.MethodStandard
+	}
+
 	// Check if address exists and belongs to user
-	var addressExists bool
-	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM addresses WHERE id = ? AND user_id = ?)", req.AddressID, userID).Scan(&addressExists)
-	if err != nil || !addressExists {
+	address, err := h.Addresses.Find(userID, req.AddressID)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid address",
 		})
 	}
 
+	// The order is priced in the currency asked for, at the current rate
+	exchange, err := h.requestExchange(c)
+	if err != nil {
+		return exchangeError(c, err)
+	}
+
 	// Check if cart is empty
-	var cartCount int
-	err = database.DB.QueryRow("SELECT COUNT(*) FROM cart WHERE user_id = ?", userID).Scan(&cartCount)
+	cartCount, err := h.Carts.Count(userID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
@@ -54,91 +97,137 @@ func PlaceOrder(c *fiber.Ctx) error {
 	}
 
 	// Start a transaction
-	tx, err := database.DB.Begin()
+	tx, err := h.DB.Begin()
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to start transaction",
 		})
 	}
+	defer tx.Rollback()
 
-	// Calculate total amount
-	var totalAmount float64
-	err = tx.QueryRow(`
-		SELECT SUM(p.base_price * (1 - p.discount_percentage / 100) * c.quantity) 
-		FROM cart c
-		JOIN products p ON c.product_id = p.id
-		WHERE c.user_id = ?`,
-		userID).Scan(&totalAmount)
+	// Read the cart and calculate the subtotal. The order is priced, taxed and
+	// filled from these same lines.
+	lines, err := promotions.CartLines(tx, userID, exchange)
 	if err != nil {
-		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to calculate total amount",
 		})
 	}
+	if len(lines) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cart is empty",
+		})
+	}
+	subtotal := promotions.Subtotal(lines)
 
-	// Create the order
-	result, err := tx.Exec(
-		`INSERT INTO orders (user_id, address_id, total_amount, payment_method, payment_status, order_status, created_at, updated_at) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-		userID, req.AddressID, totalAmount, req.PaymentMethod, "pending", "processing", time.Now(), time.Now())
+	// Apply the cart's coupon, refusing the order if it no longer qualifies
+	discount := &promotions.Discount{}
+	promotion, err := promotions.AppliedPromotion(tx, userID)
+	if err == nil && promotion != nil {
+		discount, err = promotions.Evaluate(tx, promotion, userID, lines, exchange, time.Now())
+		if err == promotions.ErrNotFound {
+			err = promotions.RuleError("Coupon is no longer available")
+		}
+		if _, ok := err.(promotions.RuleError); ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error() + ", remove the coupon to continue",
+			})
+		}
+	}
 	if err != nil {
-		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create order",
+			"error": "Failed to apply coupon",
 		})
 	}
 
-	// Get the order ID
-	orderID, _ := result.LastInsertId()
+	// Calculate tax for the delivery address
+	taxes, err := tax.Calculate(tx, address.Country, address.State, taxLines(lines, discount))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to calculate tax",
+		})
+	}
 
-	// Get cart items
-	cartRows, err := tx.Query(`
-		SELECT c.product_id, c.color_id, c.size_id, c.quantity, 
-			p.base_price * (1 - p.discount_percentage / 100) as price_per_unit
-		FROM cart c
-		JOIN products p ON c.product_id = p.id
-		WHERE c.user_id = ?`,
-		userID)
+	// Price the chosen shipping method for the address
+	weight, err := shipping.CartWeight(tx, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to calculate shipping",
+		})
+	}
+	delivery, err := shipping.Quote(tx, address.Country, address.PostalCode, req.ShippingMethod, shipping.Parcel{
+		Weight:       weight,
+		Value:        subtotal - discount.Amount,
+		FreeShipping: discount.FreeShipping,
+		Exchange:     exchange,
+	})
+	if err == shipping.ErrNoZone || err == shipping.ErrMethodUnavailable {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": shippingError(err),
+		})
+	}
 	if err != nil {
-		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch cart items",
+			"error": "Failed to calculate shipping",
 		})
 	}
-	defer cartRows.Close()
+	totalAmount := subtotal - discount.Amount + delivery.Cost + taxes.Added
 
-	// Insert order items and update inventory
-	for cartRows.Next() {
-		var productID, colorID, sizeID int64
-		var quantity int
-		var pricePerUnit float64
+	var couponCode, promotionID interface{}
+	if discount.Promotion != nil {
+		couponCode, promotionID = discount.Promotion.Code, discount.Promotion.ID
+	}
 
-		err := cartRows.Scan(&productID, &colorID, &sizeID, &quantity, &pricePerUnit)
-		if err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to process cart items",
-			})
-		}
+	// Create the order, locking in its currency and exchange rate
+	var orderID int64
+	err = tx.QueryRow(
+		`INSERT INTO orders (user_id, address_id, subtotal, discount_amount, coupon_code, promotion_id,
+			tax_amount, tax_included, shipping_method, shipping_cost, total_amount, currency, exchange_rate,
+			payment_method, payment_status, order_status, created_at, updated_at) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		userID, req.AddressID, subtotal, discount.Amount, couponCode, promotionID,
+		taxes.Added, taxes.Included, delivery.Method, delivery.Cost, totalAmount, string(exchange.Currency), exchange.Rate,
+		req.PaymentMethod, "pending", "processing", time.Now(), time.Now()).Scan(&orderID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create order",
+		})
+	}
+
+	// Start the order's timeline
+	actor := actorFromContext(c)
+	err = orderstatus.Record(tx, orderID, orderstatus.FieldOrder, "", "processing", actor, "Order placed")
+	if err == nil {
+		err = orderstatus.Record(tx, orderID, orderstatus.FieldPayment, "", "pending", actor, "Payment method "+req.PaymentMethod)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record order status",
+		})
+	}
 
-		// Check inventory again (in case it changed since adding to cart)
+	// Insert order items and update inventory
+	for i, line := range lines {
+		// Check inventory again, stock held by other shoppers is not available
 		var availableQuantity int
 		err = tx.QueryRow(
-			"SELECT quantity FROM product_inventory WHERE product_id = ? AND color_id = ? AND size_id = ?",
-			productID, colorID, sizeID).Scan(&availableQuantity)
-		if err != nil || availableQuantity < quantity {
-			tx.Rollback()
+			"SELECT "+inventory.AvailableToUser+" FROM product_inventory pi WHERE pi.product_id = ? AND pi.color_id = ? AND pi.size_id = ?",
+			userID, line.ProductID, line.ColorID, line.SizeID).Scan(&availableQuantity)
+		if err != nil || availableQuantity < line.Quantity {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": "Not enough inventory for one or more items",
 			})
 		}
 
-		// Insert order item
+		// Insert order item with its share of the discount and its tax
+		lineTax := taxes.Lines[i]
 		_, err = tx.Exec(
-			"INSERT INTO order_items (order_id, product_id, color_id, size_id, quantity, price_per_unit) VALUES (?, ?, ?, ?, ?, ?)",
-			orderID, productID, colorID, sizeID, quantity, pricePerUnit)
+			`INSERT INTO order_items (order_id, product_id, color_id, size_id, quantity, price_per_unit,
+				discount_amount, tax_rate, tax_amount, tax_included)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			orderID, line.ProductID, line.ColorID, line.SizeID, line.Quantity, line.UnitPrice,
+			discount.LineAmount(i), lineTax.Rate, lineTax.Amount, lineTax.Included)
 		if err != nil {
-			tx.Rollback()
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to create order item",
 			})
@@ -147,19 +236,43 @@ func PlaceOrder(c *fiber.Ctx) error {
 		// Update inventory
 		_, err = tx.Exec(
 			"UPDATE product_inventory SET quantity = quantity - ? WHERE product_id = ? AND color_id = ? AND size_id = ?",
-			quantity, productID, colorID, sizeID)
+			line.Quantity, line.ProductID, line.ColorID, line.SizeID)
 		if err != nil {
-			tx.Rollback()
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to update inventory",
 			})
 		}
 	}
 
+	// Count the coupon towards its usage caps and take it off the cart
+	if discount.Promotion != nil {
+		err = promotions.Redeem(tx, discount, userID, orderID)
+		if _, ok := err.(promotions.RuleError); ok {
+			// Orders placed since the coupon was checked used up its caps
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error() + ", remove the coupon to continue",
+			})
+		}
+		if err == nil {
+			err = promotions.Remove(tx, userID)
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to redeem coupon",
+			})
+		}
+	}
+
+	// The stock is sold now, so the checkout holds are no longer needed
+	if err := inventory.Release(tx, userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to release reservations",
+		})
+	}
+
 	// Clear the cart
 	_, err = tx.Exec("DELETE FROM cart WHERE user_id = ?", userID)
 	if err != nil {
-		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to clear cart",
 		})
@@ -172,21 +285,44 @@ func PlaceOrder(c *fiber.Ctx) error {
 		})
 	}
 
+	// Authorize the payment now that the order exists
+	payment, err := payments.Authorize(h.DB, orderID, req.PaymentMethod, totalAmount, exchange.Currency, req.PaymentToken)
+	if err != nil {
+		log.Printf("Failed to authorize payment for order %d: %v", orderID, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error":    "Payment provider unavailable, please retry the payment",
+			"order_id": orderID,
+		})
+	}
+	if payment.Status == payments.StatusFailed {
+		return c.Status(fiber.StatusPaymentRequired).JSON(fiber.Map{
+			"error":          "Payment was declined, please retry the payment",
+			"order_id":       orderID,
+			"payment_status": "failed",
+		})
+	}
+
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message":      "Order placed successfully",
-		"order_id":     orderID,
-		"total_amount": totalAmount,
+		"message":         "Order placed successfully",
+		"order_id":        orderID,
+		"subtotal":        subtotal,
+		"discount_amount": discount.Amount,
+		"shipping_method": delivery.Method,
+		"shipping_cost":   delivery.Cost,
+		"tax_amount":      taxes.Added,
+		"tax_included":    taxes.Included,
+		"total_amount":    totalAmount,
+		"currency":        exchange.Currency,
+		"exchange_rate":   exchange.Rate,
+		"payment_status":  h.orderPaymentStatus(orderID),
 	})
 }
 
 // GetAllOrders returns all orders for the user
-func GetAllOrders(c *fiber.Ctx) error {
+func (h *Handler) GetAllOrders(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
-	// Get the user's role
-	role := c.Locals("role").(string)
-
 	// Parse query parameters for pagination
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "10"))
@@ -198,79 +334,20 @@ func GetAllOrders(c *fiber.Ctx) error {
 	}
 	offset := (page - 1) * limit
 
-	// Prepare base query
-	baseQuery := `
-		SELECT o.id, o.user_id, o.address_id, o.total_amount, o.payment_method, 
-			o.payment_status, o.order_status, o.created_at, o.updated_at,
-			u.name as user_name, u.email as user_email
-		FROM orders o
-		JOIN users u ON o.user_id = u.id
-	`
-
-	// Add filter for non-admin users
-	var countQuery, selectQuery string
-	var queryArgs []interface{}
-
-	if role != "admin" {
-		// Regular user can only see their own orders
-		countQuery = "SELECT COUNT(*) FROM orders WHERE user_id = ?"
-		selectQuery = baseQuery + " WHERE o.user_id = ? ORDER BY o.id DESC LIMIT ? OFFSET ?"
-		queryArgs = append(queryArgs, userID, limit, offset)
-	} else {
-		// Admin can see all orders
-		countQuery = "SELECT COUNT(*) FROM orders"
-		selectQuery = baseQuery + " ORDER BY o.id DESC LIMIT ? OFFSET ?"
-		queryArgs = append(queryArgs, limit, offset)
+	// Regular users can only see their own orders, staff with orders:read see every order
+	var ownerID int64
+	if !h.hasPermission(c, permissions.OrdersRead) {
+		ownerID = userID
 	}
 
-	// Count total orders for pagination
-	var total int
-	var err error
-	if role != "admin" {
-		err = database.DB.QueryRow(countQuery, userID).Scan(&total)
-	} else {
-		err = database.DB.QueryRow(countQuery).Scan(&total)
-	}
+	// Get the page of orders and the total for pagination
+	orders, total, err := h.Orders.List(ownerID, limit, offset)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
 		})
 	}
 
-	// Query to get orders
-	var rows *sql.Rows
-	if role != "admin" {
-		rows, err = database.DB.Query(selectQuery, userID, limit, offset)
-	} else {
-		rows, err = database.DB.Query(selectQuery, limit, offset)
-	}
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Database error",
-		})
-	}
-	defer rows.Close()
-
-	// Process results
-	type OrderWithUser struct {
-		models.Order
-		UserName  string `json:"user_name"`
-		UserEmail string `json:"user_email"`
-	}
-
-	var orders []OrderWithUser
-	for rows.Next() {
-		var order OrderWithUser
-		err := rows.Scan(
-			&order.ID, &order.UserID, &order.AddressID, &order.TotalAmount,
-			&order.PaymentMethod, &order.PaymentStatus, &order.OrderStatus,
-			&order.CreatedAt, &order.UpdatedAt, &order.UserName, &order.UserEmail)
-		if err != nil {
-			continue
-		}
-		orders = append(orders, order)
-	}
-
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"orders": orders,
 		"meta": fiber.Map{
@@ -283,13 +360,10 @@ func GetAllOrders(c *fiber.Ctx) error {
 }
 
 // GetOrderByID returns a specific order
-func GetOrderByID(c *fiber.Ctx) error {
+func (h *Handler) GetOrderByID(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
-	// Get the user's role
-	role := c.Locals("role").(string)
-
 	// Get order ID from URL parameter
 	id := c.Params("id")
 	orderID, err := strconv.ParseInt(id, 10, 64)
@@ -299,50 +373,16 @@ func GetOrderByID(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if order exists and belongs to user (if not admin)
-	var order models.Order
-	var exists bool
-	var query string
-	var args []interface{}
-
-	if role != "admin" {
-		// Regular users can only view their own orders
-		query = `
-			SELECT EXISTS(SELECT 1 FROM orders WHERE id = ? AND user_id = ?),
-			id, user_id, address_id, total_amount, payment_method, payment_status, order_status, created_at, updated_at
-			FROM orders WHERE id = ? AND user_id = ?`
-		args = []interface{}{orderID, userID, orderID, userID}
-	} else {
-		// Admins can view any order
-		query = `
-			SELECT EXISTS(SELECT 1 FROM orders WHERE id = ?),
-			id, user_id, address_id, total_amount, payment_method, payment_status, order_status, created_at, updated_at
-			FROM orders WHERE id = ?`
-		args = []interface{}{orderID, orderID}
-	}
-
-	err = database.DB.QueryRow(query, args...).Scan(
-		&exists,
-		&order.ID, &order.UserID, &order.AddressID, &order.TotalAmount,
-		&order.PaymentMethod, &order.PaymentStatus, &order.OrderStatus,
-		&order.CreatedAt, &order.UpdatedAt)
-
-	if err != nil || !exists {
+	// Check if order exists and belongs to user (unless staff can read every order)
+	order, err := h.Orders.Find(orderID)
+	if err != nil || (order.UserID != userID && !h.hasPermission(c, permissions.OrdersRead)) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Order not found",
 		})
 	}
 
 	// Get the address
-	var address models.Address
-	err = database.DB.QueryRow(`
-		SELECT id, user_id, name, street, city, state, postal_code, country, phone, is_default, created_at, updated_at
-		FROM addresses
-		WHERE id = ?`,
-		order.AddressID).Scan(
-		&address.ID, &address.UserID, &address.Name, &address.Street, &address.City,
-		&address.State, &address.PostalCode, &address.Country, &address.Phone,
-		&address.IsDefault, &address.CreatedAt, &address.UpdatedAt)
+	address, err := h.Addresses.Find(order.UserID, order.AddressID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch address",
@@ -350,57 +390,54 @@ func GetOrderByID(c *fiber.Ctx) error {
 	}
 
 	// Get order items
-	rows, err := database.DB.Query(`
-		SELECT oi.id, oi.product_id, oi.color_id, oi.size_id, oi.quantity, oi.price_per_unit,
-			p.name, p.description,
-			pc.color_name, pc.color_hex,
-			ps.size_name,
-			(SELECT image_url FROM product_images WHERE product_id = p.id AND is_primary = 1 LIMIT 1) as image_url
-		FROM order_items oi
-		JOIN products p ON oi.product_id = p.id
-		JOIN product_colors pc ON oi.color_id = pc.id
-		JOIN product_sizes ps ON oi.size_id = ps.id
-		WHERE oi.order_id = ?`,
-		orderID)
+	items, err := h.Orders.Items(orderID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
 		})
 	}
-	defer rows.Close()
 
-	var items []models.OrderItemResponse
-	for rows.Next() {
-		var item models.OrderItemResponse
-		var pricePerUnit float64
-
-		err := rows.Scan(
-			&item.ID, &item.ProductID, &item.ColorID, &item.SizeID, &item.Quantity, &pricePerUnit,
-			&item.ProductName, &item.ProductDescription,
-			&item.ColorName, &item.ColorHex,
-			&item.SizeName,
-			&item.ImageURL)
-		if err != nil {
-			continue
-		}
+	// Get payment attempts
+	orderPayments, err := payments.ForOrder(h.DB, orderID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch payments",
+		})
+	}
 
-		item.PricePerUnit = pricePerUnit
-		item.SubTotal = pricePerUnit * float64(item.Quantity)
-		items = append(items, item)
+	// Get the status timeline
+	timeline, err := orderstatus.History(h.DB, orderID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch order history",
+		})
 	}
 
 	// Create order response
 	orderResponse := models.OrderResponse{
-		ID:            order.ID,
-		UserID:        order.UserID,
-		Address:       address,
-		TotalAmount:   order.TotalAmount,
-		PaymentMethod: order.PaymentMethod,
-		PaymentStatus: order.PaymentStatus,
-		OrderStatus:   order.OrderStatus,
-		Items:         items,
-		CreatedAt:     order.CreatedAt,
-		UpdatedAt:     order.UpdatedAt,
+		ID:             order.ID,
+		UserID:         order.UserID,
+		Address:        *address,
+		Subtotal:       order.Subtotal,
+		DiscountAmount: order.DiscountAmount,
+		CouponCode:     order.CouponCode,
+		TaxAmount:      order.TaxAmount,
+		TaxIncluded:    order.TaxIncluded,
+		ShippingMethod: order.ShippingMethod,
+		ShippingCost:   order.ShippingCost,
+		TotalAmount:    order.TotalAmount,
+		Currency:       order.Currency,
+		ExchangeRate:   order.ExchangeRate,
+		PaymentMethod:  order.PaymentMethod,
+		PaymentStatus:  order.PaymentStatus,
+		OrderStatus:    order.OrderStatus,
+		CancelReason:   order.CancelReason,
+		CancelledAt:    order.CancelledAt,
+		Items:          items,
+		Payments:       orderPayments,
+		Timeline:       timeline,
+		CreatedAt:      order.CreatedAt,
+		UpdatedAt:      order.UpdatedAt,
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -409,7 +446,7 @@ func GetOrderByID(c *fiber.Ctx) error {
 }
 
 // UpdateOrderStatus updates an order's status (admin only)
-func UpdateOrderStatus(c *fiber.Ctx) error {
+func (h *Handler) UpdateOrderStatus(c *fiber.Ctx) error {
 	// Get order ID from URL parameter
 	id := c.Params("id")
 	orderID, err := strconv.ParseInt(id, 10, 64)
@@ -427,74 +464,405 @@ func UpdateOrderStatus(c *fiber.Ctx) error {
 		})
 	}
 
-	// Validate input
-	if req.OrderStatus == "" && req.PaymentStatus == "" {
+	// Validate input, the payment status is only changed by the payment provider
+	if req.OrderStatus == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Order status or payment status must be provided",
+			"error": "Order status must be provided",
 		})
 	}
 
 	// Validate order status values
-	validOrderStatuses := map[string]bool{
-		"processing": true,
-		"shipped":    true,
-		"delivered":  true,
-		"cancelled":  true,
+	if !orderstatus.IsOrderStatus(req.OrderStatus) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order status",
+		})
 	}
-	validPaymentStatuses := map[string]bool{
-		"pending":  true,
-		"paid":     true,
-		"failed":   true,
-		"refunded": true,
+
+	// Check if order exists
+	order, err := h.Orders.Find(orderID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Order not found",
+		})
 	}
 
-	if req.OrderStatus != "" && !validOrderStatuses[req.OrderStatus] {
+	// Check that the order may move to the new status
+	if err := orderstatus.CheckOrder(order.OrderStatus, req.OrderStatus); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid order status",
+			"error": err.Error(),
 		})
 	}
 
-	if req.PaymentStatus != "" && !validPaymentStatuses[req.PaymentStatus] {
+	// Cancelling restocks the items and refunds the payment
+	if req.OrderStatus == "cancelled" {
+		reason := strings.TrimSpace(req.Note)
+		if reason == "" {
+			reason = "Cancelled by admin"
+		}
+		return h.respondCancelled(c, orderID, reason)
+	}
+
+	// Part of an order can only be shipped by listing its items
+	if req.OrderStatus == "partially_shipped" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid payment status",
+			"error": "Use the shipments endpoint to ship part of an order",
 		})
 	}
 
-	// Check if order exists
-	var exists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM orders WHERE id = ?)", orderID).Scan(&exists)
-	if err != nil || !exists {
+	// Start a transaction
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction",
+		})
+	}
+	defer tx.Rollback()
+
+	// Lock the order and read its payment status again, now that no other
+	// request can change it
+	if err := orderstatus.Lock(tx, orderID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	var paymentStatus string
+	if err := tx.QueryRow("SELECT payment_status FROM orders WHERE id = ?", orderID).Scan(&paymentStatus); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if paymentStatus == "failed" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Order payment has failed",
+		})
+	}
+
+	// Update the order: shipping it ships everything left in one shipment,
+	// delivering it delivers its shipments
+	actor, note := actorFromContext(c), strings.TrimSpace(req.Note)
+	if req.OrderStatus == "shipped" {
+		var plan *shipments.Plan
+		plan, err = shipments.PlanShipment(tx, orderID, nil)
+		if _, ok := err.(shipments.RuleError); ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err == nil {
+			_, err = shipments.Create(tx, orderID, "", "", plan, actor, note)
+		}
+	} else {
+		err = orderstatus.SetOrder(tx, orderID, req.OrderStatus, actor, note)
+		if err == nil && req.OrderStatus == "delivered" {
+			err = shipments.DeliverAll(tx, orderID)
+		}
+	}
+	if _, ok := err.(*orderstatus.TransitionError); ok {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update order",
+		})
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	// Capture the payment if the new status requires it, once the order has moved
+	if status, message := h.capturePayment(orderID, paymentStatus, req.OrderStatus); message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error":          message,
+			"payment_status": h.orderPaymentStatus(orderID),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Order updated successfully",
+	})
+}
+
+// CancelOrder cancels an order, restocking its items and refunding its payment.
+// Customers can cancel their own orders while they are processing, staff with
+// orders:cancel any order.
+func (h *Handler) CancelOrder(c *fiber.Ctx) error {
+	// Get user ID and role from context (set by the Protected middleware)
+	userID := c.Locals("userID").(int64)
+	role := c.Locals("role").(string)
+	staff := h.hasPermission(c, permissions.OrdersCancel)
+
+	// Get order ID from URL parameter
+	id := c.Params("id")
+	orderID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order ID",
+		})
+	}
+
+	// Parse request body, the reason is optional
+	var req models.CancelOrderRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	// Check if order exists and belongs to user (unless staff)
+	order, err := h.Orders.Find(orderID)
+	if err != nil || (!staff && order.UserID != userID) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Order not found",
 		})
 	}
 
-	// Prepare the update query and arguments
-	query := "UPDATE orders SET updated_at = ?"
-	args := []interface{}{time.Now()}
-
-	if req.OrderStatus != "" {
-		query += ", order_status = ?"
-		args = append(args, req.OrderStatus)
+	// Check if the order can still be cancelled
+	if err := orderstatus.CheckOrder(order.OrderStatus, "cancelled"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if !staff && order.OrderStatus != "processing" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": errCancelTooLate.Error(),
+		})
 	}
 
-	if req.PaymentStatus != "" {
-		query += ", payment_status = ?"
-		args = append(args, req.PaymentStatus)
+	reason := strings.TrimSpace(req.Reason)
+	if staff {
+		if reason == "" {
+			reason = "Cancelled by " + role
+		}
+		return h.respondCancelled(c, orderID, reason)
+	}
+	if reason == "" {
+		reason = "Cancelled by customer"
 	}
 
-	query += " WHERE id = ?"
-	args = append(args, orderID)
+	// The order may ship in the meantime, so the status is checked again while cancelling
+	return h.respondCancelled(c, orderID, reason, "processing")
+}
+
+// errCancelTooLate is returned when an order has moved past the statuses it may be cancelled from
+var errCancelTooLate = errors.New("Order can no longer be cancelled")
 
-	// Update the order
-	_, err = database.DB.Exec(query, args...)
+// respondCancelled cancels an order and reports the outcome of its refund or
+// of releasing its uncollected payment
+func (h *Handler) respondCancelled(c *fiber.Ctx, orderID int64, reason string, from ...string) error {
+	paymentStatus, err := h.cancelOrder(orderID, reason, actorFromContext(c), from...)
+	if err == errCancelTooLate {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if _, ok := err.(*orderstatus.TransitionError); ok {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update order",
+			"error": "Failed to cancel order",
 		})
 	}
 
+	// Refund the payment if it was collected
+	if paymentStatus == "paid" {
+		var payment *models.Payment
+		order, err := h.Orders.Find(orderID)
+		if err == nil {
+			payment, err = payments.Refund(h.DB, orderID, order.TotalAmount)
+		}
+		if err != nil || payment.Status != payments.StatusRefunded {
+			log.Printf("Failed to refund cancelled order %d: %v", orderID, err)
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error":          "Order cancelled but the refund failed",
+				"payment_status": h.orderPaymentStatus(orderID),
+			})
+		}
+	}
+
+	// Release a payment that was authorized or promised but never collected
+	if paymentStatus == "authorized" || paymentStatus == "pending" {
+		payment, err := payments.Void(h.DB, orderID)
+		if err != nil || payment.Status != payments.StatusVoided {
+			log.Printf("Failed to void the payment of cancelled order %d: %v", orderID, err)
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error":          "Order cancelled but the payment could not be released",
+				"payment_status": h.orderPaymentStatus(orderID),
+			})
+		}
+	}
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "Order updated successfully",
+		"message":        "Order cancelled successfully",
+		"payment_status": h.orderPaymentStatus(orderID),
+	})
+}
+
+// cancelOrder marks an order cancelled and returns its items to stock in one
+// transaction. Unless from is empty, it returns errCancelTooLate for an order
+// in another status. It returns the payment status the order had.
+func (h *Handler) cancelOrder(orderID int64, reason string, actor orderstatus.Actor, from ...string) (string, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// Lock the order, so that it cannot ship while it is being cancelled
+	if err := orderstatus.Lock(tx, orderID); err != nil {
+		return "", err
+	}
+
+	var paymentStatus, orderStatus string
+	err = tx.QueryRow("SELECT payment_status, order_status FROM orders WHERE id = ?", orderID).Scan(&paymentStatus, &orderStatus)
+	if err != nil {
+		return "", err
+	}
+	if len(from) > 0 && !slices.Contains(from, orderStatus) {
+		return "", errCancelTooLate
+	}
+
+	// Get order items
+	rows, err := tx.Query("SELECT product_id, color_id, size_id, quantity FROM order_items WHERE order_id = ?", orderID)
+	if err != nil {
+		return "", err
+	}
+	var items []models.OrderItem
+	for rows.Next() {
+		var item models.OrderItem
+		if err := rows.Scan(&item.ProductID, &item.ColorID, &item.SizeID, &item.Quantity); err != nil {
+			rows.Close()
+			return "", err
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+
+	// Restore inventory, recreating rows an admin may have removed since
+	for _, item := range items {
+		if err := inventory.Restock(tx, item.ProductID, item.ColorID, item.SizeID, item.Quantity); err != nil {
+			return "", err
+		}
+	}
+
+	// Mark the order cancelled, this fails if another request cancelled it first
+	if err := orderstatus.SetOrder(tx, orderID, "cancelled", actor, reason); err != nil {
+		return "", err
+	}
+
+	// The coupon it used no longer counts towards the usage limit
+	if err := promotions.Release(tx, orderID); err != nil {
+		return "", err
+	}
+	_, err = tx.Exec("UPDATE orders SET cancel_reason = ?, cancelled_at = ? WHERE id = ?", reason, time.Now(), orderID)
+	if err != nil {
+		return "", err
+	}
+
+	return paymentStatus, tx.Commit()
+}
+
+// PayOrder retries the payment of an order whose payment failed
+func (h *Handler) PayOrder(c *fiber.Ctx) error {
+	// Get user ID from context (set by the Protected middleware)
+	userID := c.Locals("userID").(int64)
+
+	// Get order ID from URL parameter
+	id := c.Params("id")
+	orderID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order ID",
+		})
+	}
+
+	// Parse request body
+	var req models.PayOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Check if order exists and belongs to user
+	order, err := h.Orders.Find(orderID)
+	if err != nil || order.UserID != userID {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Order not found",
+		})
+	}
+
+	// Only failed payments of open orders can be retried
+	if order.PaymentStatus != "failed" || order.OrderStatus == "cancelled" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Order payment cannot be retried",
+		})
+	}
+
+	// Authorize the payment again
+	payment, err := payments.Authorize(h.DB, orderID, order.PaymentMethod, order.TotalAmount, order.Currency, req.PaymentToken)
+	if err != nil {
+		log.Printf("Failed to authorize payment for order %d: %v", orderID, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Payment provider unavailable, please retry the payment",
+		})
+	}
+	if payment.Status == payments.StatusFailed {
+		return c.Status(fiber.StatusPaymentRequired).JSON(fiber.Map{
+			"error":          "Payment was declined",
+			"payment_status": "failed",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":        "Payment accepted",
+		"payment_status": h.orderPaymentStatus(orderID),
 	})
 }
+
+// actorFromContext identifies the signed in user as the actor of a status change
+func actorFromContext(c *fiber.Ctx) orderstatus.Actor {
+	return orderstatus.Actor{
+		UserID: c.Locals("userID").(int64),
+		Name:   c.Locals("role").(string),
+	}
+}
+
+// capturePayment captures an order's payment after it has moved to a status
+// that requires it: authorized payments when it ships, pending ones such as
+// cash on delivery when it is delivered. It runs once the move is committed, so
+// that a payment is never collected for a move that failed, and returns an HTTP
+// status and message when the capture fails.
+func (h *Handler) capturePayment(orderID int64, paymentStatus, orderStatus string) (int, string) {
+	fulfilling := orderStatus == "partially_shipped" || orderStatus == "shipped" || orderStatus == "delivered"
+	if (fulfilling && paymentStatus == "authorized") || (orderStatus == "delivered" && paymentStatus == "pending") {
+		payment, err := payments.Capture(h.DB, orderID)
+		if err != nil {
+			log.Printf("Failed to capture payment for order %d: %v", orderID, err)
+			return fiber.StatusBadGateway, "Order updated but the payment capture failed"
+		}
+		if payment.Status != payments.StatusCaptured {
+			return fiber.StatusPaymentRequired, "Order updated but the payment capture was declined"
+		}
+	}
+	return 0, ""
+}
+
+// orderPaymentStatus returns the current payment status of an order
+func (h *Handler) orderPaymentStatus(orderID int64) string {
+	order, err := h.Orders.Find(orderID)
+	if err != nil {
+		return ""
+	}
+	return order.PaymentStatus
+}
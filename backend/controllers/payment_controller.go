@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"backend/orderstatus"
+	"backend/payments"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PaymentWebhook receives status callbacks from a payment provider
+func (h *Handler) PaymentWebhook(c *fiber.Ctx) error {
+	// Verify and apply the callback
+	payment, err := payments.HandleWebhook(h.DB, c.Params("provider"), c.Body(), c.Get("X-Payment-Signature"))
+	switch err {
+	case nil:
+	case payments.ErrUnknownProvider:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Unknown payment provider",
+		})
+	case payments.ErrWebhooksUnsupported:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Payment provider does not send webhooks",
+		})
+	case payments.ErrInvalidSignature:
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid signature",
+		})
+	case payments.ErrWebhookSecretUnset:
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Payment webhooks are not configured",
+		})
+	case payments.ErrAmountMismatch:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Amount does not match the payment",
+		})
+	case payments.ErrUnknownTransaction:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Unknown transaction",
+		})
+	default:
+		if _, ok := err.(*orderstatus.TransitionError); ok {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		log.Printf("Failed to handle payment webhook: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid webhook",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":  "Webhook processed",
+		"order_id": payment.OrderID,
+	})
+}
@@ -1,17 +1,17 @@
 package controllers
 
 import (
-	"backend/database"
 	"backend/models"
-	"database/sql"
+	"backend/repository"
+	"backend/search"
+	"log"
 	"strconv"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // CreateProduct handles product creation
-func CreateProduct(c *fiber.Ctx) error {
+func (h *Handler) CreateProduct(c *fiber.Ctx) error {
 	// Parse request body
 	var req models.CreateProductRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -26,36 +26,35 @@ func CreateProduct(c *fiber.Ctx) error {
 			"error": "Name and price are required",
 		})
 	}
+	if req.Weight < 0 || req.Length < 0 || req.Width < 0 || req.Height < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Weight and dimensions cannot be negative",
+		})
+	}
 
-	// Create the product
-	result, err := database.DB.Exec(
-		"INSERT INTO products (name, description, category_id, base_price, discount_percentage, featured, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-		req.Name,
-		req.Description,
-		req.CategoryID,
-		req.BasePrice,
-		req.DiscountPercentage,
-		req.Featured,
-		time.Now(),
-		time.Now(),
-	)
+	// Create the product with a unique slug from its name
+	product := productFromRequest(req)
+	productID, err := h.Products.Create(&product)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create product",
 		})
 	}
 
-	// Get the product ID
-	productID, _ := result.LastInsertId()
+	// Add the product to the search index
+	if err := search.IndexProduct(h.DB, productID); err != nil {
+		log.Printf("Failed to index product %d: %v", productID, err)
+	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Product created successfully",
 		"id":      productID,
+		"slug":    product.Slug,
 	})
 }
 
 // GetAllProducts returns all products
-func GetAllProducts(c *fiber.Ctx) error {
+func (h *Handler) GetAllProducts(c *fiber.Ctx) error {
 	// Parse query parameters
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "10"))
@@ -67,71 +66,31 @@ func GetAllProducts(c *fiber.Ctx) error {
 	}
 	offset := (page - 1) * limit
 
-	// Query to get products
-	rows, err := database.DB.Query(`
-		SELECT p.id, p.name, p.description, p.category_id, p.base_price, 
-			   p.discount_percentage, p.featured, p.created_at, p.updated_at,
-			   IFNULL(c.name, 'Uncategorized') as category_name
-		FROM products p
-		LEFT JOIN categories c ON p.category_id = c.id
-		ORDER BY p.id DESC
-		LIMIT ? OFFSET ?`,
-		limit, offset)
+	// Prices are shown in the currency asked for
+	exchange, err := h.requestExchange(c)
+	if err != nil {
+		return exchangeError(c, err)
+	}
+
+	// Build filters and sorting from the query parameters
+	filter, err := productFilter(c, exchange)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	filter.Limit, filter.Offset = limit, offset
+
+	// Get the page of products and the total for pagination
+	products, total, err := h.Products.List(filter)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
 		})
 	}
-	defer rows.Close()
-
-	products := []map[string]interface{}{}
-	for rows.Next() {
-		var product models.Product
-		var categoryName string
-		err := rows.Scan(
-			&product.ID, &product.Name, &product.Description, &product.CategoryID,
-			&product.BasePrice, &product.DiscountPercentage, &product.Featured,
-			&product.CreatedAt, &product.UpdatedAt, &categoryName)
-		if err != nil {
-			continue
-		}
-
-		// Calculate final price
-		finalPrice := product.BasePrice * (1 - product.DiscountPercentage/100)
-
-		productMap := map[string]interface{}{
-			"id":                  product.ID,
-			"name":                product.Name,
-			"description":         product.Description,
-			"category_id":         product.CategoryID,
-			"category_name":       categoryName,
-			"base_price":          product.BasePrice,
-			"discount_percentage": product.DiscountPercentage,
-			"final_price":         finalPrice,
-			"featured":            product.Featured,
-			"created_at":          product.CreatedAt,
-			"updated_at":          product.UpdatedAt,
-		}
-
-		// Get primary image
-		var imageURL sql.NullString
-		database.DB.QueryRow(`
-			SELECT image_url FROM product_images 
-			WHERE product_id = ? AND is_primary = 1
-			LIMIT 1`, product.ID).Scan(&imageURL)
-
-		if imageURL.Valid {
-			productMap["primary_image"] = imageURL.String
-		} else {
-			productMap["primary_image"] = nil
-		}
-
-		products = append(products, productMap)
-	}
-
-	// Count total products for pagination
-	var total int
-	database.DB.QueryRow("SELECT COUNT(*) FROM products").Scan(&total)
+	for i := range products {
+		products[i].AverageRating = roundRating(products[i].AverageRating)
+	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"products": products,
@@ -145,7 +104,7 @@ func GetAllProducts(c *fiber.Ctx) error {
 }
 
 // GetProductByID returns a specific product by ID
-func GetProductByID(c *fiber.Ctx) error {
+func (h *Handler) GetProductByID(c *fiber.Ctx) error {
 	// Get the product ID from the URL parameter
 	id := c.Params("id")
 	productID, err := strconv.ParseInt(id, 10, 64)
@@ -155,22 +114,17 @@ func GetProductByID(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get the product from the database
-	var product models.Product
-	var categoryName string
-	err = database.DB.QueryRow(`
-		SELECT p.id, p.name, p.description, p.category_id, p.base_price, 
-			   p.discount_percentage, p.featured, p.created_at, p.updated_at,
-			   IFNULL(c.name, 'Uncategorized') as category_name
-		FROM products p
-		LEFT JOIN categories c ON p.category_id = c.id
-		WHERE p.id = ?`,
-		productID).Scan(
-		&product.ID, &product.Name, &product.Description, &product.CategoryID,
-		&product.BasePrice, &product.DiscountPercentage, &product.Featured,
-		&product.CreatedAt, &product.UpdatedAt, &categoryName)
-
-	if err == sql.ErrNoRows {
+	return h.sendProduct(c, productID, fiber.Map{})
+}
+
+// GetProductBySlug returns a specific product by its slug. Old slugs of renamed
+// products still resolve and carry a redirect hint to the current slug.
+func (h *Handler) GetProductBySlug(c *fiber.Ctx) error {
+	slug := c.Params("slug")
+
+	// Look up the product, falling back to the slug history
+	productID, currentSlug, err := h.Products.Resolve(slug)
+	if err == repository.ErrNotFound {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Product not found",
 		})
@@ -181,103 +135,42 @@ func GetProductByID(c *fiber.Ctx) error {
 		})
 	}
 
-	// Calculate final price
-	finalPrice := product.BasePrice * (1 - product.DiscountPercentage/100)
-
-	// Get all images
-	rows, err := database.DB.Query("SELECT id, product_id, image_url, is_primary, created_at FROM product_images WHERE product_id = ?", productID)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch product images",
-		})
-	}
-	defer rows.Close()
-
-	var images []models.ProductImage
-	for rows.Next() {
-		var image models.ProductImage
-		if err := rows.Scan(&image.ID, &image.ProductID, &image.ImageURL, &image.IsPrimary, &image.CreatedAt); err == nil {
-			images = append(images, image)
-		}
+	extra := fiber.Map{}
+	if currentSlug != slug {
+		extra["redirect_slug"] = currentSlug
 	}
+	return h.sendProduct(c, productID, extra)
+}
 
-	// Get all colors
-	rows, err = database.DB.Query("SELECT id, product_id, color_name, color_hex, created_at FROM product_colors WHERE product_id = ?", productID)
+// sendProduct responds with a product and all its associated data,
+// merged with any extra response fields
+func (h *Handler) sendProduct(c *fiber.Ctx, productID int64, extra fiber.Map) error {
+	// Prices are shown in the currency asked for
+	exchange, err := h.requestExchange(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch product colors",
-		})
-	}
-	defer rows.Close()
-
-	var colors []models.ProductColor
-	for rows.Next() {
-		var color models.ProductColor
-		if err := rows.Scan(&color.ID, &color.ProductID, &color.ColorName, &color.ColorHex, &color.CreatedAt); err == nil {
-			colors = append(colors, color)
-		}
+		return exchangeError(c, err)
 	}
 
-	// Get all sizes
-	rows, err = database.DB.Query("SELECT id, product_id, size_name, created_at FROM product_sizes WHERE product_id = ?", productID)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch product sizes",
+	// Get the product with its images, colors, sizes and inventory
+	product, err := h.Products.Find(productID, exchange)
+	if err == repository.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found",
 		})
 	}
-	defer rows.Close()
-
-	var sizes []models.ProductSize
-	for rows.Next() {
-		var size models.ProductSize
-		if err := rows.Scan(&size.ID, &size.ProductID, &size.SizeName, &size.CreatedAt); err == nil {
-			sizes = append(sizes, size)
-		}
-	}
-
-	// Get inventory
-	rows, err = database.DB.Query("SELECT color_id, size_id, quantity FROM product_inventory WHERE product_id = ?", productID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch product inventory",
+			"error": "Database error",
 		})
 	}
-	defer rows.Close()
-
-	var inventory []models.InventoryItem
-	for rows.Next() {
-		var item models.InventoryItem
-		if err := rows.Scan(&item.ColorID, &item.SizeID, &item.Quantity); err == nil {
-			inventory = append(inventory, item)
-		}
-	}
-
-	// Combine all data into product response
-	response := models.ProductResponse{
-		ID:                 product.ID,
-		Name:               product.Name,
-		Description:        product.Description,
-		CategoryID:         product.CategoryID,
-		CategoryName:       categoryName,
-		BasePrice:          product.BasePrice,
-		DiscountPercentage: product.DiscountPercentage,
-		FinalPrice:         finalPrice,
-		Featured:           product.Featured,
-		Images:             images,
-		Colors:             colors,
-		Sizes:              sizes,
-		Inventory:          inventory,
-		CreatedAt:          product.CreatedAt,
-		UpdatedAt:          product.UpdatedAt,
-	}
+	product.AverageRating = roundRating(product.AverageRating)
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"product": response,
-	})
+	extra["product"] = product
+	return c.Status(fiber.StatusOK).JSON(extra)
 }
 
 // UpdateProduct updates a product
-func UpdateProduct(c *fiber.Ctx) error {
+func (h *Handler) UpdateProduct(c *fiber.Ctx) error {
 	// Get the product ID from the URL parameter
 	id := c.Params("id")
 	productID, err := strconv.ParseInt(id, 10, 64)
@@ -288,8 +181,7 @@ func UpdateProduct(c *fiber.Ctx) error {
 	}
 
 	// Check if product exists
-	var exists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)", productID).Scan(&exists)
+	exists, err := h.Products.Exists(productID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
@@ -315,40 +207,40 @@ func UpdateProduct(c *fiber.Ctx) error {
 			"error": "Name and price are required",
 		})
 	}
+	if req.Weight < 0 || req.Length < 0 || req.Width < 0 || req.Height < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Weight and dimensions cannot be negative",
+		})
+	}
 
-	// Update the product
-	_, err = database.DB.Exec(
-		`UPDATE products SET 
-			name = ?, 
-			description = ?, 
-			category_id = ?, 
-			base_price = ?, 
-			discount_percentage = ?, 
-			featured = ?, 
-			updated_at = ? 
-		WHERE id = ?`,
-		req.Name,
-		req.Description,
-		req.CategoryID,
-		req.BasePrice,
-		req.DiscountPercentage,
-		req.Featured,
-		time.Now(),
-		productID,
-	)
+	// Update the product, renaming it gives it a new slug
+	product := productFromRequest(req)
+	product.ID = productID
+	err = h.Products.Update(&product)
+	if err == repository.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found",
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update product",
 		})
 	}
 
+	// Refresh the product in the search index
+	if err := search.IndexProduct(h.DB, productID); err != nil {
+		log.Printf("Failed to index product %d: %v", productID, err)
+	}
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Product updated successfully",
+		"slug":    product.Slug,
 	})
 }
 
 // DeleteProduct deletes a product
-func DeleteProduct(c *fiber.Ctx) error {
+func (h *Handler) DeleteProduct(c *fiber.Ctx) error {
 	// Get the product ID from the URL parameter
 	id := c.Params("id")
 	productID, err := strconv.ParseInt(id, 10, 64)
@@ -359,8 +251,7 @@ func DeleteProduct(c *fiber.Ctx) error {
 	}
 
 	// Check if product exists
-	var exists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)", productID).Scan(&exists)
+	exists, err := h.Products.Exists(productID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
@@ -373,14 +264,34 @@ func DeleteProduct(c *fiber.Ctx) error {
 	}
 
 	// Delete the product
-	_, err = database.DB.Exec("DELETE FROM products WHERE id = ?", productID)
-	if err != nil {
+	if err := h.Products.Delete(productID); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete product",
 		})
 	}
 
+	// Remove the product from the search index
+	if err := search.RemoveProduct(h.DB, productID); err != nil {
+		log.Printf("Failed to remove product %d from search index: %v", productID, err)
+	}
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Product deleted successfully",
 	})
-} 
\ No newline at end of file
+} 
+
+// productFromRequest returns the product a create or update request describes
+func productFromRequest(req models.CreateProductRequest) models.Product {
+	return models.Product{
+		Name:               req.Name,
+		Description:        req.Description,
+		CategoryID:         req.CategoryID,
+		BasePrice:          req.BasePrice,
+		DiscountPercentage: req.DiscountPercentage,
+		Featured:           req.Featured,
+		Weight:             req.Weight,
+		Length:             req.Length,
+		Width:              req.Width,
+		Height:             req.Height,
+	}
+}
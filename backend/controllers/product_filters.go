@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"backend/money"
+	"backend/pricing"
+	"backend/repository"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// productFilter turns the product list query parameters into a filter, with
+// prices compared in the exchange currency
+func productFilter(c *fiber.Ctx, exchange *pricing.Exchange) (repository.ProductFilter, error) {
+	filter := repository.ProductFilter{
+		Colors:   splitQueryList(c.Query("color")),
+		Sizes:    splitQueryList(c.Query("size")),
+		Featured: c.QueryBool("featured"),
+		InStock:  c.QueryBool("in_stock"),
+		OnSale:   c.QueryBool("on_sale"),
+		Query:    strings.TrimSpace(c.Query("q")),
+		Sort:     c.Query("sort", "newest"),
+		Exchange: exchange,
+	}
+
+	if value := c.Query("category_id"); value != "" {
+		categoryID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return filter, errors.New("Invalid category ID")
+		}
+		filter.CategoryID = categoryID
+	}
+
+	if value := c.Query("min_price"); value != "" {
+		minPrice, err := money.Parse(value)
+		if err != nil || minPrice < 0 {
+			return filter, errors.New("Invalid minimum price")
+		}
+		filter.MinPrice = &minPrice
+	}
+
+	if value := c.Query("max_price"); value != "" {
+		maxPrice, err := money.Parse(value)
+		if err != nil || maxPrice < 0 {
+			return filter, errors.New("Invalid maximum price")
+		}
+		filter.MaxPrice = &maxPrice
+	}
+
+	if !repository.IsProductSort(filter.Sort) {
+		return filter, errors.New("Invalid sort option")
+	}
+	return filter, nil
+}
+
+// splitQueryList splits a comma separated query value into lowercase names
+func splitQueryList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -1,15 +1,14 @@
 package controllers
 
 import (
-	"backend/database"
+	"backend/repository"
 	"strconv"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // AddProductColor adds a new color to a product
-func AddProductColor(c *fiber.Ctx) error {
+func (h *Handler) AddProductColor(c *fiber.Ctx) error {
 	// Get the product ID from the URL parameter
 	productID, err := strconv.ParseInt(c.Params("id"), 10, 64)
 	if err != nil {
@@ -19,8 +18,7 @@ func AddProductColor(c *fiber.Ctx) error {
 	}
 
 	// Check if product exists
-	var exists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)", productID).Scan(&exists)
+	exists, err := h.Products.Exists(productID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
@@ -51,18 +49,13 @@ func AddProductColor(c *fiber.Ctx) error {
 	}
 
 	// Insert the color
-	result, err := database.DB.Exec(
-		"INSERT INTO product_colors (product_id, color_name, color_hex, created_at) VALUES (?, ?, ?, ?)",
-		productID, color.ColorName, color.ColorHex, time.Now())
+	colorID, err := h.Products.AddColor(productID, color.ColorName, color.ColorHex)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to add color",
 		})
 	}
 
-	// Get the color ID
-	colorID, _ := result.LastInsertId()
-
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Color added successfully",
 		"id":      colorID,
@@ -70,7 +63,7 @@ func AddProductColor(c *fiber.Ctx) error {
 }
 
 // AddProductSize adds a new size to a product
-func AddProductSize(c *fiber.Ctx) error {
+func (h *Handler) AddProductSize(c *fiber.Ctx) error {
 	// Get the product ID from the URL parameter
 	productID, err := strconv.ParseInt(c.Params("id"), 10, 64)
 	if err != nil {
@@ -80,8 +73,7 @@ func AddProductSize(c *fiber.Ctx) error {
 	}
 
 	// Check if product exists
-	var exists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)", productID).Scan(&exists)
+	exists, err := h.Products.Exists(productID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
@@ -111,18 +103,13 @@ func AddProductSize(c *fiber.Ctx) error {
 	}
 
 	// Insert the size
-	result, err := database.DB.Exec(
-		"INSERT INTO product_sizes (product_id, size_name, created_at) VALUES (?, ?, ?)",
-		productID, size.SizeName, time.Now())
+	sizeID, err := h.Products.AddSize(productID, size.SizeName)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to add size",
 		})
 	}
 
-	// Get the size ID
-	sizeID, _ := result.LastInsertId()
-
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Size added successfully",
 		"id":      sizeID,
@@ -130,7 +117,7 @@ func AddProductSize(c *fiber.Ctx) error {
 }
 
 // UpdateInventory updates the inventory for a product variant
-func UpdateInventory(c *fiber.Ctx) error {
+func (h *Handler) UpdateInventory(c *fiber.Ctx) error {
 	// Get the product ID from the URL parameter
 	productID, err := strconv.ParseInt(c.Params("id"), 10, 64)
 	if err != nil {
@@ -140,8 +127,7 @@ func UpdateInventory(c *fiber.Ctx) error {
 	}
 
 	// Check if product exists
-	var exists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)", productID).Scan(&exists)
+	exists, err := h.Products.Exists(productID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
@@ -173,66 +159,28 @@ func UpdateInventory(c *fiber.Ctx) error {
 	}
 
 	// Check if color and size exist for this product
-	var colorExists, sizeExists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM product_colors WHERE id = ? AND product_id = ?)", inventory.ColorID, productID).Scan(&colorExists)
+	colorExists, err := h.Products.HasColor(productID, inventory.ColorID)
 	if err != nil || !colorExists {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid color for this product",
 		})
 	}
 
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM product_sizes WHERE id = ? AND product_id = ?)", inventory.SizeID, productID).Scan(&sizeExists)
+	sizeExists, err := h.Products.HasSize(productID, inventory.SizeID)
 	if err != nil || !sizeExists {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid size for this product",
 		})
 	}
 
-	// Check if inventory entry exists
-	var inventoryExists bool
-	var inventoryID int64
-	err = database.DB.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM product_inventory WHERE product_id = ? AND color_id = ? AND size_id = ?)",
-		productID, inventory.ColorID, inventory.SizeID).Scan(&inventoryExists)
-
+	// Update or insert inventory
+	inventoryID, err := h.Products.SetStock(productID, inventory.ColorID, inventory.SizeID, inventory.Quantity)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Database error",
+			"error": "Failed to update inventory",
 		})
 	}
 
-	// Update or insert inventory
-	if inventoryExists {
-		// Update existing inventory
-		_, err = database.DB.Exec(
-			"UPDATE product_inventory SET quantity = ?, updated_at = ? WHERE product_id = ? AND color_id = ? AND size_id = ?",
-			inventory.Quantity, time.Now(), productID, inventory.ColorID, inventory.SizeID)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update inventory",
-			})
-		}
-
-		// Get the inventory ID
-		database.DB.QueryRow(
-			"SELECT id FROM product_inventory WHERE product_id = ? AND color_id = ? AND size_id = ?",
-			productID, inventory.ColorID, inventory.SizeID).Scan(&inventoryID)
-
-	} else {
-		// Insert new inventory
-		result, err := database.DB.Exec(
-			"INSERT INTO product_inventory (product_id, color_id, size_id, quantity, updated_at) VALUES (?, ?, ?, ?, ?)",
-			productID, inventory.ColorID, inventory.SizeID, inventory.Quantity, time.Now())
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to add inventory",
-			})
-		}
-
-		// Get the inventory ID
-		inventoryID, _ = result.LastInsertId()
-	}
-
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Inventory updated successfully",
 		"id":      inventoryID,
@@ -240,7 +188,7 @@ func UpdateInventory(c *fiber.Ctx) error {
 }
 
 // AddProductImage adds a new image to a product
-func AddProductImage(c *fiber.Ctx) error {
+func (h *Handler) AddProductImage(c *fiber.Ctx) error {
 	// Get the product ID from the URL parameter
 	productID, err := strconv.ParseInt(c.Params("id"), 10, 64)
 	if err != nil {
@@ -250,8 +198,7 @@ func AddProductImage(c *fiber.Ctx) error {
 	}
 
 	// Check if product exists
-	var exists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)", productID).Scan(&exists)
+	exists, err := h.Products.Exists(productID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
@@ -281,46 +228,14 @@ func AddProductImage(c *fiber.Ctx) error {
 		})
 	}
 
-	// Start a transaction
-	tx, err := database.DB.Begin()
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to start transaction",
-		})
-	}
-
-	// If this is the primary image, update all other images to not be primary
-	if image.IsPrimary {
-		_, err = tx.Exec("UPDATE product_images SET is_primary = 0 WHERE product_id = ?", productID)
-		if err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update existing images",
-			})
-		}
-	}
-
-	// Insert the image
-	result, err := tx.Exec(
-		"INSERT INTO product_images (product_id, image_url, is_primary, created_at) VALUES (?, ?, ?, ?)",
-		productID, image.ImageURL, image.IsPrimary, time.Now())
+	// Insert the image, a primary image replaces the product's primary image
+	imageID, err := h.Products.AddImage(productID, image.ImageURL, image.IsPrimary)
 	if err != nil {
-		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to add image",
 		})
 	}
 
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to commit transaction",
-		})
-	}
-
-	// Get the image ID
-	imageID, _ := result.LastInsertId()
-
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Image added successfully",
 		"id":      imageID,
@@ -328,7 +243,7 @@ func AddProductImage(c *fiber.Ctx) error {
 }
 
 // DeleteProductColor deletes a color from a product
-func DeleteProductColor(c *fiber.Ctx) error {
+func (h *Handler) DeleteProductColor(c *fiber.Ctx) error {
 	// Get the product ID and color ID from the URL parameters
 	productID, err := strconv.ParseInt(c.Params("id"), 10, 64)
 	if err != nil {
@@ -344,22 +259,13 @@ func DeleteProductColor(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if color exists for this product
-	var exists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM product_colors WHERE id = ? AND product_id = ?)", colorID, productID).Scan(&exists)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Database error",
-		})
-	}
-	if !exists {
+	// Delete the color
+	err = h.Products.DeleteColor(productID, colorID)
+	if err == repository.ErrNotFound {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Color not found for this product",
 		})
 	}
-
-	// Delete the color
-	_, err = database.DB.Exec("DELETE FROM product_colors WHERE id = ? AND product_id = ?", colorID, productID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete color",
@@ -372,7 +278,7 @@ func DeleteProductColor(c *fiber.Ctx) error {
 }
 
 // DeleteProductSize deletes a size from a product
-func DeleteProductSize(c *fiber.Ctx) error {
+func (h *Handler) DeleteProductSize(c *fiber.Ctx) error {
 	// Get the product ID and size ID from the URL parameters
 	productID, err := strconv.ParseInt(c.Params("id"), 10, 64)
 	if err != nil {
@@ -388,22 +294,13 @@ func DeleteProductSize(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if size exists for this product
-	var exists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM product_sizes WHERE id = ? AND product_id = ?)", sizeID, productID).Scan(&exists)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Database error",
-		})
-	}
-	if !exists {
+	// Delete the size
+	err = h.Products.DeleteSize(productID, sizeID)
+	if err == repository.ErrNotFound {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Size not found for this product",
 		})
 	}
-
-	// Delete the size
-	_, err = database.DB.Exec("DELETE FROM product_sizes WHERE id = ? AND product_id = ?", sizeID, productID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete size",
@@ -416,7 +313,7 @@ func DeleteProductSize(c *fiber.Ctx) error {
 }
 
 // DeleteProductImage deletes an image from a product
-func DeleteProductImage(c *fiber.Ctx) error {
+func (h *Handler) DeleteProductImage(c *fiber.Ctx) error {
 	// Get the product ID and image ID from the URL parameters
 	productID, err := strconv.ParseInt(c.Params("id"), 10, 64)
 	if err != nil {
@@ -432,34 +329,19 @@ func DeleteProductImage(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if image exists for this product
-	var exists bool
-	var isPrimary bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM product_images WHERE id = ? AND product_id = ?), is_primary FROM product_images WHERE id = ?", imageID, productID, imageID).Scan(&exists, &isPrimary)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Database error",
-		})
-	}
-	if !exists {
+	// Delete the image, another image takes over if it was the primary one
+	err = h.Products.DeleteImage(productID, imageID)
+	if err == repository.ErrNotFound {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Image not found for this product",
 		})
 	}
-
-	// Delete the image
-	_, err = database.DB.Exec("DELETE FROM product_images WHERE id = ? AND product_id = ?", imageID, productID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete image",
 		})
 	}
 
-	// If this was the primary image, set another image as primary
-	if isPrimary {
-		database.DB.Exec("UPDATE product_images SET is_primary = 1 WHERE product_id = ? ORDER BY id LIMIT 1", productID)
-	}
-
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Image deleted successfully",
 	})
@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"backend/accounts"
+	"backend/database"
+	"backend/models"
+	"backend/permissions"
+	"backend/repository"
+	"backend/sessions"
+	"backend/utils"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// UpdateProfile changes the current user's name or email. A new email has to
+// be verified again, and changing it needs the current password.
+func (h *Handler) UpdateProfile(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	// Parse request body
+	var request models.UpdateProfileRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Get the user from the database
+	user, err := h.Users.FindByID(userID)
+	if err == repository.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	// Empty fields keep their value
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		name = user.Name
+	}
+	email := strings.TrimSpace(request.Email)
+	if email == "" {
+		email = user.Email
+	}
+	emailChanged := email != user.Email
+
+	if emailChanged {
+		// Whoever holds a stolen token cannot take the account over by its email
+		if status, message := h.checkUserPassword(c, user, request.CurrentPassword, "Current password is incorrect"); message != "" {
+			return c.Status(status).JSON(fiber.Map{
+				"error": message,
+			})
+		}
+
+		exists, err := h.Users.EmailExists(email)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+		if exists {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "User with this email already exists",
+			})
+		}
+	}
+
+	// Update the profile
+	if err := h.Users.UpdateProfile(userID, name, email); err != nil {
+		if database.IsUniqueViolation(err) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "User with this email already exists",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update profile",
+		})
+	}
+	user.Name = name
+
+	message := "Profile updated successfully"
+	if emailChanged {
+		user.Email = email
+		user.EmailVerifiedAt = nil
+
+		// Ask the user to confirm the new address
+		message = "Profile updated, please verify your new email address"
+		if err := accounts.SendVerification(h.DB, userID, name, email); err != nil {
+			log.Printf("Failed to send verification to user %d: %v", userID, err)
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": message,
+		"user":    user.ToResponse(),
+	})
+}
+
+// ChangePassword sets a new password for the current user, logging out every
+// other session
+func (h *Handler) ChangePassword(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+	sessionID := c.Locals("sessionID").(string)
+
+	// Parse request body
+	var request models.ChangePasswordRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if request.CurrentPassword == "" || request.NewPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Current and new password are required",
+		})
+	}
+
+	// Get the user from the database
+	user, err := h.Users.FindByID(userID)
+	if err == repository.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	// Check the current password
+	if status, message := h.checkUserPassword(c, user, request.CurrentPassword, "Current password is incorrect"); message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	// Hash the new password
+	hashedPassword, err := utils.HashPassword(request.NewPassword)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to hash password",
+		})
+	}
+
+	if err := h.Users.SetPassword(userID, hashedPassword); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update password",
+		})
+	}
+
+	// Other devices have to log in with the new password
+	if err := sessions.RevokeOthers(h.DB, userID, sessionID); err != nil {
+		log.Printf("Failed to revoke sessions of user %d: %v", userID, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Password changed successfully",
+	})
+}
+
+// DeleteAccount deletes the current user's account after checking the
+// password. Past orders are kept without the customer's personal data.
+func (h *Handler) DeleteAccount(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	// Parse request body
+	var request models.DeleteAccountRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Get the user from the database
+	user, err := h.Users.FindByID(userID)
+	if err == repository.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	// Check the password
+	if status, message := h.checkUserPassword(c, user, request.Password, "Password is incorrect"); message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	// Staff accounts are removed from their role by an administrator first,
+	// so that the store cannot lose its last administrator
+	if user.Role != permissions.RoleCustomer {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Staff accounts cannot be deleted, ask an administrator to change your role first",
+		})
+	}
+
+	err = h.Users.Anonymise(userID)
+	if err == repository.ErrOpenOrders {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Accounts with open orders cannot be deleted until the orders are delivered or cancelled",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete account",
+		})
+	}
+
+	// Access tokens of the account are rejected from now on
+	if err := sessions.RevokeAll(h.DB, userID); err != nil {
+		log.Printf("Failed to revoke sessions of user %d: %v", userID, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Account deleted successfully",
+	})
+}
@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/promotions"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetAllPromotions returns every promotion with its usage (admin only)
+func (h *Handler) GetAllPromotions(c *fiber.Ctx) error {
+	list, err := promotions.List(h.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"promotions": list,
+	})
+}
+
+// GetPromotion returns a specific promotion (admin only)
+func (h *Handler) GetPromotion(c *fiber.Ctx) error {
+	// Get promotion ID from URL parameter
+	promotionID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid promotion ID",
+		})
+	}
+
+	promotion, err := promotions.Get(h.DB, promotionID)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Promotion not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"promotion": promotion,
+	})
+}
+
+// CreatePromotion creates a new promotion (admin only)
+func (h *Handler) CreatePromotion(c *fiber.Ctx) error {
+	// Parse and validate request body
+	req, message := parsePromotionRequest(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	isActive := req.IsActive == nil || *req.IsActive
+
+	// Create the promotion
+	var promotionID int64
+	err := h.DB.QueryRow(
+		`INSERT INTO promotions (code, description, type, value, buy_quantity, get_quantity, category_id, product_id,
+			min_subtotal, starts_at, ends_at, usage_limit, per_user_limit, is_active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		req.Code, req.Description, req.Type, req.Value, req.BuyQuantity, req.GetQuantity, req.CategoryID, req.ProductID,
+		req.MinSubtotal, req.StartsAt, req.EndsAt, req.UsageLimit, req.PerUserLimit, isActive, time.Now(), time.Now()).Scan(&promotionID)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Coupon code already exists",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create promotion",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Promotion created successfully",
+		"id":      promotionID,
+	})
+}
+
+// UpdatePromotion replaces a promotion's rule (admin only)
+func (h *Handler) UpdatePromotion(c *fiber.Ctx) error {
+	// Get promotion ID from URL parameter
+	promotionID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid promotion ID",
+		})
+	}
+
+	// Parse and validate request body
+	req, message := parsePromotionRequest(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	isActive := req.IsActive == nil || *req.IsActive
+
+	// Update the promotion
+	result, err := h.DB.Exec(
+		`UPDATE promotions SET code = ?, description = ?, type = ?, value = ?, buy_quantity = ?, get_quantity = ?,
+			category_id = ?, product_id = ?, min_subtotal = ?, starts_at = ?, ends_at = ?, usage_limit = ?,
+			per_user_limit = ?, is_active = ?, updated_at = ?
+		WHERE id = ?`,
+		req.Code, req.Description, req.Type, req.Value, req.BuyQuantity, req.GetQuantity,
+		req.CategoryID, req.ProductID, req.MinSubtotal, req.StartsAt, req.EndsAt, req.UsageLimit,
+		req.PerUserLimit, isActive, time.Now(), promotionID)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Coupon code already exists",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update promotion",
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Promotion not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Promotion updated successfully",
+	})
+}
+
+// DeletePromotion deletes a promotion (admin only). Orders keep the coupon code and discount they used.
+func (h *Handler) DeletePromotion(c *fiber.Ctx) error {
+	// Get promotion ID from URL parameter
+	promotionID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid promotion ID",
+		})
+	}
+
+	// Remove it from carts, then delete it
+	_, err = h.DB.Exec("DELETE FROM cart_coupons WHERE promotion_id = ?", promotionID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete promotion",
+		})
+	}
+
+	result, err := h.DB.Exec("DELETE FROM promotions WHERE id = ?", promotionID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete promotion",
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Promotion not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Promotion deleted successfully",
+	})
+}
+
+// parsePromotionRequest parses and validates a promotion request body,
+// returning an error message for invalid requests
+func parsePromotionRequest(c *fiber.Ctx) (*models.PromotionRequest, string) {
+	var req models.PromotionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, "Invalid request body"
+	}
+
+	// Validate input
+	req.Code = promotions.NormalizeCode(req.Code)
+	if req.Code == "" {
+		return nil, "Coupon code is required"
+	}
+	if err := promotions.ValidateRule(&req); err != nil {
+		return nil, err.Error()
+	}
+
+	return &req, ""
+}
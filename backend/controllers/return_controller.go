@@ -0,0 +1,387 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/payments"
+	"backend/permissions"
+	"backend/returns"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestReturn lets a customer request a return for items of a delivered order
+func (h *Handler) RequestReturn(c *fiber.Ctx) error {
+	// Get user ID from context (set by the Protected middleware)
+	userID := c.Locals("userID").(int64)
+
+	// Get order ID from URL parameter
+	orderID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order ID",
+		})
+	}
+
+	// Parse request body
+	var req models.ReturnRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Validate input
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Reason is required",
+		})
+	}
+
+	// Check if order exists and belongs to user
+	order, err := h.Orders.Find(orderID)
+	if err != nil || order.UserID != userID {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Order not found",
+		})
+	}
+	if order.OrderStatus != "delivered" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only delivered orders can be returned",
+		})
+	}
+
+	// Check the return window
+	deliveredAt, err := returns.DeliveredAt(h.DB, orderID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if time.Since(deliveredAt) > returns.Window() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The return window for this order has closed",
+		})
+	}
+
+	// Start a transaction
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction",
+		})
+	}
+
+	// Create the return
+	returnID, err := returns.Create(tx, orderID, userID, req.Reason, req.Items)
+	if _, ok := err.(returns.RuleError); ok {
+		tx.Rollback()
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create return",
+		})
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Return requested successfully",
+		"id":      returnID,
+	})
+}
+
+// GetOrderReturns lists the returns of an order
+func (h *Handler) GetOrderReturns(c *fiber.Ctx) error {
+	// Get user ID from context (set by the Protected middleware)
+	userID := c.Locals("userID").(int64)
+
+	// Get order ID from URL parameter
+	orderID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order ID",
+		})
+	}
+
+	// Check if order exists and belongs to user (unless staff can read every order)
+	order, err := h.Orders.Find(orderID)
+	if err != nil || (order.UserID != userID && !h.hasPermission(c, permissions.OrdersRead)) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Order not found",
+		})
+	}
+
+	list, err := returns.ForOrder(h.DB, orderID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"returns": list,
+	})
+}
+
+// GetAllReturns lists returns, optionally filtered by status (admin only)
+func (h *Handler) GetAllReturns(c *fiber.Ctx) error {
+	list, err := returns.List(h.DB, c.Query("status"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"returns": list,
+	})
+}
+
+// GetReturn returns a specific return with the value of its items (admin only)
+func (h *Handler) GetReturn(c *fiber.Ctx) error {
+	// Get return ID from URL parameter
+	returnID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid return ID",
+		})
+	}
+
+	ret, err := returns.Get(h.DB, returnID)
+	if err == returns.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Return not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	value, err := returns.Value(h.DB, returnID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"return":      ret,
+		"items_value": value,
+	})
+}
+
+// ApproveReturn approves a requested return (admin only)
+func (h *Handler) ApproveReturn(c *fiber.Ctx) error {
+	return h.reviewReturn(c, true)
+}
+
+// RejectReturn rejects a requested return (admin only)
+func (h *Handler) RejectReturn(c *fiber.Ctx) error {
+	return h.reviewReturn(c, false)
+}
+
+// reviewReturn approves or rejects a return with an optional note
+func (h *Handler) reviewReturn(c *fiber.Ctx, approve bool) error {
+	// Get return ID from URL parameter
+	returnID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid return ID",
+		})
+	}
+
+	// Parse request body, the note is optional
+	var req models.ReviewReturnRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	err = returns.Review(h.DB, returnID, approve, strings.TrimSpace(req.Note))
+	if err == returns.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Return not found",
+		})
+	}
+	if _, ok := err.(returns.RuleError); ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update return",
+		})
+	}
+
+	message := "Return rejected"
+	if approve {
+		message = "Return approved"
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": message,
+	})
+}
+
+// ReceiveReturn marks the goods of an approved return as received, optionally
+// restocking them, and refunds the customer (admin only). Receiving a return
+// again retries a refund that failed.
+func (h *Handler) ReceiveReturn(c *fiber.Ctx) error {
+	// Get return ID from URL parameter
+	returnID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid return ID",
+		})
+	}
+
+	// Parse request body
+	var req models.ReceiveReturnRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	// Get the return
+	ret, err := returns.Get(h.DB, returnID)
+	if err == returns.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Return not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	retry := ret.Status == returns.StatusReceived && ret.RefundState == returns.RefundFailed
+	if ret.Status == returns.StatusReceived && ret.RefundState == returns.RefundInProgress {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Return is already being refunded",
+		})
+	}
+	if ret.Status != returns.StatusApproved && !retry {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only approved returns can be received",
+		})
+	}
+
+	// Refund the value of the returned items unless another amount is given
+	amount, err := returns.Value(h.DB, returnID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if req.RefundAmount != nil {
+		amount = *req.RefundAmount
+	}
+	if amount < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Refund amount cannot be negative",
+		})
+	}
+	if amount > 0 {
+		refundable, err := payments.Refundable(h.DB, ret.OrderID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+		if amount > refundable {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":      "Refund amount exceeds what is left of the payment",
+				"refundable": refundable,
+			})
+		}
+	}
+
+	// Start a transaction
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction",
+		})
+	}
+	defer tx.Rollback()
+
+	// Mark the goods received, then claim the refund so that no other request
+	// refunds the return as well. A return without a refund is closed at once.
+	if !retry {
+		err = returns.Receive(tx, returnID, req.Restock, strings.TrimSpace(req.Note))
+	}
+	if err == nil {
+		err = returns.ClaimRefund(tx, returnID)
+	}
+	if err == nil && amount == 0 {
+		err = returns.RecordRefund(tx, returnID, 0)
+	}
+	if _, ok := err.(returns.RuleError); ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update return",
+		})
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	// Refund the customer, a failed refund can be retried by receiving the return again
+	if amount > 0 {
+		payment, err := payments.Refund(h.DB, ret.OrderID, amount)
+		if err != nil || payment.Status == payments.StatusFailed {
+			log.Printf("Failed to refund return %d: %v", returnID, err)
+			if err := returns.ReleaseRefund(h.DB, returnID); err != nil {
+				log.Printf("Failed to release refund of return %d: %v", returnID, err)
+			}
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error":          "Return received but the refund failed",
+				"payment_status": h.orderPaymentStatus(ret.OrderID),
+			})
+		}
+
+		// The refund stays claimed if it cannot be recorded, so it is never issued twice
+		if err := returns.RecordRefund(h.DB, returnID, amount); err != nil {
+			log.Printf("Failed to record refund of return %d: %v", returnID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":          "Refund issued but it could not be recorded on the return",
+				"payment_status": h.orderPaymentStatus(ret.OrderID),
+			})
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":        "Return received successfully",
+		"refund_amount":  amount,
+		"payment_status": h.orderPaymentStatus(ret.OrderID),
+	})
+}
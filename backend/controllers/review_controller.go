@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/permissions"
+	"backend/repository"
+	"math"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// roundRating rounds an average rating to one decimal place
+func roundRating(rating float64) float64 {
+	return math.Round(rating*10) / 10
+}
+
+// CreateReview adds a review for a product the user has received
+func (h *Handler) CreateReview(c *fiber.Ctx) error {
+	// Get user ID from context (set by the Protected middleware)
+	userID := c.Locals("userID").(int64)
+
+	// Get the product ID from the URL parameter
+	productID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	// Parse request body
+	var req models.ReviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Validate input
+	if req.Rating < 1 || req.Rating > 5 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Rating must be between 1 and 5",
+		})
+	}
+
+	// Check if product exists
+	exists, err := h.Products.Exists(productID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found",
+		})
+	}
+
+	// Only customers who have received the product can review it
+	purchased, err := h.Reviews.Received(userID, productID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if !purchased {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You can only review products from delivered orders",
+		})
+	}
+
+	// Check if the user has already reviewed this product
+	reviewed, err := h.Reviews.Reviewed(userID, productID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if reviewed {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "You have already reviewed this product",
+		})
+	}
+
+	// Create the review
+	reviewID, err := h.Reviews.Create(&models.Review{UserID: userID, ProductID: productID, Rating: req.Rating, Comment: req.Comment})
+	if database.IsUniqueViolation(err) {
+		// Another request reviewed the product since the check above
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "You have already reviewed this product",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create review",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Review created successfully",
+		"id":      reviewID,
+	})
+}
+
+// GetProductReviews returns the reviews of a product
+func (h *Handler) GetProductReviews(c *fiber.Ctx) error {
+	// Get the product ID from the URL parameter
+	productID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	// Parse query parameters
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+	offset := (page - 1) * limit
+
+	sort := c.Query("sort", "newest")
+	if !repository.IsReviewSort(sort) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid sort option",
+		})
+	}
+
+	// Check if product exists
+	exists, err := h.Products.Exists(productID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found",
+		})
+	}
+
+	// Get the reviews with their authors' names
+	reviews, err := h.Reviews.List(productID, sort, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	// The summary also carries the total used for pagination
+	summary, err := h.Reviews.Summary(productID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	summary.AverageRating = roundRating(summary.AverageRating)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"reviews": reviews,
+		"summary": summary,
+		"meta": fiber.Map{
+			"page":        page,
+			"limit":       limit,
+			"total":       summary.ReviewCount,
+			"total_pages": (summary.ReviewCount + limit - 1) / limit,
+		},
+	})
+}
+
+// UpdateReview updates the user's own review
+func (h *Handler) UpdateReview(c *fiber.Ctx) error {
+	// Get user ID from context (set by the Protected middleware)
+	userID := c.Locals("userID").(int64)
+
+	// Get the product ID and review ID from the URL parameters
+	productID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	reviewID, err := strconv.ParseInt(c.Params("reviewId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid review ID",
+		})
+	}
+
+	// Parse request body
+	var req models.ReviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Validate input
+	if req.Rating < 1 || req.Rating > 5 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Rating must be between 1 and 5",
+		})
+	}
+
+	// Update the review, provided it belongs to the user
+	review := models.Review{ID: reviewID, UserID: userID, ProductID: productID, Rating: req.Rating, Comment: req.Comment}
+	err = h.Reviews.Update(&review)
+	if err == repository.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Review not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update review",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Review updated successfully",
+	})
+}
+
+// DeleteReview deletes a review (owner or moderator)
+func (h *Handler) DeleteReview(c *fiber.Ctx) error {
+	// Get user ID from context (set by the Protected middleware)
+	userID := c.Locals("userID").(int64)
+
+	// Get the product ID and review ID from the URL parameters
+	productID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	reviewID, err := strconv.ParseInt(c.Params("reviewId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid review ID",
+		})
+	}
+
+	// Get the review owner
+	ownerID, err := h.Reviews.Author(productID, reviewID)
+	if err == repository.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Review not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	// Regular users can only delete their own reviews
+	if ownerID != userID && !h.hasPermission(c, permissions.ReviewsModerate) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Review not found",
+		})
+	}
+
+	// Delete the review
+	if err := h.Reviews.Delete(reviewID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete review",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Review deleted successfully",
+	})
+}
@@ -0,0 +1,326 @@
+package controllers
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/permissions"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// roleName is the format of role names, such as support_agent
+var roleName = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+
+// hasPermission reports whether the signed in user's role grants a permission.
+// Errors deny the permission.
+func (h *Handler) hasPermission(c *fiber.Ctx, permission string) bool {
+	userID := c.Locals("userID").(int64)
+	allowed, err := permissions.Has(h.DB, userID, permission)
+	if err != nil {
+		log.Printf("Failed to check permission %s of user %d: %v", permission, userID, err)
+		return false
+	}
+	return allowed
+}
+
+// GetPermissions lists every permission roles can grant (roles:manage)
+func (h *Handler) GetPermissions(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"permissions": permissions.All,
+	})
+}
+
+// GetAllRoles lists the roles with their permissions (roles:manage)
+func (h *Handler) GetAllRoles(c *fiber.Ctx) error {
+	roles, err := permissions.ListRoles(h.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"roles": roles,
+	})
+}
+
+// CreateRole creates a role (roles:manage)
+func (h *Handler) CreateRole(c *fiber.Ctx) error {
+	// Parse and validate request body
+	req, status, message := h.parseRoleRequest(c)
+	if message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	// Create the role with its permissions
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create role",
+		})
+	}
+	defer tx.Rollback()
+
+	var roleID int64
+	err = tx.QueryRow(
+		"INSERT INTO roles (name, description, created_at, updated_at) VALUES (?, ?, ?, ?) RETURNING id",
+		req.Name, req.Description, time.Now(), time.Now()).Scan(&roleID)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "A role with this name already exists",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create role",
+		})
+	}
+	if err := permissions.SetRolePermissions(tx, roleID, req.Permissions); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create role",
+		})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create role",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Role created successfully",
+		"id":      roleID,
+	})
+}
+
+// UpdateRole renames a role and replaces its permissions (roles:manage).
+// Users keep the role when it is renamed.
+func (h *Handler) UpdateRole(c *fiber.Ctx) error {
+	// Get role ID from URL parameter
+	roleID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid role ID",
+		})
+	}
+
+	// Parse and validate request body
+	req, status, message := h.parseRoleRequest(c)
+	if message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	role, err := permissions.FindRole(h.DB, roleID)
+	if err == permissions.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Role not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	// The built-in roles keep their names, and admin keeps every permission
+	if role.Name == permissions.RoleAdmin {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The admin role cannot be changed",
+		})
+	}
+	if role.Name == permissions.RoleCustomer && req.Name != role.Name {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The customer role cannot be renamed",
+		})
+	}
+
+	// Update the role, moving its users along when it is renamed
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update role",
+		})
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE roles SET name = ?, description = ?, updated_at = ? WHERE id = ?",
+		req.Name, req.Description, time.Now(), roleID)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "A role with this name already exists",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update role",
+		})
+	}
+	if req.Name != role.Name {
+		if _, err := tx.Exec("UPDATE users SET role = ? WHERE role = ?", req.Name, role.Name); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update role",
+			})
+		}
+	}
+	if err := permissions.SetRolePermissions(tx, roleID, req.Permissions); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update role",
+		})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update role",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Role updated successfully",
+	})
+}
+
+// SetRoleTwoFactor sets whether a role's users must use two-factor
+// authentication (roles:manage). Users who have not set it up can only do so
+// until they have.
+func (h *Handler) SetRoleTwoFactor(c *fiber.Ctx) error {
+	// Get role ID from URL parameter
+	roleID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid role ID",
+		})
+	}
+
+	// Parse request body
+	var req models.TwoFactorPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	result, err := h.DB.Exec("UPDATE roles SET require_two_factor = ?, updated_at = ? WHERE id = ?",
+		req.Required, time.Now(), roleID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update role",
+		})
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Role not found",
+		})
+	}
+
+	role, err := permissions.FindRole(h.DB, roleID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Two-factor policy updated successfully",
+		"role":    role,
+	})
+}
+
+// DeleteRole deletes a role no user holds (roles:manage)
+func (h *Handler) DeleteRole(c *fiber.Ctx) error {
+	// Get role ID from URL parameter
+	roleID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid role ID",
+		})
+	}
+
+	role, err := permissions.FindRole(h.DB, roleID)
+	if err == permissions.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Role not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if role.Name == permissions.RoleAdmin || role.Name == permissions.RoleCustomer {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Built-in roles cannot be deleted",
+		})
+	}
+	if role.UserCount > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Role is still assigned to " + strconv.Itoa(role.UserCount) + " users",
+		})
+	}
+
+	// Delete the role and its permissions
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete role",
+		})
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM role_permissions WHERE role_id = ?", roleID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete role",
+		})
+	}
+	if _, err := tx.Exec("DELETE FROM roles WHERE id = ?", roleID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete role",
+		})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete role",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Role deleted successfully",
+	})
+}
+
+// parseRoleRequest parses and validates a role request body, returning an
+// HTTP status and error message for invalid requests
+func (h *Handler) parseRoleRequest(c *fiber.Ctx) (*models.RoleRequest, int, string) {
+	var req models.RoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, fiber.StatusBadRequest, "Invalid request body"
+	}
+
+	// Validate input
+	req.Name = strings.TrimSpace(req.Name)
+	if !roleName.MatchString(req.Name) {
+		return nil, fiber.StatusBadRequest, "Name must be 2 to 50 lowercase letters, digits or underscores, starting with a letter"
+	}
+	for _, permission := range req.Permissions {
+		if !permissions.Valid(permission) {
+			return nil, fiber.StatusBadRequest, "Unknown permission " + permission
+		}
+	}
+
+	// Nobody can grant permissions they do not hold
+	missing, err := permissions.Missing(h.DB, c.Locals("userID").(int64), req.Permissions)
+	if err != nil {
+		return nil, fiber.StatusInternalServerError, "Database error"
+	}
+	if len(missing) > 0 {
+		return nil, fiber.StatusForbidden, "Forbidden: you do not hold " + strings.Join(missing, ", ")
+	}
+
+	return &req, 0, ""
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"backend/search"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SearchProducts runs a full-text search over the product catalog
+func (h *Handler) SearchProducts(c *fiber.Ctx) error {
+	// Parse query parameters
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	var categoryID int64
+	if value := c.Query("category_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid category ID",
+			})
+		}
+		categoryID = id
+	}
+
+	// Run the search
+	results, err := search.Search(h.DB, search.Options{
+		Query:      c.Query("q"),
+		CategoryID: categoryID,
+		Limit:      limit,
+		Offset:     (page - 1) * limit,
+	})
+	if err == search.ErrEmptyQuery {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Search query is required",
+		})
+	}
+	if err == search.ErrUnavailable {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Search is not available",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Search failed",
+		})
+	}
+
+	response := fiber.Map{
+		"query":    c.Query("q"),
+		"products": results.Products,
+		"facets":   results.Facets,
+		"meta": fiber.Map{
+			"page":        page,
+			"limit":       limit,
+			"total":       results.Total,
+			"total_pages": (results.Total + limit - 1) / limit,
+		},
+	}
+	if results.Suggestion != "" {
+		response["suggestion"] = results.Suggestion
+	}
+	if results.CorrectedQuery != "" {
+		response["corrected_query"] = results.CorrectedQuery
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// RebuildSearchIndex re-indexes the whole catalog (admin only)
+func (h *Handler) RebuildSearchIndex(c *fiber.Ctx) error {
+	if err := search.Rebuild(h.DB); err == search.ErrUnavailable {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Search is not available",
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to rebuild search index",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Search index rebuilt successfully",
+	})
+}
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"backend/logins"
+	"backend/models"
+	"backend/repository"
+	"backend/sessions"
+	"backend/utils"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// sessionClient describes the client a session is used from
+func sessionClient(c *fiber.Ctx) sessions.Client {
+	return sessions.Client{UserAgent: c.Get("User-Agent"), IP: c.IP()}
+}
+
+// startSession opens a session for a user who just signed in and returns its tokens
+func (h *Handler) startSession(c *fiber.Ctx, userID int64, email, role string) (fiber.Map, error) {
+	refresh, err := sessions.Start(h.DB, userID, sessionClient(c))
+	if err != nil {
+		return nil, err
+	}
+	return sessionTokens(refresh, email, role)
+}
+
+// loginBlocked returns the logins reason and message for why an account may
+// not sign in, or empty strings when it may
+func loginBlocked(user *models.User) (string, string) {
+	if user.DisabledAt != nil {
+		return logins.ReasonDisabled, "This account has been disabled"
+	}
+	if user.PasswordResetRequired {
+		return logins.ReasonResetRequired, "Your password must be reset, use the link sent to your email or request a new one"
+	}
+	return "", ""
+}
+
+// tooManyAttempts responds to a login refused while the account or the client's address is throttled
+func tooManyAttempts(c *fiber.Ctx, wait time.Duration) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(wait.Seconds())))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":       "Too many failed login attempts, please try again later",
+		"retry_after": int(wait.Seconds()),
+	})
+}
+
+// recordLogin logs a failure to record a login attempt, which does not stop the login
+func recordLogin(err error) {
+	if err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+	}
+}
+
+// sessionTokens pairs a refresh token with a new access token for its session
+func sessionTokens(refresh *sessions.Token, email, role string) (fiber.Map, error) {
+	token, err := utils.GenerateToken(refresh.UserID, email, role, refresh.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	return fiber.Map{
+		"token":                    token,
+		"expires_in":               int(utils.AccessTokenTTL().Seconds()),
+		"refresh_token":            refresh.RefreshToken,
+		"refresh_token_expires_at": refresh.ExpiresAt,
+	}, nil
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token
+func (h *Handler) RefreshToken(c *fiber.Ctx) error {
+	// Parse request body
+	var request models.RefreshRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if request.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Refresh token is required",
+		})
+	}
+
+	// Rotate the refresh token, a reused one revokes its session
+	refresh, err := sessions.Rotate(h.DB, request.RefreshToken, sessionClient(c))
+	if err == sessions.ErrInvalidToken || err == sessions.ErrReused {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to refresh session",
+		})
+	}
+
+	// Access tokens carry the user's current email and role
+	user, err := h.Users.FindByID(refresh.UserID)
+	if err == repository.ErrNotFound {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if _, message := loginBlocked(user); message != "" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	response, err := sessionTokens(refresh, user.Email, user.Role)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// Logout revokes the session of the current access token
+func (h *Handler) Logout(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+	sessionID := c.Locals("sessionID").(string)
+
+	if err := sessions.Revoke(h.DB, userID, sessionID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to log out",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Logged out successfully",
+	})
+}
+
+// LogoutAll revokes every session of the current user
+func (h *Handler) LogoutAll(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	if err := sessions.RevokeAll(h.DB, userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to log out",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Logged out of all sessions",
+	})
+}
+
+// GetSessions lists the current user's signed in sessions and recent login attempts
+func (h *Handler) GetSessions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+	sessionID := c.Locals("sessionID").(string)
+
+	active, err := sessions.List(h.DB, userID, sessionID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	history, err := logins.History(h.DB, userID, 50)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"sessions": active,
+		"logins":   history,
+	})
+}
+
+// RevokeSession ends one of the current user's sessions, such as a lost device
+func (h *Handler) RevokeSession(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	if err := sessions.Revoke(h.DB, userID, c.Params("id")); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke session",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Session revoked successfully",
+	})
+}
@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/orderstatus"
+	"backend/permissions"
+	"backend/shipments"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetOrderShipments lists the shipments of an order with their tracking details
+func (h *Handler) GetOrderShipments(c *fiber.Ctx) error {
+	// Get user ID from context (set by the Protected middleware)
+	userID := c.Locals("userID").(int64)
+
+	// Get order ID from URL parameter
+	orderID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order ID",
+		})
+	}
+
+	// Check if order exists and belongs to user (unless staff can read every order)
+	order, err := h.Orders.Find(orderID)
+	if err != nil || (order.UserID != userID && !h.hasPermission(c, permissions.OrdersRead)) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Order not found",
+		})
+	}
+
+	list, err := shipments.ForOrder(h.DB, orderID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"shipments": list,
+	})
+}
+
+// CreateShipment ships some or all of an order's remaining items (admin only)
+func (h *Handler) CreateShipment(c *fiber.Ctx) error {
+	// Get order ID from URL parameter
+	orderID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order ID",
+		})
+	}
+
+	// Parse request body
+	var req models.ShipmentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Validate input
+	req.Carrier = strings.TrimSpace(req.Carrier)
+	req.TrackingNumber = strings.TrimSpace(req.TrackingNumber)
+	if req.Carrier == "" || req.TrackingNumber == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Carrier and tracking number are required",
+		})
+	}
+
+	// Start a transaction
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction",
+		})
+	}
+	defer tx.Rollback()
+
+	// Lock the order, so that concurrent requests cannot ship the same items twice
+	if err := orderstatus.Lock(tx, orderID); err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Order not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	// Check that the order is still being fulfilled
+	var paymentStatus, orderStatus string
+	err = tx.QueryRow("SELECT payment_status, order_status FROM orders WHERE id = ?", orderID).Scan(&paymentStatus, &orderStatus)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if orderStatus != "processing" && orderStatus != "partially_shipped" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot ship an order that is " + orderStatus,
+		})
+	}
+	if paymentStatus == "failed" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Order payment has failed",
+		})
+	}
+
+	// Check the quantities against what is left to ship, all of it when no items are listed
+	plan, err := shipments.PlanShipment(tx, orderID, req.Items)
+	if _, ok := err.(shipments.RuleError); ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	// Record the shipment and move the order along
+	shipmentID, err := shipments.Create(tx, orderID, req.Carrier, req.TrackingNumber, plan, actorFromContext(c), "")
+	if _, ok := err.(*orderstatus.TransitionError); ok {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create shipment",
+		})
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	// Capture the payment with the first shipment, once the shipment is recorded
+	if status, message := h.capturePayment(orderID, paymentStatus, plan.OrderStatus); message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error":          message,
+			"id":             shipmentID,
+			"order_status":   plan.OrderStatus,
+			"payment_status": h.orderPaymentStatus(orderID),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":      "Shipment created successfully",
+		"id":           shipmentID,
+		"order_status": plan.OrderStatus,
+	})
+}
+
+// DeliverShipment marks a shipment as delivered (admin only). The order is
+// delivered with its last shipment.
+func (h *Handler) DeliverShipment(c *fiber.Ctx) error {
+	// Get order and shipment IDs from URL parameters
+	orderID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order ID",
+		})
+	}
+	shipmentID, err := strconv.ParseInt(c.Params("shipmentId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid shipment ID",
+		})
+	}
+
+	// Start a transaction
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction",
+		})
+	}
+	defer tx.Rollback()
+
+	// Lock the order, so that the last two shipments cannot both miss completing it
+	if err := orderstatus.Lock(tx, orderID); err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Order not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	// Check whether this shipment completes the order
+	completes, err := shipments.CompletesOrder(tx, orderID, shipmentID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	var paymentStatus string
+	if err := tx.QueryRow("SELECT payment_status FROM orders WHERE id = ?", orderID).Scan(&paymentStatus); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if completes && paymentStatus == "failed" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Order payment has failed",
+		})
+	}
+
+	// Mark the shipment delivered, and the order once everything has arrived
+	err = shipments.Deliver(tx, orderID, shipmentID)
+	if err == nil && completes {
+		err = orderstatus.SetOrder(tx, orderID, "delivered", actorFromContext(c), "")
+	}
+	if err == shipments.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Shipment not found",
+		})
+	}
+	if _, ok := err.(shipments.RuleError); ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if _, ok := err.(*orderstatus.TransitionError); ok {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update shipment",
+		})
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	// Payments collected on delivery are captured with the last shipment
+	if completes {
+		if status, message := h.capturePayment(orderID, paymentStatus, "delivered"); message != "" {
+			return c.Status(status).JSON(fiber.Map{
+				"error":          message,
+				"order_status":   "delivered",
+				"payment_status": h.orderPaymentStatus(orderID),
+			})
+		}
+	}
+
+	response := fiber.Map{
+		"message": "Shipment marked as delivered",
+	}
+	if completes {
+		response["order_status"] = "delivered"
+	}
+	return c.Status(fiber.StatusOK).JSON(response)
+}
@@ -0,0 +1,398 @@
+package controllers
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/shipping"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetAllShippingZones returns every shipping zone with its methods (admin only)
+func (h *Handler) GetAllShippingZones(c *fiber.Ctx) error {
+	zones, err := shipping.Zones(h.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"zones": zones,
+	})
+}
+
+// CreateShippingZone creates a new shipping zone (admin only)
+func (h *Handler) CreateShippingZone(c *fiber.Ctx) error {
+	// Parse and validate request body
+	req, message := parseShippingZoneRequest(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	// Create the zone
+	var zoneID int64
+	err := h.DB.QueryRow(
+		"INSERT INTO shipping_zones (name, country, postal_prefix, created_at, updated_at) VALUES (?, ?, ?, ?, ?) RETURNING id",
+		req.Name, req.Country, req.PostalPrefix, time.Now(), time.Now()).Scan(&zoneID)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "A shipping zone for this country and postal prefix already exists",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create shipping zone",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Shipping zone created successfully",
+		"id":      zoneID,
+	})
+}
+
+// UpdateShippingZone updates a shipping zone (admin only)
+func (h *Handler) UpdateShippingZone(c *fiber.Ctx) error {
+	// Get zone ID from URL parameter
+	zoneID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid shipping zone ID",
+		})
+	}
+
+	// Parse and validate request body
+	req, message := parseShippingZoneRequest(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	// Update the zone
+	result, err := h.DB.Exec(
+		"UPDATE shipping_zones SET name = ?, country = ?, postal_prefix = ?, updated_at = ? WHERE id = ?",
+		req.Name, req.Country, req.PostalPrefix, time.Now(), zoneID)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "A shipping zone for this country and postal prefix already exists",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update shipping zone",
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Shipping zone not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Shipping zone updated successfully",
+	})
+}
+
+// DeleteShippingZone deletes a shipping zone with its methods (admin only)
+func (h *Handler) DeleteShippingZone(c *fiber.Ctx) error {
+	// Get zone ID from URL parameter
+	zoneID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid shipping zone ID",
+		})
+	}
+
+	// Start a transaction
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction",
+		})
+	}
+
+	// Delete the zone's methods and their tiers, then the zone
+	_, err = tx.Exec("DELETE FROM shipping_weight_tiers WHERE method_id IN (SELECT id FROM shipping_methods WHERE zone_id = ?)", zoneID)
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM shipping_methods WHERE zone_id = ?", zoneID)
+	}
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete shipping zone",
+		})
+	}
+
+	result, err := tx.Exec("DELETE FROM shipping_zones WHERE id = ?", zoneID)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete shipping zone",
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		tx.Rollback()
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Shipping zone not found",
+		})
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Shipping zone deleted successfully",
+	})
+}
+
+// CreateShippingMethod adds a shipping method to a zone (admin only)
+func (h *Handler) CreateShippingMethod(c *fiber.Ctx) error {
+	// Get zone ID from URL parameter
+	zoneID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid shipping zone ID",
+		})
+	}
+
+	// Check if the zone exists
+	var zoneExists bool
+	err = h.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM shipping_zones WHERE id = ?)", zoneID).Scan(&zoneExists)
+	if err != nil || !zoneExists {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Shipping zone not found",
+		})
+	}
+
+	// Parse and validate request body
+	req, message := parseShippingMethodRequest(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	isActive := req.IsActive == nil || *req.IsActive
+
+	// Start a transaction
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction",
+		})
+	}
+
+	// Create the method with its weight tiers
+	var methodID int64
+	err = tx.QueryRow(
+		`INSERT INTO shipping_methods (zone_id, code, name, pricing, rate, free_threshold, min_days, max_days,
+			is_active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		zoneID, req.Code, req.Name, req.Pricing, req.Rate, req.FreeThreshold, req.MinDays, req.MaxDays,
+		isActive, time.Now(), time.Now()).Scan(&methodID)
+	if err != nil {
+		tx.Rollback()
+		if database.IsUniqueViolation(err) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "The zone already offers this shipping method",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create shipping method",
+		})
+	}
+
+	if err := saveWeightTiers(tx, methodID, req.Tiers); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create shipping method",
+		})
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Shipping method created successfully",
+		"id":      methodID,
+	})
+}
+
+// UpdateShippingMethod replaces a shipping method and its weight tiers (admin only)
+func (h *Handler) UpdateShippingMethod(c *fiber.Ctx) error {
+	// Get method ID from URL parameter
+	methodID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid shipping method ID",
+		})
+	}
+
+	// Parse and validate request body
+	req, message := parseShippingMethodRequest(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	isActive := req.IsActive == nil || *req.IsActive
+
+	// Start a transaction
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction",
+		})
+	}
+
+	// Update the method
+	result, err := tx.Exec(
+		`UPDATE shipping_methods SET code = ?, name = ?, pricing = ?, rate = ?, free_threshold = ?,
+			min_days = ?, max_days = ?, is_active = ?, updated_at = ?
+		WHERE id = ?`,
+		req.Code, req.Name, req.Pricing, req.Rate, req.FreeThreshold,
+		req.MinDays, req.MaxDays, isActive, time.Now(), methodID)
+	if err != nil {
+		tx.Rollback()
+		if database.IsUniqueViolation(err) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "The zone already offers this shipping method",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update shipping method",
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		tx.Rollback()
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Shipping method not found",
+		})
+	}
+
+	// Replace its weight tiers
+	_, err = tx.Exec("DELETE FROM shipping_weight_tiers WHERE method_id = ?", methodID)
+	if err == nil {
+		err = saveWeightTiers(tx, methodID, req.Tiers)
+	}
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update shipping method",
+		})
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Shipping method updated successfully",
+	})
+}
+
+// DeleteShippingMethod deletes a shipping method (admin only). Orders keep the method and cost they were charged.
+func (h *Handler) DeleteShippingMethod(c *fiber.Ctx) error {
+	// Get method ID from URL parameter
+	methodID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid shipping method ID",
+		})
+	}
+
+	// Remove its weight tiers, then delete it
+	_, err = h.DB.Exec("DELETE FROM shipping_weight_tiers WHERE method_id = ?", methodID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete shipping method",
+		})
+	}
+
+	result, err := h.DB.Exec("DELETE FROM shipping_methods WHERE id = ?", methodID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete shipping method",
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Shipping method not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Shipping method deleted successfully",
+	})
+}
+
+// saveWeightTiers inserts the weight tiers of a shipping method
+func saveWeightTiers(tx *sql.Tx, methodID int64, tiers []models.WeightTier) error {
+	for _, tier := range tiers {
+		_, err := tx.Exec(
+			"INSERT INTO shipping_weight_tiers (method_id, max_weight, rate) VALUES (?, ?, ?)",
+			methodID, tier.MaxWeight, tier.Rate)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseShippingZoneRequest parses and validates a shipping zone request body,
+// returning an error message for invalid requests
+func parseShippingZoneRequest(c *fiber.Ctx) (*models.ShippingZoneRequest, string) {
+	var req models.ShippingZoneRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, "Invalid request body"
+	}
+
+	// Validate input
+	req.Name = strings.TrimSpace(req.Name)
+	req.Country = strings.ToUpper(strings.TrimSpace(req.Country))
+	req.PostalPrefix = shipping.NormalizePostalCode(req.PostalPrefix)
+	if req.Name == "" || req.Country == "" {
+		return nil, "Name and country are required"
+	}
+
+	return &req, ""
+}
+
+// parseShippingMethodRequest parses and validates a shipping method request body,
+// returning an error message for invalid requests
+func parseShippingMethodRequest(c *fiber.Ctx) (*models.ShippingMethodRequest, string) {
+	var req models.ShippingMethodRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, "Invalid request body"
+	}
+
+	// Validate input
+	if err := shipping.ValidateMethod(&req); err != nil {
+		return nil, err.Error()
+	}
+	if req.Pricing != shipping.PricingWeight {
+		req.Tiers = nil
+	}
+
+	return &req, ""
+}
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/tax"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetAllTaxRules returns every tax rule (admin only)
+func (h *Handler) GetAllTaxRules(c *fiber.Ctx) error {
+	rules, err := tax.List(h.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"tax_rules": rules,
+	})
+}
+
+// CreateTaxRule creates a new tax rule (admin only)
+func (h *Handler) CreateTaxRule(c *fiber.Ctx) error {
+	// Parse and validate request body
+	req, message := h.parseTaxRuleRequest(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	// Create the tax rule
+	var ruleID int64
+	err := h.DB.QueryRow(
+		`INSERT INTO tax_rules (name, country, state, category_id, rate, price_includes_tax, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		req.Name, req.Country, req.State, req.CategoryID, req.Rate, req.PriceIncludesTax, time.Now(), time.Now()).Scan(&ruleID)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "A tax rule for this region and category already exists",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create tax rule",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Tax rule created successfully",
+		"id":      ruleID,
+	})
+}
+
+// UpdateTaxRule replaces a tax rule (admin only). Existing orders keep the tax they were charged.
+func (h *Handler) UpdateTaxRule(c *fiber.Ctx) error {
+	// Get tax rule ID from URL parameter
+	ruleID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tax rule ID",
+		})
+	}
+
+	// Parse and validate request body
+	req, message := h.parseTaxRuleRequest(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	// Update the tax rule
+	result, err := h.DB.Exec(
+		`UPDATE tax_rules SET name = ?, country = ?, state = ?, category_id = ?, rate = ?, price_includes_tax = ?, updated_at = ?
+		WHERE id = ?`,
+		req.Name, req.Country, req.State, req.CategoryID, req.Rate, req.PriceIncludesTax, time.Now(), ruleID)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "A tax rule for this region and category already exists",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update tax rule",
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Tax rule not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Tax rule updated successfully",
+	})
+}
+
+// DeleteTaxRule deletes a tax rule (admin only)
+func (h *Handler) DeleteTaxRule(c *fiber.Ctx) error {
+	// Get tax rule ID from URL parameter
+	ruleID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tax rule ID",
+		})
+	}
+
+	result, err := h.DB.Exec("DELETE FROM tax_rules WHERE id = ?", ruleID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete tax rule",
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Tax rule not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Tax rule deleted successfully",
+	})
+}
+
+// parseTaxRuleRequest parses and validates a tax rule request body,
+// returning an error message for invalid requests
+func (h *Handler) parseTaxRuleRequest(c *fiber.Ctx) (*models.TaxRuleRequest, string) {
+	var req models.TaxRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, "Invalid request body"
+	}
+
+	// Validate input
+	req.Name = strings.TrimSpace(req.Name)
+	req.Country = tax.NormalizeRegion(req.Country)
+	req.State = tax.NormalizeRegion(req.State)
+	if req.Country == "" {
+		return nil, "Country is required"
+	}
+	if req.Rate < 0 || req.Rate > 100 {
+		return nil, "Rate must be between 0 and 100"
+	}
+
+	// Check that the category exists
+	if req.CategoryID != nil {
+		var exists bool
+		h.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE id = ?)", *req.CategoryID).Scan(&exists)
+		if !exists {
+			return nil, "Category not found"
+		}
+	}
+
+	return &req, ""
+}
@@ -0,0 +1,342 @@
+package controllers
+
+import (
+	"backend/accounts"
+	"backend/logins"
+	"backend/models"
+	"backend/repository"
+	"backend/twofactor"
+	"backend/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetTwoFactor returns whether the current user has two-factor authentication
+// enabled, whether their role requires it and how many recovery codes are left
+func (h *Handler) GetTwoFactor(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	status, err := twofactor.Status(h.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"two_factor": status,
+	})
+}
+
+// SetupTwoFactor starts setting up two-factor authentication and returns the
+// secret to add to an authenticator app, also as a provisioning URI for a QR code
+func (h *Handler) SetupTwoFactor(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+	email := c.Locals("email").(string)
+
+	secret, err := twofactor.Begin(h.DB, userID)
+	if err == twofactor.ErrAlreadyEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Two-factor authentication is already enabled",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to set up two-factor authentication",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":          "Add the account to your authenticator app, then confirm with a code",
+		"secret":           secret,
+		"provisioning_uri": twofactor.ProvisioningURI(twofactor.Issuer(), email, secret),
+	})
+}
+
+// ConfirmTwoFactor enables two-factor authentication with a first code from
+// the authenticator app and returns the recovery codes, which are shown only once
+func (h *Handler) ConfirmTwoFactor(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	// Parse request body
+	var request models.TwoFactorCodeRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if request.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Code is required",
+		})
+	}
+
+	codes, err := twofactor.Confirm(h.DB, userID, request.Code)
+	switch err {
+	case nil:
+	case twofactor.ErrInvalidCode:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid code, check the time on your device and try again",
+		})
+	case twofactor.ErrNotStarted:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Start the two-factor setup first",
+		})
+	case twofactor.ErrAlreadyEnabled:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Two-factor authentication is already enabled",
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to enable two-factor authentication",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":        "Two-factor authentication enabled, keep the recovery codes somewhere safe",
+		"recovery_codes": codes,
+	})
+}
+
+// VerifyTwoFactor finishes a login of an account with two-factor
+// authentication, exchanging the challenge token from LoginUser and a code
+// from the authenticator app or a recovery code for the session's tokens
+func (h *Handler) VerifyTwoFactor(c *fiber.Ctx) error {
+	// Parse request body
+	var request models.TwoFactorVerifyRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if request.ChallengeToken == "" || request.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Challenge token and code are required",
+		})
+	}
+
+	// The challenge stays valid for further codes until one is right
+	userID, email, err := accounts.Lookup(h.DB, request.ChallengeToken, accounts.PurposeTwoFactor)
+	if err == accounts.ErrInvalidToken {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired challenge, please log in again",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	client := sessionClient(c)
+	attempt := logins.Attempt{UserID: userID, Email: email, IP: client.IP, UserAgent: client.UserAgent}
+
+	// The account may have been disabled since the password was checked
+	user, err := h.Users.FindByID(userID)
+	if err == repository.ErrNotFound {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if reason, message := loginBlocked(user); message != "" {
+		recordLogin(logins.Refused(h.DB, attempt, reason))
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	// Wrong codes are throttled like wrong passwords
+	wait, err := logins.Begin(h.DB, attempt)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if wait > 0 {
+		recordLogin(logins.Refused(h.DB, attempt, logins.ReasonTooManyAttempts))
+		return tooManyAttempts(c, wait)
+	}
+
+	// Check the code
+	err = twofactor.Verify(h.DB, userID, request.Code)
+	if err == twofactor.ErrInvalidCode {
+		recordLogin(logins.Failed(h.DB, attempt, logins.ReasonInvalidCode))
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid two-factor code",
+		})
+	}
+	if err == twofactor.ErrNotEnabled {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Two-factor authentication is no longer enabled, please log in again",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	recordLogin(logins.Passed(h.DB, attempt))
+
+	// Use up the challenge, which only one of concurrent requests can
+	if _, _, err := accounts.Consume(h.DB, request.ChallengeToken, accounts.PurposeTwoFactor); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired challenge, please log in again",
+		})
+	}
+	recordLogin(logins.Succeeded(h.DB, attempt))
+
+	// Start a session and generate its tokens
+	response, err := h.startSession(c, user.ID, user.Email, user.Role)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
+	}
+
+	// Return the user and tokens
+	response["message"] = "Login successful"
+	response["user"] = user.ToResponse()
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// DisableTwoFactor turns off two-factor authentication, which needs the
+// password and a code, unless the user's role requires it
+func (h *Handler) DisableTwoFactor(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	// Parse request body
+	var request models.TwoFactorDisableRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if status, message := h.checkPassword(c, userID, request.Password); message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	status, err := twofactor.Status(h.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if !status.Enabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Two-factor authentication is not enabled",
+		})
+	}
+	if status.Required {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Your role requires two-factor authentication",
+		})
+	}
+
+	// A recovery code works too, for users who lost their authenticator app
+	err = twofactor.Verify(h.DB, userID, request.Code)
+	if err == twofactor.ErrInvalidCode {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid two-factor code",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	if err := twofactor.Disable(h.DB, userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to disable two-factor authentication",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes, which
+// needs the password
+func (h *Handler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int64)
+
+	// Parse request body
+	var request models.RecoveryCodesRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if status, message := h.checkPassword(c, userID, request.Password); message != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	codes, err := twofactor.RegenerateRecoveryCodes(h.DB, userID)
+	if err == twofactor.ErrNotEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Two-factor authentication is not enabled",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create recovery codes",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":        "New recovery codes created, the old ones no longer work",
+		"recovery_codes": codes,
+	})
+}
+
+// checkPassword returns an HTTP status and error message unless a password is
+// the user's
+func (h *Handler) checkPassword(c *fiber.Ctx, userID int64, password string) (int, string) {
+	user, err := h.Users.FindByID(userID)
+	if err == repository.ErrNotFound {
+		return fiber.StatusNotFound, "User not found"
+	}
+	if err != nil {
+		return fiber.StatusInternalServerError, "Database error"
+	}
+	return h.checkUserPassword(c, user, password, "Password is incorrect")
+}
+
+// checkUserPassword checks the password of a signed in user before a sensitive
+// change. Wrong passwords are throttled like failed logins. It returns an HTTP
+// status and error message, incorrect for a wrong password, unless the
+// password is right.
+func (h *Handler) checkUserPassword(c *fiber.Ctx, user *models.User, password, incorrect string) (int, string) {
+	client := sessionClient(c)
+	attempt := logins.Attempt{UserID: user.ID, Email: user.Email, IP: client.IP, UserAgent: client.UserAgent}
+	wait, err := logins.Begin(h.DB, attempt)
+	if err != nil {
+		return fiber.StatusInternalServerError, "Database error"
+	}
+	if wait > 0 {
+		recordLogin(logins.Refused(h.DB, attempt, logins.ReasonTooManyAttempts))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(wait.Seconds())))
+		return fiber.StatusTooManyRequests, "Too many failed attempts, please try again later"
+	}
+
+	if !utils.CheckPasswordHash(password, user.Password) {
+		recordLogin(logins.Failed(h.DB, attempt, logins.ReasonInvalidCredentials))
+		return fiber.StatusUnauthorized, incorrect
+	}
+	recordLogin(logins.Passed(h.DB, attempt))
+	return 0, ""
+}
@@ -1,17 +1,19 @@
 package controllers
 
 import (
-	"backend/database"
+	"backend/accounts"
+	"backend/logins"
 	"backend/models"
+	"backend/repository"
+	"backend/twofactor"
 	"backend/utils"
-	"database/sql"
-	"time"
+	"log"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // RegisterUser handles user registration
-func RegisterUser(c *fiber.Ctx) error {
+func (h *Handler) RegisterUser(c *fiber.Ctx) error {
 	// Parse request body
 	var userRegister models.UserRegister
 	if err := c.BodyParser(&userRegister); err != nil {
@@ -28,8 +30,7 @@ func RegisterUser(c *fiber.Ctx) error {
 	}
 
 	// Check if user with this email already exists
-	var exists bool
-	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)", userRegister.Email).Scan(&exists)
+	exists, err := h.Users.EmailExists(userRegister.Email)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
@@ -50,47 +51,45 @@ func RegisterUser(c *fiber.Ctx) error {
 	}
 
 	// Create the user
-	result, err := database.DB.Exec(
-		"INSERT INTO users (name, email, password, role, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
-		userRegister.Name,
-		userRegister.Email,
-		hashedPassword,
-		"customer", // Default role
-		time.Now(),
-		time.Now(),
-	)
+	userID, err := h.Users.Create(&models.User{
+		Name:     userRegister.Name,
+		Email:    userRegister.Email,
+		Password: hashedPassword,
+		Role:     "customer", // Default role
+	})
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create user",
 		})
 	}
 
-	// Get the user ID
-	userID, _ := result.LastInsertId()
+	// Ask the user to confirm the email address, the account works meanwhile
+	if err := accounts.SendVerification(h.DB, userID, userRegister.Name, userRegister.Email); err != nil {
+		log.Printf("Failed to send verification to user %d: %v", userID, err)
+	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(userID, userRegister.Email, "customer")
+	// Start a session and generate its tokens
+	response, err := h.startSession(c, userID, userRegister.Email, "customer")
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate token",
 		})
 	}
 
-	// Return the user and token
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": "User registered successfully",
-		"user": fiber.Map{
-			"id":    userID,
-			"name":  userRegister.Name,
-			"email": userRegister.Email,
-			"role":  "customer",
-		},
-		"token": token,
-	})
+	// Return the user and tokens
+	response["message"] = "User registered successfully"
+	response["user"] = fiber.Map{
+		"id":             userID,
+		"name":           userRegister.Name,
+		"email":          userRegister.Email,
+		"role":           "customer",
+		"email_verified": false,
+	}
+	return c.Status(fiber.StatusCreated).JSON(response)
 }
 
 // LoginUser handles user authentication
-func LoginUser(c *fiber.Ctx) error {
+func (h *Handler) LoginUser(c *fiber.Ctx) error {
 	// Parse request body
 	var userLogin models.UserLogin
 	if err := c.BodyParser(&userLogin); err != nil {
@@ -106,14 +105,29 @@ func LoginUser(c *fiber.Ctx) error {
 		})
 	}
 
-	// Find the user
-	var user models.User
-	err := database.DB.QueryRow(
-		"SELECT id, name, email, password, role, created_at, updated_at FROM users WHERE email = ?",
-		userLogin.Email,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	// Refuse attempts while the account or the client's address is throttled,
+	// otherwise count the attempt as failed until the password is checked
+	client := sessionClient(c)
+	attempt := logins.Attempt{Email: userLogin.Email, IP: client.IP, UserAgent: client.UserAgent}
+	wait, err := logins.Begin(h.DB, attempt)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if wait > 0 {
+		// The owner sees refused attempts in the login history too
+		if user, err := h.Users.FindByEmail(attempt.Email); err == nil {
+			attempt.UserID = user.ID
+		}
+		recordLogin(logins.Refused(h.DB, attempt, logins.ReasonTooManyAttempts))
+		return tooManyAttempts(c, wait)
+	}
 
-	if err == sql.ErrNoRows {
+	// Find the user
+	user, err := h.Users.FindByEmail(userLogin.Email)
+	if err == repository.ErrNotFound {
+		recordLogin(logins.Failed(h.DB, attempt, logins.ReasonInvalidCredentials))
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Invalid email or password",
 		})
@@ -123,43 +137,70 @@ func LoginUser(c *fiber.Ctx) error {
 			"error": "Database error",
 		})
 	}
+	attempt.UserID = user.ID
 
 	// Check password
 	if !utils.CheckPasswordHash(userLogin.Password, user.Password) {
+		recordLogin(logins.Failed(h.DB, attempt, logins.ReasonInvalidCredentials))
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Invalid email or password",
 		})
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user.ID, user.Email, user.Role)
+	recordLogin(logins.Passed(h.DB, attempt))
+
+	// Check the account may log in
+	if reason, message := loginBlocked(user); message != "" {
+		recordLogin(logins.Refused(h.DB, attempt, reason))
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	// Accounts with two-factor authentication finish logging in with a code
+	if user.TwoFactorEnabled {
+		challenge, err := accounts.Issue(h.DB, user.ID, accounts.PurposeTwoFactor, user.Email)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to start two-factor login",
+			})
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message":             "Enter the code from your authenticator app",
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+			"expires_in":          int(accounts.TTL(accounts.PurposeTwoFactor).Seconds()),
+		})
+	}
+	recordLogin(logins.Succeeded(h.DB, attempt))
+
+	// Start a session and generate its tokens
+	response, err := h.startSession(c, user.ID, user.Email, user.Role)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate token",
 		})
 	}
 
-	// Return the user and token
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "Login successful",
-		"user":    user.ToResponse(),
-		"token":   token,
-	})
+	// The session only reaches the two-factor setup while the user's role requires it
+	if pending, err := twofactor.SetupPending(h.DB, user.ID); err == nil && pending {
+		response["two_factor_setup_required"] = true
+	}
+
+	// Return the user and tokens
+	response["message"] = "Login successful"
+	response["user"] = user.ToResponse()
+	return c.Status(fiber.StatusOK).JSON(response)
 }
 
 // GetCurrentUser returns the current authenticated user
-func GetCurrentUser(c *fiber.Ctx) error {
+func (h *Handler) GetCurrentUser(c *fiber.Ctx) error {
 	// Get the user ID from the context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
 	// Get the user from the database
-	var user models.User
-	err := database.DB.QueryRow(
-		"SELECT id, name, email, password, role, created_at, updated_at FROM users WHERE id = ?",
-		userID,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.CreatedAt, &user.UpdatedAt)
-
-	if err == sql.ErrNoRows {
+	user, err := h.Users.FindByID(userID)
+	if err == repository.ErrNotFound {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "User not found",
 		})
@@ -1,16 +1,14 @@
 package controllers
 
 import (
-	"backend/database"
-	"backend/models"
+	"backend/repository"
 	"strconv"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // AddToWishlist adds a product to the user's wishlist
-func AddToWishlist(c *fiber.Ctx) error {
+func (h *Handler) AddToWishlist(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
@@ -32,8 +30,7 @@ func AddToWishlist(c *fiber.Ctx) error {
 	}
 
 	// Check if product exists
-	var productExists bool
-	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)", req.ProductID).Scan(&productExists)
+	productExists, err := h.Products.Exists(req.ProductID)
 	if err != nil || !productExists {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Product not found",
@@ -41,11 +38,7 @@ func AddToWishlist(c *fiber.Ctx) error {
 	}
 
 	// Check if item already exists in wishlist
-	var wishlistExists bool
-	err = database.DB.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM wishlist WHERE user_id = ? AND product_id = ?)",
-		userID, req.ProductID).Scan(&wishlistExists)
-
+	wishlistExists, err := h.Wishlist.Contains(userID, req.ProductID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
@@ -59,18 +52,13 @@ func AddToWishlist(c *fiber.Ctx) error {
 	}
 
 	// Add to wishlist
-	result, err := database.DB.Exec(
-		"INSERT INTO wishlist (user_id, product_id, created_at) VALUES (?, ?, ?)",
-		userID, req.ProductID, time.Now())
+	wishlistItemID, err := h.Wishlist.Add(userID, req.ProductID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to add to wishlist",
 		})
 	}
 
-	// Get the wishlist item ID
-	wishlistItemID, _ := result.LastInsertId()
-
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Added to wishlist successfully",
 		"id":      wishlistItemID,
@@ -78,63 +66,32 @@ func AddToWishlist(c *fiber.Ctx) error {
 }
 
 // GetWishlist retrieves the user's wishlist
-func GetWishlist(c *fiber.Ctx) error {
+func (h *Handler) GetWishlist(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
-	// Query to get wishlist items with product details
-	rows, err := database.DB.Query(`
-		SELECT 
-			w.id, w.product_id, w.created_at,
-			p.name, p.description, p.base_price, p.discount_percentage,
-			(SELECT image_url FROM product_images WHERE product_id = p.id AND is_primary = 1 LIMIT 1) as image_url,
-			(SELECT COUNT(*) > 0 FROM product_inventory pi 
-				JOIN product_colors pc ON pi.color_id = pc.id 
-				JOIN product_sizes ps ON pi.size_id = ps.id 
-				WHERE pi.product_id = p.id AND pi.quantity > 0) as in_stock
-		FROM wishlist w
-		JOIN products p ON w.product_id = p.id
-		WHERE w.user_id = ?
-		ORDER BY w.id DESC`,
-		userID)
+	// Prices are shown in the currency asked for
+	exchange, err := h.requestExchange(c)
+	if err != nil {
+		return exchangeError(c, err)
+	}
+
+	// Get the wishlist items with product details
+	wishlistItems, err := h.Wishlist.Items(userID, exchange)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database error",
 		})
 	}
-	defer rows.Close()
-
-	var wishlistItems []models.WishlistItemResponse
-
-	for rows.Next() {
-		var item models.WishlistItemResponse
-		var basePrice, discountPercentage float64
-		var inStock bool
-
-		err := rows.Scan(
-			&item.ID, &item.ProductID, &item.CreatedAt,
-			&item.ProductName, &item.ProductDescription, &basePrice, &discountPercentage,
-			&item.ImageURL, &inStock)
-		if err != nil {
-			continue
-		}
-
-		// Calculate final price
-		item.BasePrice = basePrice
-		item.DiscountPercentage = discountPercentage
-		item.FinalPrice = basePrice * (1 - discountPercentage/100)
-		item.InStock = inStock
-
-		wishlistItems = append(wishlistItems, item)
-	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"items": wishlistItems,
+		"items":    wishlistItems,
+		"currency": exchange.Currency,
 	})
 }
 
 // RemoveFromWishlist removes a product from the user's wishlist
-func RemoveFromWishlist(c *fiber.Ctx) error {
+func (h *Handler) RemoveFromWishlist(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
@@ -147,17 +104,13 @@ func RemoveFromWishlist(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if wishlist item exists and belongs to user
-	var exists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM wishlist WHERE id = ? AND user_id = ?)", wishlistItemID, userID).Scan(&exists)
-	if err != nil || !exists {
+	// Delete the wishlist item, provided it belongs to the user
+	err = h.Wishlist.Remove(userID, wishlistItemID)
+	if err == repository.ErrNotFound {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Wishlist item not found",
 		})
 	}
-
-	// Delete wishlist item
-	_, err = database.DB.Exec("DELETE FROM wishlist WHERE id = ? AND user_id = ?", wishlistItemID, userID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to remove from wishlist",
@@ -170,13 +123,12 @@ func RemoveFromWishlist(c *fiber.Ctx) error {
 }
 
 // ClearWishlist removes all items from the user's wishlist
-func ClearWishlist(c *fiber.Ctx) error {
+func (h *Handler) ClearWishlist(c *fiber.Ctx) error {
 	// Get user ID from context (set by the Protected middleware)
 	userID := c.Locals("userID").(int64)
 
 	// Delete all wishlist items for this user
-	_, err := database.DB.Exec("DELETE FROM wishlist WHERE user_id = ?", userID)
-	if err != nil {
+	if err := h.Wishlist.Clear(userID); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to clear wishlist",
 		})
@@ -1,29 +1,33 @@
 package main
 
 import (
+	"backend/database"
+	"backend/money"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 
-	_ "github.com/mattn/go-sqlite3"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var db *sql.DB
 
 func main() {
-	// Open database connection
-	dbDir := "../database/data"
-	if _, err := os.Stat(dbDir); os.IsNotExist(err) {
-		fmt.Println("Database directory not found. Make sure the database is initialized.")
-		return
+	// Open database connection, the server's SQLite file unless DATABASE_URL is set
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		dbDir := "../database/data"
+		if _, err := os.Stat(dbDir); os.IsNotExist(err) {
+			fmt.Println("Database directory not found. Make sure the database is initialized.")
+			return
+		}
+		url = "sqlite://" + filepath.Join(dbDir, "ecommerce.db")
 	}
 
-	dbPath := filepath.Join(dbDir, "ecommerce.db")
 	var err error
-	db, err = sql.Open("sqlite3", dbPath)
+	db, _, err = database.Connect(url)
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
 	}
@@ -47,49 +51,47 @@ func main() {
 	// Seed products
 	seedProducts()
 
+	// Seed tax rules
+	seedTaxRules()
+
+	// Seed shipping zones
+	seedShippingZones()
+
+	// Seed exchange rates
+	seedExchangeRates()
+
 	fmt.Println("Data seeding completed successfully!")
 }
 
 func seedUsers() {
 	fmt.Println("Seeding users...")
 
-	// Clear existing users
-	_, err := db.Exec("DELETE FROM users")
-	if err != nil {
-		log.Printf("Warning: Failed to clear users table: %v", err)
-	}
-
-	// Create admin user
-	adminPassword, _ := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
-	_, err = db.Exec(
-		"INSERT INTO users (name, email, password, role) VALUES (?, ?, ?, ?)",
-		"Admin User", "admin@example.com", string(adminPassword), "admin",
-	)
-	if err != nil {
-		log.Printf("Failed to create admin user: %v", err)
-	} else {
-		fmt.Println("Admin user created")
-	}
-
-	// Create regular users
+	// Seeded accounts have verified emails. Users are only added when their
+	// email is missing, so reseeding keeps accounts and any role changes.
 	users := []struct {
 		name     string
 		email    string
 		password string
+		role     string
 	}{
-		{"John Doe", "john@example.com", "password123"},
-		{"Jane Smith", "jane@example.com", "password123"},
-		{"Bob Johnson", "bob@example.com", "password123"},
+		{"Admin User", "admin@example.com", "admin123", "admin"},
+		{"John Doe", "john@example.com", "password123", "customer"},
+		{"Jane Smith", "jane@example.com", "password123", "customer"},
+		{"Bob Johnson", "bob@example.com", "password123", "customer"},
 	}
 
 	for _, user := range users {
 		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(user.password), bcrypt.DefaultCost)
-		_, err := db.Exec(
-			"INSERT INTO users (name, email, password, role) VALUES (?, ?, ?, ?)",
-			user.name, user.email, string(hashedPassword), "customer",
+		result, err := db.Exec(
+			"INSERT INTO users (name, email, password, role, email_verified_at) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP) ON CONFLICT (email) DO NOTHING",
+			user.name, user.email, string(hashedPassword), user.role,
 		)
 		if err != nil {
 			log.Printf("Failed to create user %s: %v", user.email, err)
+			continue
+		}
+		if created, _ := result.RowsAffected(); created == 0 {
+			fmt.Printf("User already exists: %s\n", user.email)
 		} else {
 			fmt.Printf("User created: %s\n", user.email)
 		}
@@ -190,6 +192,7 @@ func seedProducts() {
 		price             float64
 		discountPercent   float64
 		featured          bool
+		weight            float64
 		colors            []string
 		colorHexes        []string
 		sizes             []string
@@ -203,6 +206,7 @@ func seedProducts() {
 			price:           24.99,
 			discountPercent: 0,
 			featured:        true,
+			weight:          0.2,
 			colors:          []string{"White", "Black", "Gray"},
 			colorHexes:      []string{"#FFFFFF", "#000000", "#808080"},
 			sizes:           []string{"S", "M", "L", "XL"},
@@ -219,6 +223,7 @@ func seedProducts() {
 			price:           49.99,
 			discountPercent: 10,
 			featured:        true,
+			weight:          0.35,
 			colors:          []string{"Blue", "Red"},
 			colorHexes:      []string{"#0000FF", "#FF0000"},
 			sizes:           []string{"S", "M", "L"},
@@ -235,6 +240,7 @@ func seedProducts() {
 			price:           79.99,
 			discountPercent: 0,
 			featured:        true,
+			weight:          0.9,
 			colors:          []string{"Blue"},
 			colorHexes:      []string{"#0000AA"},
 			sizes:           []string{"M", "L", "XL"},
@@ -251,6 +257,7 @@ func seedProducts() {
 			price:           89.99,
 			discountPercent: 15,
 			featured:        true,
+			weight:          0.6,
 			colors:          []string{"Brown", "Black"},
 			colorHexes:      []string{"#964B00", "#000000"},
 			sizes:           []string{},
@@ -266,6 +273,7 @@ func seedProducts() {
 			price:           119.99,
 			discountPercent: 0,
 			featured:        true,
+			weight:          1.1,
 			colors:          []string{"White", "Black", "Red"},
 			colorHexes:      []string{"#FFFFFF", "#000000", "#FF0000"},
 			sizes:           []string{"7", "8", "9", "10", "11"},
@@ -282,6 +290,7 @@ func seedProducts() {
 			price:           59.99,
 			discountPercent: 0,
 			featured:        true,
+			weight:          0.5,
 			colors:          []string{"Beige", "Navy", "Olive"},
 			colorHexes:      []string{"#F5F5DC", "#000080", "#808000"},
 			sizes:           []string{"28", "30", "32", "34", "36"},
@@ -297,6 +306,7 @@ func seedProducts() {
 			price:           64.99,
 			discountPercent: 20,
 			featured:        true,
+			weight:          0.7,
 			colors:          []string{"Cream", "Gray", "Pink"},
 			colorHexes:      []string{"#FFFDD0", "#808080", "#FFC0CB"},
 			sizes:           []string{"S", "M", "L"},
@@ -312,6 +322,7 @@ func seedProducts() {
 			price:           149.99,
 			discountPercent: 0,
 			featured:        true,
+			weight:          0.25,
 			colors:          []string{"Silver", "Gold", "Rose Gold"},
 			colorHexes:      []string{"#C0C0C0", "#FFD700", "#B76E79"},
 			sizes:           []string{},
@@ -327,16 +338,16 @@ func seedProducts() {
 		// Insert product
 		categoryID := categoryIDs[product.categoryName]
 
-		result, err := db.Exec(
-			"INSERT INTO products (name, description, category_id, base_price, discount_percentage, featured) VALUES (?, ?, ?, ?, ?, ?)",
-			product.name, product.description, categoryID, product.price, product.discountPercent, product.featured,
-		)
+		var productID int64
+		err := db.QueryRow(
+			"INSERT INTO products (name, description, category_id, base_price, discount_percentage, featured, weight) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id",
+			product.name, product.description, categoryID, money.FromFloat(product.price), product.discountPercent, product.featured, product.weight,
+		).Scan(&productID)
 		if err != nil {
 			log.Printf("Failed to create product %s: %v", product.name, err)
 			continue
 		}
 
-		productID, _ := result.LastInsertId()
 		fmt.Printf("Product created: %s (ID: %d)\n", product.name, productID)
 
 		// Insert product images
@@ -354,30 +365,30 @@ func seedProducts() {
 		// Insert product colors
 		colorIDs := make([]int64, len(product.colors))
 		for i, color := range product.colors {
-			result, err := db.Exec(
-				"INSERT INTO product_colors (product_id, color_name, color_hex) VALUES (?, ?, ?)",
+			var colorID int64
+			err := db.QueryRow(
+				"INSERT INTO product_colors (product_id, color_name, color_hex) VALUES (?, ?, ?) RETURNING id",
 				productID, color, product.colorHexes[i],
-			)
+			).Scan(&colorID)
 			if err != nil {
 				log.Printf("Failed to add color %s for product %s: %v", color, product.name, err)
 				continue
 			}
-			colorID, _ := result.LastInsertId()
 			colorIDs[i] = colorID
 		}
 
 		// Insert product sizes
 		sizeIDs := make([]int64, len(product.sizes))
 		for i, size := range product.sizes {
-			result, err := db.Exec(
-				"INSERT INTO product_sizes (product_id, size_name) VALUES (?, ?)",
+			var sizeID int64
+			err := db.QueryRow(
+				"INSERT INTO product_sizes (product_id, size_name) VALUES (?, ?) RETURNING id",
 				productID, size,
-			)
+			).Scan(&sizeID)
 			if err != nil {
 				log.Printf("Failed to add size %s for product %s: %v", size, product.name, err)
 				continue
 			}
-			sizeID, _ := result.LastInsertId()
 			sizeIDs[i] = sizeID
 		}
 
@@ -397,3 +408,174 @@ func seedProducts() {
 		}
 	}
 }
+
+func seedTaxRules() {
+	fmt.Println("Seeding tax rules...")
+
+	// Clear existing tax rules
+	_, err := db.Exec("DELETE FROM tax_rules")
+	if err != nil {
+		log.Printf("Warning: Failed to clear tax_rules table: %v", err)
+	}
+
+	rules := []struct {
+		name             string
+		country          string
+		state            string
+		rate             float64
+		priceIncludesTax bool
+	}{
+		{"California Sales Tax", "US", "CA", 7.25, false},
+		{"New York Sales Tax", "US", "NY", 4, false},
+		{"Texas Sales Tax", "US", "TX", 6.25, false},
+		{"UK VAT", "GB", "", 20, true},
+		{"German VAT", "DE", "", 19, true},
+	}
+
+	for _, rule := range rules {
+		_, err := db.Exec(
+			"INSERT INTO tax_rules (name, country, state, rate, price_includes_tax) VALUES (?, ?, ?, ?, ?)",
+			rule.name, rule.country, rule.state, rule.rate, rule.priceIncludesTax,
+		)
+		if err != nil {
+			log.Printf("Failed to create tax rule %s: %v", rule.name, err)
+		} else {
+			fmt.Printf("Tax rule created: %s\n", rule.name)
+		}
+	}
+}
+
+func seedShippingZones() {
+	fmt.Println("Seeding shipping zones...")
+
+	// Clear existing shipping zones
+	tables := []string{
+		"shipping_weight_tiers",
+		"shipping_methods",
+		"shipping_zones",
+	}
+
+	for _, table := range tables {
+		_, err := db.Exec(fmt.Sprintf("DELETE FROM %s", table))
+		if err != nil {
+			log.Printf("Warning: Failed to clear %s table: %v", table, err)
+		}
+	}
+
+	type tier struct {
+		maxWeight float64
+		rate      float64
+	}
+	type method struct {
+		code          string
+		name          string
+		pricing       string
+		rate          float64
+		freeThreshold float64
+		minDays       int
+		maxDays       int
+		tiers         []tier
+	}
+
+	zones := []struct {
+		name         string
+		country      string
+		postalPrefix string
+		methods      []method
+	}{
+		{
+			name:    "United States",
+			country: "US",
+			methods: []method{
+				{"standard", "Standard Shipping", "free_over", 5.99, 50, 3, 7, nil},
+				{"express", "Express Shipping", "weight", 0, 0, 1, 2, []tier{{1, 12.99}, {5, 19.99}, {20, 34.99}}},
+				{"pickup", "Store Pickup", "flat", 0, 0, 0, 1, nil},
+			},
+		},
+		{
+			name:         "Alaska",
+			country:      "US",
+			postalPrefix: "99",
+			methods: []method{
+				{"standard", "Standard Shipping", "flat", 14.99, 0, 5, 10, nil},
+				{"express", "Express Shipping", "weight", 0, 0, 2, 4, []tier{{1, 29.99}, {5, 49.99}}},
+			},
+		},
+		{
+			name:    "United Kingdom",
+			country: "GB",
+			methods: []method{
+				{"standard", "Standard Shipping", "flat", 4.99, 0, 3, 5, nil},
+				{"express", "Express Shipping", "flat", 9.99, 0, 1, 2, nil},
+			},
+		},
+	}
+
+	for _, zone := range zones {
+		var zoneID int64
+		err := db.QueryRow(
+			"INSERT INTO shipping_zones (name, country, postal_prefix) VALUES (?, ?, ?) RETURNING id",
+			zone.name, zone.country, zone.postalPrefix,
+		).Scan(&zoneID)
+		if err != nil {
+			log.Printf("Failed to create shipping zone %s: %v", zone.name, err)
+			continue
+		}
+
+		for _, method := range zone.methods {
+			var methodID int64
+			err := db.QueryRow(
+				"INSERT INTO shipping_methods (zone_id, code, name, pricing, rate, free_threshold, min_days, max_days) VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id",
+				zoneID, method.code, method.name, method.pricing, money.FromFloat(method.rate), money.FromFloat(method.freeThreshold), method.minDays, method.maxDays,
+			).Scan(&methodID)
+			if err != nil {
+				log.Printf("Failed to add shipping method %s to %s: %v", method.code, zone.name, err)
+				continue
+			}
+
+			for _, tier := range method.tiers {
+				_, err := db.Exec(
+					"INSERT INTO shipping_weight_tiers (method_id, max_weight, rate) VALUES (?, ?, ?)",
+					methodID, tier.maxWeight, money.FromFloat(tier.rate),
+				)
+				if err != nil {
+					log.Printf("Failed to add weight tier for %s: %v", method.code, err)
+				}
+			}
+		}
+
+		fmt.Printf("Shipping zone created: %s\n", zone.name)
+	}
+}
+
+func seedExchangeRates() {
+	fmt.Println("Seeding exchange rates...")
+
+	// Clear existing exchange rates
+	_, err := db.Exec("DELETE FROM exchange_rates")
+	if err != nil {
+		log.Printf("Warning: Failed to clear exchange_rates table: %v", err)
+	}
+
+	// Rates against the default USD store currency
+	rates := []struct {
+		currency string
+		rate     float64
+	}{
+		{"EUR", 0.92},
+		{"GBP", 0.79},
+		{"CAD", 1.36},
+	}
+
+	for _, rate := range rates {
+		_, err := db.Exec(
+			"INSERT INTO exchange_rates (currency, rate) VALUES (?, ?)",
+			rate.currency, rate.rate,
+		)
+		if err != nil {
+			log.Printf("Failed to create exchange rate %s: %v", rate.currency, err)
+		} else {
+			fmt.Printf("Exchange rate created: %s\n", rate.currency)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"backend/dialect"
+	"backend/migrations"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// DefaultPath is the SQLite database used when DATABASE_URL is not set
+const DefaultPath = "./database/data/ecommerce.db"
+
+var DB *sql.DB
+
+// Dialect is the SQL dialect of DB
+var Dialect dialect.Dialect
+
+// InitDatabase initializes the database. It refuses to start on a schema
+// with pending migrations unless AUTO_MIGRATE is enabled.
+func InitDatabase() {
+	Open()
+
+	// Bring the schema up to date
+	pending, err := migrations.Pending(DB, Dialect)
+	if err != nil {
+		log.Fatalf("Failed to read schema migrations: %v", err)
+	}
+	if len(pending) > 0 {
+		if !autoMigrate() {
+			log.Fatalf("Database has %d pending migrations, run `go run ./cmd/migrate up` or set AUTO_MIGRATE=true", len(pending))
+		}
+		applied, err := migrations.Up(DB, Dialect)
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		for _, migration := range applied {
+			log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
+		}
+	}
+
+	// Fill in slugs for rows created without one, e.g. by the seeder
+	backfillSlugs()
+}
+
+// Open connects to the database named by DATABASE_URL without touching its schema
+func Open() {
+	db, d, err := Connect(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+
+	// Test the connection
+	if err := db.Ping(); err != nil {
+		log.Fatalf("Failed to ping database: %v", err)
+	}
+
+	DB = db
+	Dialect = d
+	fmt.Printf("Database connection established (%s)\n", d)
+}
+
+// Connect opens the database at url and reports its dialect. An empty url
+// is the SQLite database at DefaultPath; otherwise it is sqlite://<path>,
+// a SQLite file: URI, or a postgres:// connection URL.
+func Connect(url string) (*sql.DB, dialect.Dialect, error) {
+	switch {
+	case url == "":
+		return connectSQLite(DefaultPath)
+	case strings.HasPrefix(url, "sqlite://"):
+		return connectSQLite(strings.TrimPrefix(url, "sqlite://"))
+	case strings.HasPrefix(url, "file:"):
+		db, err := sql.Open("sqlite3", url)
+		return db, dialect.SQLite, err
+	case strings.HasPrefix(url, "postgres://"), strings.HasPrefix(url, "postgresql://"):
+		db, err := connectPostgres(url)
+		return db, dialect.Postgres, err
+	}
+	return nil, "", fmt.Errorf("unsupported DATABASE_URL %q, expected sqlite://<path> or postgres://", url)
+}
+
+// connectSQLite opens the SQLite database at path, creating its directory
+func connectSQLite(path string) (*sql.DB, dialect.Dialect, error) {
+	if path == "" {
+		return nil, "", fmt.Errorf("DATABASE_URL has no SQLite database path")
+	}
+
+	// Create database directory if it doesn't exist
+	dbDir := filepath.Dir(path)
+	if _, err := os.Stat(dbDir); os.IsNotExist(err) {
+		if err := os.MkdirAll(dbDir, 0755); err != nil {
+			return nil, "", fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	return db, dialect.SQLite, err
+}
+
+// autoMigrate reports whether pending migrations are applied on startup
+func autoMigrate() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("AUTO_MIGRATE"))
+	return enabled
+}
+
+// CloseDatabase closes the database connection
+func CloseDatabase() {
+	if DB != nil {
+		DB.Close()
+		fmt.Println("Database connection closed")
+	}
+}
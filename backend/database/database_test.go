@@ -0,0 +1,83 @@
+package database_test
+
+import (
+	"backend/database"
+	"backend/database/dbtest"
+	"backend/dialect"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM users WHERE id = ?", "SELECT * FROM users WHERE id = $1"},
+		{"INSERT INTO t (a, b) VALUES (?, ?)", "INSERT INTO t (a, b) VALUES ($1, $2)"},
+		{"SELECT '?' FROM t WHERE a = ?", "SELECT '?' FROM t WHERE a = $1"},
+		{"SELECT 'it''s ?' FROM t WHERE a = ?", "SELECT 'it''s ?' FROM t WHERE a = $1"},
+		{`SELECT "odd?name" FROM t WHERE a = ?`, `SELECT "odd?name" FROM t WHERE a = $1`},
+		{"SELECT a -- why?\nFROM t WHERE b = ?", "SELECT a -- why?\nFROM t WHERE b = $1"},
+		{"SELECT a /* why? */ FROM t WHERE b = ? AND c = ?", "SELECT a /* why? */ FROM t WHERE b = $1 AND c = $2"},
+		{"SELECT 'unterminated ?", "SELECT 'unterminated ?"},
+	}
+
+	for _, tt := range tests {
+		if got := database.Rebind(tt.query); got != tt.want {
+			t.Errorf("Rebind(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestConnect(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		url     string
+		dialect dialect.Dialect
+	}{
+		{"sqlite://" + filepath.Join(dir, "nested", "a.db"), dialect.SQLite},
+		{"file:" + filepath.Join(dir, "b.db") + "?cache=shared", dialect.SQLite},
+	}
+
+	for _, tt := range tests {
+		db, d, err := database.Connect(tt.url)
+		if err != nil {
+			t.Fatalf("Connect(%q): %v", tt.url, err)
+		}
+		if d != tt.dialect {
+			t.Errorf("Connect(%q) dialect = %s, want %s", tt.url, d, tt.dialect)
+		}
+		if err := db.Ping(); err != nil {
+			t.Errorf("Connect(%q) ping: %v", tt.url, err)
+		}
+		db.Close()
+	}
+
+	for _, url := range []string{"mysql://localhost/shop", "sqlite://"} {
+		if _, _, err := database.Connect(url); err == nil {
+			t.Errorf("Connect(%q) succeeded, want an error", url)
+		}
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		insert := "INSERT INTO categories (name) VALUES (?)"
+		if _, err := db.Exec(insert, "Shoes"); err != nil {
+			t.Fatalf("inserting category: %v", err)
+		}
+
+		_, err := db.Exec(insert, "Shoes")
+		if !database.IsUniqueViolation(err) {
+			t.Errorf("duplicate insert error %v is not a unique violation", err)
+		}
+
+		_, err = db.Exec("INSERT INTO categories (id) VALUES (?)", nil)
+		if err == nil || database.IsUniqueViolation(err) {
+			t.Errorf("NOT NULL violation %v reported as a unique violation", err)
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package dbtest
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+// CreateUser creates an account with a role and an email numbered in the
+// order accounts are created, user1@example.com first
+func CreateUser(t *testing.T, db *sql.DB, role string) *models.User {
+	t.Helper()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatalf("counting users: %v", err)
+	}
+
+	user := &models.User{Name: "Test User", Email: fmt.Sprintf("user%d@example.com", count+1), Password: "hash", Role: role}
+	err := db.QueryRow(
+		"INSERT INTO users (name, email, password, role, created_at, updated_at) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING id",
+		user.Name, user.Email, user.Password, user.Role).Scan(&user.ID)
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	return user
+}
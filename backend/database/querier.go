@@ -0,0 +1,15 @@
+package database
+
+import "database/sql"
+
+// TimestampFormat is the text format of CURRENT_TIMESTAMP. Times stored in it
+// compare as text with CURRENT_TIMESTAMP, such as expiry times.
+const TimestampFormat = "2006-01-02 15:04:05"
+
+// Querier is satisfied by both *sql.DB and *sql.Tx, so that the same code runs
+// on its own or in a caller's transaction
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"backend/utils"
+	"log"
+)
+
+// UniqueSlug builds a slug from name that no other row of the table uses,
+// adding a numeric suffix on collision. Product slugs must also avoid the
+// old slugs of other products so that those keep resolving.
+func UniqueSlug(q Querier, table, name string, excludeID int64) (string, error) {
+	return utils.UniqueSlug(name, table, func(slug string) (bool, error) {
+		return slugTaken(q, table, slug, excludeID)
+	})
+}
+
+// slugTaken reports whether another row already uses the slug
+func slugTaken(q Querier, table, slug string, excludeID int64) (bool, error) {
+	var taken bool
+	err := q.QueryRow("SELECT EXISTS(SELECT 1 FROM "+table+" WHERE slug = ? AND id != ?)", slug, excludeID).Scan(&taken)
+	if err != nil || taken || table != "products" {
+		return taken, err
+	}
+
+	err = q.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM product_slug_history WHERE slug = ? AND product_id != ?)",
+		slug, excludeID).Scan(&taken)
+	return taken, err
+}
+
+// backfillSlugs generates slugs for products and categories that have none
+func backfillSlugs() {
+	for _, table := range []string{"categories", "products"} {
+		rows, err := DB.Query("SELECT id, name FROM " + table + " WHERE slug IS NULL OR slug = '' ORDER BY id")
+		if err != nil {
+			log.Fatalf("Failed to read %s without slugs: %v", table, err)
+		}
+
+		type row struct {
+			id   int64
+			name string
+		}
+		var missing []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.name); err == nil {
+				missing = append(missing, r)
+			}
+		}
+		rows.Close()
+
+		for _, r := range missing {
+			slug, err := UniqueSlug(DB, table, r.name, r.id)
+			if err != nil {
+				log.Fatalf("Failed to generate slug for %s %d: %v", table, r.id, err)
+			}
+			if _, err := DB.Exec("UPDATE "+table+" SET slug = ? WHERE id = ?", slug, r.id); err != nil {
+				log.Fatalf("Failed to store slug for %s %d: %v", table, r.id, err)
+			}
+		}
+	}
+}
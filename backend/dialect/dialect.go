@@ -0,0 +1,27 @@
+package dialect
+
+// Dialect names the SQL dialect of a database. Queries are written with ?
+// placeholders in SQL that both dialects accept; the dialect only selects
+// the migration files and features such as SQLite's full-text search and
+// row locks.
+type Dialect string
+
+const (
+	// SQLite is the default, file based database
+	SQLite Dialect = "sqlite"
+
+	// Postgres is PostgreSQL, selected with a postgres:// DATABASE_URL
+	Postgres Dialect = "postgres"
+)
+
+// All lists every supported dialect
+var All = []Dialect{SQLite, Postgres}
+
+// ForUpdate returns the clause that locks the rows a SELECT reads until the
+// transaction ends. SQLite has none, its first write locks the whole database.
+func (d Dialect) ForUpdate() string {
+	if d == Postgres {
+		return " FOR UPDATE"
+	}
+	return ""
+}
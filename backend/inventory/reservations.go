@@ -0,0 +1,195 @@
+package inventory
+
+import (
+	"backend/database"
+	"backend/dialect"
+	"backend/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// activeHolds sums the unexpired reservations on the inventory row aliased as pi
+const activeHolds = `COALESCE((
+		SELECT SUM(r.quantity) FROM inventory_reservations r
+		WHERE r.product_id = pi.product_id AND r.color_id = pi.color_id AND r.size_id = pi.size_id
+		AND r.expires_at > CURRENT_TIMESTAMP%s), 0)`
+
+var (
+	// Available is the SQL expression for the sellable quantity of the
+	// inventory row aliased as pi: stock on hand minus active reservations
+	Available = "(pi.quantity - " + fmt.Sprintf(activeHolds, "") + ")"
+
+	// AvailableToUser is like Available but ignores the holds of one user,
+	// whose ID is the expression's only argument
+	AvailableToUser = "(pi.quantity - " + fmt.Sprintf(activeHolds, " AND r.user_id <> ?") + ")"
+
+	// ErrInsufficientStock is returned when a cart line cannot be reserved
+	ErrInsufficientStock = errors.New("not enough inventory")
+
+	// ErrEmptyCart is returned when there is nothing to reserve
+	ErrEmptyCart = errors.New("cart is empty")
+)
+
+// TTL returns how long checkout holds last, configured by RESERVATION_TTL_MINUTES
+func TTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("RESERVATION_TTL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// Reserve replaces the user's holds with one per cart line, all expiring together.
+// If any line is short, nothing is reserved and the shortages are returned.
+func Reserve(db *sql.DB, d dialect.Dialect, userID int64) (time.Time, []models.StockShortage, error) {
+	expiresAt := time.Now().UTC().Add(TTL()).Truncate(time.Second)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return expiresAt, nil, err
+	}
+	defer tx.Rollback()
+
+	if err := Release(tx, userID); err != nil {
+		return expiresAt, nil, err
+	}
+
+	// Lock the inventory of the cart, so that concurrent checkouts reserve one after another
+	_, err = tx.Exec(`
+		SELECT pi.id FROM product_inventory pi
+		WHERE EXISTS (
+			SELECT 1 FROM cart c
+			WHERE c.user_id = ? AND c.product_id = pi.product_id AND c.color_id = pi.color_id AND c.size_id = pi.size_id
+		)
+		ORDER BY pi.id`+d.ForUpdate(),
+		userID)
+	if err != nil {
+		return expiresAt, nil, err
+	}
+
+	// Check every cart line against the stock not held by other shoppers
+	rows, err := tx.Query(`
+		SELECT c.id, c.product_id, c.color_id, c.size_id, c.quantity, COALESCE(`+AvailableToUser+`, 0)
+		FROM cart c
+		LEFT JOIN product_inventory pi ON c.product_id = pi.product_id AND c.color_id = pi.color_id AND c.size_id = pi.size_id
+		WHERE c.user_id = ?
+		ORDER BY c.id`,
+		userID, userID)
+	if err != nil {
+		return expiresAt, nil, err
+	}
+
+	var lines []models.StockShortage
+	for rows.Next() {
+		var line models.StockShortage
+		err := rows.Scan(&line.CartItemID, &line.ProductID, &line.ColorID, &line.SizeID, &line.Requested, &line.Available)
+		if err != nil {
+			rows.Close()
+			return expiresAt, nil, err
+		}
+		lines = append(lines, line)
+	}
+	rows.Close()
+	if len(lines) == 0 {
+		return expiresAt, nil, ErrEmptyCart
+	}
+
+	shortages := []models.StockShortage{}
+	for _, line := range lines {
+		if line.Available < line.Requested {
+			line.Available = max(line.Available, 0)
+			shortages = append(shortages, line)
+			continue
+		}
+
+		// Only hold stock that is still available when the hold is written
+		result, err := tx.Exec(
+			"UPDATE product_inventory AS pi SET updated_at = updated_at WHERE product_id = ? AND color_id = ? AND size_id = ? AND "+AvailableToUser+" >= ?",
+			line.ProductID, line.ColorID, line.SizeID, userID, line.Requested)
+		if err != nil {
+			return expiresAt, nil, err
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			if err != nil {
+				return expiresAt, nil, err
+			}
+			err := tx.QueryRow("SELECT "+AvailableToUser+" FROM product_inventory pi WHERE product_id = ? AND color_id = ? AND size_id = ?",
+				userID, line.ProductID, line.ColorID, line.SizeID).Scan(&line.Available)
+			if err != nil && err != sql.ErrNoRows {
+				return expiresAt, nil, err
+			}
+			line.Available = max(line.Available, 0)
+			shortages = append(shortages, line)
+			continue
+		}
+
+		_, err = tx.Exec(
+			`INSERT INTO inventory_reservations (user_id, product_id, color_id, size_id, quantity, expires_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			userID, line.ProductID, line.ColorID, line.SizeID, line.Requested, expiresAt.Format(database.TimestampFormat), time.Now())
+		if err != nil {
+			return expiresAt, nil, err
+		}
+	}
+	if len(shortages) > 0 {
+		return expiresAt, shortages, ErrInsufficientStock
+	}
+
+	return expiresAt, nil, tx.Commit()
+}
+
+// Release drops every hold of a user, e.g. when the cart changes or the order is placed
+func Release(db database.Querier, userID int64) error {
+	_, err := db.Exec("DELETE FROM inventory_reservations WHERE user_id = ?", userID)
+	return err
+}
+
+// ReleaseExpired drops every hold past its expiry and returns how many were dropped
+func ReleaseExpired(q database.Querier) (int64, error) {
+	result, err := q.Exec("DELETE FROM inventory_reservations WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// StartReaper releases expired holds in the background at the given interval
+func StartReaper(db *sql.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			released, err := ReleaseExpired(db)
+			if err != nil {
+				log.Printf("Failed to release expired reservations: %v", err)
+			} else if released > 0 {
+				log.Printf("Released %d expired reservations", released)
+			}
+		}
+	}()
+}
+
+// Restock puts units of a variant back into stock, recreating its inventory
+// row if an admin removed it since
+func Restock(db database.Querier, productID, colorID, sizeID int64, quantity int) error {
+	result, err := db.Exec(
+		"UPDATE product_inventory SET quantity = quantity + ?, updated_at = ? WHERE product_id = ? AND color_id = ? AND size_id = ?",
+		quantity, time.Now(), productID, colorID, sizeID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		return nil
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO product_inventory (product_id, color_id, size_id, quantity, updated_at) VALUES (?, ?, ?, ?, ?)",
+		productID, colorID, sizeID, quantity, time.Now())
+	return err
+}
@@ -0,0 +1,279 @@
+// Package logins throttles failed logins per account and per IP address and
+// keeps each user's login history. The counters live in the database, so they
+// need no cache and hold across restarts and servers.
+package logins
+
+import (
+	"backend/database"
+	"backend/models"
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reasons recorded for failed attempts
+const (
+	ReasonInvalidCredentials = "invalid_credentials"
+	ReasonInvalidCode        = "invalid_two_factor_code"
+	ReasonTooManyAttempts    = "too_many_attempts"
+	ReasonDisabled           = "account_disabled"
+	ReasonResetRequired      = "password_reset_required"
+)
+
+// Throttle scopes, an account is throttled by its email so that unknown emails behave the same
+const (
+	scopeAccount = "account"
+	scopeIP      = "ip"
+)
+
+// Attempt is a login attempt from a client
+type Attempt struct {
+	UserID    int64 // 0 when no account uses the email
+	Email     string
+	IP        string
+	UserAgent string
+}
+
+// MaxFailures returns how many failed logins in a row lock an account, configured by LOGIN_MAX_FAILURES
+func MaxFailures() int {
+	return envInt("LOGIN_MAX_FAILURES", 5)
+}
+
+// MaxIPFailures returns how many failed logins lock an IP address, configured by LOGIN_MAX_IP_FAILURES
+func MaxIPFailures() int {
+	return envInt("LOGIN_MAX_IP_FAILURES", 20)
+}
+
+// LockoutDuration returns how long a lockout lasts, configured by LOGIN_LOCKOUT_MINUTES.
+// Failures older than that are forgotten.
+func LockoutDuration() time.Duration {
+	return time.Duration(envInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute
+}
+
+// Delay returns how long an account has to wait after a number of failed
+// logins in a row: nothing after the first, then 1, 2, 4... seconds, until
+// MaxFailures locks it for the LockoutDuration
+func Delay(failures int) time.Duration {
+	if failures >= MaxFailures() {
+		return LockoutDuration()
+	}
+	if failures < 2 {
+		return 0
+	}
+	return min(time.Second<<min(failures-2, 16), LockoutDuration())
+}
+
+// ipDelay returns how long an IP address has to wait after a number of failed
+// logins. Addresses can be shared, so they are only locked, not slowed down.
+func ipDelay(failures int) time.Duration {
+	if failures >= MaxIPFailures() {
+		return LockoutDuration()
+	}
+	return 0
+}
+
+// Check returns how long logins to an email or from an IP address are refused, zero when they are allowed
+func Check(q database.Querier, email, ip string) (time.Duration, error) {
+	var blockedUntil time.Time
+	err := q.QueryRow(`
+		SELECT blocked_until FROM login_throttles
+		WHERE ((scope = ? AND subject = ?) OR (scope = ? AND subject = ?)) AND blocked_until > CURRENT_TIMESTAMP
+		ORDER BY blocked_until DESC LIMIT 1`,
+		scopeAccount, accountSubject(email), scopeIP, ip).Scan(&blockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return max(time.Until(blockedUntil).Round(time.Second), time.Second), nil
+}
+
+// Begin counts an attempt as failed against the account and the IP address
+// before its password or code is checked, so that parallel attempts cannot
+// all get through before the first failure is counted. It returns how long
+// the attempt is refused, zero when it may go ahead, in which case Passed
+// takes the failure back once the password or code is right.
+func Begin(db *sql.DB, attempt Attempt) (time.Duration, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	counted, err := countFailure(tx, scopeAccount, accountSubject(attempt.Email), Delay)
+	if err == nil && counted && attempt.IP != "" {
+		counted, err = countFailure(tx, scopeIP, attempt.IP, ipDelay)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !counted {
+		tx.Rollback()
+		wait, err := Check(db, attempt.Email, attempt.IP)
+		return max(wait, time.Second), err
+	}
+	return 0, tx.Commit()
+}
+
+// Passed takes back the failure Begin counted for an attempt whose password or code was right
+func Passed(db *sql.DB, attempt Attempt) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := uncountFailure(tx, scopeAccount, accountSubject(attempt.Email), Delay); err != nil {
+		return err
+	}
+	if attempt.IP != "" {
+		if err := uncountFailure(tx, scopeIP, attempt.IP, ipDelay); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Failed records an attempt with a wrong email, password or two-factor code,
+// which Begin has already counted
+func Failed(q database.Querier, attempt Attempt, reason string) error {
+	return record(q, attempt, false, reason)
+}
+
+// Refused records a login that was turned away for a reason other than wrong
+// credentials, without counting it as a failure
+func Refused(q database.Querier, attempt Attempt, reason string) error {
+	return record(q, attempt, false, reason)
+}
+
+// Succeeded records a successful login and clears the account's failures
+func Succeeded(db *sql.DB, attempt Attempt) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := record(tx, attempt, true, ""); err != nil {
+		return err
+	}
+	if err := unlock(tx, attempt.Email); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Unlock clears the failed logins of an account, lifting its delay or lockout
+func Unlock(q database.Querier, email string) error {
+	return unlock(q, email)
+}
+
+// History returns a user's most recent login attempts, newest first
+func History(q database.Querier, userID int64, limit int) ([]models.LoginAttempt, error) {
+	rows, err := q.Query(`
+		SELECT id, COALESCE(ip_address, ''), COALESCE(user_agent, ''), success, COALESCE(reason, ''), created_at
+		FROM login_attempts
+		WHERE user_id = ?
+		ORDER BY id DESC LIMIT ?`,
+		userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []models.LoginAttempt{}
+	for rows.Next() {
+		var attempt models.LoginAttempt
+		err := rows.Scan(&attempt.ID, &attempt.IPAddress, &attempt.UserAgent, &attempt.Success, &attempt.Reason, &attempt.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
+
+// record adds an attempt to the login history
+func record(db database.Querier, attempt Attempt, success bool, reason string) error {
+	var userID interface{}
+	if attempt.UserID != 0 {
+		userID = attempt.UserID
+	}
+	_, err := db.Exec(
+		"INSERT INTO login_attempts (user_id, email, ip_address, user_agent, success, reason) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, attempt.Email, attempt.IP, attempt.UserAgent, success, reason)
+	return err
+}
+
+// countFailure adds a failure to a throttle unless it is refusing attempts,
+// starting over when the last one is older than the lockout, and refuses
+// attempts for the resulting delay. It reports whether the failure was counted.
+func countFailure(db database.Querier, scope, subject string, delay func(failures int) time.Duration) (bool, error) {
+	now := time.Now().UTC()
+
+	var failures int
+	err := db.QueryRow(`
+		INSERT INTO login_throttles (scope, subject, failures, last_failure_at) VALUES (?, ?, 1, ?)
+		ON CONFLICT(scope, subject) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END,
+			last_failure_at = excluded.last_failure_at
+		WHERE login_throttles.blocked_until IS NULL OR login_throttles.blocked_until <= CURRENT_TIMESTAMP
+		RETURNING failures`,
+		scope, subject, now.Format(database.TimestampFormat), now.Add(-LockoutDuration()).Format(database.TimestampFormat)).Scan(&failures)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, block(db, scope, subject, delay(failures))
+}
+
+// uncountFailure takes a failure off a throttle and refuses attempts for the delay of the rest
+func uncountFailure(db database.Querier, scope, subject string, delay func(failures int) time.Duration) error {
+	var failures int
+	err := db.QueryRow(
+		"UPDATE login_throttles SET failures = failures - 1 WHERE scope = ? AND subject = ? AND failures > 0 RETURNING failures",
+		scope, subject).Scan(&failures)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return block(db, scope, subject, delay(failures))
+}
+
+// block refuses attempts of a throttle for a while from now, or lifts the block for no wait
+func block(db database.Querier, scope, subject string, wait time.Duration) error {
+	var blockedUntil interface{}
+	if wait > 0 {
+		blockedUntil = time.Now().UTC().Add(wait).Format(database.TimestampFormat)
+	}
+	_, err := db.Exec("UPDATE login_throttles SET blocked_until = ? WHERE scope = ? AND subject = ?", blockedUntil, scope, subject)
+	return err
+}
+
+// unlock deletes the throttle of an account
+func unlock(db database.Querier, email string) error {
+	_, err := db.Exec("DELETE FROM login_throttles WHERE scope = ? AND subject = ?", scopeAccount, accountSubject(email))
+	return err
+}
+
+// accountSubject is the throttle subject of an email, ignoring case and spaces
+func accountSubject(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// envInt reads a positive number from the environment
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,122 @@
+package logins_test
+
+import (
+	"backend/database/dbtest"
+	"backend/dialect"
+	"backend/logins"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	t.Setenv("LOGIN_MAX_FAILURES", "5")
+	t.Setenv("LOGIN_LOCKOUT_MINUTES", "15")
+
+	want := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 15 * time.Minute, 15 * time.Minute}
+	for failures, delay := range want {
+		if got := logins.Delay(failures); got != delay {
+			t.Errorf("Delay(%d) = %v, want %v", failures, got, delay)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	t.Setenv("LOGIN_MAX_FAILURES", "3")
+	t.Setenv("LOGIN_MAX_IP_FAILURES", "4")
+
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		attempt := logins.Attempt{UserID: 1, Email: "ada@example.com", IP: "10.0.0.1", UserAgent: "test"}
+
+		// The first failure costs nothing, the second makes the account wait
+		assertWait(t, db, "ada@example.com", "10.0.0.1", false)
+		fail(t, db, attempt)
+		assertWait(t, db, "ada@example.com", "10.0.0.2", false)
+		fail(t, db, attempt)
+		assertWait(t, db, "ADA@example.com", "10.0.0.2", true)
+		assertWait(t, db, "bob@example.com", "10.0.0.2", false)
+
+		// Logging in clears the account's failures
+		if err := logins.Succeeded(db, attempt); err != nil {
+			t.Fatalf("Succeeded: %v", err)
+		}
+		assertWait(t, db, "ada@example.com", "10.0.0.2", false)
+
+		// The address keeps counting across accounts
+		for _, email := range []string{"bob@example.com", "eve@example.com"} {
+			fail(t, db, logins.Attempt{Email: email, IP: "10.0.0.1"})
+		}
+		assertWait(t, db, "carol@example.com", "10.0.0.1", true)
+		assertWait(t, db, "carol@example.com", "10.0.0.2", false)
+
+		history, err := logins.History(db, 1, 10)
+		if err != nil {
+			t.Fatalf("History: %v", err)
+		}
+		if len(history) != 3 || !history[0].Success || history[1].Reason != logins.ReasonInvalidCredentials {
+			t.Errorf("History = %+v, want a success after two failures", history)
+		}
+	})
+}
+
+func TestUnlock(t *testing.T) {
+	t.Setenv("LOGIN_MAX_FAILURES", "2")
+
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		attempt := logins.Attempt{Email: "ada@example.com", IP: "10.0.0.1"}
+
+		for range 2 {
+			fail(t, db, attempt)
+		}
+		if wait, err := logins.Check(db, attempt.Email, "10.0.0.2"); err != nil || wait < 14*time.Minute {
+			t.Errorf("Check of a locked account = %v, %v, want the lockout", wait, err)
+		}
+
+		if err := logins.Unlock(db, attempt.Email); err != nil {
+			t.Fatalf("Unlock: %v", err)
+		}
+		assertWait(t, db, attempt.Email, "10.0.0.2", false)
+	})
+}
+
+func TestBeginCountsBeforeTheCheck(t *testing.T) {
+	t.Setenv("LOGIN_MAX_FAILURES", "5")
+
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		attempt := logins.Attempt{Email: "ada@example.com", IP: "10.0.0.1"}
+
+		// Attempts still being checked count, so a burst cannot get past the delay
+		for i, want := range []bool{false, false, true} {
+			wait, err := logins.Begin(db, attempt)
+			if err != nil || (wait > 0) != want {
+				t.Fatalf("Begin %d = %v, %v, want waiting %v", i+1, wait, err, want)
+			}
+		}
+
+		// A right password takes its attempt back, which lifts the delay
+		if err := logins.Passed(db, attempt); err != nil {
+			t.Fatalf("Passed: %v", err)
+		}
+		assertWait(t, db, attempt.Email, attempt.IP, false)
+	})
+}
+
+// fail counts and records a failed login
+func fail(t *testing.T, db *sql.DB, attempt logins.Attempt) {
+	t.Helper()
+
+	if wait, err := logins.Begin(db, attempt); err != nil || wait > 0 {
+		t.Fatalf("Begin = %v, %v, want no wait", wait, err)
+	}
+	if err := logins.Failed(db, attempt, logins.ReasonInvalidCredentials); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+}
+
+func assertWait(t *testing.T, db *sql.DB, email, ip string, want bool) {
+	t.Helper()
+
+	if wait, err := logins.Check(db, email, ip); err != nil || (wait > 0) != want {
+		t.Errorf("Check(%s, %s) = %v, %v, want waiting %v", email, ip, wait, err, want)
+	}
+}
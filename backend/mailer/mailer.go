@@ -0,0 +1,93 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer is implemented by every way of delivering email
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// current delivers the mail sent through Send
+var current Mailer = LogMailer{}
+
+// Init selects the mailer configured by MAILER: smtp, file or log (the default)
+func Init() error {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "StyleSpace <no-reply@stylespace.com>"
+	}
+
+	kind := os.Getenv("MAILER")
+	switch kind {
+	case "", "log":
+		kind = "log"
+		Use(LogMailer{})
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "./mail"
+		}
+		Use(&FileMailer{Dir: dir, From: from})
+	case "smtp":
+		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			port = 587
+		}
+		if os.Getenv("SMTP_HOST") == "" {
+			return fmt.Errorf("MAILER=smtp needs SMTP_HOST")
+		}
+		Use(&SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		})
+	default:
+		return fmt.Errorf("unknown MAILER %q, expected smtp, file or log", kind)
+	}
+
+	log.Printf("Sending mail with the %s mailer", kind)
+	return nil
+}
+
+// Use replaces the mailer, e.g. with a fake in tests
+func Use(m Mailer) {
+	current = m
+}
+
+// Send delivers a message with the configured mailer
+func Send(msg Message) error {
+	return current.Send(msg)
+}
+
+// LogMailer writes messages to the log instead of sending them
+type LogMailer struct{}
+
+// linkToken matches the token of a verification or password reset link
+var linkToken = regexp.MustCompile(`([?&]token=)[^&\s]+`)
+
+// Send logs the message with the tokens of its links redacted, since logs are
+// read by more people than the recipient
+func (LogMailer) Send(msg Message) error {
+	log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, redact(msg.Body))
+	return nil
+}
+
+// redact replaces the tokens of links in a text
+func redact(text string) string {
+	return linkToken.ReplaceAllString(text, "${1}[redacted]")
+}
@@ -0,0 +1,26 @@
+package mailer_test
+
+import (
+	"backend/mailer"
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestLogMailerRedactsTokens(t *testing.T) {
+	var out bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&out)
+	t.Cleanup(func() { log.SetOutput(previous) })
+
+	body := "Reset your password: http://localhost:3000/auth/reset-password?token=s3cr3t-T0ken_x&lang=en\nThanks"
+	if err := (mailer.LogMailer{}).Send(mailer.Message{To: "ada@example.com", Subject: "Reset", Body: body}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	logged := out.String()
+	if strings.Contains(logged, "s3cr3t") || !strings.Contains(logged, "reset-password?token=[redacted]&lang=en") {
+		t.Errorf("logged %q, want the token redacted", logged)
+	}
+}
@@ -1,10 +1,18 @@
 package main
 
 import (
+	"backend/controllers"
 	"backend/database"
+	"backend/inventory"
+	"backend/mailer"
+	"backend/payments"
+	"backend/repository"
 	"backend/routes"
+	"backend/search"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -24,9 +32,43 @@ func main() {
 	database.InitDatabase()
 	defer database.CloseDatabase()
 
+	// Register the payment providers enabled in the environment
+	payments.Init()
+
+	// Select how account emails are delivered
+	if err := mailer.Init(); err != nil {
+		log.Fatalf("Failed to configure mail: %v", err)
+	}
+
+	// Initialize the full-text search index
+	search.Init(database.DB, database.Dialect)
+
+	// Release expired checkout reservations in the background
+	inventory.StartReaper(database.DB, time.Minute)
+
+	// Handlers read and write through the repositories
+	h := controllers.NewHandler(database.DB, database.Dialect, repository.New(database.DB))
+
+	// Behind a reverse proxy, client addresses are read from PROXY_HEADER, such
+	// as X-Real-IP, on requests from TRUSTED_PROXIES only, a comma separated list
+	// of addresses or ranges. Any other client could claim any address.
+	proxyHeader := os.Getenv("PROXY_HEADER")
+	var trustedProxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if proxyHeader != "" && len(trustedProxies) == 0 {
+		log.Fatal("PROXY_HEADER is set without TRUSTED_PROXIES, the addresses of the proxies that set it")
+	}
+
 	// Create a new Fiber instance
 	app := fiber.New(fiber.Config{
-		AppName: "E-Commerce API",
+		AppName:                 "E-Commerce API",
+		ProxyHeader:             proxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          trustedProxies,
 	})
 
 	// Setup middlewares
@@ -40,12 +82,20 @@ func main() {
 	}))
 
 	// Routes setup
-	routes.SetupUserRoutes(app)
-	routes.SetupProductRoutes(app)
-	routes.SetupCartRoutes(app)
-	routes.SetupWishlistRoutes(app)
-	routes.SetupAddressRoutes(app)
-	routes.SetupOrderRoutes(app)
+	routes.SetupUserRoutes(app, h)
+	routes.SetupProductRoutes(app, h)
+	routes.SetupCartRoutes(app, h)
+	routes.SetupWishlistRoutes(app, h)
+	routes.SetupAddressRoutes(app, h)
+	routes.SetupOrderRoutes(app, h)
+	routes.SetupPaymentRoutes(app, h)
+	routes.SetupPromotionRoutes(app, h)
+	routes.SetupTaxRoutes(app, h)
+	routes.SetupShippingRoutes(app, h)
+	routes.SetupReturnRoutes(app, h)
+	routes.SetupSearchRoutes(app, h)
+	routes.SetupCurrencyRoutes(app, h)
+	routes.SetupAdminRoutes(app, h)
 
 	// Health check endpoint
 	app.Get("/api/health", func(c *fiber.Ctx) error {
@@ -1,70 +1,133 @@
 package middlewares
 
 import (
+	"backend/permissions"
+	"backend/sessions"
+	"backend/twofactor"
 	"backend/utils"
+	"database/sql"
+	"errors"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// authenticate validates the bearer token and stores the user data in the context
+func authenticate(c *fiber.Ctx, db *sql.DB) error {
+	// Get the authorization header
+	authHeader := c.Get("Authorization")
+	if authHeader == "" {
+		return errors.New("Unauthorized: No token provided")
+	}
+
+	// Check if the header format is correct
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return errors.New("Unauthorized: Invalid token format")
+	}
+
+	// Get the token
+	tokenString := parts[1]
+
+	// Validate the token
+	claims, err := utils.ValidateToken(tokenString)
+	if err != nil {
+		return errors.New("Unauthorized: Invalid token")
+	}
+
+	// Reject tokens of sessions that were logged out or revoked, and of disabled accounts
+	active, err := sessions.Active(db, claims.SessionID)
+	if err != nil || !active {
+		return errors.New("Unauthorized: Session expired or revoked")
+	}
+
+	// Set user data in context
+	c.Locals("userID", claims.UserID)
+	c.Locals("email", claims.Email)
+	c.Locals("role", claims.Role)
+	c.Locals("sessionID", claims.SessionID)
+
+	return nil
+}
+
+// setupPending returns an HTTP status and error message for users whose role
+// requires two-factor authentication they have not set up, or an empty message
+func setupPending(c *fiber.Ctx, db *sql.DB) (int, string) {
+	pending, err := twofactor.SetupPending(db, c.Locals("userID").(int64))
+	if err != nil {
+		return fiber.StatusInternalServerError, "Database error"
+	}
+	if pending {
+		return fiber.StatusForbidden, "Forbidden: your role requires two-factor authentication, set it up first"
+	}
+	return 0, ""
+}
+
 // Protected is a middleware that checks if the user is authenticated
-func Protected() fiber.Handler {
+func Protected(db *sql.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Get the authorization header
-		authHeader := c.Get("Authorization")
-		if authHeader == "" {
+		if err := authenticate(c, db); err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Unauthorized: No token provided",
+				"error": err.Error(),
 			})
 		}
-
-		// Check if the header format is correct
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Unauthorized: Invalid token format",
+		if status, message := setupPending(c, db); message != "" {
+			return c.Status(status).JSON(fiber.Map{
+				"error": message,
 			})
 		}
 
-		// Get the token
-		tokenString := parts[1]
+		// Continue
+		return c.Next()
+	}
+}
 
-		// Validate the token
-		claims, err := utils.ValidateToken(tokenString)
-		if err != nil {
+// Enrolling is a middleware like Protected that also lets in users who still
+// have to set up two-factor authentication, for the routes they need to do so
+func Enrolling(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := authenticate(c, db); err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Unauthorized: Invalid token",
+				"error": err.Error(),
 			})
 		}
 
-		// Set user data in context
-		c.Locals("userID", claims.UserID)
-		c.Locals("email", claims.Email)
-		c.Locals("role", claims.Role)
-
 		// Continue
 		return c.Next()
 	}
 }
 
-// AdminOnly is a middleware that checks if the user is an admin
-func AdminOnly() fiber.Handler {
+// RequirePermission is a middleware that checks if the user's role grants the
+// permission, or any of several permissions
+func RequirePermission(db *sql.DB, required ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// First run the Protected middleware
-		err := Protected()(c)
-		if err != nil {
-			return err
+		// Authenticate first, without handing control to the next handler
+		if err := authenticate(c, db); err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if status, message := setupPending(c, db); message != "" {
+			return c.Status(status).JSON(fiber.Map{
+				"error": message,
+			})
 		}
 
-		// Check if the user is an admin
-		role := c.Locals("role")
-		if role != "admin" {
+		// Check the permission against the user's current role
+		allowed, err := permissions.Has(db, c.Locals("userID").(int64), required...)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+		if !allowed {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error": "Forbidden: Admin access required",
+				"error": "Forbidden: " + strings.Join(required, " or ") + " permission required",
 			})
 		}
 
 		// Continue
 		return c.Next()
 	}
-} 
\ No newline at end of file
+}
@@ -0,0 +1,469 @@
+package migrations
+
+import (
+	"backend/dialect"
+	"backend/money"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Dir is where migration files live in the source tree, relative to the
+// backend directory. Each dialect has its own subdirectory with the same
+// numbered migrations.
+const Dir = "migrations/sql"
+
+//go:embed sql/*/*.sql
+var files embed.FS
+
+var (
+	// fileName matches migration files such as 0002_add_gift_cards.up.sql
+	fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+	// migrationName matches the name part of a migration file
+	migrationName = regexp.MustCompile(`^\w+$`)
+)
+
+// ErrNothingToRollBack is returned by Down when no migration is applied
+var ErrNothingToRollBack = errors.New("no migration to roll back")
+
+// Migration is one numbered schema change with the SQL to apply and revert it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and when it was applied, nil while it is pending
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load returns the embedded migrations of a dialect ordered by version
+func Load(d dialect.Dialect) ([]Migration, error) {
+	dir := "sql/" + string(d)
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %q: %w", d, err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s is not named NNNN_name.up.sql or NNNN_name.down.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := files.ReadFile(dir + "/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has files with different names", version)
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d needs both an up and a down file", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// List returns every migration with when it was applied
+func List(db *sql.DB, d dialect.Dialect) ([]Status, error) {
+	migrations, err := Load(d)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(migrations))
+	for i, migration := range migrations {
+		statuses[i] = Status{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations not yet applied, in the order they will run
+func Pending(db *sql.DB, d dialect.Dialect) ([]Migration, error) {
+	statuses, err := List(db, d)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration, each in its own transaction, and
+// returns the ones applied. It stops at the first migration that fails.
+func Up(db *sql.DB, d dialect.Dialect) ([]Migration, error) {
+	pending, err := Pending(db, d)
+	if err != nil {
+		return nil, err
+	}
+	var applied []Migration
+	for _, migration := range pending {
+		err := inTransaction(db, func(tx *sql.Tx) error {
+			// A SQLite database created before migrations is adopted by the first one
+			if migration.Version == 1 && d == dialect.SQLite {
+				if err := adoptLegacySchema(tx, migration); err != nil {
+					return err
+				}
+			}
+			if _, err := tx.Exec(migration.Up); err != nil {
+				return err
+			}
+			_, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+				migration.Version, migration.Name, time.Now())
+			return err
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// Down reverts the most recently applied migration in a transaction
+func Down(db *sql.DB, d dialect.Dialect) (*Migration, error) {
+	statuses, err := List(db, d)
+	if err != nil {
+		return nil, err
+	}
+
+	var last *Migration
+	for i := range statuses {
+		if statuses[i].AppliedAt != nil {
+			last = &statuses[i].Migration
+		}
+	}
+	if last == nil {
+		return nil, ErrNothingToRollBack
+	}
+
+	err = inTransaction(db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(last.Down); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", last.Version)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rolling back migration %04d_%s failed: %w", last.Version, last.Name, err)
+	}
+	return last, nil
+}
+
+// Create writes empty up and down files for a new migration numbered after
+// the existing ones into the subdirectory of dir of every dialect, and
+// returns their paths
+func Create(dir, name string) ([]string, error) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), "_"))
+	if !migrationName.MatchString(name) {
+		return nil, errors.New("migration name may only contain letters, digits and underscores")
+	}
+
+	version := 1
+	for _, d := range dialect.All {
+		migrations, err := Load(d)
+		if err != nil {
+			return nil, err
+		}
+		if len(migrations) > 0 && migrations[len(migrations)-1].Version >= version {
+			version = migrations[len(migrations)-1].Version + 1
+		}
+	}
+
+	var paths []string
+	for _, d := range dialect.All {
+		base := filepath.Join(dir, string(d), fmt.Sprintf("%04d_%s", version, name))
+		up, down := base+".up.sql", base+".down.sql"
+		if err := os.WriteFile(up, []byte("-- "+name+"\n"), 0644); err != nil {
+			return paths, err
+		}
+		if err := os.WriteFile(down, []byte("-- Revert "+name+"\n"), 0644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, up, down)
+	}
+	return paths, nil
+}
+
+// appliedVersions returns when each applied migration ran, creating the
+// schema_migrations table on first use
+func appliedVersions(db *sql.DB) (map[int]time.Time, error) {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	);`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// inTransaction runs fn in a transaction, committing only if it succeeds
+func inTransaction(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// legacyMoneyColumns are the amounts that releases before minor units stored
+// as REAL major units
+var legacyMoneyColumns = [][2]string{
+	{"products", "base_price"},
+	{"orders", "subtotal"}, {"orders", "discount_amount"}, {"orders", "tax_amount"},
+	{"orders", "shipping_cost"}, {"orders", "total_amount"},
+	{"order_items", "price_per_unit"}, {"order_items", "discount_amount"}, {"order_items", "tax_amount"},
+	{"payments", "amount"},
+	{"promotions", "min_subtotal"},
+	{"promotion_redemptions", "discount_amount"},
+	{"shipping_methods", "rate"}, {"shipping_methods", "free_threshold"},
+	{"shipping_weight_tiers", "rate"},
+	{"returns", "refund_amount"},
+}
+
+// adoptLegacySchema brings a SQLite database created before migrations up to
+// the initial schema, in the transaction of the initial migration. The
+// initial migration leaves existing tables as they are, so the columns they
+// lack are added first, and amounts still stored as major units are
+// converted to minor units.
+func adoptLegacySchema(tx *sql.Tx, initial Migration) error {
+	if exists, err := tableExists(tx, "users"); err != nil || !exists {
+		return err
+	}
+
+	// Build the initial schema in memory to compare against
+	scratch, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return err
+	}
+	defer scratch.Close()
+	if _, err := scratch.Exec(initial.Up); err != nil {
+		return err
+	}
+
+	rows, err := scratch.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return err
+	}
+	var tableNames []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			rows.Close()
+			return err
+		}
+		tableNames = append(tableNames, table)
+	}
+	rows.Close()
+
+	// Add the columns of existing tables that the initial schema defines;
+	// tables missing altogether are created by the migration
+	legacy := map[string][]string{}
+	for _, table := range tableNames {
+		existing, err := columns(tx, table)
+		if err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			continue
+		}
+		legacy[table] = existing
+
+		definitions, err := columnDefinitions(scratch, table)
+		if err != nil {
+			return err
+		}
+		for _, definition := range definitions {
+			if contains(existing, definition.name) {
+				continue
+			}
+			if _, err := tx.Exec("ALTER TABLE " + table + " ADD COLUMN " + definition.String()); err != nil {
+				return fmt.Errorf("adding %s.%s: %w", table, definition.name, err)
+			}
+		}
+	}
+
+	// Orders placed before currencies were in the store currency
+	if legacy["orders"] != nil {
+		if _, err := tx.Exec("UPDATE orders SET currency = ? WHERE currency IS NULL", string(money.StoreCurrency())); err != nil {
+			return err
+		}
+	}
+
+	// Convert the amounts of columns that were already there, except those a
+	// release that kept amounts in minor units already converted
+	converted := map[string]bool{}
+	exists, err := tableExists(tx, "minor_unit_columns")
+	if err != nil {
+		return err
+	}
+	if exists {
+		rows, err := tx.Query("SELECT table_name || '.' || column_name FROM minor_unit_columns")
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var column string
+			if err := rows.Scan(&column); err != nil {
+				rows.Close()
+				return err
+			}
+			converted[column] = true
+		}
+		rows.Close()
+	}
+	for _, column := range legacyMoneyColumns {
+		table, name := column[0], column[1]
+		if !contains(legacy[table], name) || converted[table+"."+name] {
+			continue
+		}
+		_, err := tx.Exec("UPDATE " + table + " SET " + name + " = CAST(ROUND(" + name + " * 100) AS INTEGER) WHERE " + name + " IS NOT NULL")
+		if err != nil {
+			return fmt.Errorf("converting %s.%s to minor units: %w", table, name, err)
+		}
+	}
+	return nil
+}
+
+// columnDefinition is a column as PRAGMA table_info describes it
+type columnDefinition struct {
+	name, kind string
+	notNull    bool
+	dflt       sql.NullString
+}
+
+// String returns the column as it is written in ALTER TABLE ADD COLUMN
+func (c columnDefinition) String() string {
+	definition := c.name + " " + c.kind
+	if c.notNull {
+		definition += " NOT NULL"
+	}
+	if c.dflt.Valid {
+		definition += " DEFAULT " + c.dflt.String
+	}
+	return definition
+}
+
+// columnDefinitions returns the columns of a table with their types and defaults
+func columnDefinitions(db *sql.DB, table string) ([]columnDefinition, error) {
+	rows, err := db.Query(`SELECT name, type, "notnull", dflt_value FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var definitions []columnDefinition
+	for rows.Next() {
+		var c columnDefinition
+		if err := rows.Scan(&c.name, &c.kind, &c.notNull, &c.dflt); err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, c)
+	}
+	return definitions, rows.Err()
+}
+
+// tableExists reports whether a SQLite database has a table
+func tableExists(tx *sql.Tx, table string) (bool, error) {
+	var count int
+	err := tx.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count)
+	return count > 0, err
+}
+
+// columns returns the column names of a table, none if it does not exist
+func columns(tx *sql.Tx, table string) ([]string, error) {
+	rows, err := tx.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// contains reports whether names includes name
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
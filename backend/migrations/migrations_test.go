@@ -0,0 +1,159 @@
+package migrations_test
+
+import (
+	"backend/database"
+	"backend/database/dbtest"
+	"backend/dialect"
+	"backend/migrations"
+	"backend/money"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Every migration must exist for every dialect, so databases of either
+// kind reach the same schema version
+func TestDialectsHaveTheSameMigrations(t *testing.T) {
+	want, err := migrations.Load(dialect.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range dialect.All {
+		got, err := migrations.Load(d)
+		if err != nil {
+			t.Fatalf("loading %s migrations: %v", d, err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s has %d migrations, %s has %d", d, len(got), dialect.SQLite, len(want))
+		}
+		for i := range got {
+			if got[i].Version != want[i].Version || got[i].Name != want[i].Name {
+				t.Errorf("%s migration %04d_%s does not match %s migration %04d_%s",
+					d, got[i].Version, got[i].Name, dialect.SQLite, want[i].Version, want[i].Name)
+			}
+		}
+	}
+}
+
+func TestUpAndDown(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		all, err := migrations.Load(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		applied, err := migrations.Up(db, d)
+		if err != nil {
+			t.Fatalf("Up: %v", err)
+		}
+		if len(applied) != len(all) {
+			t.Fatalf("Up applied %d migrations, want %d", len(applied), len(all))
+		}
+		assertPending(t, db, d, 0)
+
+		// Applying again is a no-op
+		if applied, err := migrations.Up(db, d); err != nil || len(applied) != 0 {
+			t.Fatalf("second Up applied %d migrations, err %v", len(applied), err)
+		}
+
+		// Roll everything back and forward again
+		for range all {
+			if _, err := migrations.Down(db, d); err != nil {
+				t.Fatalf("Down: %v", err)
+			}
+		}
+		if _, err := migrations.Down(db, d); err != migrations.ErrNothingToRollBack {
+			t.Fatalf("Down with nothing applied returned %v, want ErrNothingToRollBack", err)
+		}
+		assertPending(t, db, d, len(all))
+
+		if _, err := migrations.Up(db, d); err != nil {
+			t.Fatalf("Up after Down: %v", err)
+		}
+		assertPending(t, db, d, 0)
+	})
+}
+
+// A database created before migrations is brought up to the current schema,
+// with its amounts converted from major to minor units
+func TestUpAdoptsBaselineDatabase(t *testing.T) {
+	baseline, err := os.ReadFile("../database/data/ecommerce.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "ecommerce.db")
+	if err := os.WriteFile(path, baseline, 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+	INSERT INTO users (id, name, email, password) VALUES (1, 'Ada', 'ada@example.com', 'hash');
+	INSERT INTO addresses (id, user_id, name, street, city, state, postal_code, country, phone)
+	VALUES (1, 1, 'Ada', '1 Main St', 'Austin', 'TX', '73301', 'US', '555-0100');
+	INSERT INTO products (id, name, base_price) VALUES (1, 'Linen Shirt', 49.99);
+	INSERT INTO orders (id, user_id, address_id, total_amount, payment_method) VALUES (1, 1, 1, 99.98, 'card');
+	INSERT INTO order_items (order_id, product_id, color_id, size_id, quantity, price_per_unit) VALUES (1, 1, 1, 1, 2, 49.99);
+	INSERT INTO reviews (user_id, product_id, rating, comment) VALUES (1, 1, 2, 'first');
+	INSERT INTO reviews (user_id, product_id, rating, comment) VALUES (1, 1, 4, 'second');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrations.Up(db, dialect.SQLite); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	assertPending(t, db, dialect.SQLite, 0)
+
+	var price, total, unitPrice, shipping money.Amount
+	var currency string
+	err = db.QueryRow(`
+	SELECT p.base_price, o.total_amount, i.price_per_unit, o.shipping_cost, o.currency
+	FROM products p, orders o, order_items i`).Scan(&price, &total, &unitPrice, &shipping, &currency)
+	if err != nil {
+		t.Fatalf("reading the adopted rows: %v", err)
+	}
+	if price != 4999 || total != 9998 || unitPrice != 4999 || shipping != 0 {
+		t.Errorf("amounts = %d, %d, %d, %d, want 4999, 9998, 4999 and 0 minor units", price, total, unitPrice, shipping)
+	}
+	if currency != string(money.StoreCurrency()) {
+		t.Errorf("order currency = %q, want the store currency %q", currency, money.StoreCurrency())
+	}
+
+	// Duplicate reviews leave the newest behind, and no more can be added
+	var reviews int
+	var comment string
+	if err := db.QueryRow("SELECT COUNT(*), MAX(comment) FROM reviews").Scan(&reviews, &comment); err != nil || reviews != 1 || comment != "second" {
+		t.Errorf("reviews = %d, newest %q, %v, want only the second", reviews, comment, err)
+	}
+	_, err = db.Exec("INSERT INTO reviews (user_id, product_id, rating) VALUES (1, 1, 5)")
+	if !database.IsUniqueViolation(err) {
+		t.Errorf("adding a second review = %v, want a unique violation", err)
+	}
+
+	// Amounts are converted once, not again on the next run
+	if _, err := migrations.Up(db, dialect.SQLite); err != nil {
+		t.Fatalf("second Up: %v", err)
+	}
+	if err := db.QueryRow("SELECT base_price FROM products").Scan(&price); err != nil || price != 4999 {
+		t.Errorf("price after a second Up = %d, %v, want 4999", price, err)
+	}
+}
+
+func assertPending(t *testing.T, db *sql.DB, d dialect.Dialect, want int) {
+	t.Helper()
+
+	pending, err := migrations.Pending(db, d)
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	if len(pending) != want {
+		t.Fatalf("%d migrations pending, want %d", len(pending), want)
+	}
+}
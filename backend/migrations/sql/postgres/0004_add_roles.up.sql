@@ -0,0 +1,55 @@
+-- Roles table: users.role names one of these roles
+CREATE TABLE IF NOT EXISTS roles (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT UNIQUE NOT NULL,
+	description TEXT,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Role permissions table: the permissions granted by each role
+CREATE TABLE IF NOT EXISTS role_permissions (
+	role_id BIGINT NOT NULL,
+	permission TEXT NOT NULL,
+	PRIMARY KEY (role_id, permission),
+	FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE
+);
+
+-- Built-in roles. admin holds every permission and customer none; the staff
+-- roles can be changed through the API.
+INSERT INTO roles (name, description) VALUES
+	('admin', 'Full access to the store'),
+	('customer', 'Shops in the store, no back office access'),
+	('catalog_manager', 'Maintains products, categories and promotions'),
+	('fulfilment_clerk', 'Ships orders, keeps stock and receives returned goods'),
+	('support_agent', 'Helps customers with their orders, returns and reviews'),
+	('finance', 'Manages taxes, exchange rates and refunds');
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'products:write' FROM roles WHERE name IN ('admin', 'catalog_manager');
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'inventory:write' FROM roles WHERE name IN ('admin', 'catalog_manager', 'fulfilment_clerk');
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'orders:read' FROM roles WHERE name IN ('admin', 'fulfilment_clerk', 'support_agent', 'finance');
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'orders:update' FROM roles WHERE name IN ('admin', 'fulfilment_clerk');
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'orders:cancel' FROM roles WHERE name IN ('admin', 'support_agent');
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'returns:manage' FROM roles WHERE name IN ('admin', 'support_agent');
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'returns:receive' FROM roles WHERE name IN ('admin', 'fulfilment_clerk', 'finance');
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'promotions:write' FROM roles WHERE name IN ('admin', 'catalog_manager');
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'tax:write' FROM roles WHERE name IN ('admin', 'finance');
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'shipping:write' FROM roles WHERE name IN ('admin', 'fulfilment_clerk');
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'currencies:write' FROM roles WHERE name IN ('admin', 'finance');
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'reviews:moderate' FROM roles WHERE name IN ('admin', 'catalog_manager', 'support_agent');
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'users:manage' FROM roles WHERE name = 'admin';
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'roles:manage' FROM roles WHERE name = 'admin';
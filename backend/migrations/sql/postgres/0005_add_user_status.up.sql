@@ -0,0 +1,8 @@
+-- Disabled accounts cannot log in, and accounts whose password an admin
+-- reset cannot log in until the owner sets a new one
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN password_reset_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Viewing accounts with their orders and addresses
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'users:read' FROM roles WHERE name IN ('admin', 'support_agent');
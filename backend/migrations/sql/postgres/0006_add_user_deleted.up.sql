@@ -0,0 +1,2 @@
+-- Deleted accounts keep an anonymised row, so their orders stay on the books
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
@@ -0,0 +1,25 @@
+-- Login attempts table: the login history shown to users, including attempts
+-- on unknown emails, which have no user_id
+CREATE TABLE IF NOT EXISTS login_attempts (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT,
+	email TEXT NOT NULL,
+	ip_address TEXT,
+	user_agent TEXT,
+	success BOOLEAN NOT NULL,
+	reason TEXT,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_user ON login_attempts(user_id, created_at);
+
+-- Login throttles table: recent failed logins per account email and per IP
+-- address, and until when further attempts are refused
+CREATE TABLE IF NOT EXISTS login_throttles (
+	scope TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	failures INTEGER NOT NULL DEFAULT 0,
+	last_failure_at TIMESTAMPTZ NOT NULL,
+	blocked_until TIMESTAMPTZ,
+	PRIMARY KEY (scope, subject)
+);
@@ -0,0 +1,24 @@
+-- Two-factor table: a user's TOTP secret, enabled once confirmed with a first
+-- code. last_used_step stops a code from being used twice.
+CREATE TABLE IF NOT EXISTS user_two_factor (
+	user_id BIGINT PRIMARY KEY,
+	secret TEXT NOT NULL,
+	enabled_at TIMESTAMPTZ,
+	last_used_step BIGINT NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Recovery codes table: single-use codes for when the authenticator is lost,
+-- stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS two_factor_recovery_codes (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	code_hash TEXT NOT NULL,
+	used_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_two_factor_recovery_codes_user ON two_factor_recovery_codes(user_id);
+
+-- Roles can require their users to set up two-factor authentication
+ALTER TABLE roles ADD COLUMN require_two_factor BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,5 @@
+-- Whether the refund of a received return is in progress, failed or done.
+-- Claiming the refund by moving it to in_progress stops it from being issued twice.
+ALTER TABLE returns ADD COLUMN refund_state TEXT;
+UPDATE returns SET refund_state = 'refunded' WHERE refunded_at IS NOT NULL;
+UPDATE returns SET refund_state = 'failed' WHERE status = 'received' AND refunded_at IS NULL;
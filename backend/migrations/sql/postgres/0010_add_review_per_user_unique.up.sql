@@ -0,0 +1,3 @@
+-- A user reviews a product once. Earlier duplicates are dropped, keeping the newest review.
+DELETE FROM reviews WHERE id NOT IN (SELECT MAX(id) FROM reviews GROUP BY user_id, product_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reviews_user_product ON reviews(user_id, product_id);
@@ -0,0 +1,8 @@
+-- How many orders that were not cancelled used each promotion. Redeeming a
+-- coupon increments it only while it is below the usage limit, so concurrent
+-- orders cannot exceed the limit.
+ALTER TABLE promotions ADD COLUMN times_used INTEGER NOT NULL DEFAULT 0;
+UPDATE promotions SET times_used = (
+	SELECT COUNT(*) FROM promotion_redemptions r JOIN orders o ON o.id = r.order_id
+	WHERE r.promotion_id = promotions.id AND o.order_status != 'cancelled'
+);
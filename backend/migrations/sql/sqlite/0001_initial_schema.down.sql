@@ -0,0 +1,31 @@
+-- Drops the whole schema, in reverse order of creation
+DROP TABLE IF EXISTS product_prices;
+DROP TABLE IF EXISTS exchange_rates;
+DROP TABLE IF EXISTS return_items;
+DROP TABLE IF EXISTS returns;
+DROP TABLE IF EXISTS shipment_items;
+DROP TABLE IF EXISTS shipments;
+DROP TABLE IF EXISTS shipping_weight_tiers;
+DROP TABLE IF EXISTS shipping_methods;
+DROP TABLE IF EXISTS shipping_zones;
+DROP TABLE IF EXISTS tax_rules;
+DROP TABLE IF EXISTS promotion_redemptions;
+DROP TABLE IF EXISTS cart_coupons;
+DROP TABLE IF EXISTS promotions;
+DROP TABLE IF EXISTS order_status_history;
+DROP TABLE IF EXISTS inventory_reservations;
+DROP TABLE IF EXISTS payments;
+DROP TABLE IF EXISTS product_slug_history;
+DROP TABLE IF EXISTS reviews;
+DROP TABLE IF EXISTS wishlist;
+DROP TABLE IF EXISTS cart;
+DROP TABLE IF EXISTS order_items;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS product_inventory;
+DROP TABLE IF EXISTS product_sizes;
+DROP TABLE IF EXISTS product_colors;
+DROP TABLE IF EXISTS product_images;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS addresses;
+DROP TABLE IF EXISTS users;
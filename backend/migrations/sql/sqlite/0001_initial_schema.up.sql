@@ -0,0 +1,427 @@
+-- The schema as it stood before versioned migrations. Every statement is
+-- guarded, so databases created by earlier releases keep their tables; Up
+-- first adds the columns they lack and converts their amounts to minor units.
+
+-- Users table
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	email TEXT UNIQUE NOT NULL,
+	password TEXT NOT NULL,
+	role TEXT DEFAULT 'customer',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Addresses table
+CREATE TABLE IF NOT EXISTS addresses (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	street TEXT NOT NULL,
+	city TEXT NOT NULL,
+	state TEXT NOT NULL,
+	postal_code TEXT NOT NULL,
+	country TEXT NOT NULL,
+	phone TEXT NOT NULL,
+	is_default BOOLEAN DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Categories table
+CREATE TABLE IF NOT EXISTS categories (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT UNIQUE NOT NULL,
+	slug TEXT,
+	description TEXT,
+	image_url TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Products table
+CREATE TABLE IF NOT EXISTS products (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	description TEXT,
+	category_id INTEGER,
+	slug TEXT,
+	base_price INTEGER NOT NULL,
+	discount_percentage REAL DEFAULT 0,
+	featured BOOLEAN DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	weight REAL NOT NULL DEFAULT 0,
+	length REAL NOT NULL DEFAULT 0,
+	width REAL NOT NULL DEFAULT 0,
+	height REAL NOT NULL DEFAULT 0,
+	FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL
+);
+
+-- Product Images table
+CREATE TABLE IF NOT EXISTS product_images (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id INTEGER NOT NULL,
+	image_url TEXT NOT NULL,
+	is_primary BOOLEAN DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+-- Product Colors table
+CREATE TABLE IF NOT EXISTS product_colors (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id INTEGER NOT NULL,
+	color_name TEXT NOT NULL,
+	color_hex TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+-- Product Sizes table
+CREATE TABLE IF NOT EXISTS product_sizes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id INTEGER NOT NULL,
+	size_name TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+-- Product Inventory table
+CREATE TABLE IF NOT EXISTS product_inventory (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id INTEGER NOT NULL,
+	color_id INTEGER NOT NULL,
+	size_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL DEFAULT 0,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+	FOREIGN KEY (color_id) REFERENCES product_colors(id) ON DELETE CASCADE,
+	FOREIGN KEY (size_id) REFERENCES product_sizes(id) ON DELETE CASCADE,
+	UNIQUE(product_id, color_id, size_id)
+);
+
+-- Orders table
+CREATE TABLE IF NOT EXISTS orders (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	address_id INTEGER NOT NULL,
+	total_amount INTEGER NOT NULL,
+	payment_method TEXT NOT NULL,
+	payment_status TEXT DEFAULT 'pending',
+	order_status TEXT DEFAULT 'processing',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	cancel_reason TEXT,
+	cancelled_at TIMESTAMP,
+	subtotal INTEGER,
+	discount_amount INTEGER NOT NULL DEFAULT 0,
+	coupon_code TEXT,
+	promotion_id INTEGER,
+	tax_amount INTEGER NOT NULL DEFAULT 0,
+	tax_included INTEGER NOT NULL DEFAULT 0,
+	shipping_method TEXT,
+	shipping_cost INTEGER NOT NULL DEFAULT 0,
+	currency TEXT,
+	exchange_rate REAL NOT NULL DEFAULT 1,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (address_id) REFERENCES addresses(id) ON DELETE RESTRICT
+);
+
+-- Order Items table
+CREATE TABLE IF NOT EXISTS order_items (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	order_id INTEGER NOT NULL,
+	product_id INTEGER NOT NULL,
+	color_id INTEGER NOT NULL,
+	size_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL,
+	price_per_unit INTEGER NOT NULL,
+	discount_amount INTEGER NOT NULL DEFAULT 0,
+	tax_rate REAL NOT NULL DEFAULT 0,
+	tax_amount INTEGER NOT NULL DEFAULT 0,
+	tax_included BOOLEAN NOT NULL DEFAULT 0,
+	FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE RESTRICT,
+	FOREIGN KEY (color_id) REFERENCES product_colors(id) ON DELETE RESTRICT,
+	FOREIGN KEY (size_id) REFERENCES product_sizes(id) ON DELETE RESTRICT
+);
+
+-- Cart table
+CREATE TABLE IF NOT EXISTS cart (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	product_id INTEGER NOT NULL,
+	color_id INTEGER NOT NULL,
+	size_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+	FOREIGN KEY (color_id) REFERENCES product_colors(id) ON DELETE CASCADE,
+	FOREIGN KEY (size_id) REFERENCES product_sizes(id) ON DELETE CASCADE,
+	UNIQUE(user_id, product_id, color_id, size_id)
+);
+
+-- Wishlist table
+CREATE TABLE IF NOT EXISTS wishlist (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	product_id INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+	UNIQUE(user_id, product_id)
+);
+
+-- Reviews table
+CREATE TABLE IF NOT EXISTS reviews (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	product_id INTEGER NOT NULL,
+	rating INTEGER NOT NULL CHECK (rating BETWEEN 1 AND 5),
+	comment TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+-- Product Slug History table
+CREATE TABLE IF NOT EXISTS product_slug_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id INTEGER NOT NULL,
+	slug TEXT UNIQUE NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+-- Payments table, one row per provider call or callback
+CREATE TABLE IF NOT EXISTS payments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	order_id INTEGER NOT NULL,
+	provider TEXT NOT NULL,
+	transaction_id TEXT,
+	type TEXT NOT NULL,
+	status TEXT NOT NULL,
+	amount INTEGER NOT NULL,
+	error_message TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
+
+-- Inventory Reservations table, time-limited holds placed at checkout
+CREATE TABLE IF NOT EXISTS inventory_reservations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	product_id INTEGER NOT NULL,
+	color_id INTEGER NOT NULL,
+	size_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+-- Order Status History table, every order and payment status change
+CREATE TABLE IF NOT EXISTS order_status_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	order_id INTEGER NOT NULL,
+	field TEXT NOT NULL,
+	from_status TEXT NOT NULL,
+	to_status TEXT NOT NULL,
+	actor_id INTEGER,
+	actor TEXT NOT NULL,
+	note TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
+
+-- Promotions table, coupon codes and their discount rules
+CREATE TABLE IF NOT EXISTS promotions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	code TEXT UNIQUE NOT NULL,
+	description TEXT,
+	type TEXT NOT NULL,
+	value REAL NOT NULL DEFAULT 0,
+	buy_quantity INTEGER NOT NULL DEFAULT 0,
+	get_quantity INTEGER NOT NULL DEFAULT 0,
+	category_id INTEGER,
+	product_id INTEGER,
+	min_subtotal INTEGER NOT NULL DEFAULT 0,
+	starts_at TIMESTAMP,
+	ends_at TIMESTAMP,
+	usage_limit INTEGER,
+	per_user_limit INTEGER,
+	is_active BOOLEAN DEFAULT 1,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+-- Cart Coupons table, the coupon applied to each user's cart
+CREATE TABLE IF NOT EXISTS cart_coupons (
+	user_id INTEGER PRIMARY KEY,
+	promotion_id INTEGER NOT NULL,
+	applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (promotion_id) REFERENCES promotions(id) ON DELETE CASCADE
+);
+
+-- Promotion Redemptions table, one row per order that used a coupon
+CREATE TABLE IF NOT EXISTS promotion_redemptions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	promotion_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	order_id INTEGER NOT NULL,
+	discount_amount INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (promotion_id) REFERENCES promotions(id) ON DELETE CASCADE,
+	FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
+
+-- Tax Rules table, rates by country, optionally narrowed to a state and a category
+CREATE TABLE IF NOT EXISTS tax_rules (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT,
+	country TEXT NOT NULL,
+	state TEXT NOT NULL DEFAULT '',
+	category_id INTEGER,
+	rate REAL NOT NULL,
+	price_includes_tax BOOLEAN DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+);
+
+-- Shipping Zones table, a country optionally narrowed to a postal code prefix
+CREATE TABLE IF NOT EXISTS shipping_zones (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	country TEXT NOT NULL,
+	postal_prefix TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(country, postal_prefix)
+);
+
+-- Shipping Methods table
+CREATE TABLE IF NOT EXISTS shipping_methods (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	zone_id INTEGER NOT NULL,
+	code TEXT NOT NULL,
+	name TEXT NOT NULL,
+	pricing TEXT NOT NULL,
+	rate INTEGER NOT NULL DEFAULT 0,
+	free_threshold INTEGER NOT NULL DEFAULT 0,
+	min_days INTEGER NOT NULL DEFAULT 0,
+	max_days INTEGER NOT NULL DEFAULT 0,
+	is_active BOOLEAN DEFAULT 1,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (zone_id) REFERENCES shipping_zones(id) ON DELETE CASCADE,
+	UNIQUE(zone_id, code)
+);
+
+-- Shipping Weight Tiers table, prices of weight priced methods
+CREATE TABLE IF NOT EXISTS shipping_weight_tiers (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	method_id INTEGER NOT NULL,
+	max_weight REAL NOT NULL,
+	rate INTEGER NOT NULL,
+	FOREIGN KEY (method_id) REFERENCES shipping_methods(id) ON DELETE CASCADE
+);
+
+-- Shipments table, parcels sent for an order
+CREATE TABLE IF NOT EXISTS shipments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	order_id INTEGER NOT NULL,
+	carrier TEXT,
+	tracking_number TEXT,
+	status TEXT NOT NULL DEFAULT 'shipped',
+	shipped_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	delivered_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
+
+-- Shipment Items table, the order item quantities packed in a shipment
+CREATE TABLE IF NOT EXISTS shipment_items (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	shipment_id INTEGER NOT NULL,
+	order_item_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL,
+	FOREIGN KEY (shipment_id) REFERENCES shipments(id) ON DELETE CASCADE,
+	FOREIGN KEY (order_item_id) REFERENCES order_items(id) ON DELETE CASCADE
+);
+
+-- Returns table, customer requests to send back items of a delivered order
+CREATE TABLE IF NOT EXISTS returns (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	order_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	status TEXT NOT NULL DEFAULT 'requested',
+	reason TEXT,
+	admin_note TEXT,
+	restocked BOOLEAN NOT NULL DEFAULT 0,
+	refund_amount INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	received_at TIMESTAMP,
+	refunded_at TIMESTAMP,
+	FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Return Items table, the order item quantities of a return
+CREATE TABLE IF NOT EXISTS return_items (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	return_id INTEGER NOT NULL,
+	order_item_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL,
+	FOREIGN KEY (return_id) REFERENCES returns(id) ON DELETE CASCADE,
+	FOREIGN KEY (order_item_id) REFERENCES order_items(id) ON DELETE CASCADE
+);
+
+-- Exchange Rates table, how much of a currency one unit of the store currency buys
+CREATE TABLE IF NOT EXISTS exchange_rates (
+	currency TEXT PRIMARY KEY,
+	rate REAL NOT NULL,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Product Prices table, base prices set per currency instead of converting the store price
+CREATE TABLE IF NOT EXISTS product_prices (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id INTEGER NOT NULL,
+	currency TEXT NOT NULL,
+	base_price INTEGER NOT NULL,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+	UNIQUE(product_id, currency)
+);
+
+-- Indexes
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_slug ON products(slug);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories(slug);
+CREATE INDEX IF NOT EXISTS idx_payments_order ON payments(order_id);
+CREATE INDEX IF NOT EXISTS idx_payments_transaction ON payments(provider, transaction_id);
+CREATE INDEX IF NOT EXISTS idx_reservations_item ON inventory_reservations(product_id, color_id, size_id);
+CREATE INDEX IF NOT EXISTS idx_reservations_user ON inventory_reservations(user_id);
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order ON order_status_history(order_id);
+CREATE INDEX IF NOT EXISTS idx_promotion_redemptions_promotion ON promotion_redemptions(promotion_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_returns_order ON returns(order_id);
+CREATE INDEX IF NOT EXISTS idx_returns_status ON returns(status);
+CREATE INDEX IF NOT EXISTS idx_return_items_return ON return_items(return_id);
+CREATE INDEX IF NOT EXISTS idx_return_items_order_item ON return_items(order_item_id);
+CREATE INDEX IF NOT EXISTS idx_shipments_order ON shipments(order_id);
+CREATE INDEX IF NOT EXISTS idx_shipment_items_shipment ON shipment_items(shipment_id);
+CREATE INDEX IF NOT EXISTS idx_shipment_items_order_item ON shipment_items(order_item_id);
+CREATE INDEX IF NOT EXISTS idx_shipping_weight_tiers_method ON shipping_weight_tiers(method_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tax_rules_scope ON tax_rules(country, state, COALESCE(category_id, 0));
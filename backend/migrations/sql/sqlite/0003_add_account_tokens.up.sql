@@ -0,0 +1,18 @@
+-- Accounts start unverified until the owner follows the link mailed to them
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+
+-- Account tokens table: single-use tokens mailed for email verification and
+-- password resets. Only their hashes are kept.
+CREATE TABLE IF NOT EXISTS account_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	purpose TEXT NOT NULL,
+	token_hash TEXT UNIQUE NOT NULL,
+	email TEXT NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_tokens_user ON account_tokens(user_id, purpose);
@@ -0,0 +1,3 @@
+DELETE FROM role_permissions WHERE permission = 'users:read';
+ALTER TABLE users DROP COLUMN password_reset_required;
+ALTER TABLE users DROP COLUMN disabled_at;
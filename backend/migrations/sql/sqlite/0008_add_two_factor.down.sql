@@ -0,0 +1,3 @@
+ALTER TABLE roles DROP COLUMN require_two_factor;
+DROP TABLE IF EXISTS two_factor_recovery_codes;
+DROP TABLE IF EXISTS user_two_factor;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_reviews_user_product;
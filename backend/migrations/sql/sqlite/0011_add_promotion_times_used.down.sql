@@ -0,0 +1 @@
+ALTER TABLE promotions DROP COLUMN times_used;
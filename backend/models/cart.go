@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"backend/money"
+	"time"
+)
 
 // CartItem represents an item in the shopping cart
 type CartItem struct {
@@ -24,30 +27,43 @@ type CartItemRequest struct {
 
 // CartItemResponse is the response format for cart items with product details
 type CartItemResponse struct {
-	ID                 int64   `json:"id"`
-	ProductID          int64   `json:"product_id"`
-	ProductName        string  `json:"product_name"`
-	ProductDescription string  `json:"product_description"`
-	BasePrice          float64 `json:"base_price"`
-	DiscountPercentage float64 `json:"discount_percentage"`
-	FinalPrice         float64 `json:"final_price"`
-	ColorID            int64   `json:"color_id"`
-	ColorName          string  `json:"color_name"`
-	ColorHex           string  `json:"color_hex"`
-	SizeID             int64   `json:"size_id"`
-	SizeName           string  `json:"size_name"`
-	ImageURL           string  `json:"image_url"`
-	Quantity           int     `json:"quantity"`
-	InStock            int     `json:"in_stock"`
-	SubTotal           float64 `json:"sub_total"`
+	ID                 int64        `json:"id"`
+	ProductID          int64        `json:"product_id"`
+	ProductName        string       `json:"product_name"`
+	ProductDescription string       `json:"product_description"`
+	BasePrice          money.Amount `json:"base_price"`
+	DiscountPercentage float64      `json:"discount_percentage"`
+	FinalPrice         money.Amount `json:"final_price"`
+	ColorID            int64        `json:"color_id"`
+	ColorName          string       `json:"color_name"`
+	ColorHex           string       `json:"color_hex"`
+	SizeID             int64        `json:"size_id"`
+	SizeName           string       `json:"size_name"`
+	ImageURL           string       `json:"image_url"`
+	Quantity           int          `json:"quantity"`
+	InStock            int          `json:"in_stock"`
+	SubTotal           money.Amount `json:"sub_total"`
 }
 
 // CartSummary represents a summary of the cart
 type CartSummary struct {
-	TotalItems     int     `json:"total_items"`
-	SubTotal       float64 `json:"sub_total"`
-	ShippingCost   float64 `json:"shipping_cost"`
-	Tax            float64 `json:"tax"`
-	Total          float64 `json:"total"`
-	DiscountAmount float64 `json:"discount_amount"`
-} 
\ No newline at end of file
+	TotalItems     int            `json:"total_items"`
+	SubTotal       money.Amount   `json:"sub_total"`
+	ShippingMethod string         `json:"shipping_method"`
+	ShippingCost   money.Amount   `json:"shipping_cost"`
+	Tax            money.Amount   `json:"tax"`
+	TaxIncluded    money.Amount   `json:"tax_included"`
+	Total          money.Amount   `json:"total"`
+	DiscountAmount money.Amount   `json:"discount_amount"`
+	FreeShipping   bool           `json:"free_shipping"`
+	Currency       money.Currency `json:"currency"`
+} 
+// StockShortage describes a cart line that cannot be reserved
+type StockShortage struct {
+	CartItemID int64 `json:"cart_item_id"`
+	ProductID  int64 `json:"product_id"`
+	ColorID    int64 `json:"color_id"`
+	SizeID     int64 `json:"size_id"`
+	Requested  int   `json:"requested"`
+	Available  int   `json:"available"`
+}
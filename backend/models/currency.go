@@ -0,0 +1,31 @@
+package models
+
+import (
+	"backend/money"
+	"time"
+)
+
+// ExchangeRate is how much of a currency one unit of the store currency buys
+type ExchangeRate struct {
+	Currency  money.Currency `json:"currency"`
+	Rate      float64        `json:"rate"`
+	UpdatedAt *time.Time     `json:"updated_at,omitempty"`
+}
+
+// ExchangeRateRequest is the request format for setting an exchange rate
+type ExchangeRateRequest struct {
+	Rate float64 `json:"rate"`
+}
+
+// ProductPrice is a base price set for a product in a currency, used instead of converting the store price
+type ProductPrice struct {
+	Currency  money.Currency `json:"currency"`
+	BasePrice money.Amount   `json:"base_price"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// ProductPriceRequest is the request format for setting a product's price in a currency
+type ProductPriceRequest struct {
+	Currency  string       `json:"currency"`
+	BasePrice money.Amount `json:"base_price"`
+}
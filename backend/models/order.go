@@ -1,72 +1,118 @@
 package models
 
-import "time"
+import (
+	"backend/money"
+	"time"
+)
 
 // Order represents an order in the system
 type Order struct {
-	ID            int64     `json:"id"`
-	UserID        int64     `json:"user_id"`
-	AddressID     int64     `json:"address_id"`
-	TotalAmount   float64   `json:"total_amount"`
-	PaymentMethod string    `json:"payment_method"`
-	PaymentStatus string    `json:"payment_status"`
-	OrderStatus   string    `json:"order_status"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID             int64          `json:"id"`
+	UserID         int64          `json:"user_id"`
+	AddressID      int64          `json:"address_id"`
+	Subtotal       money.Amount   `json:"subtotal"`
+	DiscountAmount money.Amount   `json:"discount_amount"`
+	CouponCode     string         `json:"coupon_code,omitempty"`
+	TaxAmount      money.Amount   `json:"tax_amount"`
+	TaxIncluded    money.Amount   `json:"tax_included"`
+	ShippingMethod string         `json:"shipping_method"`
+	ShippingCost   money.Amount   `json:"shipping_cost"`
+	TotalAmount    money.Amount   `json:"total_amount"`
+	Currency       money.Currency `json:"currency"`
+	ExchangeRate   float64        `json:"exchange_rate"`
+	PaymentMethod  string         `json:"payment_method"`
+	PaymentStatus  string         `json:"payment_status"`
+	OrderStatus    string         `json:"order_status"`
+	CancelReason   string         `json:"cancel_reason,omitempty"`
+	CancelledAt    *time.Time     `json:"cancelled_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// OrderWithUser is an order listed with the name and email of its customer
+type OrderWithUser struct {
+	Order
+	UserName  string `json:"user_name"`
+	UserEmail string `json:"user_email"`
 }
 
 // OrderItem represents an item in an order
 type OrderItem struct {
-	ID           int64   `json:"id"`
-	OrderID      int64   `json:"order_id"`
-	ProductID    int64   `json:"product_id"`
-	ColorID      int64   `json:"color_id"`
-	SizeID       int64   `json:"size_id"`
-	Quantity     int     `json:"quantity"`
-	PricePerUnit float64 `json:"price_per_unit"`
+	ID           int64        `json:"id"`
+	OrderID      int64        `json:"order_id"`
+	ProductID    int64        `json:"product_id"`
+	ColorID      int64        `json:"color_id"`
+	SizeID       int64        `json:"size_id"`
+	Quantity     int          `json:"quantity"`
+	PricePerUnit money.Amount `json:"price_per_unit"`
 }
 
 // OrderItemResponse is the response format for order items with product details
 type OrderItemResponse struct {
-	ID                 int64   `json:"id"`
-	ProductID          int64   `json:"product_id"`
-	ProductName        string  `json:"product_name"`
-	ProductDescription string  `json:"product_description"`
-	ColorID            int64   `json:"color_id"`
-	ColorName          string  `json:"color_name"`
-	ColorHex           string  `json:"color_hex"`
-	SizeID             int64   `json:"size_id"`
-	SizeName           string  `json:"size_name"`
-	ImageURL           string  `json:"image_url"`
-	Quantity           int     `json:"quantity"`
-	PricePerUnit       float64 `json:"price_per_unit"`
-	SubTotal           float64 `json:"sub_total"`
+	ID                 int64        `json:"id"`
+	ProductID          int64        `json:"product_id"`
+	ProductName        string       `json:"product_name"`
+	ProductDescription string       `json:"product_description"`
+	ColorID            int64        `json:"color_id"`
+	ColorName          string       `json:"color_name"`
+	ColorHex           string       `json:"color_hex"`
+	SizeID             int64        `json:"size_id"`
+	SizeName           string       `json:"size_name"`
+	ImageURL           string       `json:"image_url"`
+	Quantity           int          `json:"quantity"`
+	ShippedQuantity    int          `json:"shipped_quantity"`
+	PricePerUnit       money.Amount `json:"price_per_unit"`
+	SubTotal           money.Amount `json:"sub_total"`
+	DiscountAmount     money.Amount `json:"discount_amount"`
+	TaxRate            float64      `json:"tax_rate"`
+	TaxAmount          money.Amount `json:"tax_amount"`
+	TaxIncluded        bool         `json:"tax_included"`
 }
 
 // OrderRequest is the request format for creating an order
 type OrderRequest struct {
-	AddressID     int64  `json:"address_id"`
-	PaymentMethod string `json:"payment_method"`
+	AddressID      int64  `json:"address_id"`
+	PaymentMethod  string `json:"payment_method"`
+	PaymentToken   string `json:"payment_token"`
+	ShippingMethod string `json:"shipping_method"`
 }
 
 // OrderResponse is the response format for orders with address and items
 type OrderResponse struct {
-	ID            int64              `json:"id"`
-	UserID        int64              `json:"user_id"`
-	Address       Address            `json:"address"`
-	TotalAmount   float64            `json:"total_amount"`
-	PaymentMethod string             `json:"payment_method"`
-	PaymentStatus string             `json:"payment_status"`
-	OrderStatus   string             `json:"order_status"`
-	Items         []OrderItemResponse `json:"items"`
-	CreatedAt     time.Time          `json:"created_at"`
-	UpdatedAt     time.Time          `json:"updated_at"`
+	ID             int64               `json:"id"`
+	UserID         int64               `json:"user_id"`
+	Address        Address             `json:"address"`
+	Subtotal       money.Amount        `json:"subtotal"`
+	DiscountAmount money.Amount        `json:"discount_amount"`
+	CouponCode     string              `json:"coupon_code,omitempty"`
+	TaxAmount      money.Amount        `json:"tax_amount"`
+	TaxIncluded    money.Amount        `json:"tax_included"`
+	ShippingMethod string              `json:"shipping_method"`
+	ShippingCost   money.Amount        `json:"shipping_cost"`
+	TotalAmount    money.Amount        `json:"total_amount"`
+	Currency       money.Currency      `json:"currency"`
+	ExchangeRate   float64             `json:"exchange_rate"`
+	PaymentMethod  string              `json:"payment_method"`
+	PaymentStatus  string              `json:"payment_status"`
+	OrderStatus    string              `json:"order_status"`
+	CancelReason   string              `json:"cancel_reason,omitempty"`
+	CancelledAt    *time.Time          `json:"cancelled_at,omitempty"`
+	Items          []OrderItemResponse `json:"items"`
+	Payments       []Payment           `json:"payments"`
+	Timeline       []OrderStatusChange `json:"timeline"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+}
+
+// CancelOrderRequest is the request format for cancelling an order
+type CancelOrderRequest struct {
+	Reason string `json:"reason"`
 }
 
 // UpdateOrderStatusRequest is the request format for updating order status
 type UpdateOrderStatusRequest struct {
-	OrderStatus   string `json:"order_status"`
-	PaymentStatus string `json:"payment_status"`
+	OrderStatus string `json:"order_status"`
+	Note        string `json:"note"`
 }
 
 // Address model represents a user's address
@@ -95,4 +141,17 @@ type AddressRequest struct {
 	Country    string `json:"country"`
 	Phone      string `json:"phone"`
 	IsDefault  bool   `json:"is_default"`
-} 
\ No newline at end of file
+}
+
+// OrderStatusChange is an entry in an order's status timeline
+type OrderStatusChange struct {
+	ID         int64     `json:"id"`
+	OrderID    int64     `json:"order_id"`
+	Field      string    `json:"field"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ActorID    *int64    `json:"actor_id,omitempty"`
+	Actor      string    `json:"actor"`
+	Note       string    `json:"note"`
+	CreatedAt  time.Time `json:"created_at"`
+}
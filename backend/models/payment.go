@@ -0,0 +1,24 @@
+package models
+
+import (
+	"backend/money"
+	"time"
+)
+
+// Payment records one attempt to authorize, capture or refund an order's payment
+type Payment struct {
+	ID            int64        `json:"id"`
+	OrderID       int64        `json:"order_id"`
+	Provider      string       `json:"provider"`
+	TransactionID string       `json:"transaction_id"`
+	Type          string       `json:"type"`
+	Status        string       `json:"status"`
+	Amount        money.Amount `json:"amount"`
+	ErrorMessage  string       `json:"error_message,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// PayOrderRequest is the request format for retrying an order's payment
+type PayOrderRequest struct {
+	PaymentToken string `json:"payment_token"`
+}
@@ -1,18 +1,26 @@
 package models
 
-import "time"
+import (
+	"backend/money"
+	"time"
+)
 
 // Product represents a product in the e-commerce system
 type Product struct {
-	ID                 int64     `json:"id"`
-	Name               string    `json:"name"`
-	Description        string    `json:"description"`
-	CategoryID         int64     `json:"category_id"`
-	BasePrice          float64   `json:"base_price"`
-	DiscountPercentage float64   `json:"discount_percentage"`
-	Featured           bool      `json:"featured"`
-	CreatedAt          time.Time `json:"created_at"`
-	UpdatedAt          time.Time `json:"updated_at"`
+	ID                 int64        `json:"id"`
+	Name               string       `json:"name"`
+	Description        string       `json:"description"`
+	CategoryID         int64        `json:"category_id"`
+	Slug               string       `json:"slug"`
+	BasePrice          money.Amount `json:"base_price"`
+	DiscountPercentage float64      `json:"discount_percentage"`
+	Featured           bool         `json:"featured"`
+	Weight             float64      `json:"weight"`
+	Length             float64      `json:"length"`
+	Width              float64      `json:"width"`
+	Height             float64      `json:"height"`
+	CreatedAt          time.Time    `json:"created_at"`
+	UpdatedAt          time.Time    `json:"updated_at"`
 }
 
 // ProductImage represents a product image
@@ -55,21 +63,49 @@ type ProductInventory struct {
 type ProductResponse struct {
 	ID                 int64           `json:"id"`
 	Name               string          `json:"name"`
+	Slug               string          `json:"slug"`
 	Description        string          `json:"description"`
 	CategoryID         int64           `json:"category_id"`
 	CategoryName       string          `json:"category_name"`
-	BasePrice          float64         `json:"base_price"`
+	BasePrice          money.Amount    `json:"base_price"`
 	DiscountPercentage float64         `json:"discount_percentage"`
-	FinalPrice         float64         `json:"final_price"`
+	FinalPrice         money.Amount    `json:"final_price"`
+	Currency           money.Currency  `json:"currency"`
 	Featured           bool            `json:"featured"`
+	Weight             float64         `json:"weight"`
+	Length             float64         `json:"length"`
+	Width              float64         `json:"width"`
+	Height             float64         `json:"height"`
 	Images             []ProductImage  `json:"images"`
 	Colors             []ProductColor  `json:"colors"`
 	Sizes              []ProductSize   `json:"sizes"`
 	Inventory          []InventoryItem `json:"inventory"`
+	AverageRating      float64         `json:"average_rating"`
+	ReviewCount        int             `json:"review_count"`
 	CreatedAt          time.Time       `json:"created_at"`
 	UpdatedAt          time.Time       `json:"updated_at"`
 }
 
+// ProductListItem represents a product in a list of products
+type ProductListItem struct {
+	ID                 int64          `json:"id"`
+	Name               string         `json:"name"`
+	Slug               string         `json:"slug"`
+	Description        string         `json:"description"`
+	CategoryID         int64          `json:"category_id"`
+	CategoryName       string         `json:"category_name"`
+	BasePrice          money.Amount   `json:"base_price"`
+	DiscountPercentage float64        `json:"discount_percentage"`
+	FinalPrice         money.Amount   `json:"final_price"`
+	Currency           money.Currency `json:"currency"`
+	Featured           bool           `json:"featured"`
+	AverageRating      float64        `json:"average_rating"`
+	ReviewCount        int            `json:"review_count"`
+	PrimaryImage       *string        `json:"primary_image"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+}
+
 // InventoryItem represents a simplified inventory item for the response
 type InventoryItem struct {
 	ColorID  int64 `json:"color_id"`
@@ -79,18 +115,23 @@ type InventoryItem struct {
 
 // CreateProductRequest represents the request to create a product
 type CreateProductRequest struct {
-	Name               string  `json:"name"`
-	Description        string  `json:"description"`
-	CategoryID         int64   `json:"category_id"`
-	BasePrice          float64 `json:"base_price"`
-	DiscountPercentage float64 `json:"discount_percentage"`
-	Featured           bool    `json:"featured"`
+	Name               string       `json:"name"`
+	Description        string       `json:"description"`
+	CategoryID         int64        `json:"category_id"`
+	BasePrice          money.Amount `json:"base_price"`
+	DiscountPercentage float64      `json:"discount_percentage"`
+	Featured           bool         `json:"featured"`
+	Weight             float64      `json:"weight"`
+	Length             float64      `json:"length"`
+	Width              float64      `json:"width"`
+	Height             float64      `json:"height"`
 }
 
 // Category represents a product category
 type Category struct {
 	ID          int64     `json:"id"`
 	Name        string    `json:"name"`
+	Slug        string    `json:"slug"`
 	Description string    `json:"description"`
 	ImageURL    string    `json:"image_url"`
 	CreatedAt   time.Time `json:"created_at"`
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"backend/money"
+	"time"
+)
+
+// Promotion is a coupon code with the rule that computes its discount
+type Promotion struct {
+	ID           int64        `json:"id"`
+	Code         string       `json:"code"`
+	Description  string       `json:"description"`
+	Type         string       `json:"type"`
+	Value        float64      `json:"value"`
+	BuyQuantity  int          `json:"buy_quantity"`
+	GetQuantity  int          `json:"get_quantity"`
+	CategoryID   *int64       `json:"category_id"`
+	ProductID    *int64       `json:"product_id"`
+	MinSubtotal  money.Amount `json:"min_subtotal"`
+	StartsAt     *time.Time   `json:"starts_at"`
+	EndsAt       *time.Time   `json:"ends_at"`
+	UsageLimit   *int         `json:"usage_limit"`
+	PerUserLimit *int         `json:"per_user_limit"`
+	IsActive     bool         `json:"is_active"`
+	TimesUsed    int          `json:"times_used"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+// PromotionRequest is the request format for creating/updating a promotion
+type PromotionRequest struct {
+	Code         string       `json:"code"`
+	Description  string       `json:"description"`
+	Type         string       `json:"type"`
+	Value        float64      `json:"value"`
+	BuyQuantity  int          `json:"buy_quantity"`
+	GetQuantity  int          `json:"get_quantity"`
+	CategoryID   *int64       `json:"category_id"`
+	ProductID    *int64       `json:"product_id"`
+	MinSubtotal  money.Amount `json:"min_subtotal"`
+	StartsAt     *time.Time   `json:"starts_at"`
+	EndsAt       *time.Time   `json:"ends_at"`
+	UsageLimit   *int         `json:"usage_limit"`
+	PerUserLimit *int         `json:"per_user_limit"`
+	IsActive     *bool        `json:"is_active"`
+}
+
+// ApplyCouponRequest is the request format for applying a coupon to the cart
+type ApplyCouponRequest struct {
+	Code string `json:"code"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"backend/money"
+	"time"
+)
+
+// Return is a customer's request to send back items of a delivered order
+type Return struct {
+	ID           int64        `json:"id"`
+	OrderID      int64        `json:"order_id"`
+	UserID       int64        `json:"user_id"`
+	Status       string       `json:"status"`
+	Reason       string       `json:"reason"`
+	AdminNote    string       `json:"admin_note"`
+	Restocked    bool         `json:"restocked"`
+	RefundAmount money.Amount `json:"refund_amount"`
+	RefundState  string       `json:"refund_state"`
+	Items        []ReturnItem `json:"items"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	ReceivedAt   *time.Time   `json:"received_at"`
+	RefundedAt   *time.Time   `json:"refunded_at"`
+}
+
+// ReturnItem is the quantity of an order item being returned
+type ReturnItem struct {
+	OrderItemID int64  `json:"order_item_id"`
+	ProductID   int64  `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Quantity    int    `json:"quantity"`
+}
+
+// ReturnRequest is the request format for requesting a return
+type ReturnRequest struct {
+	Reason string              `json:"reason"`
+	Items  []ReturnItemRequest `json:"items"`
+}
+
+// ReturnItemRequest is a quantity of an order item to return
+type ReturnItemRequest struct {
+	OrderItemID int64 `json:"order_item_id"`
+	Quantity    int   `json:"quantity"`
+}
+
+// ReviewReturnRequest is the request format for approving or rejecting a return
+type ReviewReturnRequest struct {
+	Note string `json:"note"`
+}
+
+// ReceiveReturnRequest is the request format for receiving returned goods.
+// Without a refund amount the value of the returned items is refunded.
+type ReceiveReturnRequest struct {
+	Restock      bool          `json:"restock"`
+	RefundAmount *money.Amount `json:"refund_amount"`
+	Note         string        `json:"note"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Role is a named set of permissions held by the users assigned to it
+type Role struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	UserCount   int       `json:"user_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// RequireTwoFactor makes the role's users set up two-factor authentication
+	RequireTwoFactor bool `json:"require_two_factor"`
+}
+
+// RoleRequest is the request format for creating/updating a role
+type RoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// Permission is a right that roles can grant
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// AssignRoleRequest is the request format for changing a user's role
+type AssignRoleRequest struct {
+	Role string `json:"role"`
+}
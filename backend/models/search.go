@@ -0,0 +1,34 @@
+package models
+
+import "backend/money"
+
+// SearchResult represents a product matched by a full-text search
+type SearchResult struct {
+	ID                 int64        `json:"id"`
+	Name               string       `json:"name"`
+	Slug               string       `json:"slug"`
+	Description        string       `json:"description"`
+	CategoryID         int64        `json:"category_id"`
+	CategoryName       string       `json:"category_name"`
+	BasePrice          money.Amount `json:"base_price"`
+	DiscountPercentage float64      `json:"discount_percentage"`
+	FinalPrice         money.Amount `json:"final_price"`
+	PrimaryImage       *string      `json:"primary_image"`
+	HighlightedName    string       `json:"highlighted_name"`
+	Snippet            string       `json:"snippet"`
+	Score              float64      `json:"score"`
+}
+
+// FacetCount is the number of matching products for a facet value
+type FacetCount struct {
+	ID    int64  `json:"id,omitempty"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// SearchFacets groups facet counts of a search by attribute
+type SearchFacets struct {
+	Categories []FacetCount `json:"categories"`
+	Colors     []FacetCount `json:"colors"`
+	Sizes      []FacetCount `json:"sizes"`
+}
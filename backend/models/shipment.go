@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Shipment is a parcel sent for part or all of an order
+type Shipment struct {
+	ID             int64          `json:"id"`
+	OrderID        int64          `json:"order_id"`
+	Carrier        string         `json:"carrier"`
+	TrackingNumber string         `json:"tracking_number"`
+	Status         string         `json:"status"`
+	Items          []ShipmentItem `json:"items"`
+	ShippedAt      time.Time      `json:"shipped_at"`
+	DeliveredAt    *time.Time     `json:"delivered_at"`
+}
+
+// ShipmentItem is the quantity of an order item packed in a shipment
+type ShipmentItem struct {
+	OrderItemID int64  `json:"order_item_id"`
+	ProductID   int64  `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Quantity    int    `json:"quantity"`
+}
+
+// ShipmentRequest is the request format for shipping order items
+type ShipmentRequest struct {
+	Carrier        string                `json:"carrier"`
+	TrackingNumber string                `json:"tracking_number"`
+	Items          []ShipmentItemRequest `json:"items"`
+}
+
+// ShipmentItemRequest is a quantity of an order item to ship
+type ShipmentItemRequest struct {
+	OrderItemID int64 `json:"order_item_id"`
+	Quantity    int   `json:"quantity"`
+}
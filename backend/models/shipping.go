@@ -0,0 +1,68 @@
+package models
+
+import (
+	"backend/money"
+	"time"
+)
+
+// ShippingZone is a delivery region, a country optionally narrowed to a postal code prefix
+type ShippingZone struct {
+	ID           int64            `json:"id"`
+	Name         string           `json:"name"`
+	Country      string           `json:"country"`
+	PostalPrefix string           `json:"postal_prefix"`
+	Methods      []ShippingMethod `json:"methods"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+}
+
+// ShippingMethod is a way of delivering to a zone together with its pricing
+type ShippingMethod struct {
+	ID            int64        `json:"id"`
+	ZoneID        int64        `json:"zone_id"`
+	Code          string       `json:"code"`
+	Name          string       `json:"name"`
+	Pricing       string       `json:"pricing"`
+	Rate          money.Amount `json:"rate"`
+	FreeThreshold money.Amount `json:"free_threshold"`
+	MinDays       int          `json:"min_days"`
+	MaxDays       int          `json:"max_days"`
+	IsActive      bool         `json:"is_active"`
+	Tiers         []WeightTier `json:"tiers"`
+}
+
+// WeightTier is the price of parcels up to a weight in kilograms
+type WeightTier struct {
+	MaxWeight float64      `json:"max_weight"`
+	Rate      money.Amount `json:"rate"`
+}
+
+// ShippingZoneRequest is the request format for creating/updating a shipping zone
+type ShippingZoneRequest struct {
+	Name         string `json:"name"`
+	Country      string `json:"country"`
+	PostalPrefix string `json:"postal_prefix"`
+}
+
+// ShippingMethodRequest is the request format for creating/updating a shipping method
+type ShippingMethodRequest struct {
+	Code          string       `json:"code"`
+	Name          string       `json:"name"`
+	Pricing       string       `json:"pricing"`
+	Rate          money.Amount `json:"rate"`
+	FreeThreshold money.Amount `json:"free_threshold"`
+	MinDays       int          `json:"min_days"`
+	MaxDays       int          `json:"max_days"`
+	IsActive      *bool        `json:"is_active"`
+	Tiers         []WeightTier `json:"tiers"`
+}
+
+// ShippingOption is a priced shipping method offered for a cart
+type ShippingOption struct {
+	MethodID int64        `json:"method_id"`
+	Method   string       `json:"method"`
+	Name     string       `json:"name"`
+	Cost     money.Amount `json:"cost"`
+	MinDays  int          `json:"min_days"`
+	MaxDays  int          `json:"max_days"`
+}
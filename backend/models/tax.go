@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// TaxRule is the tax rate of a country, optionally narrowed to a state and a category
+type TaxRule struct {
+	ID               int64     `json:"id"`
+	Name             string    `json:"name"`
+	Country          string    `json:"country"`
+	State            string    `json:"state"`
+	CategoryID       *int64    `json:"category_id"`
+	Rate             float64   `json:"rate"`
+	PriceIncludesTax bool      `json:"price_includes_tax"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// TaxRuleRequest is the request format for creating/updating a tax rule
+type TaxRuleRequest struct {
+	Name             string  `json:"name"`
+	Country          string  `json:"country"`
+	State            string  `json:"state"`
+	CategoryID       *int64  `json:"category_id"`
+	Rate             float64 `json:"rate"`
+	PriceIncludesTax bool    `json:"price_includes_tax"`
+}
@@ -0,0 +1,36 @@
+package models
+
+// TwoFactorStatus describes a user's two-factor authentication
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TwoFactorCodeRequest is the expected request format for confirming two-factor setup
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// TwoFactorVerifyRequest is the expected request format for finishing a login
+// with a code from the authenticator app or a recovery code
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
+// TwoFactorDisableRequest is the expected request format for turning off two-factor authentication
+type TwoFactorDisableRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// RecoveryCodesRequest is the expected request format for replacing the recovery codes
+type RecoveryCodesRequest struct {
+	Password string `json:"password"`
+}
+
+// TwoFactorPolicyRequest is the expected request format for requiring two-factor authentication of a role
+type TwoFactorPolicyRequest struct {
+	Required bool `json:"required"`
+}
@@ -13,16 +13,35 @@ type User struct {
 	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// EmailVerifiedAt is nil until the owner confirms the email address
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// DisabledAt is set while the account is not allowed to log in
+	DisabledAt *time.Time `json:"disabled_at"`
+	// PasswordResetRequired blocks logins until the owner sets a new password
+	PasswordResetRequired bool `json:"password_reset_required"`
+	// DeletedAt is set once the owner deleted the account and it was anonymised
+	DeletedAt *time.Time `json:"deleted_at"`
+	// TwoFactorEnabled is set once logins need a code from an authenticator app
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
 }
 
 // UserResponse is what we return to the client (excludes password)
 type UserResponse struct {
-	ID        int64     `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	Role          string    `json:"role"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	// Account status, shown to administrators
+	DisabledAt            *time.Time `json:"disabled_at,omitempty"`
+	PasswordResetRequired bool       `json:"password_reset_required,omitempty"`
+	DeletedAt             *time.Time `json:"deleted_at,omitempty"`
+
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
 }
 
 // UserLogin is the expected request format for login
@@ -38,14 +57,61 @@ type UserRegister struct {
 	Password string `json:"password"`
 }
 
+// UpdateProfileRequest is the expected request format for updating the current user.
+// Empty fields are left unchanged; changing the email needs the current password.
+type UpdateProfileRequest struct {
+	Name            string `json:"name"`
+	Email           string `json:"email"`
+	CurrentPassword string `json:"current_password"`
+}
+
+// ChangePasswordRequest is the expected request format for changing the current user's password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// DeleteAccountRequest is the expected request format for deleting the current user's account
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// RefreshRequest is the expected request format for refreshing an access token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ForgotPasswordRequest is the expected request format for asking for a password reset
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest is the expected request format for setting a new password
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// VerifyEmailRequest is the expected request format for verifying an email address
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
 // ToResponse converts a User to a UserResponse
 func (u *User) ToResponse() UserResponse {
 	return UserResponse{
-		ID:        u.ID,
-		Name:      u.Name,
-		Email:     u.Email,
-		Role:      u.Role,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
+		ID:            u.ID,
+		Name:          u.Name,
+		Email:         u.Email,
+		Role:          u.Role,
+		EmailVerified: u.EmailVerifiedAt != nil,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+
+		DisabledAt:            u.DisabledAt,
+		PasswordResetRequired: u.PasswordResetRequired,
+		DeletedAt:             u.DeletedAt,
+
+		TwoFactorEnabled: u.TwoFactorEnabled,
 	}
 } 
\ No newline at end of file
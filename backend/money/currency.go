@@ -0,0 +1,58 @@
+package money
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// ErrUnknownCurrency is returned for a code that is not a supported ISO 4217 currency
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// Currency is an ISO 4217 currency code. Every supported currency is
+// divided into 100 minor units, so an Amount means the same in all of them.
+type Currency string
+
+// Supported currencies
+const (
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+	GBP Currency = "GBP"
+	CAD Currency = "CAD"
+	AUD Currency = "AUD"
+	CHF Currency = "CHF"
+	SEK Currency = "SEK"
+)
+
+// currencies is the set of supported currencies
+var currencies = map[Currency]bool{
+	USD: true,
+	EUR: true,
+	GBP: true,
+	CAD: true,
+	AUD: true,
+	CHF: true,
+	SEK: true,
+}
+
+// ParseCurrency returns the supported currency for a case-insensitive code
+func ParseCurrency(code string) (Currency, error) {
+	currency := Currency(strings.ToUpper(strings.TrimSpace(code)))
+	if !currencies[currency] {
+		return "", ErrUnknownCurrency
+	}
+	return currency, nil
+}
+
+// StoreCurrency returns the currency prices are kept in, from STORE_CURRENCY (default USD)
+func StoreCurrency() Currency {
+	if currency, err := ParseCurrency(os.Getenv("STORE_CURRENCY")); err == nil {
+		return currency
+	}
+	return USD
+}
+
+// Format formats an amount with the currency code, such as "19.99 USD"
+func (c Currency) Format(amount Amount) string {
+	return amount.String() + " " + string(c)
+}
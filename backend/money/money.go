@@ -0,0 +1,234 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidAmount is returned for text that is not a decimal amount
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// Amount is a sum of money in minor units, hundredths of the currency's major
+// unit. Amounts are stored as integers and encoded in JSON as decimal numbers,
+// so 1999 is written as 19.99.
+//
+// Rounding is always half away from zero, on the exact decimal value.
+type Amount int64
+
+// FromFloat converts a decimal amount in major units, rounding to the nearest minor unit
+func FromFloat(value float64) Amount {
+	amount, err := Parse(strconv.FormatFloat(value, 'f', -1, 64))
+	if err != nil {
+		return Amount(math.Round(value * 100))
+	}
+	return amount
+}
+
+// Parse reads a decimal amount in major units such as "19.99" or "-5",
+// rounding to the nearest minor unit
+func Parse(text string) (Amount, error) {
+	text = strings.TrimSpace(text)
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+
+	whole, fraction, _ := strings.Cut(text, ".")
+	if whole == "" && fraction == "" {
+		return 0, ErrInvalidAmount
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	for _, part := range []string{whole, fraction} {
+		if strings.Trim(part, "0123456789") != "" {
+			return 0, ErrInvalidAmount
+		}
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/100-1 {
+		return 0, ErrInvalidAmount
+	}
+
+	// Two digits are kept, the third decides the rounding
+	fraction += "000"
+	cents, _ := strconv.ParseInt(fraction[:2], 10, 64)
+	amount := units*100 + cents
+	if fraction[2] >= '5' {
+		amount++
+	}
+
+	if negative {
+		amount = -amount
+	}
+	return Amount(amount), nil
+}
+
+// Float returns the amount in major units, for display and logging only
+func (a Amount) Float() float64 {
+	return float64(a) / 100
+}
+
+// String formats the amount in major units with two decimals
+func (a Amount) String() string {
+	sign := ""
+	value := int64(a)
+	if value < 0 {
+		sign, value = "-", -value
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, value/100, value%100)
+}
+
+// Mul returns the amount multiplied by a quantity
+func (a Amount) Mul(quantity int) Amount {
+	return a * Amount(quantity)
+}
+
+// Percent returns the given percentage of the amount, such as 20 for 20%.
+// Percentages are taken to two decimals.
+func (a Amount) Percent(percentage float64) Amount {
+	return a.mulDiv(basisPoints(percentage), 10000)
+}
+
+// IncludedPercent returns the part of a gross amount that a percentage
+// added on top of its net value accounts for, such as VAT in a VAT-inclusive price
+func (a Amount) IncludedPercent(percentage float64) Amount {
+	points := basisPoints(percentage)
+	return a.mulDiv(points, 10000+points)
+}
+
+// Convert converts the amount at an exchange rate, which is taken to six decimals
+func (a Amount) Convert(rate float64) Amount {
+	return a.mulDiv(int64(math.Round(rate*1e6)), 1e6)
+}
+
+// Share returns the part of the amount proportional to part out of whole,
+// such as the discount on 2 of 3 units of an order line
+func (a Amount) Share(part, whole int64) Amount {
+	if whole == 0 {
+		return 0
+	}
+	return a.mulDiv(part, whole)
+}
+
+// Allocate splits the amount over weights in proportion, such as a discount
+// over cart lines by their value. Every share but the last is rounded, the
+// last takes the remainder so the shares always add up to the amount.
+func (a Amount) Allocate(weights []Amount) []Amount {
+	shares := make([]Amount, len(weights))
+	if len(weights) == 0 {
+		return shares
+	}
+
+	total := Sum(weights...)
+	remaining := a
+	for i := range weights[:len(weights)-1] {
+		shares[i] = a.Share(int64(weights[i]), int64(total))
+		remaining -= shares[i]
+	}
+	shares[len(shares)-1] = remaining
+	return shares
+}
+
+// Sum adds up amounts
+func Sum(amounts ...Amount) Amount {
+	var total Amount
+	for _, amount := range amounts {
+		total += amount
+	}
+	return total
+}
+
+// Min returns the smaller of two amounts
+func Min(a, b Amount) Amount {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// mulDiv returns a * numerator / denominator rounded half away from zero,
+// without overflowing on the intermediate product
+func (a Amount) mulDiv(numerator, denominator int64) Amount {
+	product := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(numerator))
+	quotient, remainder := new(big.Int).QuoRem(product, big.NewInt(denominator), new(big.Int))
+
+	// Round away from zero when the remainder is at least half the denominator
+	remainder.Abs(remainder).Mul(remainder, big.NewInt(2))
+	if remainder.Cmp(new(big.Int).Abs(big.NewInt(denominator))) >= 0 {
+		if product.Sign()*big.NewInt(denominator).Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return Amount(quotient.Int64())
+}
+
+// basisPoints converts a percentage to hundredths of a percent
+func basisPoints(percentage float64) int64 {
+	return int64(math.Round(percentage * 100))
+}
+
+// MarshalJSON encodes the amount as a decimal number in major units
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON decodes a decimal number, or a string holding one, in major units
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	amount, err := Parse(strings.Trim(text, `"`))
+	if err != nil {
+		// Numbers in exponent notation such as 1e3
+		value, floatErr := strconv.ParseFloat(strings.Trim(text, `"`), 64)
+		if floatErr != nil || math.IsInf(value, 0) || math.IsNaN(value) {
+			return err
+		}
+		amount = FromFloat(value)
+	}
+	*a = amount
+	return nil
+}
+
+// Scan reads an amount of minor units from the database. Columns created
+// before amounts were stored as integers may hand back REAL values.
+func (a *Amount) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*a = 0
+	case int64:
+		*a = Amount(v)
+	case float64:
+		*a = Amount(math.Round(v))
+	case []byte:
+		return a.scanText(string(v))
+	case string:
+		return a.scanText(v)
+	default:
+		return fmt.Errorf("cannot scan %T into money.Amount", value)
+	}
+	return nil
+}
+
+// scanText reads minor units stored as text
+func (a *Amount) scanText(text string) error {
+	value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return err
+	}
+	*a = Amount(math.Round(value))
+	return nil
+}
+
+// Value stores the amount as an integer of minor units
+func (a Amount) Value() (driver.Value, error) {
+	return int64(a), nil
+}
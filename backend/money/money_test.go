@@ -0,0 +1,149 @@
+package money_test
+
+import (
+	"backend/money"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want money.Amount
+	}{
+		{"19.99", 1999},
+		{"-5", -500},
+		{"+3", 300},
+		{".5", 50},
+		{"7.", 700},
+		{" 1.2 ", 120},
+		// The third digit rounds half up, away from zero for negative amounts
+		{"0.004", 0},
+		{"0.005", 1},
+		{"0.0049999", 0},
+		{"19.995", 2000},
+		{"-0.005", -1},
+		{"-19.994", -1999},
+	}
+	for _, test := range tests {
+		got, err := money.Parse(test.text)
+		if err != nil || got != test.want {
+			t.Errorf("Parse(%q) = %d, %v, want %d", test.text, got, err, test.want)
+		}
+	}
+
+	for _, text := range []string{"", ".", "-", "abc", "1.2.3", "1,50", "1e3", "--1", "99999999999999999999"} {
+		if _, err := money.Parse(text); err != money.ErrInvalidAmount {
+			t.Errorf("Parse(%q) = %v, want ErrInvalidAmount", text, err)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := map[money.Amount]string{
+		0:     "0.00",
+		5:     "0.05",
+		1999:  "19.99",
+		-1:    "-0.01",
+		-1999: "-19.99",
+	}
+	for amount, want := range tests {
+		if got := amount.String(); got != want {
+			t.Errorf("Amount(%d).String() = %q, want %q", amount, got, want)
+		}
+	}
+}
+
+func TestRounding(t *testing.T) {
+	tests := []struct {
+		name string
+		got  money.Amount
+		want money.Amount
+	}{
+		{"10% of 0.05", money.Amount(5).Percent(10), 1},
+		{"10% of -0.05", money.Amount(-5).Percent(10), -1},
+		{"12.5% of 19.99", money.Amount(1999).Percent(12.5), 250},
+		{"-12.5% of 19.99", money.Amount(1999).Percent(-12.5), -250},
+		{"20% VAT in 120.00", money.Amount(12000).IncludedPercent(20), 2000},
+		{"half of 0.15", money.Amount(15).Share(1, 2), 8},
+		{"half of -0.15", money.Amount(-15).Share(1, 2), -8},
+		{"0.15 over -2", money.Amount(15).Share(1, -2), -8},
+		{"a share of nothing", money.Amount(15).Share(1, 0), 0},
+		{"no overflow", money.Amount(1<<62).Share(3, 4), 3 << 60},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s = %d, want %d", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		amount money.Amount
+		rate   float64
+		want   money.Amount
+	}{
+		{1000, 1, 1000},
+		{1000, 0.915, 915},
+		{1999, 1.5, 2999},
+		{-1999, 1.5, -2999},
+		{10000, 149.3456789, 1493457}, // the rate is taken to six decimals
+		{1000, 0.0000004, 0},
+	}
+	for _, test := range tests {
+		if got := test.amount.Convert(test.rate); got != test.want {
+			t.Errorf("Amount(%d).Convert(%v) = %d, want %d", test.amount, test.rate, got, test.want)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		amount  money.Amount
+		weights []money.Amount
+		want    []money.Amount
+	}{
+		{100, []money.Amount{1, 1, 1}, []money.Amount{33, 33, 34}},
+		{-100, []money.Amount{1, 1, 1}, []money.Amount{-33, -33, -34}},
+		{1000, []money.Amount{2500, 7500}, []money.Amount{250, 750}},
+		{1, []money.Amount{1, 1}, []money.Amount{1, 0}},
+		{500, []money.Amount{0, 0}, []money.Amount{0, 500}},
+		{500, []money.Amount{}, []money.Amount{}},
+	}
+	for _, test := range tests {
+		got := test.amount.Allocate(test.weights)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Amount(%d).Allocate(%v) = %v, want %v", test.amount, test.weights, got, test.want)
+		}
+		if len(got) > 0 && money.Sum(got...) != test.amount {
+			t.Errorf("Amount(%d).Allocate(%v) adds up to %d", test.amount, test.weights, money.Sum(got...))
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var decoded struct {
+		Number money.Amount  `json:"number"`
+		Text   money.Amount  `json:"text"`
+		Exp    money.Amount  `json:"exp"`
+		Null   *money.Amount `json:"null"`
+	}
+	err := json.Unmarshal([]byte(`{"number": 19.995, "text": "-2.5", "exp": 1e2, "null": null}`), &decoded)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if decoded.Number != 2000 || decoded.Text != -250 || decoded.Exp != 10000 || decoded.Null != nil {
+		t.Errorf("Unmarshal = %+v, want 2000, -250, 10000 and nil", decoded)
+	}
+
+	encoded, err := json.Marshal(map[string]money.Amount{"total": 1999})
+	if err != nil || string(encoded) != `{"total":19.99}` {
+		t.Errorf("Marshal = %s, %v, want {\"total\":19.99}", encoded, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"number": "abc"}`), &decoded); err == nil {
+		t.Error("Unmarshal of text that is not a number succeeded")
+	}
+}
@@ -0,0 +1,183 @@
+package orderstatus
+
+import (
+	"backend/database"
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Fields whose changes are recorded in order_status_history
+const (
+	FieldOrder   = "order_status"
+	FieldPayment = "payment_status"
+)
+
+// orderTransitions lists the statuses an order can move to from each status
+var orderTransitions = map[string][]string{
+	"processing":        {"partially_shipped", "shipped", "cancelled"},
+	"partially_shipped": {"shipped", "cancelled"},
+	"shipped":           {"delivered", "cancelled"},
+	"delivered":         {},
+	"cancelled":         {},
+}
+
+// paymentTransitions lists the payment statuses reachable from each payment status
+var paymentTransitions = map[string][]string{
+	"pending":            {"authorized", "paid", "failed", "voided"},
+	"authorized":         {"paid", "failed", "voided"},
+	"failed":             {"pending", "authorized", "paid"},
+	"paid":               {"refunded", "partially_refunded"},
+	"partially_refunded": {"refunded"},
+	"refunded":           {},
+	"voided":             {},
+}
+
+// TransitionError is returned for a status change the state machine does not allow
+type TransitionError struct {
+	Field string
+	From  string
+	To    string
+}
+
+// Error describes the rejected move, ready to be shown to API clients
+func (e *TransitionError) Error() string {
+	label := strings.ReplaceAll(e.Field, "_", " ")
+	if e.From == e.To {
+		return fmt.Sprintf("%s%s is already %s", strings.ToUpper(label[:1]), label[1:], e.To)
+	}
+	return fmt.Sprintf("Cannot change %s from %s to %s", label, e.From, e.To)
+}
+
+// Actor identifies who changed a status: a user with their role, or a named
+// system component such as a payment provider
+type Actor struct {
+	UserID int64
+	Name   string
+}
+
+// System is the actor for changes made by the application itself
+var System = Actor{Name: "system"}
+
+// IsOrderStatus reports whether status is a known order status
+func IsOrderStatus(status string) bool {
+	_, ok := orderTransitions[status]
+	return ok
+}
+
+// CheckOrder returns a TransitionError unless an order may move from one status to another
+func CheckOrder(from, to string) error {
+	return check(orderTransitions, FieldOrder, from, to)
+}
+
+// CheckPayment returns a TransitionError unless a payment may move from one status to another
+func CheckPayment(from, to string) error {
+	return check(paymentTransitions, FieldPayment, from, to)
+}
+
+// Lock touches an order so that it stays locked until the transaction ends,
+// making concurrent changes to the order run one after another. It returns
+// sql.ErrNoRows for an unknown order.
+func Lock(tx *sql.Tx, orderID int64) error {
+	result, err := tx.Exec("UPDATE orders SET updated_at = updated_at WHERE id = ?", orderID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		if err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetOrder moves an order to a new status and records the change
+func SetOrder(db database.Querier, orderID int64, to string, actor Actor, note string) error {
+	return set(db, orderID, FieldOrder, orderTransitions, to, actor, note)
+}
+
+// SetPayment moves an order's payment to a new status and records the change.
+// Staying in the same status, such as a retried payment failing again, is not an error.
+func SetPayment(db database.Querier, orderID int64, to string, actor Actor, note string) error {
+	err := set(db, orderID, FieldPayment, paymentTransitions, to, actor, note)
+	if e, ok := err.(*TransitionError); ok && e.From == e.To {
+		return nil
+	}
+	return err
+}
+
+// Record adds an entry to an order's status history
+func Record(db database.Querier, orderID int64, field, from, to string, actor Actor, note string) error {
+	var actorID interface{}
+	if actor.UserID > 0 {
+		actorID = actor.UserID
+	}
+
+	_, err := db.Exec(
+		`INSERT INTO order_status_history (order_id, field, from_status, to_status, actor_id, actor, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		orderID, field, from, to, actorID, actor.Name, note, time.Now())
+	return err
+}
+
+// History returns the status changes of an order, oldest first
+func History(db database.Querier, orderID int64) ([]models.OrderStatusChange, error) {
+	rows, err := db.Query(`
+		SELECT id, field, from_status, to_status, actor_id, actor, COALESCE(note, ''), created_at
+		FROM order_status_history
+		WHERE order_id = ?
+		ORDER BY created_at ASC, id ASC`,
+		orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.OrderStatusChange{}
+	for rows.Next() {
+		change := models.OrderStatusChange{OrderID: orderID}
+		var actorID sql.NullInt64
+		err := rows.Scan(
+			&change.ID, &change.Field, &change.FromStatus, &change.ToStatus,
+			&actorID, &change.Actor, &change.Note, &change.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if actorID.Valid {
+			change.ActorID = &actorID.Int64
+		}
+		history = append(history, change)
+	}
+	return history, rows.Err()
+}
+
+// check looks a transition up in a transition table
+func check(transitions map[string][]string, field, from, to string) error {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return &TransitionError{Field: field, From: from, To: to}
+}
+
+// set reads the current status, checks the transition, then updates and records it
+func set(db database.Querier, orderID int64, field string, transitions map[string][]string, to string, actor Actor, note string) error {
+	var from string
+	err := db.QueryRow("SELECT "+field+" FROM orders WHERE id = ?", orderID).Scan(&from)
+	if err != nil {
+		return err
+	}
+	if err := check(transitions, field, from, to); err != nil {
+		return err
+	}
+
+	_, err = db.Exec("UPDATE orders SET "+field+" = ?, updated_at = ? WHERE id = ?", to, time.Now(), orderID)
+	if err != nil {
+		return err
+	}
+	return Record(db, orderID, field, from, to, actor, note)
+}
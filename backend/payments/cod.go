@@ -0,0 +1,37 @@
+package payments
+
+import "backend/money"
+
+// CashOnDeliveryProvider accepts orders paid in cash to the courier. Payments
+// stay pending until the order is delivered, when the cash is captured.
+type CashOnDeliveryProvider struct{}
+
+// Name returns the payment method handled by the provider
+func (CashOnDeliveryProvider) Name() string {
+	return "cod"
+}
+
+// Authorize accepts the order, nothing is collected up front
+func (CashOnDeliveryProvider) Authorize(req AuthorizeRequest) (*Result, error) {
+	return &Result{TransactionID: newTransactionID("cod"), Status: StatusPending}, nil
+}
+
+// Capture records the cash collected on delivery
+func (CashOnDeliveryProvider) Capture(transactionID string, amount money.Amount) (*Result, error) {
+	return &Result{TransactionID: transactionID, Status: StatusCaptured}, nil
+}
+
+// Refund records cash handed back to the customer
+func (CashOnDeliveryProvider) Refund(transactionID string, amount money.Amount) (*Result, error) {
+	return &Result{TransactionID: transactionID, Status: StatusRefunded}, nil
+}
+
+// Void records that no cash will be collected for a cancelled order
+func (CashOnDeliveryProvider) Void(transactionID string) (*Result, error) {
+	return &Result{TransactionID: transactionID, Status: StatusVoided}, nil
+}
+
+// VerifyWebhook always fails, cash payments have no callbacks
+func (CashOnDeliveryProvider) VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error) {
+	return nil, ErrWebhooksUnsupported
+}
@@ -0,0 +1,95 @@
+package payments
+
+import (
+	"backend/money"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+)
+
+// Tokens that make the mock gateway behave like a real card would
+const (
+	MockTokenDecline = "tok_decline"
+	MockTokenAsync   = "tok_async"
+)
+
+// MockProvider is a local gateway for development and testing. It approves
+// every payment except those made with MockTokenDecline, and leaves payments
+// made with MockTokenAsync pending until a webhook settles them.
+type MockProvider struct{}
+
+// Name returns the payment method handled by the provider
+func (MockProvider) Name() string {
+	return "mock"
+}
+
+// Authorize reserves the order amount
+func (MockProvider) Authorize(req AuthorizeRequest) (*Result, error) {
+	transactionID := newTransactionID("mock")
+
+	switch req.Token {
+	case MockTokenDecline:
+		return &Result{TransactionID: transactionID, Status: StatusFailed, Message: "Card declined"}, nil
+	case MockTokenAsync:
+		return &Result{TransactionID: transactionID, Status: StatusPending}, nil
+	}
+	return &Result{TransactionID: transactionID, Status: StatusAuthorized}, nil
+}
+
+// Capture collects a previously authorized amount
+func (MockProvider) Capture(transactionID string, amount money.Amount) (*Result, error) {
+	return &Result{TransactionID: transactionID, Status: StatusCaptured}, nil
+}
+
+// Refund returns a captured amount to the customer
+func (MockProvider) Refund(transactionID string, amount money.Amount) (*Result, error) {
+	return &Result{TransactionID: transactionID, Status: StatusRefunded}, nil
+}
+
+// Void releases an authorized amount without collecting it
+func (MockProvider) Void(transactionID string) (*Result, error) {
+	return &Result{TransactionID: transactionID, Status: StatusVoided}, nil
+}
+
+// VerifyWebhook checks the HMAC-SHA256 signature of a callback and decodes it.
+// Callbacks are refused while PAYMENT_WEBHOOK_SECRET is not set.
+func (MockProvider) VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error) {
+	mac, err := mockSignature(payload)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, mac) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// SignMockWebhook returns the signature the mock gateway would send with a payload
+func SignMockWebhook(payload []byte) (string, error) {
+	mac, err := mockSignature(payload)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(mac), nil
+}
+
+// mockSignature computes the HMAC of a payload with the webhook secret, which
+// has no default so that nobody can sign callbacks with a known one
+func mockSignature(payload []byte) ([]byte, error) {
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		return nil, ErrWebhookSecretUnset
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return mac.Sum(nil), nil
+}
@@ -0,0 +1,326 @@
+package payments
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/money"
+	"backend/orderstatus"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Payment operations recorded in the payments table
+const (
+	TypeAuthorize = "authorize"
+	TypeCapture   = "capture"
+	TypeRefund    = "refund"
+	TypeVoid      = "void"
+	TypeWebhook   = "webhook"
+)
+
+var (
+	// ErrNothingToCapture is returned when an order has no open authorization
+	ErrNothingToCapture = errors.New("order has no authorized payment to capture")
+
+	// ErrNothingToVoid is returned when an order has no open authorization to release
+	ErrNothingToVoid = errors.New("order has no authorized payment to void")
+
+	// ErrNothingToRefund is returned when an order has no captured payment
+	ErrNothingToRefund = errors.New("order has no captured payment to refund")
+
+	// ErrRefundTooLarge is returned when a refund exceeds what is left of the captured payment
+	ErrRefundTooLarge = errors.New("refund exceeds the captured amount")
+
+	// ErrUnknownTransaction is returned for webhooks about transactions we never started
+	ErrUnknownTransaction = errors.New("unknown transaction")
+)
+
+// orderPaymentStatus maps provider statuses to the order's payment_status
+var orderPaymentStatus = map[string]string{
+	StatusPending:           "pending",
+	StatusAuthorized:        "authorized",
+	StatusCaptured:          "paid",
+	StatusFailed:            "failed",
+	StatusRefunded:          "refunded",
+	StatusPartiallyRefunded: "partially_refunded",
+	StatusVoided:            "voided",
+}
+
+// Authorize starts the payment of an order, in the order's currency, with the
+// provider of its payment method
+func Authorize(db *sql.DB, orderID int64, method string, amount money.Amount, currency money.Currency, token string) (*models.Payment, error) {
+	provider, err := Get(method)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := provider.Authorize(AuthorizeRequest{OrderID: orderID, Amount: amount, Currency: currency, Token: token})
+	return apply(db, orderID, provider.Name(), TypeAuthorize, amount, result, err)
+}
+
+// Capture collects the open authorization of an order
+func Capture(db *sql.DB, orderID int64) (*models.Payment, error) {
+	payment, err := latest(db, orderID, TypeAuthorize, StatusAuthorized, StatusPending)
+	if err == sql.ErrNoRows {
+		return nil, ErrNothingToCapture
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := Get(payment.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := provider.Capture(payment.TransactionID, payment.Amount)
+	return apply(db, orderID, provider.Name(), TypeCapture, payment.Amount, result, err)
+}
+
+// Void releases the open authorization of an order without collecting it, for
+// orders cancelled before their payment was captured
+func Void(db *sql.DB, orderID int64) (*models.Payment, error) {
+	payment, err := latest(db, orderID, TypeAuthorize, StatusAuthorized, StatusPending)
+	if err == sql.ErrNoRows {
+		return nil, ErrNothingToVoid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := Get(payment.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := provider.Void(payment.TransactionID)
+	return apply(db, orderID, provider.Name(), TypeVoid, payment.Amount, result, err)
+}
+
+// Refund returns an amount of an order's captured payment to the customer.
+// The order's payment is partially refunded until refunds add up to the captured amount.
+func Refund(db *sql.DB, orderID int64, amount money.Amount) (*models.Payment, error) {
+	payment, err := latest(db, orderID, "", StatusCaptured)
+	if err == sql.ErrNoRows {
+		return nil, ErrNothingToRefund
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Check what is left after earlier refunds
+	left, err := refundable(db, payment)
+	if err != nil {
+		return nil, err
+	}
+	if left <= 0 {
+		return nil, ErrNothingToRefund
+	}
+	if amount > left {
+		return nil, ErrRefundTooLarge
+	}
+
+	provider, err := Get(payment.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := provider.Refund(payment.TransactionID, amount)
+	if err == nil && result.Status == StatusRefunded && amount < left {
+		result.Status = StatusPartiallyRefunded
+	}
+	return apply(db, orderID, provider.Name(), TypeRefund, amount, result, err)
+}
+
+// Refundable returns how much of an order's captured payment has not been refunded yet
+func Refundable(q database.Querier, orderID int64) (money.Amount, error) {
+	payment, err := latest(q, orderID, "", StatusCaptured)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return refundable(q, payment)
+}
+
+// refundable returns the captured amount of a payment less the refunds made on its order
+func refundable(q database.Querier, captured *models.Payment) (money.Amount, error) {
+	var refunded money.Amount
+	err := q.QueryRow(
+		"SELECT COALESCE(SUM(amount), 0) FROM payments WHERE order_id = ? AND type = ? AND status IN (?, ?)",
+		captured.OrderID, TypeRefund, StatusRefunded, StatusPartiallyRefunded).Scan(&refunded)
+	return captured.Amount - refunded, err
+}
+
+// HandleWebhook verifies a provider callback and applies it to the order it belongs to
+func HandleWebhook(db *sql.DB, providerName string, payload []byte, signature string) (*models.Payment, error) {
+	provider, err := Get(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := provider.VerifyWebhook(payload, signature)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := orderPaymentStatus[event.Status]; !ok {
+		return nil, errors.New("unknown payment status " + event.Status)
+	}
+
+	// Find the order and amount the transaction was started for
+	var orderID int64
+	var amount money.Amount
+	err = db.QueryRow(
+		"SELECT order_id, amount FROM payments WHERE provider = ? AND transaction_id = ? AND type = ? ORDER BY id ASC LIMIT 1",
+		provider.Name(), event.TransactionID, TypeAuthorize).Scan(&orderID, &amount)
+	if err == sql.ErrNoRows {
+		return nil, ErrUnknownTransaction
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A payment settles for the amount it was started with. Refunds made at
+	// the provider return at most what is left, and are recorded as refunds so
+	// that later ones count them.
+	result := &Result{TransactionID: event.TransactionID, Status: event.Status}
+	paymentType := TypeWebhook
+	switch event.Status {
+	case StatusRefunded, StatusPartiallyRefunded:
+		left, err := Refundable(db, orderID)
+		if err != nil {
+			return nil, err
+		}
+		if event.Amount <= 0 || event.Amount > left {
+			return nil, ErrAmountMismatch
+		}
+		if event.Amount < left {
+			result.Status = StatusPartiallyRefunded
+		}
+		paymentType = TypeRefund
+	default:
+		if event.Amount != amount {
+			return nil, ErrAmountMismatch
+		}
+	}
+	return apply(db, orderID, provider.Name(), paymentType, event.Amount, result, nil)
+}
+
+// ForOrder returns every payment attempt of an order, oldest first
+func ForOrder(q database.Querier, orderID int64) ([]models.Payment, error) {
+	rows, err := q.Query(`
+		SELECT id, order_id, provider, transaction_id, type, status, amount, COALESCE(error_message, ''), created_at
+		FROM payments
+		WHERE order_id = ?
+		ORDER BY id ASC`,
+		orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []models.Payment{}
+	for rows.Next() {
+		var payment models.Payment
+		err := rows.Scan(
+			&payment.ID, &payment.OrderID, &payment.Provider, &payment.TransactionID, &payment.Type,
+			&payment.Status, &payment.Amount, &payment.ErrorMessage, &payment.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, rows.Err()
+}
+
+// latest returns the newest payment of an order with one of the given statuses,
+// optionally restricted to one operation type
+func latest(q database.Querier, orderID int64, paymentType string, statuses ...string) (*models.Payment, error) {
+	query := "SELECT id, provider, transaction_id, amount FROM payments WHERE order_id = ? AND status IN ("
+	args := []interface{}{orderID}
+	for i, status := range statuses {
+		if i > 0 {
+			query += ", "
+		}
+		query += "?"
+		args = append(args, status)
+	}
+	query += ")"
+	if paymentType != "" {
+		query += " AND type = ?"
+		args = append(args, paymentType)
+	}
+	query += " ORDER BY id DESC LIMIT 1"
+
+	payment := &models.Payment{OrderID: orderID}
+	err := q.QueryRow(query, args...).Scan(&payment.ID, &payment.Provider, &payment.TransactionID, &payment.Amount)
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// apply records the outcome of a provider call and moves the order's payment
+// status along with it. Provider errors are recorded as failed attempts but
+// leave the order's payment status untouched. A *orderstatus.TransitionError
+// is returned with the recorded payment when the status change is not allowed.
+func apply(db *sql.DB, orderID int64, providerName, paymentType string, amount money.Amount, result *Result, callErr error) (*models.Payment, error) {
+	payment := &models.Payment{
+		OrderID:   orderID,
+		Provider:  providerName,
+		Type:      paymentType,
+		Amount:    amount,
+		CreatedAt: time.Now(),
+	}
+	if callErr != nil {
+		payment.Status = StatusFailed
+		payment.ErrorMessage = callErr.Error()
+	} else {
+		payment.TransactionID = result.TransactionID
+		payment.Status = result.Status
+		payment.ErrorMessage = result.Message
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(
+		`INSERT INTO payments (order_id, provider, transaction_id, type, status, amount, error_message, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		payment.OrderID, payment.Provider, payment.TransactionID, payment.Type,
+		payment.Status, payment.Amount, payment.ErrorMessage, payment.CreatedAt).Scan(&payment.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Move the order's payment status, an illegal move such as a late webhook
+	// about a refunded payment is kept as a payment record only
+	var transitionErr error
+	if callErr == nil {
+		note := paymentType + " " + payment.TransactionID
+		if payment.ErrorMessage != "" {
+			note += ": " + payment.ErrorMessage
+		}
+
+		err = orderstatus.SetPayment(tx, orderID, orderPaymentStatus[payment.Status], orderstatus.Actor{Name: "payment:" + providerName}, note)
+		if _, ok := err.(*orderstatus.TransitionError); ok {
+			transitionErr = err
+		} else if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if transitionErr != nil {
+		return payment, transitionErr
+	}
+	return payment, callErr
+}
@@ -0,0 +1,143 @@
+package payments_test
+
+import (
+	"backend/database/dbtest"
+	"backend/dialect"
+	"backend/models"
+	"backend/money"
+	"backend/payments"
+	"backend/repository"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMockRequiresFlag(t *testing.T) {
+	t.Setenv("PAYMENTS_MOCK", "")
+	payments.Init()
+	if _, err := payments.Get("mock"); err == nil {
+		t.Fatal("the mock gateway is registered without PAYMENTS_MOCK=true")
+	}
+	if _, err := payments.Get("cod"); err != nil {
+		t.Errorf("Get(cod) = %v, want nil", err)
+	}
+}
+
+func TestMockWebhook(t *testing.T) {
+	t.Setenv("PAYMENTS_MOCK", "true")
+	payments.Init()
+
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		orderID := createOrder(t, db, 2500)
+		payment, err := payments.Authorize(db, orderID, "mock", 2500, "USD", payments.MockTokenAsync)
+		if err != nil {
+			t.Fatalf("Authorize: %v", err)
+		}
+
+		send := func(status string, amount money.Amount) error {
+			payload := []byte(fmt.Sprintf(`{"transaction_id": %q, "status": %q, "amount": %s}`,
+				payment.TransactionID, status, amount))
+			signature, err := payments.SignMockWebhook(payload)
+			if err != nil {
+				return err
+			}
+			_, err = payments.HandleWebhook(db, "mock", payload, signature)
+			return err
+		}
+
+		// Without a secret nobody can sign a webhook
+		t.Setenv("PAYMENT_WEBHOOK_SECRET", "")
+		if err := send(payments.StatusCaptured, 2500); err != payments.ErrWebhookSecretUnset {
+			t.Errorf("webhook without a secret = %v, want ErrWebhookSecretUnset", err)
+		}
+
+		t.Setenv("PAYMENT_WEBHOOK_SECRET", "test_secret")
+		if err := send(payments.StatusCaptured, 1); err != payments.ErrAmountMismatch {
+			t.Errorf("webhook for another amount = %v, want ErrAmountMismatch", err)
+		}
+		if err := send(payments.StatusCaptured, 2500); err != nil {
+			t.Fatalf("webhook for the payment = %v, want nil", err)
+		}
+		if err := send(payments.StatusRefunded, 2501); err != payments.ErrAmountMismatch {
+			t.Errorf("refund webhook above the payment = %v, want ErrAmountMismatch", err)
+		}
+		assertPaymentStatus(t, db, orderID, "paid")
+
+		// A refund made at the provider leaves less to refund from here
+		if err := send(payments.StatusRefunded, 1000); err != nil {
+			t.Fatalf("refund webhook = %v, want nil", err)
+		}
+		assertPaymentStatus(t, db, orderID, "partially_refunded")
+		if left, err := payments.Refundable(db, orderID); err != nil || left != 1500 {
+			t.Errorf("Refundable after the refund webhook = %s, %v, want 15.00", left, err)
+		}
+		if _, err := payments.Refund(db, orderID, 1501); err != payments.ErrRefundTooLarge {
+			t.Errorf("refunding more than is left = %v, want ErrRefundTooLarge", err)
+		}
+		if _, err := payments.Refund(db, orderID, 1500); err != nil {
+			t.Fatalf("Refund of what is left: %v", err)
+		}
+		assertPaymentStatus(t, db, orderID, "refunded")
+		if err := send(payments.StatusRefunded, 1); err != payments.ErrAmountMismatch {
+			t.Errorf("refund webhook once fully refunded = %v, want ErrAmountMismatch", err)
+		}
+	})
+}
+
+func TestVoid(t *testing.T) {
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		orderID := createOrder(t, db, 1800)
+
+		if _, err := payments.Void(db, orderID); err != payments.ErrNothingToVoid {
+			t.Errorf("Void before Authorize = %v, want ErrNothingToVoid", err)
+		}
+		if _, err := payments.Authorize(db, orderID, "cod", 1800, "USD", ""); err != nil {
+			t.Fatalf("Authorize: %v", err)
+		}
+		payment, err := payments.Void(db, orderID)
+		if err != nil || payment.Status != payments.StatusVoided {
+			t.Fatalf("Void = %+v, %v, want a voided payment", payment, err)
+		}
+		if _, err := payments.Capture(db, orderID); err == nil {
+			t.Error("Capture after Void succeeded")
+		}
+
+		assertPaymentStatus(t, db, orderID, "voided")
+	})
+}
+
+// assertPaymentStatus checks the payment_status of an order
+func assertPaymentStatus(t *testing.T, db *sql.DB, orderID int64, want string) {
+	t.Helper()
+
+	var status string
+	if err := db.QueryRow("SELECT payment_status FROM orders WHERE id = ?", orderID).Scan(&status); err != nil {
+		t.Fatalf("reading order: %v", err)
+	}
+	if status != want {
+		t.Errorf("payment_status = %q, want %q", status, want)
+	}
+}
+
+// createOrder creates an order of a new customer for an amount
+func createOrder(t *testing.T, db *sql.DB, amount money.Amount) int64 {
+	t.Helper()
+
+	userID := dbtest.CreateUser(t, db, "customer").ID
+	addressID, err := repository.New(db).Addresses.Create(&models.Address{UserID: userID, Name: "Home",
+		Street: "1 Main St", City: "Austin", State: "TX", PostalCode: "73301", Country: "US", Phone: "555"})
+	if err != nil {
+		t.Fatalf("creating address: %v", err)
+	}
+
+	var orderID int64
+	err = db.QueryRow(
+		`INSERT INTO orders (user_id, address_id, total_amount, currency, payment_method, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		userID, addressID, amount, "USD", "mock", time.Now(), time.Now()).Scan(&orderID)
+	if err != nil {
+		t.Fatalf("creating order: %v", err)
+	}
+	return orderID
+}
@@ -0,0 +1,111 @@
+package payments
+
+import (
+	"backend/money"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+)
+
+// Statuses reported by payment providers
+const (
+	StatusPending    = "pending"
+	StatusAuthorized = "authorized"
+	StatusCaptured   = "captured"
+	StatusFailed     = "failed"
+	StatusRefunded   = "refunded"
+	StatusVoided     = "voided"
+
+	// StatusPartiallyRefunded is a refund of part of a captured payment
+	StatusPartiallyRefunded = "partially_refunded"
+)
+
+var (
+	// ErrUnknownProvider is returned for a payment method without a registered provider
+	ErrUnknownProvider = errors.New("unknown payment provider")
+
+	// ErrInvalidSignature is returned when a webhook signature does not match its payload
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+
+	// ErrWebhooksUnsupported is returned by providers that never send callbacks
+	ErrWebhooksUnsupported = errors.New("provider does not send webhooks")
+
+	// ErrWebhookSecretUnset is returned for callbacks while PAYMENT_WEBHOOK_SECRET is not set
+	ErrWebhookSecretUnset = errors.New("webhook secret is not configured")
+
+	// ErrAmountMismatch is returned for callbacks about another amount than the payment they settle
+	ErrAmountMismatch = errors.New("webhook amount does not match the payment")
+)
+
+// AuthorizeRequest describes the payment of an order
+type AuthorizeRequest struct {
+	OrderID  int64
+	Amount   money.Amount
+	Currency money.Currency
+	Token    string
+}
+
+// Result is a provider's answer to a payment operation
+type Result struct {
+	TransactionID string
+	Status        string
+	Message       string
+}
+
+// WebhookEvent is a verified status callback from a provider
+type WebhookEvent struct {
+	TransactionID string       `json:"transaction_id"`
+	Status        string       `json:"status"`
+	Amount        money.Amount `json:"amount"`
+}
+
+// PaymentProvider is implemented by every payment gateway. Declined payments
+// are reported through a failed Result, errors mean the provider could not be reached.
+type PaymentProvider interface {
+	Name() string
+	Authorize(req AuthorizeRequest) (*Result, error)
+	Capture(transactionID string, amount money.Amount) (*Result, error)
+	Refund(transactionID string, amount money.Amount) (*Result, error)
+	Void(transactionID string) (*Result, error)
+	VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error)
+}
+
+// providers holds the registered providers by name, which is also the payment method
+var providers = map[string]PaymentProvider{}
+
+func init() {
+	Register(CashOnDeliveryProvider{})
+}
+
+// Init registers the providers enabled in the environment. The mock gateway
+// approves payments without collecting money, so it is only offered with
+// PAYMENTS_MOCK=true.
+func Init() {
+	if os.Getenv("PAYMENTS_MOCK") == "true" {
+		Register(MockProvider{})
+		log.Println("The mock payment gateway is enabled, do not use it in production")
+	}
+}
+
+// Register makes a provider available as a payment method
+func Register(provider PaymentProvider) {
+	providers[provider.Name()] = provider
+}
+
+// Get returns the provider for a payment method
+func Get(name string) (PaymentProvider, error) {
+	provider, ok := providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+// newTransactionID returns a random transaction ID with the given prefix
+func newTransactionID(prefix string) string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return prefix + "_" + hex.EncodeToString(buf)
+}
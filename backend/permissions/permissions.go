@@ -0,0 +1,227 @@
+package permissions
+
+import (
+	"backend/database"
+	"backend/models"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// Permissions checked by the API
+const (
+	ProductsWrite   = "products:write"
+	InventoryWrite  = "inventory:write"
+	OrdersRead      = "orders:read"
+	OrdersUpdate    = "orders:update"
+	OrdersCancel    = "orders:cancel"
+	ReturnsManage   = "returns:manage"
+	ReturnsReceive  = "returns:receive"
+	PromotionsWrite = "promotions:write"
+	TaxWrite        = "tax:write"
+	ShippingWrite   = "shipping:write"
+	CurrenciesWrite = "currencies:write"
+	ReviewsModerate = "reviews:moderate"
+	UsersRead       = "users:read"
+	UsersManage     = "users:manage"
+	RolesManage     = "roles:manage"
+)
+
+// Built-in roles. Admin always holds every permission, new accounts are customers.
+const (
+	RoleAdmin    = "admin"
+	RoleCustomer = "customer"
+)
+
+// ErrNotFound is returned when a role does not exist
+var ErrNotFound = errors.New("role not found")
+
+// All lists every permission with what it allows
+var All = []models.Permission{
+	{Name: ProductsWrite, Description: "Create, change and delete products, categories, images and prices, and rebuild the search index"},
+	{Name: InventoryWrite, Description: "Change stock levels"},
+	{Name: OrdersRead, Description: "View every customer's orders, shipments and returns"},
+	{Name: OrdersUpdate, Description: "Change order status, ship and deliver orders"},
+	{Name: OrdersCancel, Description: "Cancel any order, also after it was shipped"},
+	{Name: ReturnsManage, Description: "List, approve and reject returns"},
+	{Name: ReturnsReceive, Description: "Receive returned goods, restock and refund them"},
+	{Name: PromotionsWrite, Description: "Manage promotions and coupons"},
+	{Name: TaxWrite, Description: "Manage tax rules"},
+	{Name: ShippingWrite, Description: "Manage shipping zones and methods"},
+	{Name: CurrenciesWrite, Description: "Set exchange rates"},
+	{Name: ReviewsModerate, Description: "Delete any review"},
+	{Name: UsersRead, Description: "Find accounts and view their orders and addresses"},
+	{Name: UsersManage, Description: "Assign roles, disable accounts and force password resets"},
+	{Name: RolesManage, Description: "Create, change and delete roles"},
+}
+
+// Valid reports whether a permission exists
+func Valid(name string) bool {
+	for _, permission := range All {
+		if permission.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Has reports whether a user's role grants any of the given permissions. It is
+// read from the database on every check, so role changes apply to tokens already issued.
+func Has(q database.Querier, userID int64, wanted ...string) (bool, error) {
+	if len(wanted) == 0 {
+		return false, nil
+	}
+
+	args := []interface{}{userID}
+	for _, permission := range wanted {
+		args = append(args, permission)
+	}
+
+	var count int
+	err := q.QueryRow(`
+		SELECT COUNT(*) FROM users u
+		JOIN roles r ON r.name = u.role
+		JOIN role_permissions rp ON rp.role_id = r.id
+		WHERE u.id = ? AND rp.permission IN (?`+strings.Repeat(", ?", len(wanted)-1)+`)`,
+		args...).Scan(&count)
+	return count > 0, err
+}
+
+// Of returns the permissions a user's role grants
+func Of(q database.Querier, userID int64) ([]string, error) {
+	rows, err := q.Query(`
+		SELECT rp.permission FROM users u
+		JOIN roles r ON r.name = u.role
+		JOIN role_permissions rp ON rp.role_id = r.id
+		WHERE u.id = ?
+		ORDER BY rp.permission`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanPermissions(rows)
+}
+
+// Missing returns the permissions that a user's own role does not grant,
+// so that nobody can hand out more rights than they hold
+func Missing(q database.Querier, userID int64, wanted []string) ([]string, error) {
+	held, err := Of(q, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []string{}
+	for _, permission := range wanted {
+		if !slices.Contains(held, permission) {
+			missing = append(missing, permission)
+		}
+	}
+	return missing, nil
+}
+
+// ListRoles returns every role with its permissions and number of users
+func ListRoles(q database.Querier) ([]models.Role, error) {
+	rows, err := q.Query(`
+		SELECT r.id, r.name, COALESCE(r.description, ''), r.created_at, r.updated_at, r.require_two_factor,
+			(SELECT COUNT(*) FROM users u WHERE u.role = r.name)
+		FROM roles r
+		ORDER BY r.id`)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := []models.Role{}
+	for rows.Next() {
+		var role models.Role
+		err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt, &role.UpdatedAt, &role.RequireTwoFactor, &role.UserCount)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range roles {
+		if roles[i].Permissions, err = RolePermissions(q, roles[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return roles, nil
+}
+
+// FindRole returns a role
+func FindRole(q database.Querier, roleID int64) (*models.Role, error) {
+	return findRole(q, "r.id = ?", roleID)
+}
+
+// FindRoleByName returns the role with a name
+func FindRoleByName(q database.Querier, name string) (*models.Role, error) {
+	return findRole(q, "r.name = ?", name)
+}
+
+// findRole returns the role matching a condition, with its permissions
+func findRole(q database.Querier, condition string, arg interface{}) (*models.Role, error) {
+	var role models.Role
+	err := q.QueryRow(`
+		SELECT r.id, r.name, COALESCE(r.description, ''), r.created_at, r.updated_at, r.require_two_factor,
+			(SELECT COUNT(*) FROM users u WHERE u.role = r.name)
+		FROM roles r
+		WHERE `+condition,
+		arg).Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt, &role.UpdatedAt, &role.RequireTwoFactor, &role.UserCount)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if role.Permissions, err = RolePermissions(q, role.ID); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// RolePermissions returns the permissions a role grants
+func RolePermissions(q database.Querier, roleID int64) ([]string, error) {
+	rows, err := q.Query("SELECT permission FROM role_permissions WHERE role_id = ? ORDER BY permission", roleID)
+	if err != nil {
+		return nil, err
+	}
+	return scanPermissions(rows)
+}
+
+// SetRolePermissions replaces the permissions a role grants
+func SetRolePermissions(q database.Querier, roleID int64, granted []string) error {
+	if _, err := q.Exec("DELETE FROM role_permissions WHERE role_id = ?", roleID); err != nil {
+		return err
+	}
+
+	// Duplicates are stored once
+	names := slices.Compact(slices.Sorted(slices.Values(granted)))
+	for _, permission := range names {
+		if _, err := q.Exec("INSERT INTO role_permissions (role_id, permission) VALUES (?, ?)", roleID, permission); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanPermissions reads a column of permission names
+func scanPermissions(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	granted := []string{}
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		granted = append(granted, permission)
+	}
+	return granted, rows.Err()
+}
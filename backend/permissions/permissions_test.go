@@ -0,0 +1,57 @@
+package permissions_test
+
+import (
+	"backend/database/dbtest"
+	"backend/dialect"
+	"backend/permissions"
+	"backend/repository"
+	"database/sql"
+	"slices"
+	"testing"
+)
+
+func TestHas(t *testing.T) {
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		users := repository.New(db).Users
+		userID := dbtest.CreateUser(t, db, permissions.RoleCustomer).ID
+
+		assertHas(t, db, userID, false, permissions.OrdersRead)
+
+		// The seeded roles grant their permissions
+		if err := users.SetRole(userID, "support_agent"); err != nil {
+			t.Fatalf("SetRole: %v", err)
+		}
+		assertHas(t, db, userID, true, permissions.OrdersRead)
+		assertHas(t, db, userID, false, permissions.OrdersUpdate)
+		assertHas(t, db, userID, true, permissions.OrdersUpdate, permissions.OrdersCancel)
+
+		// Changing a role applies straight away
+		role, err := permissions.FindRoleByName(db, "support_agent")
+		if err != nil {
+			t.Fatalf("FindRoleByName: %v", err)
+		}
+		granted := []string{permissions.OrdersUpdate, permissions.OrdersUpdate}
+		if err := permissions.SetRolePermissions(db, role.ID, granted); err != nil {
+			t.Fatalf("SetRolePermissions: %v", err)
+		}
+		assertHas(t, db, userID, false, permissions.OrdersRead)
+		assertHas(t, db, userID, true, permissions.OrdersUpdate)
+
+		held, err := permissions.Of(db, userID)
+		if err != nil || !slices.Equal(held, []string{permissions.OrdersUpdate}) {
+			t.Errorf("Of = %v, %v, want [%s]", held, err, permissions.OrdersUpdate)
+		}
+		missing, err := permissions.Missing(db, userID, []string{permissions.OrdersUpdate, permissions.RolesManage})
+		if err != nil || !slices.Equal(missing, []string{permissions.RolesManage}) {
+			t.Errorf("Missing = %v, %v, want [%s]", missing, err, permissions.RolesManage)
+		}
+	})
+}
+
+func assertHas(t *testing.T, db *sql.DB, userID int64, want bool, wanted ...string) {
+	t.Helper()
+
+	if allowed, err := permissions.Has(db, userID, wanted...); err != nil || allowed != want {
+		t.Errorf("Has(%v) = %v, %v, want %v", wanted, allowed, err, want)
+	}
+}
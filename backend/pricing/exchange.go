@@ -0,0 +1,129 @@
+package pricing
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/money"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrNoRate is returned for a currency without an exchange rate
+var ErrNoRate = errors.New("currency has no exchange rate")
+
+// Exchange converts store currency prices into the currency a customer shops in.
+// Rate is how much of the currency one unit of the store currency buys.
+type Exchange struct {
+	Currency money.Currency
+	Rate     float64
+}
+
+// Store returns the exchange of the store currency into itself
+func Store() *Exchange {
+	return &Exchange{Currency: money.StoreCurrency(), Rate: 1}
+}
+
+// For returns the exchange into a currency at its current rate
+func For(q database.Querier, currency money.Currency) (*Exchange, error) {
+	if currency == money.StoreCurrency() {
+		return Store(), nil
+	}
+
+	var rate float64
+	err := q.QueryRow("SELECT rate FROM exchange_rates WHERE currency = ?", currency).Scan(&rate)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoRate
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Exchange{Currency: currency, Rate: rate}, nil
+}
+
+// IsStore reports whether the exchange is into the store currency
+func (e *Exchange) IsStore() bool {
+	return e.Currency == money.StoreCurrency()
+}
+
+// Convert converts a store currency amount, such as a shipping rate or a coupon's fixed value
+func (e *Exchange) Convert(amount money.Amount) money.Amount {
+	if e.IsStore() {
+		return amount
+	}
+	return amount.Convert(e.Rate)
+}
+
+// Price returns a product's base price and unit price in the exchange currency.
+// A price set for the currency wins over converting the store price.
+func (e *Exchange) Price(basePrice money.Amount, override *money.Amount, discountPercentage float64) (money.Amount, money.Amount) {
+	if override != nil && !e.IsStore() {
+		basePrice = *override
+	} else {
+		basePrice = e.Convert(basePrice)
+	}
+	return basePrice, UnitPrice(basePrice, discountPercentage)
+}
+
+// OverrideSQL selects the price set in the exchange currency for products aliased p,
+// NULL when there is none. Currencies are validated codes, so they are safe to inline.
+func (e *Exchange) OverrideSQL() string {
+	if e.IsStore() {
+		return "NULL"
+	}
+	return "(SELECT pp.base_price FROM product_prices pp WHERE pp.product_id = p.id AND pp.currency = '" + string(e.Currency) + "')"
+}
+
+// UnitPriceSQL is Price as an SQL expression over products aliased p, for
+// filtering and sorting by price. It rounds exactly like Price.
+func (e *Exchange) UnitPriceSQL() string {
+	base := "p.base_price"
+	if !e.IsStore() {
+		// The rate to six decimals, as money.Amount.Convert takes it
+		base = fmt.Sprintf("COALESCE(%s, CAST(ROUND(p.base_price * %d / 1000000.0) AS INTEGER))",
+			e.OverrideSQL(), int64(math.Round(e.Rate*1e6)))
+	}
+	// NUMERIC so PostgreSQL rounds half away from zero, not to even
+	return "(" + base + " - CAST(ROUND(CAST(" + base + " * ROUND(p.discount_percentage * 100) AS NUMERIC) / 10000.0) AS INTEGER))"
+}
+
+// Rates returns every exchange rate ordered by currency
+func Rates(q database.Querier) ([]models.ExchangeRate, error) {
+	rows, err := q.Query("SELECT currency, rate, updated_at FROM exchange_rates ORDER BY currency")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []models.ExchangeRate{}
+	for rows.Next() {
+		var rate models.ExchangeRate
+		if err := rows.Scan(&rate.Currency, &rate.Rate, &rate.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// ProductPrices returns the prices set for a product in other currencies
+func ProductPrices(q database.Querier, productID int64) ([]models.ProductPrice, error) {
+	rows, err := q.Query(
+		"SELECT currency, base_price, updated_at FROM product_prices WHERE product_id = ? ORDER BY currency",
+		productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := []models.ProductPrice{}
+	for rows.Next() {
+		var price models.ProductPrice
+		if err := rows.Scan(&price.Currency, &price.BasePrice, &price.UpdatedAt); err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+	return prices, rows.Err()
+}
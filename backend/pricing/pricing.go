@@ -0,0 +1,10 @@
+package pricing
+
+import "backend/money"
+
+// UnitPrice is the price of one unit of a product: its base price less its
+// percentage discount, rounded to the minor unit. Every price shown or charged
+// for a product goes through here, see Exchange.UnitPriceSQL for its SQL twin.
+func UnitPrice(basePrice money.Amount, discountPercentage float64) money.Amount {
+	return basePrice - basePrice.Percent(discountPercentage)
+}
@@ -0,0 +1,92 @@
+package pricing_test
+
+import (
+	"backend/database/dbtest"
+	"backend/dialect"
+	"backend/money"
+	"backend/pricing"
+	"database/sql"
+	"testing"
+)
+
+func TestUnitPrice(t *testing.T) {
+	tests := []struct {
+		base     money.Amount
+		discount float64
+		want     money.Amount
+	}{
+		{2000, 0, 2000},
+		{2000, 10, 1800},
+		{1999, 12.5, 1749}, // 249.875 off rounds to 250
+		{15, 50, 7},        // 7.5 off rounds to 8
+		{999, 33.33, 666},
+		{2000, 100, 0},
+	}
+	for _, test := range tests {
+		if got := pricing.UnitPrice(test.base, test.discount); got != test.want {
+			t.Errorf("UnitPrice(%d, %v) = %d, want %d", test.base, test.discount, got, test.want)
+		}
+	}
+}
+
+func TestUnitPriceSQL(t *testing.T) {
+	prices := []struct {
+		base     money.Amount
+		discount float64
+		override *money.Amount
+	}{
+		{2000, 0, nil},
+		{1999, 12.5, nil},
+		{15, 50, nil},
+		{999, 33.33, nil},
+		{1, 50, nil},
+		{12345, 99.99, nil},
+		{4999, 15, amount(4500)},
+		{2001, 0, amount(1850)},
+	}
+	exchanges := []*pricing.Exchange{
+		pricing.Store(),
+		{Currency: "EUR", Rate: 0.923456},
+		{Currency: "JPY", Rate: 149.3456789},
+	}
+
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		ids := make([]int64, len(prices))
+		for i, price := range prices {
+			err := db.QueryRow(
+				"INSERT INTO products (name, description, base_price, discount_percentage, featured) VALUES (?, ?, ?, ?, ?) RETURNING id",
+				"Tee", "A plain tee", price.base, price.discount, false).Scan(&ids[i])
+			if err == nil && price.override != nil {
+				_, err = db.Exec("INSERT INTO product_prices (product_id, currency, base_price) VALUES (?, ?, ?)",
+					ids[i], "EUR", *price.override)
+			}
+			if err != nil {
+				t.Fatalf("creating product: %v", err)
+			}
+		}
+
+		for _, exchange := range exchanges {
+			for i, price := range prices {
+				override := price.override
+				if exchange.Currency != "EUR" {
+					override = nil
+				}
+				_, want := exchange.Price(price.base, override, price.discount)
+
+				var got money.Amount
+				err := db.QueryRow("SELECT "+exchange.UnitPriceSQL()+" FROM products p WHERE p.id = ?", ids[i]).Scan(&got)
+				if err != nil {
+					t.Fatalf("selecting the unit price in %s: %v", exchange.Currency, err)
+				}
+				if got != want {
+					t.Errorf("UnitPriceSQL in %s of %d less %v%% = %d, Price = %d",
+						exchange.Currency, price.base, price.discount, got, want)
+				}
+			}
+		}
+	})
+}
+
+func amount(a money.Amount) *money.Amount {
+	return &a
+}
@@ -0,0 +1,412 @@
+package promotions
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/money"
+	"backend/pricing"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Promotion rule types
+const (
+	TypePercentage   = "percentage"
+	TypeFixed        = "fixed"
+	TypeFreeShipping = "free_shipping"
+	TypeBuyXGetY     = "buy_x_get_y"
+)
+
+// ErrNotFound is returned for an unknown or inactive coupon code
+var ErrNotFound = errors.New("coupon not found")
+
+// RuleError explains why a coupon does not apply, ready to be shown to customers
+type RuleError string
+
+func (e RuleError) Error() string {
+	return string(e)
+}
+
+// Line is a cart line as seen by the promotion rules
+type Line struct {
+	CartItemID int64
+	ProductID  int64
+	ColorID    int64
+	SizeID     int64
+	CategoryID int64
+	Quantity   int
+	UnitPrice  money.Amount
+}
+
+// Discount is the outcome of applying a promotion to a cart. LineAmounts
+// splits Amount over the cart lines, in the order they were evaluated.
+type Discount struct {
+	Promotion    *models.Promotion
+	Amount       money.Amount
+	LineAmounts  []money.Amount
+	FreeShipping bool
+}
+
+// LineAmount returns the share of the discount on the i-th line
+func (d *Discount) LineAmount(i int) money.Amount {
+	if i < len(d.LineAmounts) {
+		return d.LineAmounts[i]
+	}
+	return 0
+}
+
+// selectPromotion selects every promotion column, usage counts only orders that were not cancelled
+const selectPromotion = `
+	SELECT p.id, p.code, COALESCE(p.description, ''), p.type, p.value, p.buy_quantity, p.get_quantity,
+		p.category_id, p.product_id, p.min_subtotal, p.starts_at, p.ends_at, p.usage_limit, p.per_user_limit,
+		p.is_active, p.created_at, p.updated_at, p.times_used
+	FROM promotions p`
+
+// NormalizeCode returns the canonical form of a coupon code
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Get returns a promotion by ID
+func Get(q database.Querier, id int64) (*models.Promotion, error) {
+	return scan(q.QueryRow(selectPromotion+" WHERE p.id = ?", id))
+}
+
+// FindByCode returns the active promotion with the given coupon code
+func FindByCode(q database.Querier, code string) (*models.Promotion, error) {
+	promotion, err := scan(q.QueryRow(selectPromotion+" WHERE p.code = ? AND p.is_active = TRUE", NormalizeCode(code)))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return promotion, err
+}
+
+// List returns every promotion, newest first
+func List(q database.Querier) ([]models.Promotion, error) {
+	rows, err := q.Query(selectPromotion + " ORDER BY p.id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promotions := []models.Promotion{}
+	for rows.Next() {
+		promotion, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, *promotion)
+	}
+	return promotions, rows.Err()
+}
+
+// CartLines returns the lines of a user's cart at their current prices in the exchange currency
+func CartLines(q database.Querier, userID int64, exchange *pricing.Exchange) ([]Line, error) {
+	rows, err := q.Query(`
+		SELECT c.id, c.product_id, c.color_id, c.size_id, COALESCE(p.category_id, 0), c.quantity,
+			p.base_price, `+exchange.OverrideSQL()+`, p.discount_percentage
+		FROM cart c
+		JOIN products p ON c.product_id = p.id
+		WHERE c.user_id = ?
+		ORDER BY c.id`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []Line
+	for rows.Next() {
+		var line Line
+		var basePrice money.Amount
+		var override *money.Amount
+		var discountPercentage float64
+		err := rows.Scan(&line.CartItemID, &line.ProductID, &line.ColorID, &line.SizeID, &line.CategoryID, &line.Quantity,
+			&basePrice, &override, &discountPercentage)
+		if err != nil {
+			return nil, err
+		}
+		_, line.UnitPrice = exchange.Price(basePrice, override, discountPercentage)
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// Subtotal returns the value of the given lines before any discount
+func Subtotal(lines []Line) money.Amount {
+	var subtotal money.Amount
+	for _, line := range lines {
+		subtotal += line.UnitPrice.Mul(line.Quantity)
+	}
+	return subtotal
+}
+
+// AppliedPromotion returns the promotion applied to a user's cart, or nil
+func AppliedPromotion(q database.Querier, userID int64) (*models.Promotion, error) {
+	var promotionID int64
+	err := q.QueryRow("SELECT promotion_id FROM cart_coupons WHERE user_id = ?", userID).Scan(&promotionID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	promotion, err := Get(q, promotionID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return promotion, err
+}
+
+// Apply attaches a promotion to a user's cart, replacing any previous coupon
+func Apply(q database.Querier, userID, promotionID int64) error {
+	if err := Remove(q, userID); err != nil {
+		return err
+	}
+	_, err := q.Exec(
+		"INSERT INTO cart_coupons (user_id, promotion_id, applied_at) VALUES (?, ?, ?)",
+		userID, promotionID, time.Now())
+	return err
+}
+
+// Remove detaches the coupon from a user's cart
+func Remove(q database.Querier, userID int64) error {
+	_, err := q.Exec("DELETE FROM cart_coupons WHERE user_id = ?", userID)
+	return err
+}
+
+// Redeem records the use of a promotion by an order, counting towards its usage
+// caps. It returns a RuleError when the order would exceed a cap, which orders
+// placed since the promotion was evaluated may have reached.
+func Redeem(q database.Querier, discount *Discount, userID, orderID int64) error {
+	promotion := discount.Promotion
+
+	// Counting the use locks the promotion, so that the orders redeeming it
+	// check its caps one at a time
+	result, err := q.Exec(
+		"UPDATE promotions SET times_used = times_used + 1 WHERE id = ? AND (usage_limit IS NULL OR times_used < usage_limit)",
+		promotion.ID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		if err != nil {
+			return err
+		}
+		return RuleError("Coupon usage limit has been reached")
+	}
+	if promotion.PerUserLimit != nil {
+		used, err := timesUsedBy(q, promotion.ID, userID)
+		if err != nil {
+			return err
+		}
+		if used >= *promotion.PerUserLimit {
+			return RuleError("You have already used this coupon the maximum number of times")
+		}
+	}
+
+	_, err = q.Exec(
+		"INSERT INTO promotion_redemptions (promotion_id, user_id, order_id, discount_amount, created_at) VALUES (?, ?, ?, ?, ?)",
+		discount.Promotion.ID, userID, orderID, discount.Amount, time.Now())
+	return err
+}
+
+// Release stops the promotion redeemed by an order counting towards its usage
+// limit, once the order is cancelled
+func Release(q database.Querier, orderID int64) error {
+	_, err := q.Exec(`
+		UPDATE promotions SET times_used = times_used - 1
+		WHERE times_used > 0 AND id IN (SELECT promotion_id FROM promotion_redemptions WHERE order_id = ?)`,
+		orderID)
+	return err
+}
+
+// timesUsedBy returns how many orders of a user that were not cancelled used a promotion
+func timesUsedBy(q database.Querier, promotionID, userID int64) (int, error) {
+	var used int
+	err := q.QueryRow(`
+		SELECT COUNT(*) FROM promotion_redemptions r JOIN orders o ON o.id = r.order_id
+		WHERE r.promotion_id = ? AND r.user_id = ? AND o.order_status != 'cancelled'`,
+		promotionID, userID).Scan(&used)
+	return used, err
+}
+
+// Evaluate checks that a promotion applies to a user's cart and computes its discount
+// in the currency of the lines. A RuleError is returned when the cart does not qualify.
+func Evaluate(q database.Querier, promotion *models.Promotion, userID int64, lines []Line, exchange *pricing.Exchange, now time.Time) (*Discount, error) {
+	if !promotion.IsActive {
+		return nil, ErrNotFound
+	}
+	if promotion.StartsAt != nil && now.Before(*promotion.StartsAt) {
+		return nil, RuleError("Coupon is not valid yet")
+	}
+	if promotion.EndsAt != nil && !now.Before(*promotion.EndsAt) {
+		return nil, RuleError("Coupon has expired")
+	}
+
+	// Minimum subtotals and fixed discounts are set in the store currency
+	minSubtotal := exchange.Convert(promotion.MinSubtotal)
+	if Subtotal(lines) < minSubtotal {
+		return nil, RuleError(fmt.Sprintf("Coupon requires a subtotal of at least %s", exchange.Currency.Format(minSubtotal)))
+	}
+
+	// Usage caps
+	if promotion.UsageLimit != nil && promotion.TimesUsed >= *promotion.UsageLimit {
+		return nil, RuleError("Coupon usage limit has been reached")
+	}
+	if promotion.PerUserLimit != nil {
+		used, err := timesUsedBy(q, promotion.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if used >= *promotion.PerUserLimit {
+			return nil, RuleError("You have already used this coupon the maximum number of times")
+		}
+	}
+
+	// Only lines within the promotion's scope are discounted
+	var eligible []Line
+	var eligibleIndexes []int
+	for i, line := range lines {
+		if promotion.CategoryID != nil && line.CategoryID != *promotion.CategoryID {
+			continue
+		}
+		if promotion.ProductID != nil && line.ProductID != *promotion.ProductID {
+			continue
+		}
+		eligible = append(eligible, line)
+		eligibleIndexes = append(eligibleIndexes, i)
+	}
+	if len(eligible) == 0 {
+		return nil, RuleError("Coupon does not apply to any item in your cart")
+	}
+
+	discount := &Discount{Promotion: promotion}
+	eligibleSubtotal := Subtotal(eligible)
+
+	switch promotion.Type {
+	case TypePercentage:
+		discount.Amount = eligibleSubtotal.Percent(promotion.Value)
+	case TypeFixed:
+		discount.Amount = money.Min(exchange.Convert(money.FromFloat(promotion.Value)), eligibleSubtotal)
+	case TypeFreeShipping:
+		discount.FreeShipping = true
+	case TypeBuyXGetY:
+		discount.Amount = buyXGetY(eligible, promotion.BuyQuantity, promotion.GetQuantity)
+		if discount.Amount == 0 {
+			return nil, RuleError(fmt.Sprintf("Add at least %d eligible items to use this coupon", promotion.BuyQuantity+promotion.GetQuantity))
+		}
+	}
+
+	// Spread the discount over the eligible lines by value, the last line takes the rounding remainder
+	values := make([]money.Amount, len(eligible))
+	for n, line := range eligible {
+		values[n] = line.UnitPrice.Mul(line.Quantity)
+	}
+	discount.LineAmounts = make([]money.Amount, len(lines))
+	for n, share := range discount.Amount.Allocate(values) {
+		discount.LineAmounts[eligibleIndexes[n]] = share
+	}
+
+	return discount, nil
+}
+
+// buyXGetY makes the cheapest get units free in every group of buy+get eligible units
+func buyXGetY(lines []Line, buy, get int) money.Amount {
+	if buy <= 0 || get <= 0 {
+		return 0
+	}
+
+	var prices []money.Amount
+	for _, line := range lines {
+		for i := 0; i < line.Quantity; i++ {
+			prices = append(prices, line.UnitPrice)
+		}
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i] < prices[j]
+	})
+
+	free := len(prices) / (buy + get) * get
+	return money.Sum(prices[:free]...)
+}
+
+// ValidateRule checks that a promotion request describes a usable rule
+func ValidateRule(req *models.PromotionRequest) error {
+	switch req.Type {
+	case TypePercentage:
+		if req.Value <= 0 || req.Value > 100 {
+			return RuleError("Percentage must be between 0 and 100")
+		}
+	case TypeFixed:
+		if req.Value <= 0 {
+			return RuleError("Fixed discount must be positive")
+		}
+	case TypeFreeShipping:
+	case TypeBuyXGetY:
+		if req.BuyQuantity <= 0 || req.GetQuantity <= 0 {
+			return RuleError("Buy and get quantities must be positive")
+		}
+	default:
+		return RuleError("Type must be percentage, fixed, free_shipping or buy_x_get_y")
+	}
+
+	if req.MinSubtotal < 0 {
+		return RuleError("Minimum subtotal cannot be negative")
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return RuleError("End date must be after the start date")
+	}
+	if (req.UsageLimit != nil && *req.UsageLimit <= 0) || (req.PerUserLimit != nil && *req.PerUserLimit <= 0) {
+		return RuleError("Usage limits must be positive")
+	}
+	return nil
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scan reads a row selected with selectPromotion
+func scan(row scanner) (*models.Promotion, error) {
+	var promotion models.Promotion
+	var categoryID, productID, usageLimit, perUserLimit sql.NullInt64
+	var startsAt, endsAt sql.NullTime
+
+	err := row.Scan(
+		&promotion.ID, &promotion.Code, &promotion.Description, &promotion.Type, &promotion.Value,
+		&promotion.BuyQuantity, &promotion.GetQuantity, &categoryID, &productID, &promotion.MinSubtotal,
+		&startsAt, &endsAt, &usageLimit, &perUserLimit, &promotion.IsActive,
+		&promotion.CreatedAt, &promotion.UpdatedAt, &promotion.TimesUsed)
+	if err != nil {
+		return nil, err
+	}
+
+	if categoryID.Valid {
+		promotion.CategoryID = &categoryID.Int64
+	}
+	if productID.Valid {
+		promotion.ProductID = &productID.Int64
+	}
+	if startsAt.Valid {
+		promotion.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		promotion.EndsAt = &endsAt.Time
+	}
+	if usageLimit.Valid {
+		limit := int(usageLimit.Int64)
+		promotion.UsageLimit = &limit
+	}
+	if perUserLimit.Valid {
+		limit := int(perUserLimit.Int64)
+		promotion.PerUserLimit = &limit
+	}
+	return &promotion, nil
+}
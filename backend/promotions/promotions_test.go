@@ -0,0 +1,131 @@
+package promotions_test
+
+import (
+	"backend/database/dbtest"
+	"backend/dialect"
+	"backend/models"
+	"backend/promotions"
+	"backend/repository"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestRedeemEnforcesUsageLimit(t *testing.T) {
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		promotion := createPromotion(t, db, "ONCE", "usage_limit")
+		first, second := createOrder(t, db), createOrder(t, db)
+
+		if err := redeem(db, promotion, first); err != nil {
+			t.Fatalf("Redeem: %v", err)
+		}
+		if _, ok := redeem(db, promotion, second).(promotions.RuleError); !ok {
+			t.Errorf("Redeem over the usage limit did not return a RuleError")
+		}
+		assertTimesUsed(t, db, promotion.ID, 1)
+
+		// Cancelling an order frees its use of the coupon
+		if _, err := db.Exec("UPDATE orders SET order_status = 'cancelled' WHERE id = ?", first.ID); err != nil {
+			t.Fatalf("cancelling order: %v", err)
+		}
+		if err := promotions.Release(db, first.ID); err != nil {
+			t.Fatalf("Release: %v", err)
+		}
+		assertTimesUsed(t, db, promotion.ID, 0)
+		if err := redeem(db, promotion, second); err != nil {
+			t.Errorf("Redeem after the cancellation: %v", err)
+		}
+	})
+}
+
+func TestRedeemEnforcesPerUserLimit(t *testing.T) {
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		promotion := createPromotion(t, db, "WELCOME", "per_user_limit")
+		first := createOrder(t, db)
+		again := createOrderFor(t, db, first.UserID)
+
+		if err := redeem(db, promotion, first); err != nil {
+			t.Fatalf("Redeem: %v", err)
+		}
+		if _, ok := redeem(db, promotion, again).(promotions.RuleError); !ok {
+			t.Errorf("Redeem over the per user limit did not return a RuleError")
+		}
+		assertTimesUsed(t, db, promotion.ID, 1)
+
+		// Other customers can still use the coupon
+		if err := redeem(db, promotion, createOrder(t, db)); err != nil {
+			t.Errorf("Redeem by another customer: %v", err)
+		}
+	})
+}
+
+// redeem redeems a promotion for an order in a transaction, as placing an
+// order does, rolling it back when redeeming fails
+func redeem(db *sql.DB, promotion *models.Promotion, order *models.Order) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	discount := &promotions.Discount{Promotion: promotion, Amount: 500}
+	if err := promotions.Redeem(tx, discount, order.UserID, order.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// createPromotion creates a percentage coupon with one of its limits set to 1
+func createPromotion(t *testing.T, db *sql.DB, code, limit string) *models.Promotion {
+	t.Helper()
+
+	_, err := db.Exec("INSERT INTO promotions (code, type, value, "+limit+", is_active) VALUES (?, ?, ?, ?, ?)",
+		code, "percentage", 10, 1, true)
+	if err != nil {
+		t.Fatalf("creating promotion: %v", err)
+	}
+	promotion, err := promotions.FindByCode(db, code)
+	if err != nil {
+		t.Fatalf("FindByCode: %v", err)
+	}
+	return promotion
+}
+
+// createOrder creates an order of a new customer
+func createOrder(t *testing.T, db *sql.DB) *models.Order {
+	t.Helper()
+	return createOrderFor(t, db, dbtest.CreateUser(t, db, "customer").ID)
+}
+
+// createOrderFor creates an order of a customer
+func createOrderFor(t *testing.T, db *sql.DB, userID int64) *models.Order {
+	t.Helper()
+
+	addressID, err := repository.New(db).Addresses.Create(&models.Address{UserID: userID, Name: "Home",
+		Street: "1 Main St", City: "Austin", State: "TX", PostalCode: "73301", Country: "US", Phone: "555"})
+	if err != nil {
+		t.Fatalf("creating address: %v", err)
+	}
+
+	order := &models.Order{UserID: userID}
+	err = db.QueryRow(
+		`INSERT INTO orders (user_id, address_id, total_amount, currency, payment_method, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		userID, addressID, 5000, "USD", "cod", time.Now(), time.Now()).Scan(&order.ID)
+	if err != nil {
+		t.Fatalf("creating order: %v", err)
+	}
+	return order
+}
+
+func assertTimesUsed(t *testing.T, db *sql.DB, promotionID int64, want int) {
+	t.Helper()
+
+	promotion, err := promotions.Get(db, promotionID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if promotion.TimesUsed != want {
+		t.Errorf("TimesUsed = %d, want %d", promotion.TimesUsed, want)
+	}
+}
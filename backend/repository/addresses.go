@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"backend/models"
+	"database/sql"
+	"time"
+)
+
+// sqlAddresses is the SQL AddressRepository
+type sqlAddresses struct {
+	db *sql.DB
+}
+
+const addressColumns = "id, user_id, name, street, city, state, postal_code, country, phone, is_default, created_at, updated_at"
+
+func (r *sqlAddresses) List(userID int64) ([]models.Address, error) {
+	rows, err := r.db.Query(
+		"SELECT "+addressColumns+" FROM addresses WHERE user_id = ? ORDER BY is_default DESC, id DESC",
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	addresses := []models.Address{}
+	for rows.Next() {
+		address, err := scanAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, *address)
+	}
+	return addresses, rows.Err()
+}
+
+func (r *sqlAddresses) Find(userID, addressID int64) (*models.Address, error) {
+	address, err := scanAddress(r.db.QueryRow(
+		"SELECT "+addressColumns+" FROM addresses WHERE id = ? AND user_id = ?",
+		addressID, userID))
+	return address, notFound(err)
+}
+
+func (r *sqlAddresses) FindDefault(userID int64) (*models.Address, error) {
+	address, err := scanAddress(r.db.QueryRow(
+		"SELECT "+addressColumns+" FROM addresses WHERE user_id = ? AND is_default = TRUE",
+		userID))
+	return address, notFound(err)
+}
+
+func (r *sqlAddresses) Create(address *models.Address) (int64, error) {
+	var addressID int64
+	err := inTransaction(r.db, func(tx *sql.Tx) error {
+		// The first address is the default whatever was asked
+		var count int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM addresses WHERE user_id = ?", address.UserID).Scan(&count); err != nil {
+			return err
+		}
+		if count == 0 {
+			address.IsDefault = true
+		}
+
+		// A new default replaces the old one
+		if address.IsDefault {
+			if _, err := tx.Exec("UPDATE addresses SET is_default = FALSE WHERE user_id = ?", address.UserID); err != nil {
+				return err
+			}
+		}
+
+		return tx.QueryRow(
+			`INSERT INTO addresses (user_id, name, street, city, state, postal_code, country, phone, is_default, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+			address.UserID, address.Name, address.Street, address.City, address.State, address.PostalCode,
+			address.Country, address.Phone, address.IsDefault, time.Now(), time.Now()).Scan(&addressID)
+	})
+	return addressID, err
+}
+
+func (r *sqlAddresses) Update(address *models.Address) error {
+	return inTransaction(r.db, func(tx *sql.Tx) error {
+		var currentIsDefault bool
+		err := tx.QueryRow("SELECT is_default FROM addresses WHERE id = ? AND user_id = ?", address.ID, address.UserID).Scan(&currentIsDefault)
+		if err != nil {
+			return notFound(err)
+		}
+
+		if address.IsDefault && !currentIsDefault {
+			// A new default replaces the old one
+			if _, err := tx.Exec("UPDATE addresses SET is_default = FALSE WHERE user_id = ?", address.UserID); err != nil {
+				return err
+			}
+		} else if !address.IsDefault && currentIsDefault {
+			// The default moves to the newest other address, the only address stays the default
+			result, err := tx.Exec(
+				"UPDATE addresses SET is_default = TRUE WHERE id = (SELECT id FROM addresses WHERE user_id = ? AND id != ? ORDER BY id DESC LIMIT 1)",
+				address.UserID, address.ID)
+			if err != nil {
+				return err
+			}
+			if moved, err := result.RowsAffected(); err != nil {
+				return err
+			} else if moved == 0 {
+				address.IsDefault = true
+			}
+		}
+
+		_, err = tx.Exec(
+			`UPDATE addresses SET
+				name = ?,
+				street = ?,
+				city = ?,
+				state = ?,
+				postal_code = ?,
+				country = ?,
+				phone = ?,
+				is_default = ?,
+				updated_at = ?
+			WHERE id = ? AND user_id = ?`,
+			address.Name, address.Street, address.City, address.State, address.PostalCode, address.Country,
+			address.Phone, address.IsDefault, time.Now(),
+			address.ID, address.UserID)
+		return err
+	})
+}
+
+func (r *sqlAddresses) Delete(userID, addressID int64) error {
+	return inTransaction(r.db, func(tx *sql.Tx) error {
+		var isDefault bool
+		err := tx.QueryRow("SELECT is_default FROM addresses WHERE id = ? AND user_id = ?", addressID, userID).Scan(&isDefault)
+		if err != nil {
+			return notFound(err)
+		}
+
+		if _, err := tx.Exec("DELETE FROM addresses WHERE id = ? AND user_id = ?", addressID, userID); err != nil {
+			return err
+		}
+
+		// The default moves to the newest remaining address, if any
+		if isDefault {
+			_, err = tx.Exec(
+				"UPDATE addresses SET is_default = TRUE WHERE id = (SELECT id FROM addresses WHERE user_id = ? ORDER BY id DESC LIMIT 1)",
+				userID)
+		}
+		return err
+	})
+}
+
+// rowScanner is a *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAddress reads an address selected with addressColumns
+func scanAddress(row rowScanner) (*models.Address, error) {
+	var address models.Address
+	err := row.Scan(
+		&address.ID, &address.UserID, &address.Name, &address.Street, &address.City,
+		&address.State, &address.PostalCode, &address.Country, &address.Phone,
+		&address.IsDefault, &address.CreatedAt, &address.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"backend/inventory"
+	"backend/models"
+	"backend/money"
+	"backend/pricing"
+	"database/sql"
+	"time"
+)
+
+// sqlCarts is the SQL CartRepository
+type sqlCarts struct {
+	db *sql.DB
+}
+
+func (r *sqlCarts) Count(userID int64) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM cart WHERE user_id = ?", userID).Scan(&count)
+	return count, err
+}
+
+func (r *sqlCarts) Items(userID int64, exchange *pricing.Exchange) ([]models.CartItemResponse, error) {
+	rows, err := r.db.Query(`
+		SELECT
+			c.id, c.product_id, c.color_id, c.size_id, c.quantity,
+			p.name, p.description, p.base_price, `+exchange.OverrideSQL()+`, p.discount_percentage,
+			pc.color_name, pc.color_hex,
+			ps.size_name,
+			COALESCE(`+inventory.AvailableToUser+`, 0) as in_stock,
+			COALESCE(`+PrimaryImageSQL+`, '') as image_url
+		FROM cart c
+		JOIN products p ON c.product_id = p.id
+		JOIN product_colors pc ON c.color_id = pc.id
+		JOIN product_sizes ps ON c.size_id = ps.id
+		LEFT JOIN product_inventory pi ON c.product_id = pi.product_id AND c.color_id = pi.color_id AND c.size_id = pi.size_id
+		WHERE c.user_id = ?
+		ORDER BY c.id DESC`,
+		userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.CartItemResponse
+	for rows.Next() {
+		var item models.CartItemResponse
+		var basePrice money.Amount
+		var override *money.Amount
+		var inStock int
+
+		err := rows.Scan(
+			&item.ID, &item.ProductID, &item.ColorID, &item.SizeID, &item.Quantity,
+			&item.ProductName, &item.ProductDescription, &basePrice, &override, &item.DiscountPercentage,
+			&item.ColorName, &item.ColorHex,
+			&item.SizeName,
+			&inStock,
+			&item.ImageURL)
+		if err != nil {
+			return nil, err
+		}
+
+		// Price the line in the exchange currency
+		item.BasePrice, item.FinalPrice = exchange.Price(basePrice, override, item.DiscountPercentage)
+		item.SubTotal = item.FinalPrice.Mul(item.Quantity)
+		item.InStock = max(inStock, 0)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *sqlCarts) Find(userID, itemID int64) (*models.CartItem, error) {
+	return r.scanItem(r.db.QueryRow(
+		"SELECT id, user_id, product_id, color_id, size_id, quantity, created_at, updated_at FROM cart WHERE id = ? AND user_id = ?",
+		itemID, userID))
+}
+
+func (r *sqlCarts) FindVariant(userID, productID, colorID, sizeID int64) (*models.CartItem, error) {
+	return r.scanItem(r.db.QueryRow(`
+		SELECT id, user_id, product_id, color_id, size_id, quantity, created_at, updated_at
+		FROM cart
+		WHERE user_id = ? AND product_id = ? AND color_id = ? AND size_id = ?`,
+		userID, productID, colorID, sizeID))
+}
+
+func (r *sqlCarts) Add(item *models.CartItem) (int64, error) {
+	var itemID int64
+	err := r.db.QueryRow(
+		"INSERT INTO cart (user_id, product_id, color_id, size_id, quantity, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id",
+		item.UserID, item.ProductID, item.ColorID, item.SizeID, item.Quantity, time.Now(), time.Now()).Scan(&itemID)
+	return itemID, err
+}
+
+func (r *sqlCarts) SetQuantity(userID, itemID int64, quantity int) error {
+	result, err := r.db.Exec(
+		"UPDATE cart SET quantity = ?, updated_at = ? WHERE id = ? AND user_id = ?",
+		quantity, time.Now(), itemID, userID)
+	return affectedOne(result, err)
+}
+
+func (r *sqlCarts) Remove(userID, itemID int64) error {
+	result, err := r.db.Exec("DELETE FROM cart WHERE id = ? AND user_id = ?", itemID, userID)
+	return affectedOne(result, err)
+}
+
+func (r *sqlCarts) Clear(userID int64) error {
+	_, err := r.db.Exec("DELETE FROM cart WHERE user_id = ?", userID)
+	return err
+}
+
+func (r *sqlCarts) ReleaseHolds(userID int64) error {
+	return inventory.Release(r.db, userID)
+}
+
+func (r *sqlCarts) HoldsExpireAt(userID int64) (*time.Time, error) {
+	var expiresAt sql.NullTime
+	err := r.db.QueryRow(
+		"SELECT expires_at FROM inventory_reservations WHERE user_id = ? AND expires_at > CURRENT_TIMESTAMP ORDER BY expires_at LIMIT 1",
+		userID).Scan(&expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil || !expiresAt.Valid {
+		return nil, err
+	}
+	return &expiresAt.Time, nil
+}
+
+// scanItem reads a cart line selected by Find or FindVariant
+func (r *sqlCarts) scanItem(row *sql.Row) (*models.CartItem, error) {
+	var item models.CartItem
+	err := row.Scan(&item.ID, &item.UserID, &item.ProductID, &item.ColorID, &item.SizeID,
+		&item.Quantity, &item.CreatedAt, &item.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &item, nil
+}
+
+// affectedOne returns ErrNotFound when a statement changed no row
+func affectedOne(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		if err != nil {
+			return err
+		}
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"backend/models"
+	"database/sql"
+)
+
+// sqlOrders is the SQL OrderRepository
+type sqlOrders struct {
+	db *sql.DB
+}
+
+func (r *sqlOrders) List(userID int64, limit, offset int) ([]models.OrderWithUser, int, error) {
+	where := ""
+	var args []interface{}
+	if userID != 0 {
+		where = " WHERE o.user_id = ?"
+		args = append(args, userID)
+	}
+
+	// Count every order for pagination
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM orders o"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT o.id, o.user_id, o.address_id, o.total_amount, o.currency, o.exchange_rate, o.payment_method,
+			o.payment_status, o.order_status, o.created_at, o.updated_at,
+			u.name as user_name, u.email as user_email
+		FROM orders o
+		JOIN users u ON o.user_id = u.id`+where+`
+		ORDER BY o.id DESC LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var orders []models.OrderWithUser
+	for rows.Next() {
+		var order models.OrderWithUser
+		err := rows.Scan(
+			&order.ID, &order.UserID, &order.AddressID, &order.TotalAmount, &order.Currency, &order.ExchangeRate,
+			&order.PaymentMethod, &order.PaymentStatus, &order.OrderStatus,
+			&order.CreatedAt, &order.UpdatedAt, &order.UserName, &order.UserEmail)
+		if err != nil {
+			return nil, 0, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, total, rows.Err()
+}
+
+func (r *sqlOrders) Find(orderID int64) (*models.Order, error) {
+	var order models.Order
+	err := r.db.QueryRow(`
+		SELECT id, user_id, address_id, COALESCE(subtotal, total_amount), discount_amount, COALESCE(coupon_code, ''),
+			tax_amount, tax_included, COALESCE(shipping_method, ''), shipping_cost, total_amount, currency, exchange_rate,
+			payment_method, payment_status, order_status,
+			COALESCE(cancel_reason, ''), cancelled_at, created_at, updated_at
+		FROM orders WHERE id = ?`,
+		orderID).Scan(
+		&order.ID, &order.UserID, &order.AddressID,
+		&order.Subtotal, &order.DiscountAmount, &order.CouponCode,
+		&order.TaxAmount, &order.TaxIncluded, &order.ShippingMethod, &order.ShippingCost, &order.TotalAmount,
+		&order.Currency, &order.ExchangeRate, &order.PaymentMethod, &order.PaymentStatus, &order.OrderStatus,
+		&order.CancelReason, &order.CancelledAt, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &order, nil
+}
+
+func (r *sqlOrders) Items(orderID int64) ([]models.OrderItemResponse, error) {
+	rows, err := r.db.Query(`
+		SELECT oi.id, oi.product_id, oi.color_id, oi.size_id, oi.quantity, oi.price_per_unit,
+			oi.discount_amount, oi.tax_rate, oi.tax_amount, oi.tax_included,
+			COALESCE((SELECT SUM(si.quantity) FROM shipment_items si WHERE si.order_item_id = oi.id), 0),
+			p.name, p.description,
+			pc.color_name, pc.color_hex,
+			ps.size_name,
+			COALESCE(`+PrimaryImageSQL+`, '') as image_url
+		FROM order_items oi
+		JOIN products p ON oi.product_id = p.id
+		JOIN product_colors pc ON oi.color_id = pc.id
+		JOIN product_sizes ps ON oi.size_id = ps.id
+		WHERE oi.order_id = ?`,
+		orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.OrderItemResponse
+	for rows.Next() {
+		var item models.OrderItemResponse
+		err := rows.Scan(
+			&item.ID, &item.ProductID, &item.ColorID, &item.SizeID, &item.Quantity, &item.PricePerUnit,
+			&item.DiscountAmount, &item.TaxRate, &item.TaxAmount, &item.TaxIncluded, &item.ShippedQuantity,
+			&item.ProductName, &item.ProductDescription,
+			&item.ColorName, &item.ColorHex,
+			&item.SizeName,
+			&item.ImageURL)
+		if err != nil {
+			return nil, err
+		}
+		item.SubTotal = item.PricePerUnit.Mul(item.Quantity)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,475 @@
+package repository
+
+import (
+	"backend/database"
+	"backend/inventory"
+	"backend/models"
+	"backend/money"
+	"backend/pricing"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// sqlProducts is the SQL ProductRepository
+type sqlProducts struct {
+	db *sql.DB
+}
+
+// productSortOptions maps the sorts of ProductFilter to an ORDER BY clause
+var productSortOptions = map[string]string{
+	"newest":   "p.created_at DESC, p.id DESC",
+	"oldest":   "p.created_at ASC, p.id ASC",
+	"discount": "p.discount_percentage DESC, p.id DESC",
+	"rating":   "average_rating DESC, review_count DESC, p.id DESC",
+}
+
+// IsProductSort reports whether a sort is one ProductFilter accepts
+func IsProductSort(sort string) bool {
+	_, ok := productSortOptions[sort]
+	return ok || sort == "price_asc" || sort == "price_desc"
+}
+
+func (r *sqlProducts) Exists(productID int64) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)", productID).Scan(&exists)
+	return exists, err
+}
+
+func (r *sqlProducts) HasColor(productID, colorID int64) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM product_colors WHERE id = ? AND product_id = ?)", colorID, productID).Scan(&exists)
+	return exists, err
+}
+
+func (r *sqlProducts) HasSize(productID, sizeID int64) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM product_sizes WHERE id = ? AND product_id = ?)", sizeID, productID).Scan(&exists)
+	return exists, err
+}
+
+func (r *sqlProducts) PrimaryImage(productID int64) (*string, error) {
+	var imageURL sql.NullString
+	err := r.db.QueryRow("SELECT "+PrimaryImageSQL+" FROM products p WHERE p.id = ?", productID).Scan(&imageURL)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if !imageURL.Valid {
+		return nil, nil
+	}
+	return &imageURL.String, nil
+}
+
+func (r *sqlProducts) Available(userID, productID, colorID, sizeID int64) (int, error) {
+	var available int
+	err := r.db.QueryRow(
+		"SELECT "+inventory.AvailableToUser+" FROM product_inventory pi WHERE pi.product_id = ? AND pi.color_id = ? AND pi.size_id = ?",
+		userID, productID, colorID, sizeID).Scan(&available)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return available, err
+}
+
+func (r *sqlProducts) List(filter ProductFilter) ([]models.ProductListItem, int, error) {
+	exchange := filter.Exchange
+	where, args := productConditions(filter)
+
+	orderBy, ok := productSortOptions[filter.Sort]
+	switch filter.Sort {
+	case "price_asc":
+		orderBy, ok = exchange.UnitPriceSQL()+" ASC, p.id DESC", true
+	case "price_desc":
+		orderBy, ok = exchange.UnitPriceSQL()+" DESC, p.id DESC", true
+	}
+	if !ok {
+		orderBy = productSortOptions["newest"]
+	}
+
+	// Count every product matching the filter for pagination
+	var total int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM products p
+		LEFT JOIN categories c ON p.category_id = c.id`+where,
+		args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT p.id, p.name, p.description, COALESCE(p.category_id, 0), COALESCE(p.slug, ''), p.base_price,
+			`+exchange.OverrideSQL()+`, p.discount_percentage, p.featured, p.created_at, p.updated_at,
+			COALESCE(c.name, 'Uncategorized') as category_name,
+			(SELECT COALESCE(AVG(rating), 0) FROM reviews WHERE product_id = p.id) as average_rating,
+			(SELECT COUNT(*) FROM reviews WHERE product_id = p.id) as review_count,
+			`+PrimaryImageSQL+` as primary_image
+		FROM products p
+		LEFT JOIN categories c ON p.category_id = c.id`+where+`
+		ORDER BY `+orderBy+`
+		LIMIT ? OFFSET ?`,
+		append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	products := []models.ProductListItem{}
+	for rows.Next() {
+		var product models.ProductListItem
+		var override *money.Amount
+		err := rows.Scan(
+			&product.ID, &product.Name, &product.Description, &product.CategoryID, &product.Slug,
+			&product.BasePrice, &override, &product.DiscountPercentage, &product.Featured,
+			&product.CreatedAt, &product.UpdatedAt, &product.CategoryName,
+			&product.AverageRating, &product.ReviewCount, &product.PrimaryImage)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// Price the product in the exchange currency
+		product.BasePrice, product.FinalPrice = exchange.Price(product.BasePrice, override, product.DiscountPercentage)
+		product.Currency = exchange.Currency
+		products = append(products, product)
+	}
+	return products, total, rows.Err()
+}
+
+func (r *sqlProducts) Find(productID int64, exchange *pricing.Exchange) (*models.ProductResponse, error) {
+	var product models.ProductResponse
+	var override *money.Amount
+	err := r.db.QueryRow(`
+		SELECT p.id, p.name, p.description, COALESCE(p.category_id, 0), COALESCE(p.slug, ''), p.base_price,
+			`+exchange.OverrideSQL()+`, p.discount_percentage, p.featured, p.weight, p.length, p.width, p.height, p.created_at, p.updated_at,
+			COALESCE(c.name, 'Uncategorized') as category_name,
+			(SELECT COALESCE(AVG(rating), 0) FROM reviews WHERE product_id = p.id) as average_rating,
+			(SELECT COUNT(*) FROM reviews WHERE product_id = p.id) as review_count
+		FROM products p
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE p.id = ?`,
+		productID).Scan(
+		&product.ID, &product.Name, &product.Description, &product.CategoryID, &product.Slug,
+		&product.BasePrice, &override, &product.DiscountPercentage, &product.Featured,
+		&product.Weight, &product.Length, &product.Width, &product.Height,
+		&product.CreatedAt, &product.UpdatedAt, &product.CategoryName,
+		&product.AverageRating, &product.ReviewCount)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	// Price the product in the exchange currency
+	product.BasePrice, product.FinalPrice = exchange.Price(product.BasePrice, override, product.DiscountPercentage)
+	product.Currency = exchange.Currency
+
+	// Get all images
+	rows, err := r.db.Query("SELECT id, product_id, image_url, is_primary, created_at FROM product_images WHERE product_id = ?", productID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var image models.ProductImage
+		if err := rows.Scan(&image.ID, &image.ProductID, &image.ImageURL, &image.IsPrimary, &image.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		product.Images = append(product.Images, image)
+	}
+	rows.Close()
+
+	// Get all colors
+	rows, err = r.db.Query("SELECT id, product_id, color_name, color_hex, created_at FROM product_colors WHERE product_id = ?", productID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var color models.ProductColor
+		if err := rows.Scan(&color.ID, &color.ProductID, &color.ColorName, &color.ColorHex, &color.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		product.Colors = append(product.Colors, color)
+	}
+	rows.Close()
+
+	// Get all sizes
+	rows, err = r.db.Query("SELECT id, product_id, size_name, created_at FROM product_sizes WHERE product_id = ?", productID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var size models.ProductSize
+		if err := rows.Scan(&size.ID, &size.ProductID, &size.SizeName, &size.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		product.Sizes = append(product.Sizes, size)
+	}
+	rows.Close()
+
+	// Get inventory, less the stock held by shoppers in checkout
+	rows, err = r.db.Query("SELECT pi.color_id, pi.size_id, "+inventory.Available+" FROM product_inventory pi WHERE pi.product_id = ?", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.InventoryItem
+		if err := rows.Scan(&item.ColorID, &item.SizeID, &item.Quantity); err != nil {
+			return nil, err
+		}
+		item.Quantity = max(item.Quantity, 0)
+		product.Inventory = append(product.Inventory, item)
+	}
+	return &product, rows.Err()
+}
+
+func (r *sqlProducts) Resolve(slug string) (int64, string, error) {
+	// Look up the current slug first
+	var productID int64
+	err := r.db.QueryRow("SELECT id FROM products WHERE slug = ?", slug).Scan(&productID)
+	if err != sql.ErrNoRows {
+		return productID, slug, err
+	}
+
+	// Fall back to the slug history
+	var currentSlug string
+	err = r.db.QueryRow(`
+		SELECT p.id, p.slug
+		FROM product_slug_history h
+		JOIN products p ON h.product_id = p.id
+		WHERE h.slug = ?`,
+		slug).Scan(&productID, &currentSlug)
+	if err != nil {
+		return 0, "", notFound(err)
+	}
+	return productID, currentSlug, nil
+}
+
+func (r *sqlProducts) Create(product *models.Product) (int64, error) {
+	// Generate a unique slug from the name
+	slug, err := database.UniqueSlug(r.db, "products", product.Name, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	var productID int64
+	err = r.db.QueryRow(
+		`INSERT INTO products (name, description, category_id, slug, base_price, discount_percentage, featured,
+			weight, length, width, height, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		product.Name, product.Description, product.CategoryID, slug, product.BasePrice, product.DiscountPercentage,
+		product.Featured, product.Weight, product.Length, product.Width, product.Height,
+		time.Now(), time.Now()).Scan(&productID)
+	if err != nil {
+		return 0, err
+	}
+	product.ID, product.Slug = productID, slug
+	return productID, nil
+}
+
+func (r *sqlProducts) Update(product *models.Product) error {
+	return inTransaction(r.db, func(tx *sql.Tx) error {
+		var currentName, currentSlug string
+		err := tx.QueryRow("SELECT name, COALESCE(slug, '') FROM products WHERE id = ?", product.ID).Scan(&currentName, &currentSlug)
+		if err != nil {
+			return notFound(err)
+		}
+
+		// Renaming the product gives it a new slug
+		slug := currentSlug
+		if product.Name != currentName || slug == "" {
+			slug, err = database.UniqueSlug(tx, "products", product.Name, product.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Keep the old slug resolvable, the new one may be one the product used before
+		if slug != currentSlug && currentSlug != "" {
+			_, err = tx.Exec("DELETE FROM product_slug_history WHERE slug = ? AND product_id = ?", slug, product.ID)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(
+				"INSERT INTO product_slug_history (product_id, slug, created_at) VALUES (?, ?, ?)",
+				product.ID, currentSlug, time.Now())
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(`
+			UPDATE products SET name = ?, description = ?, category_id = ?, slug = ?, base_price = ?,
+				discount_percentage = ?, featured = ?, weight = ?, length = ?, width = ?, height = ?, updated_at = ?
+			WHERE id = ?`,
+			product.Name, product.Description, product.CategoryID, slug, product.BasePrice,
+			product.DiscountPercentage, product.Featured, product.Weight, product.Length, product.Width, product.Height,
+			time.Now(), product.ID)
+		if err != nil {
+			return err
+		}
+		product.Slug = slug
+		return nil
+	})
+}
+
+func (r *sqlProducts) Delete(productID int64) error {
+	result, err := r.db.Exec("DELETE FROM products WHERE id = ?", productID)
+	return affectedOne(result, err)
+}
+
+func (r *sqlProducts) AddColor(productID int64, name, hex string) (int64, error) {
+	var colorID int64
+	err := r.db.QueryRow(
+		"INSERT INTO product_colors (product_id, color_name, color_hex, created_at) VALUES (?, ?, ?, ?) RETURNING id",
+		productID, name, hex, time.Now()).Scan(&colorID)
+	return colorID, err
+}
+
+func (r *sqlProducts) AddSize(productID int64, name string) (int64, error) {
+	var sizeID int64
+	err := r.db.QueryRow(
+		"INSERT INTO product_sizes (product_id, size_name, created_at) VALUES (?, ?, ?) RETURNING id",
+		productID, name, time.Now()).Scan(&sizeID)
+	return sizeID, err
+}
+
+func (r *sqlProducts) AddImage(productID int64, imageURL string, primary bool) (int64, error) {
+	var imageID int64
+	err := inTransaction(r.db, func(tx *sql.Tx) error {
+		// A new primary image replaces the old one
+		if primary {
+			if _, err := tx.Exec("UPDATE product_images SET is_primary = FALSE WHERE product_id = ?", productID); err != nil {
+				return err
+			}
+		}
+		return tx.QueryRow(
+			"INSERT INTO product_images (product_id, image_url, is_primary, created_at) VALUES (?, ?, ?, ?) RETURNING id",
+			productID, imageURL, primary, time.Now()).Scan(&imageID)
+	})
+	return imageID, err
+}
+
+func (r *sqlProducts) SetStock(productID, colorID, sizeID int64, quantity int) (int64, error) {
+	var inventoryID int64
+	err := r.db.QueryRow(`
+		INSERT INTO product_inventory (product_id, color_id, size_id, quantity, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(product_id, color_id, size_id) DO UPDATE SET quantity = excluded.quantity, updated_at = excluded.updated_at
+		RETURNING id`,
+		productID, colorID, sizeID, quantity, time.Now()).Scan(&inventoryID)
+	return inventoryID, err
+}
+
+func (r *sqlProducts) DeleteColor(productID, colorID int64) error {
+	result, err := r.db.Exec("DELETE FROM product_colors WHERE id = ? AND product_id = ?", colorID, productID)
+	return affectedOne(result, err)
+}
+
+func (r *sqlProducts) DeleteSize(productID, sizeID int64) error {
+	result, err := r.db.Exec("DELETE FROM product_sizes WHERE id = ? AND product_id = ?", sizeID, productID)
+	return affectedOne(result, err)
+}
+
+func (r *sqlProducts) DeleteImage(productID, imageID int64) error {
+	return inTransaction(r.db, func(tx *sql.Tx) error {
+		var primary bool
+		err := tx.QueryRow("SELECT is_primary FROM product_images WHERE id = ? AND product_id = ?", imageID, productID).Scan(&primary)
+		if err != nil {
+			return notFound(err)
+		}
+		if _, err := tx.Exec("DELETE FROM product_images WHERE id = ?", imageID); err != nil {
+			return err
+		}
+
+		// The oldest image left takes over from a deleted primary image
+		if primary {
+			_, err = tx.Exec(
+				"UPDATE product_images SET is_primary = TRUE WHERE id = (SELECT id FROM product_images WHERE product_id = ? ORDER BY id LIMIT 1)",
+				productID)
+		}
+		return err
+	})
+}
+
+func (r *sqlProducts) Prices(productID int64) ([]models.ProductPrice, error) {
+	return pricing.ProductPrices(r.db, productID)
+}
+
+func (r *sqlProducts) SetPrice(productID int64, currency money.Currency, basePrice money.Amount) error {
+	_, err := r.db.Exec(
+		`INSERT INTO product_prices (product_id, currency, base_price, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(product_id, currency) DO UPDATE SET base_price = excluded.base_price, updated_at = excluded.updated_at`,
+		productID, string(currency), basePrice, time.Now())
+	return err
+}
+
+func (r *sqlProducts) DeletePrice(productID int64, currency money.Currency) error {
+	result, err := r.db.Exec("DELETE FROM product_prices WHERE product_id = ? AND currency = ?", productID, string(currency))
+	return affectedOne(result, err)
+}
+
+// productConditions turns a filter into a WHERE clause over products aliased
+// p and categories aliased c
+func productConditions(filter ProductFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.CategoryID != 0 {
+		conditions = append(conditions, "p.category_id = ?")
+		args = append(args, filter.CategoryID)
+	}
+	if filter.MinPrice != nil {
+		conditions = append(conditions, filter.Exchange.UnitPriceSQL()+" >= ?")
+		args = append(args, *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		conditions = append(conditions, filter.Exchange.UnitPriceSQL()+" <= ?")
+		args = append(args, *filter.MaxPrice)
+	}
+
+	// Colors and sizes match any of their names, ignoring case
+	if len(filter.Colors) > 0 {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM product_colors pc
+			WHERE pc.product_id = p.id AND LOWER(pc.color_name) IN (`+placeholders(len(filter.Colors))+`))`)
+		for _, color := range filter.Colors {
+			args = append(args, strings.ToLower(color))
+		}
+	}
+	if len(filter.Sizes) > 0 {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM product_sizes ps
+			WHERE ps.product_id = p.id AND LOWER(ps.size_name) IN (`+placeholders(len(filter.Sizes))+`))`)
+		for _, size := range filter.Sizes {
+			args = append(args, strings.ToLower(size))
+		}
+	}
+
+	if filter.Featured {
+		conditions = append(conditions, "p.featured = TRUE")
+	}
+	if filter.InStock {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM product_inventory pi
+			WHERE pi.product_id = p.id AND `+inventory.Available+` > 0)`)
+	}
+	if filter.OnSale {
+		conditions = append(conditions, "p.discount_percentage > 0")
+	}
+
+	if filter.Query != "" {
+		pattern := "%" + strings.ToLower(filter.Query) + "%"
+		conditions = append(conditions, "(LOWER(p.name) LIKE ? OR LOWER(p.description) LIKE ? OR LOWER(c.name) LIKE ?)")
+		args = append(args, pattern, pattern, pattern)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// placeholders returns n comma separated SQL placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
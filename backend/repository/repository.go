@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"backend/models"
+	"backend/money"
+	"backend/pricing"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when a row does not exist or belongs to another user
+var ErrNotFound = errors.New("not found")
+
+// ErrOpenOrders is returned when an account cannot be deleted while its orders
+// are still being fulfilled
+var ErrOpenOrders = errors.New("account has open orders")
+
+// PrimaryImageSQL selects the primary image of the product aliased p, NULL when it has none
+const PrimaryImageSQL = "(SELECT image_url FROM product_images WHERE product_id = p.id AND is_primary = TRUE LIMIT 1)"
+
+// ProductFilter selects a page of products. Prices are compared and sorted in
+// the exchange currency, and zero fields do not filter.
+type ProductFilter struct {
+	CategoryID int64
+	MinPrice   *money.Amount
+	MaxPrice   *money.Amount
+	// Colors and Sizes match products with any of the names, ignoring case
+	Colors   []string
+	Sizes    []string
+	Featured bool
+	InStock  bool
+	OnSale   bool
+	// Query matches the name, description or category name of products
+	Query string
+	// Sort is newest, oldest, discount, rating, price_asc or price_desc, newest by default
+	Sort     string
+	Exchange *pricing.Exchange
+	Limit    int
+	Offset   int
+}
+
+// ProductRepository reads and writes products, their variants and their prices
+type ProductRepository interface {
+	// Exists reports whether a product exists
+	Exists(productID int64) (bool, error)
+	// HasColor reports whether a color belongs to a product
+	HasColor(productID, colorID int64) (bool, error)
+	// HasSize reports whether a size belongs to a product
+	HasSize(productID, sizeID int64) (bool, error)
+	// PrimaryImage returns the URL of a product's primary image, nil when it has none
+	PrimaryImage(productID int64) (*string, error)
+	// Available returns the stock of a variant not held by shoppers other than userID
+	Available(userID, productID, colorID, sizeID int64) (int, error)
+	// List returns a page of the products matching a filter, priced in its
+	// exchange currency, with the total count
+	List(filter ProductFilter) ([]models.ProductListItem, int, error)
+	// Find returns a product with its images, variants, stock and unrounded
+	// rating, priced in the exchange currency
+	Find(productID int64, exchange *pricing.Exchange) (*models.ProductResponse, error)
+	// Resolve returns the product a slug belongs to and its current slug.
+	// Old slugs of renamed products resolve too.
+	Resolve(slug string) (int64, string, error)
+	// Create creates a product with a unique slug from its name and returns its ID.
+	// ID and Slug are updated to match.
+	Create(product *models.Product) (int64, error)
+	// Update updates a product. Renaming it gives it a new slug and keeps the
+	// old one resolvable; Slug is updated to match.
+	Update(product *models.Product) error
+	// Delete deletes a product
+	Delete(productID int64) error
+	// AddColor adds a color to a product and returns its ID
+	AddColor(productID int64, name, hex string) (int64, error)
+	// AddSize adds a size to a product and returns its ID
+	AddSize(productID int64, name string) (int64, error)
+	// AddImage adds an image to a product and returns its ID. A primary
+	// image replaces the product's primary image.
+	AddImage(productID int64, imageURL string, primary bool) (int64, error)
+	// SetStock sets the stock of a variant and returns the ID of its inventory row
+	SetStock(productID, colorID, sizeID int64, quantity int) (int64, error)
+	// DeleteColor deletes a color of a product
+	DeleteColor(productID, colorID int64) error
+	// DeleteSize deletes a size of a product
+	DeleteSize(productID, sizeID int64) error
+	// DeleteImage deletes an image of a product. The oldest image left takes
+	// over from a deleted primary image.
+	DeleteImage(productID, imageID int64) error
+	// Prices returns the prices set for a product in other currencies
+	Prices(productID int64) ([]models.ProductPrice, error)
+	// SetPrice sets a product's base price in a currency, used instead of converting its store price
+	SetPrice(productID int64, currency money.Currency, basePrice money.Amount) error
+	// DeletePrice removes a product's price in a currency
+	DeletePrice(productID int64, currency money.Currency) error
+}
+
+// CartRepository reads and writes the lines of users' carts
+type CartRepository interface {
+	// Count returns the number of lines in a user's cart
+	Count(userID int64) (int, error)
+	// Items returns a user's cart lines with product details, priced in the exchange currency
+	Items(userID int64, exchange *pricing.Exchange) ([]models.CartItemResponse, error)
+	// Find returns a line of a user's cart
+	Find(userID, itemID int64) (*models.CartItem, error)
+	// FindVariant returns the line of a user's cart holding a product variant
+	FindVariant(userID, productID, colorID, sizeID int64) (*models.CartItem, error)
+	// Add adds a line to a user's cart and returns its ID
+	Add(item *models.CartItem) (int64, error)
+	// SetQuantity changes the quantity of a line of a user's cart
+	SetQuantity(userID, itemID int64, quantity int) error
+	// Remove removes a line from a user's cart
+	Remove(userID, itemID int64) error
+	// Clear removes every line from a user's cart
+	Clear(userID int64) error
+	// ReleaseHolds drops the stock held for a user's checkout
+	ReleaseHolds(userID int64) error
+	// HoldsExpireAt returns when the first stock held for a user's checkout
+	// runs out, nil when none is held
+	HoldsExpireAt(userID int64) (*time.Time, error)
+}
+
+// OrderRepository reads orders
+type OrderRepository interface {
+	// List returns a page of orders, newest first, with the total count.
+	// A userID of 0 lists the orders of every user.
+	List(userID int64, limit, offset int) ([]models.OrderWithUser, int, error)
+	// Find returns an order
+	Find(orderID int64) (*models.Order, error)
+	// Items returns the items of an order with product details
+	Items(orderID int64) ([]models.OrderItemResponse, error)
+}
+
+// UserRepository reads and writes user accounts
+type UserRepository interface {
+	// EmailExists reports whether an account uses an email address
+	EmailExists(email string) (bool, error)
+	// Create creates an account from a user with a hashed password and returns its ID
+	Create(user *models.User) (int64, error)
+	// FindByEmail returns the account with an email address
+	FindByEmail(email string) (*models.User, error)
+	// FindByID returns an account
+	FindByID(userID int64) (*models.User, error)
+	// Search returns a page of accounts whose name or email contains query,
+	// newest first, with the total count. An empty query matches every account.
+	Search(query string, limit, offset int) ([]models.User, int, error)
+	// SetPassword replaces the hashed password of an account, lifting a required reset
+	SetPassword(userID int64, hash string) error
+	// MarkEmailVerified verifies an account's email, provided it is still the given address
+	MarkEmailVerified(userID int64, email string) error
+	// UpdateProfile changes the name and email of an account. A changed email
+	// is unverified, and the tokens mailed to the old one are voided.
+	UpdateProfile(userID int64, name, email string) error
+	// Anonymise deletes an account on its owner's request. Its orders are kept,
+	// the row stays behind without personal data, and everything else it owned is deleted.
+	// It returns ErrOpenOrders while an order is neither delivered nor cancelled.
+	Anonymise(userID int64) error
+	// SetRole changes the role of an account
+	SetRole(userID int64, role string) error
+	// SetDisabled disables or re-enables logging in to an account
+	SetDisabled(userID int64, disabled bool) error
+	// RequirePasswordReset blocks logins to an account until a new password is set
+	RequirePasswordReset(userID int64) error
+}
+
+// AddressRepository reads and writes users' addresses. A user with
+// addresses always has exactly one default address.
+type AddressRepository interface {
+	// List returns a user's addresses, the default first
+	List(userID int64) ([]models.Address, error)
+	// Find returns an address of a user
+	Find(userID, addressID int64) (*models.Address, error)
+	// FindDefault returns a user's default address
+	FindDefault(userID int64) (*models.Address, error)
+	// Create creates an address and returns its ID. The first address of a
+	// user becomes the default; IsDefault is updated to match.
+	Create(address *models.Address) (int64, error)
+	// Update updates an address. Unsetting the default moves it to the user's
+	// newest other address; IsDefault is updated to match.
+	Update(address *models.Address) error
+	// Delete deletes an address, moving the default to the user's newest other address
+	Delete(userID, addressID int64) error
+}
+
+// ReviewRepository reads and writes product reviews. A user reviews a product at most once.
+type ReviewRepository interface {
+	// Received reports whether a user has received a product in a delivered order
+	Received(userID, productID int64) (bool, error)
+	// Reviewed reports whether a user has reviewed a product
+	Reviewed(userID, productID int64) (bool, error)
+	// List returns a page of a product's reviews with the names of their
+	// authors. Sort is newest, highest or lowest, newest by default.
+	List(productID int64, sort string, limit, offset int) ([]models.ReviewResponse, error)
+	// Summary returns the unrounded average rating of a product and its review count
+	Summary(productID int64) (models.RatingSummary, error)
+	// Create creates a review and returns its ID
+	Create(review *models.Review) (int64, error)
+	// Update changes the rating and comment of a user's review of a product
+	Update(review *models.Review) error
+	// Author returns the ID of the user who wrote a review of a product
+	Author(productID, reviewID int64) (int64, error)
+	// Delete deletes a review
+	Delete(reviewID int64) error
+}
+
+// WishlistRepository reads and writes the products users keep on their wishlists
+type WishlistRepository interface {
+	// Items returns a user's wishlist with product details, newest first,
+	// priced in the exchange currency
+	Items(userID int64, exchange *pricing.Exchange) ([]models.WishlistItemResponse, error)
+	// Contains reports whether a product is on a user's wishlist
+	Contains(userID, productID int64) (bool, error)
+	// Add adds a product to a user's wishlist and returns the ID of its item
+	Add(userID, productID int64) (int64, error)
+	// Remove removes an item from a user's wishlist
+	Remove(userID, itemID int64) error
+	// Clear removes every item from a user's wishlist
+	Clear(userID int64) error
+}
+
+// Repositories groups the repositories the handlers work with
+type Repositories struct {
+	Products  ProductRepository
+	Carts     CartRepository
+	Orders    OrderRepository
+	Users     UserRepository
+	Addresses AddressRepository
+	Reviews   ReviewRepository
+	Wishlist  WishlistRepository
+}
+
+// New returns repositories backed by a SQL database. The queries use ?
+// placeholders and SQL that SQLite and PostgreSQL both accept.
+func New(db *sql.DB) Repositories {
+	return Repositories{
+		Products:  &sqlProducts{db: db},
+		Carts:     &sqlCarts{db: db},
+		Orders:    &sqlOrders{db: db},
+		Users:     &sqlUsers{db: db},
+		Addresses: &sqlAddresses{db: db},
+		Reviews:   &sqlReviews{db: db},
+		Wishlist:  &sqlWishlist{db: db},
+	}
+}
+
+// notFound maps sql.ErrNoRows to ErrNotFound
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+// inTransaction runs fn in a transaction, committing only if it succeeds
+func inTransaction(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,473 @@
+package repository_test
+
+import (
+	"backend/database"
+	"backend/database/dbtest"
+	"backend/dialect"
+	"backend/models"
+	"backend/money"
+	"backend/pricing"
+	"backend/repository"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUsers(t *testing.T) {
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		users := repository.New(db).Users
+
+		userID, err := users.Create(&models.User{Name: "Ada", Email: "ada@example.com", Password: "hash", Role: "customer"})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		if exists, err := users.EmailExists("ada@example.com"); err != nil || !exists {
+			t.Errorf("EmailExists = %v, %v, want true", exists, err)
+		}
+		if exists, err := users.EmailExists("bob@example.com"); err != nil || exists {
+			t.Errorf("EmailExists of unknown email = %v, %v, want false", exists, err)
+		}
+
+		user, err := users.FindByEmail("ada@example.com")
+		if err != nil {
+			t.Fatalf("FindByEmail: %v", err)
+		}
+		if user.ID != userID || user.Name != "Ada" || user.Role != "customer" {
+			t.Errorf("FindByEmail = %+v, want user %d", user, userID)
+		}
+
+		if _, err := users.FindByID(userID + 1); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("FindByID of unknown user returned %v, want ErrNotFound", err)
+		}
+
+		if err := users.SetPassword(userID, "new hash"); err != nil {
+			t.Fatalf("SetPassword: %v", err)
+		}
+		if user.EmailVerifiedAt != nil {
+			t.Errorf("new account has EmailVerifiedAt %v, want nil", user.EmailVerifiedAt)
+		}
+		if err := users.MarkEmailVerified(userID, "old@example.com"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("MarkEmailVerified of another address returned %v, want ErrNotFound", err)
+		}
+		if err := users.MarkEmailVerified(userID, "ada@example.com"); err != nil {
+			t.Fatalf("MarkEmailVerified: %v", err)
+		}
+		user, err = users.FindByID(userID)
+		if err != nil {
+			t.Fatalf("FindByID: %v", err)
+		}
+		if user.Password != "new hash" || user.EmailVerifiedAt == nil {
+			t.Errorf("FindByID = %+v, want the new password and a verified email", user)
+		}
+
+		if err := users.SetDisabled(userID, true); err != nil {
+			t.Fatalf("SetDisabled: %v", err)
+		}
+		if err := users.RequirePasswordReset(userID); err != nil {
+			t.Fatalf("RequirePasswordReset: %v", err)
+		}
+		if user, err = users.FindByID(userID); err != nil || user.DisabledAt == nil || !user.PasswordResetRequired {
+			t.Errorf("FindByID = %+v, %v, want a disabled account with a required reset", user, err)
+		}
+		if err := users.SetDisabled(userID, false); err != nil {
+			t.Fatalf("SetDisabled: %v", err)
+		}
+		if err := users.SetPassword(userID, "newer hash"); err != nil {
+			t.Fatalf("SetPassword: %v", err)
+		}
+		if user, err = users.FindByID(userID); err != nil || user.DisabledAt != nil || user.PasswordResetRequired {
+			t.Errorf("FindByID = %+v, %v, want an enabled account without a required reset", user, err)
+		}
+
+		if _, err := users.Create(&models.User{Name: "Bob", Email: "bob@example.com", Password: "hash", Role: "customer"}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		found, total, err := users.Search("ADA", 10, 0)
+		if err != nil || total != 1 || len(found) != 1 || found[0].ID != userID {
+			t.Errorf("Search(ADA) = %+v, %d, %v, want user %d", found, total, err, userID)
+		}
+		found, total, err = users.Search("", 1, 0)
+		if err != nil || total != 2 || len(found) != 1 || found[0].Name != "Bob" {
+			t.Errorf("Search() = %+v, %d, %v, want Bob of 2 accounts", found, total, err)
+		}
+	})
+}
+
+func TestAddressesKeepOneDefault(t *testing.T) {
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		repos := repository.New(db)
+		userID := createUser(t, repos, "ada@example.com")
+
+		first := &models.Address{UserID: userID, Name: "Home", Street: "1 Main St", City: "Austin", State: "TX",
+			PostalCode: "73301", Country: "US", Phone: "555"}
+		firstID, err := repos.Addresses.Create(first)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if !first.IsDefault {
+			t.Error("first address is not the default")
+		}
+
+		second := *first
+		second.Name = "Work"
+		second.IsDefault = true
+		secondID, err := repos.Addresses.Create(&second)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		assertDefault(t, repos, userID, secondID)
+
+		// Unsetting the default moves it to the newest other address
+		second.ID = secondID
+		second.IsDefault = false
+		if err := repos.Addresses.Update(&second); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		assertDefault(t, repos, userID, firstID)
+
+		// Deleting the default moves it too
+		if err := repos.Addresses.Delete(userID, firstID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		assertDefault(t, repos, userID, secondID)
+
+		if err := repos.Addresses.Delete(userID+1, secondID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("deleting another user's address returned %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestCarts(t *testing.T) {
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		repos := repository.New(db)
+		userID := createUser(t, repos, "ada@example.com")
+		productID, colorID, sizeID := createProduct(t, db, 5)
+
+		itemID, err := repos.Carts.Add(&models.CartItem{UserID: userID, ProductID: productID, ColorID: colorID, SizeID: sizeID, Quantity: 2})
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		if err := repos.Carts.SetQuantity(userID, itemID, 3); err != nil {
+			t.Fatalf("SetQuantity: %v", err)
+		}
+
+		item, err := repos.Carts.FindVariant(userID, productID, colorID, sizeID)
+		if err != nil || item.ID != itemID || item.Quantity != 3 {
+			t.Fatalf("FindVariant = %+v, %v, want item %d with quantity 3", item, err, itemID)
+		}
+
+		items, err := repos.Carts.Items(userID, pricing.Store())
+		if err != nil {
+			t.Fatalf("Items: %v", err)
+		}
+		if len(items) != 1 {
+			t.Fatalf("Items returned %d lines, want 1", len(items))
+		}
+		line := items[0]
+		if line.FinalPrice != money.Amount(1800) || line.InStock != 5 || line.ImageURL != "https://example.com/tee.jpg" {
+			t.Errorf("cart line = %+v, want final price 18.00, 5 in stock and the primary image", line)
+		}
+
+		if available, err := repos.Products.Available(userID, productID, colorID, sizeID); err != nil || available != 5 {
+			t.Errorf("Available = %d, %v, want 5", available, err)
+		}
+
+		if err := repos.Carts.Remove(userID+1, itemID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("removing another user's line returned %v, want ErrNotFound", err)
+		}
+		if err := repos.Carts.Clear(userID); err != nil {
+			t.Fatalf("Clear: %v", err)
+		}
+		if count, err := repos.Carts.Count(userID); err != nil || count != 0 {
+			t.Errorf("Count after Clear = %d, %v, want 0", count, err)
+		}
+	})
+}
+
+func TestOrders(t *testing.T) {
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		repos := repository.New(db)
+		productID, colorID, sizeID := createProduct(t, db, 5)
+
+		var orderIDs []int64
+		for _, email := range []string{"ada@example.com", "bob@example.com"} {
+			userID := createUser(t, repos, email)
+			addressID, err := repos.Addresses.Create(&models.Address{UserID: userID, Name: "Home", Street: "1 Main St",
+				City: "Austin", State: "TX", PostalCode: "73301", Country: "US", Phone: "555"})
+			if err != nil {
+				t.Fatalf("creating address: %v", err)
+			}
+
+			var orderID int64
+			err = db.QueryRow(
+				`INSERT INTO orders (user_id, address_id, subtotal, total_amount, tax_included, currency, payment_method, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+				userID, addressID, money.Amount(3600), money.Amount(3600), false, "USD", "cod", time.Now(), time.Now()).Scan(&orderID)
+			if err != nil {
+				t.Fatalf("creating order: %v", err)
+			}
+			_, err = db.Exec(
+				"INSERT INTO order_items (order_id, product_id, color_id, size_id, quantity, price_per_unit) VALUES (?, ?, ?, ?, ?, ?)",
+				orderID, productID, colorID, sizeID, 2, money.Amount(1800))
+			if err != nil {
+				t.Fatalf("creating order item: %v", err)
+			}
+			orderIDs = append(orderIDs, orderID)
+		}
+
+		all, total, err := repos.Orders.List(0, 10, 0)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if total != 2 || len(all) != 2 || all[0].ID != orderIDs[1] || all[0].UserEmail != "bob@example.com" {
+			t.Errorf("List of every order = %+v, total %d, want both orders newest first", all, total)
+		}
+
+		order, err := repos.Orders.Find(orderIDs[0])
+		if err != nil {
+			t.Fatalf("Find: %v", err)
+		}
+		mine, total, err := repos.Orders.List(order.UserID, 10, 0)
+		if err != nil || total != 1 || len(mine) != 1 || mine[0].ID != orderIDs[0] {
+			t.Errorf("List of a user's orders = %+v, total %d, %v, want order %d", mine, total, err, orderIDs[0])
+		}
+
+		items, err := repos.Orders.Items(order.ID)
+		if err != nil {
+			t.Fatalf("Items: %v", err)
+		}
+		if len(items) != 1 || items[0].SubTotal != money.Amount(3600) || items[0].ColorName != "White" {
+			t.Errorf("Items = %+v, want one line of 36.00 in White", items)
+		}
+
+		if _, err := repos.Orders.Find(orderIDs[1] + 1); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Find of unknown order returned %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestReviews(t *testing.T) {
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		repos := repository.New(db)
+		productID, _, _ := createProduct(t, db, 5)
+
+		var reviewIDs []int64
+		for i, email := range []string{"ada@example.com", "bob@example.com"} {
+			userID := createUser(t, repos, email)
+			reviewID, err := repos.Reviews.Create(&models.Review{UserID: userID, ProductID: productID, Rating: 4 - 2*i, Comment: email})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			reviewIDs = append(reviewIDs, reviewID)
+		}
+		ada, err := repos.Reviews.Author(productID, reviewIDs[0])
+		if err != nil {
+			t.Fatalf("Author: %v", err)
+		}
+
+		// A user reviews a product once
+		_, err = repos.Reviews.Create(&models.Review{UserID: ada, ProductID: productID, Rating: 5})
+		if !database.IsUniqueViolation(err) {
+			t.Errorf("creating a second review returned %v, want a unique violation", err)
+		}
+		if reviewed, err := repos.Reviews.Reviewed(ada, productID); err != nil || !reviewed {
+			t.Errorf("Reviewed = %v, %v, want true", reviewed, err)
+		}
+		if received, err := repos.Reviews.Received(ada, productID); err != nil || received {
+			t.Errorf("Received without an order = %v, %v, want false", received, err)
+		}
+
+		reviews, err := repos.Reviews.List(productID, "lowest", 10, 0)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(reviews) != 2 || reviews[0].ID != reviewIDs[1] || reviews[0].UserName != "Test" {
+			t.Errorf("List lowest first = %+v, want the 2 star review first with its author's name", reviews)
+		}
+		if summary, err := repos.Reviews.Summary(productID); err != nil || summary.AverageRating != 3 || summary.ReviewCount != 2 {
+			t.Errorf("Summary = %+v, %v, want an average of 3 over 2 reviews", summary, err)
+		}
+
+		// Only the author can update a review
+		err = repos.Reviews.Update(&models.Review{ID: reviewIDs[0], UserID: ada + 1, ProductID: productID, Rating: 1})
+		if !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("updating another user's review returned %v, want ErrNotFound", err)
+		}
+		if err := repos.Reviews.Update(&models.Review{ID: reviewIDs[0], UserID: ada, ProductID: productID, Rating: 5}); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+
+		if err := repos.Reviews.Delete(reviewIDs[1]); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := repos.Reviews.Author(productID, reviewIDs[1]); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Author of a deleted review returned %v, want ErrNotFound", err)
+		}
+		if summary, err := repos.Reviews.Summary(productID); err != nil || summary.AverageRating != 5 || summary.ReviewCount != 1 {
+			t.Errorf("Summary after updating and deleting = %+v, %v, want 5 over 1 review", summary, err)
+		}
+	})
+}
+
+func TestWishlist(t *testing.T) {
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		repos := repository.New(db)
+		userID := createUser(t, repos, "ada@example.com")
+		productID, _, _ := createProduct(t, db, 5)
+
+		itemID, err := repos.Wishlist.Add(userID, productID)
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		if contains, err := repos.Wishlist.Contains(userID, productID); err != nil || !contains {
+			t.Errorf("Contains = %v, %v, want true", contains, err)
+		}
+
+		items, err := repos.Wishlist.Items(userID, pricing.Store())
+		if err != nil {
+			t.Fatalf("Items: %v", err)
+		}
+		if len(items) != 1 || items[0].ID != itemID || items[0].FinalPrice != money.Amount(1800) || !items[0].InStock ||
+			items[0].ImageURL != "https://example.com/tee.jpg" {
+			t.Errorf("Items = %+v, want item %d at 18.00, in stock, with the primary image", items, itemID)
+		}
+
+		if err := repos.Wishlist.Remove(userID+1, itemID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("removing another user's item returned %v, want ErrNotFound", err)
+		}
+		if err := repos.Wishlist.Clear(userID); err != nil {
+			t.Fatalf("Clear: %v", err)
+		}
+		if items, err := repos.Wishlist.Items(userID, pricing.Store()); err != nil || len(items) != 0 {
+			t.Errorf("Items after Clear = %+v, %v, want none", items, err)
+		}
+	})
+}
+
+func TestAnonymiseKeepsOrders(t *testing.T) {
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		repos := repository.New(db)
+		userID := createUser(t, repos, "ada@example.com")
+
+		var addressIDs []int64
+		for _, street := range []string{"1 Main St", "2 Side St"} {
+			addressID, err := repos.Addresses.Create(&models.Address{UserID: userID, Name: "Ada", Street: street,
+				City: "Austin", State: "TX", PostalCode: "73301", Country: "US", Phone: "555"})
+			if err != nil {
+				t.Fatalf("creating address: %v", err)
+			}
+			addressIDs = append(addressIDs, addressID)
+		}
+		var orderID int64
+		err := db.QueryRow(
+			`INSERT INTO orders (user_id, address_id, total_amount, currency, payment_method, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+			userID, addressIDs[0], money.Amount(1000), "USD", "cod", time.Now(), time.Now()).Scan(&orderID)
+		if err != nil {
+			t.Fatalf("creating order: %v", err)
+		}
+
+		// Changing the email unverifies it
+		if err := repos.Users.MarkEmailVerified(userID, "ada@example.com"); err != nil {
+			t.Fatalf("MarkEmailVerified: %v", err)
+		}
+		if err := repos.Users.UpdateProfile(userID, "Ada L", "ada@example.org"); err != nil {
+			t.Fatalf("UpdateProfile: %v", err)
+		}
+		user, err := repos.Users.FindByID(userID)
+		if err != nil || user.Name != "Ada L" || user.Email != "ada@example.org" || user.EmailVerifiedAt != nil {
+			t.Errorf("FindByID = %+v, %v, want the new name and an unverified new email", user, err)
+		}
+
+		// The account stays while its order is being fulfilled
+		if err := repos.Users.Anonymise(userID); !errors.Is(err, repository.ErrOpenOrders) {
+			t.Fatalf("Anonymise with an open order returned %v, want ErrOpenOrders", err)
+		}
+		if addresses, err := repos.Addresses.List(userID); err != nil || len(addresses) != 2 || addresses[0].Street == "" {
+			t.Errorf("Addresses = %+v, %v, want both addresses untouched while the order is open", addresses, err)
+		}
+		if _, err := db.Exec("UPDATE orders SET order_status = 'delivered' WHERE id = ?", orderID); err != nil {
+			t.Fatalf("delivering order: %v", err)
+		}
+
+		if err := repos.Users.Anonymise(userID); err != nil {
+			t.Fatalf("Anonymise: %v", err)
+		}
+		if err := repos.Users.Anonymise(userID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Anonymise of a deleted account returned %v, want ErrNotFound", err)
+		}
+
+		user, err = repos.Users.FindByID(userID)
+		if err != nil || user.DeletedAt == nil || user.DisabledAt == nil || user.Email == "ada@example.org" || user.Name == "Ada L" {
+			t.Errorf("FindByID = %+v, %v, want a deleted account without personal data", user, err)
+		}
+		if exists, err := repos.Users.EmailExists("ada@example.org"); err != nil || exists {
+			t.Errorf("EmailExists of the deleted account's email = %v, %v, want false", exists, err)
+		}
+
+		if order, err := repos.Orders.Find(orderID); err != nil || order.UserID != userID {
+			t.Errorf("Find = %+v, %v, want the order kept", order, err)
+		}
+		addresses, err := repos.Addresses.List(userID)
+		if err != nil || len(addresses) != 1 || addresses[0].ID != addressIDs[0] || addresses[0].Street != "" || addresses[0].City != "Austin" {
+			t.Errorf("Addresses = %+v, %v, want only the ordered-to address, without its street", addresses, err)
+		}
+	})
+}
+
+// createUser creates a customer account and returns its ID
+func createUser(t *testing.T, repos repository.Repositories, email string) int64 {
+	t.Helper()
+
+	userID, err := repos.Users.Create(&models.User{Name: "Test", Email: email, Password: "hash", Role: "customer"})
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	return userID
+}
+
+// createProduct creates a 20.00 product at 10% off with one variant in stock
+func createProduct(t *testing.T, db *sql.DB, stock int) (productID, colorID, sizeID int64) {
+	t.Helper()
+
+	err := db.QueryRow(
+		"INSERT INTO products (name, description, base_price, discount_percentage, featured) VALUES (?, ?, ?, ?, ?) RETURNING id",
+		"Tee", "A plain tee", money.Amount(2000), 10.0, false).Scan(&productID)
+	if err == nil {
+		err = db.QueryRow("INSERT INTO product_colors (product_id, color_name, color_hex) VALUES (?, ?, ?) RETURNING id",
+			productID, "White", "#FFFFFF").Scan(&colorID)
+	}
+	if err == nil {
+		err = db.QueryRow("INSERT INTO product_sizes (product_id, size_name) VALUES (?, ?) RETURNING id",
+			productID, "M").Scan(&sizeID)
+	}
+	if err == nil {
+		_, err = db.Exec("INSERT INTO product_inventory (product_id, color_id, size_id, quantity) VALUES (?, ?, ?, ?)",
+			productID, colorID, sizeID, stock)
+	}
+	if err == nil {
+		_, err = db.Exec("INSERT INTO product_images (product_id, image_url, is_primary) VALUES (?, ?, ?)",
+			productID, "https://example.com/tee.jpg", true)
+	}
+	if err != nil {
+		t.Fatalf("creating product: %v", err)
+	}
+	return productID, colorID, sizeID
+}
+
+// assertDefault checks that an address is a user's only default address
+func assertDefault(t *testing.T, repos repository.Repositories, userID, addressID int64) {
+	t.Helper()
+
+	addresses, err := repos.Addresses.List(userID)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	for _, address := range addresses {
+		if address.IsDefault != (address.ID == addressID) {
+			t.Errorf("address %d default = %v, want address %d to be the only default", address.ID, address.IsDefault, addressID)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package repotest
+
+import (
+	"backend/models"
+	"backend/repository"
+	"slices"
+	"time"
+)
+
+// addresses is the in-memory AddressRepository
+type addresses struct {
+	s *Store
+}
+
+func (r *addresses) List(userID int64) ([]models.Address, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	// The default first, then the newest
+	addresses := []models.Address{}
+	for _, address := range slices.Backward(r.s.addresses) {
+		if address.UserID == userID {
+			addresses = append(addresses, *address)
+		}
+	}
+	slices.SortStableFunc(addresses, func(a, b models.Address) int {
+		if a.IsDefault == b.IsDefault {
+			return 0
+		}
+		if a.IsDefault {
+			return -1
+		}
+		return 1
+	})
+	return addresses, nil
+}
+
+func (r *addresses) Find(userID, addressID int64) (*models.Address, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return found(r.find(userID, addressID))
+}
+
+func (r *addresses) FindDefault(userID int64) (*models.Address, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, address := range r.s.addresses {
+		if address.UserID == userID && address.IsDefault {
+			return found(address)
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *addresses) Create(address *models.Address) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	// The first address is the default whatever was asked, and a new default
+	// replaces the old one
+	if r.newest(address.UserID, 0) == nil {
+		address.IsDefault = true
+	}
+	if address.IsDefault {
+		r.clearDefault(address.UserID)
+	}
+
+	created := *address
+	created.ID = r.s.nextID()
+	created.CreatedAt, created.UpdatedAt = time.Now(), time.Now()
+	r.s.addresses = append(r.s.addresses, &created)
+	return created.ID, nil
+}
+
+func (r *addresses) Update(address *models.Address) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	current := r.find(address.UserID, address.ID)
+	if current == nil {
+		return repository.ErrNotFound
+	}
+
+	if address.IsDefault && !current.IsDefault {
+		// A new default replaces the old one
+		r.clearDefault(address.UserID)
+	} else if !address.IsDefault && current.IsDefault {
+		// The default moves to the newest other address, the only address stays the default
+		if other := r.newest(address.UserID, address.ID); other != nil {
+			other.IsDefault = true
+		} else {
+			address.IsDefault = true
+		}
+	}
+
+	updated := *address
+	updated.CreatedAt, updated.UpdatedAt = current.CreatedAt, time.Now()
+	*current = updated
+	return nil
+}
+
+func (r *addresses) Delete(userID, addressID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	address := r.find(userID, addressID)
+	if address == nil {
+		return repository.ErrNotFound
+	}
+	r.s.addresses = retain(r.s.addresses, func(a *models.Address) bool { return a.ID != addressID })
+
+	// The default moves to the newest remaining address, if any
+	if other := r.newest(userID, 0); address.IsDefault && other != nil {
+		other.IsDefault = true
+	}
+	return nil
+}
+
+// find returns an address of a user, nil when there is none. The caller holds the lock.
+func (r *addresses) find(userID, addressID int64) *models.Address {
+	for _, address := range r.s.addresses {
+		if address.ID == addressID && address.UserID == userID {
+			return address
+		}
+	}
+	return nil
+}
+
+// newest returns the newest address of a user other than exceptID, nil when
+// there is none. The caller holds the lock.
+func (r *addresses) newest(userID, exceptID int64) *models.Address {
+	for _, address := range slices.Backward(r.s.addresses) {
+		if address.UserID == userID && address.ID != exceptID {
+			return address
+		}
+	}
+	return nil
+}
+
+// clearDefault unsets the default address of a user. The caller holds the lock.
+func (r *addresses) clearDefault(userID int64) {
+	for _, address := range r.s.addresses {
+		if address.UserID == userID {
+			address.IsDefault = false
+		}
+	}
+}
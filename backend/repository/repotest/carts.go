@@ -0,0 +1,152 @@
+package repotest
+
+import (
+	"backend/models"
+	"backend/pricing"
+	"backend/repository"
+	"slices"
+	"time"
+)
+
+// carts is the in-memory CartRepository
+type carts struct {
+	s *Store
+}
+
+func (r *carts) Count(userID int64) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	count := 0
+	for _, item := range r.s.cart {
+		if item.UserID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *carts) Items(userID int64, exchange *pricing.Exchange) ([]models.CartItemResponse, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var items []models.CartItemResponse
+	for _, line := range slices.Backward(r.s.cart) {
+		if line.UserID != userID {
+			continue
+		}
+
+		// Lines of variants that no longer exist are left out
+		p := r.s.product(line.ProductID)
+		if p == nil {
+			continue
+		}
+		color := slices.IndexFunc(p.colors, func(c models.ProductColor) bool { return c.ID == line.ColorID })
+		size := slices.IndexFunc(p.sizes, func(s models.ProductSize) bool { return s.ID == line.SizeID })
+		if color < 0 || size < 0 {
+			continue
+		}
+
+		item := models.CartItemResponse{
+			ID:                 line.ID,
+			ProductID:          p.ID,
+			ProductName:        p.Name,
+			ProductDescription: p.Description,
+			DiscountPercentage: p.DiscountPercentage,
+			ColorID:            line.ColorID,
+			ColorName:          p.colors[color].ColorName,
+			ColorHex:           p.colors[color].ColorHex,
+			SizeID:             line.SizeID,
+			SizeName:           p.sizes[size].SizeName,
+			Quantity:           line.Quantity,
+			InStock:            p.quantity(line.ColorID, line.SizeID),
+		}
+		if imageURL := p.primaryImage(); imageURL != nil {
+			item.ImageURL = *imageURL
+		}
+		item.BasePrice, item.FinalPrice = p.unitPrice(exchange)
+		item.SubTotal = item.FinalPrice.Mul(item.Quantity)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (r *carts) Find(userID, itemID int64) (*models.CartItem, error) {
+	return r.find(func(item *models.CartItem) bool {
+		return item.ID == itemID && item.UserID == userID
+	})
+}
+
+func (r *carts) FindVariant(userID, productID, colorID, sizeID int64) (*models.CartItem, error) {
+	return r.find(func(item *models.CartItem) bool {
+		return item.UserID == userID && item.ProductID == productID && item.ColorID == colorID && item.SizeID == sizeID
+	})
+}
+
+func (r *carts) Add(item *models.CartItem) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	line := *item
+	line.ID = r.s.nextID()
+	line.CreatedAt, line.UpdatedAt = time.Now(), time.Now()
+	r.s.cart = append(r.s.cart, &line)
+	return line.ID, nil
+}
+
+func (r *carts) SetQuantity(userID, itemID int64, quantity int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, item := range r.s.cart {
+		if item.ID == itemID && item.UserID == userID {
+			item.Quantity, item.UpdatedAt = quantity, time.Now()
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+func (r *carts) Remove(userID, itemID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	count := len(r.s.cart)
+	r.s.cart = retain(r.s.cart, func(item *models.CartItem) bool { return item.ID != itemID || item.UserID != userID })
+	if len(r.s.cart) == count {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *carts) Clear(userID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.cart = retain(r.s.cart, func(item *models.CartItem) bool { return item.UserID != userID })
+	return nil
+}
+
+// ReleaseHolds does nothing, no stock is held here
+func (r *carts) ReleaseHolds(userID int64) error {
+	return nil
+}
+
+// HoldsExpireAt always returns nil, no stock is held here
+func (r *carts) HoldsExpireAt(userID int64) (*time.Time, error) {
+	return nil, nil
+}
+
+// find returns a copy of the first cart line that match returns true for
+func (r *carts) find(match func(item *models.CartItem) bool) (*models.CartItem, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, item := range r.s.cart {
+		if match(item) {
+			found := *item
+			return &found, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
@@ -0,0 +1,63 @@
+package repotest
+
+import (
+	"backend/models"
+	"backend/repository"
+	"slices"
+)
+
+// order is an order with its items
+type order struct {
+	models.Order
+	items []models.OrderItemResponse
+}
+
+// orders is the in-memory OrderRepository
+type orders struct {
+	s *Store
+}
+
+func (r *orders) List(userID int64, limit, offset int) ([]models.OrderWithUser, int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	// Orders of users that do not exist are left out
+	var orders []models.OrderWithUser
+	for _, o := range slices.Backward(r.s.orders) {
+		user := r.s.user(o.UserID)
+		if user == nil || (userID != 0 && o.UserID != userID) {
+			continue
+		}
+		orders = append(orders, models.OrderWithUser{Order: o.Order, UserName: user.Name, UserEmail: user.Email})
+	}
+
+	total := len(orders)
+	start := min(offset, total)
+	end := min(start+limit, total)
+	return orders[start:end], total, nil
+}
+
+func (r *orders) Find(orderID int64) (*models.Order, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, o := range r.s.orders {
+		if o.ID == orderID {
+			found := o.Order
+			return &found, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *orders) Items(orderID int64) ([]models.OrderItemResponse, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, o := range r.s.orders {
+		if o.ID == orderID {
+			return slices.Clone(o.items), nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,488 @@
+package repotest
+
+import (
+	"backend/models"
+	"backend/money"
+	"backend/pricing"
+	"backend/repository"
+	"backend/utils"
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// productRow is a product with its variants, images and prices
+type productRow struct {
+	models.Product
+	images   []models.ProductImage
+	colors   []models.ProductColor
+	sizes    []models.ProductSize
+	stock    []models.ProductInventory
+	prices   map[money.Currency]models.ProductPrice
+	oldSlugs []string
+}
+
+// unitPrice returns the base price and unit price of a product in the exchange currency
+func (p *productRow) unitPrice(exchange *pricing.Exchange) (money.Amount, money.Amount) {
+	var override *money.Amount
+	if price, ok := p.prices[exchange.Currency]; ok {
+		override = &price.BasePrice
+	}
+	return exchange.Price(p.BasePrice, override, p.DiscountPercentage)
+}
+
+// primaryImage returns the URL of the primary image, nil when there is none
+func (p *productRow) primaryImage() *string {
+	for _, image := range p.images {
+		if image.IsPrimary {
+			return &image.ImageURL
+		}
+	}
+	return nil
+}
+
+// quantity returns the stock of a variant
+func (p *productRow) quantity(colorID, sizeID int64) int {
+	for _, row := range p.stock {
+		if row.ColorID == colorID && row.SizeID == sizeID {
+			return row.Quantity
+		}
+	}
+	return 0
+}
+
+// products is the in-memory ProductRepository
+type products struct {
+	s *Store
+}
+
+func (r *products) Exists(productID int64) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.product(productID) != nil, nil
+}
+
+func (r *products) HasColor(productID, colorID int64) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p := r.s.product(productID)
+	return p != nil && slices.ContainsFunc(p.colors, func(c models.ProductColor) bool { return c.ID == colorID }), nil
+}
+
+func (r *products) HasSize(productID, sizeID int64) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p := r.s.product(productID)
+	return p != nil && slices.ContainsFunc(p.sizes, func(s models.ProductSize) bool { return s.ID == sizeID }), nil
+}
+
+func (r *products) PrimaryImage(productID int64) (*string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p := r.s.product(productID)
+	if p == nil {
+		return nil, repository.ErrNotFound
+	}
+	return p.primaryImage(), nil
+}
+
+func (r *products) Available(userID, productID, colorID, sizeID int64) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if p := r.s.product(productID); p != nil {
+		return p.quantity(colorID, sizeID), nil
+	}
+	return 0, nil
+}
+
+func (r *products) List(filter repository.ProductFilter) ([]models.ProductListItem, int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	items := []models.ProductListItem{}
+	for _, p := range r.s.products {
+		if !r.matches(p, filter) {
+			continue
+		}
+		item := models.ProductListItem{
+			ID:                 p.ID,
+			Name:               p.Name,
+			Slug:               p.Slug,
+			Description:        p.Description,
+			CategoryID:         p.CategoryID,
+			CategoryName:       r.categoryName(p.CategoryID),
+			DiscountPercentage: p.DiscountPercentage,
+			Currency:           filter.Exchange.Currency,
+			Featured:           p.Featured,
+			PrimaryImage:       p.primaryImage(),
+			CreatedAt:          p.CreatedAt,
+			UpdatedAt:          p.UpdatedAt,
+		}
+		item.BasePrice, item.FinalPrice = p.unitPrice(filter.Exchange)
+		rating := r.s.rating(p.ID)
+		item.AverageRating, item.ReviewCount = rating.AverageRating, rating.ReviewCount
+		items = append(items, item)
+	}
+
+	slices.SortStableFunc(items, func(a, b models.ProductListItem) int {
+		switch filter.Sort {
+		case "oldest":
+			return cmp.Compare(a.ID, b.ID)
+		case "discount":
+			if a.DiscountPercentage != b.DiscountPercentage {
+				return cmp.Compare(b.DiscountPercentage, a.DiscountPercentage)
+			}
+		case "rating":
+			if a.AverageRating != b.AverageRating {
+				return cmp.Compare(b.AverageRating, a.AverageRating)
+			}
+			if a.ReviewCount != b.ReviewCount {
+				return cmp.Compare(b.ReviewCount, a.ReviewCount)
+			}
+		case "price_asc":
+			if a.FinalPrice != b.FinalPrice {
+				return cmp.Compare(a.FinalPrice, b.FinalPrice)
+			}
+		case "price_desc":
+			if a.FinalPrice != b.FinalPrice {
+				return cmp.Compare(b.FinalPrice, a.FinalPrice)
+			}
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	total := len(items)
+	start := min(filter.Offset, total)
+	end := min(start+filter.Limit, total)
+	return items[start:end], total, nil
+}
+
+func (r *products) Find(productID int64, exchange *pricing.Exchange) (*models.ProductResponse, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p := r.s.product(productID)
+	if p == nil {
+		return nil, repository.ErrNotFound
+	}
+	product := &models.ProductResponse{
+		ID:                 p.ID,
+		Name:               p.Name,
+		Slug:               p.Slug,
+		Description:        p.Description,
+		CategoryID:         p.CategoryID,
+		CategoryName:       r.categoryName(p.CategoryID),
+		DiscountPercentage: p.DiscountPercentage,
+		Currency:           exchange.Currency,
+		Featured:           p.Featured,
+		Weight:             p.Weight,
+		Length:             p.Length,
+		Width:              p.Width,
+		Height:             p.Height,
+		Images:             slices.Clone(p.images),
+		Colors:             slices.Clone(p.colors),
+		Sizes:              slices.Clone(p.sizes),
+		CreatedAt:          p.CreatedAt,
+		UpdatedAt:          p.UpdatedAt,
+	}
+	product.BasePrice, product.FinalPrice = p.unitPrice(exchange)
+	rating := r.s.rating(p.ID)
+	product.AverageRating, product.ReviewCount = rating.AverageRating, rating.ReviewCount
+	for _, row := range p.stock {
+		product.Inventory = append(product.Inventory, models.InventoryItem{ColorID: row.ColorID, SizeID: row.SizeID, Quantity: row.Quantity})
+	}
+	return product, nil
+}
+
+func (r *products) Resolve(slug string) (int64, string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	// Look up the current slug first, then the old ones
+	for _, p := range r.s.products {
+		if p.Slug == slug {
+			return p.ID, slug, nil
+		}
+	}
+	for _, p := range r.s.products {
+		if slices.Contains(p.oldSlugs, slug) {
+			return p.ID, p.Slug, nil
+		}
+	}
+	return 0, "", repository.ErrNotFound
+}
+
+func (r *products) Create(product *models.Product) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p := &productRow{Product: *product, prices: map[money.Currency]models.ProductPrice{}}
+	p.ID = r.s.nextID()
+	p.Slug = r.uniqueSlug(p.Name, p.ID)
+	p.CreatedAt, p.UpdatedAt = time.Now(), time.Now()
+	r.s.products = append(r.s.products, p)
+
+	product.ID, product.Slug = p.ID, p.Slug
+	return p.ID, nil
+}
+
+func (r *products) Update(product *models.Product) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p := r.s.product(product.ID)
+	if p == nil {
+		return repository.ErrNotFound
+	}
+
+	// Renaming the product gives it a new slug and keeps the old one resolvable
+	slug := p.Slug
+	if product.Name != p.Name {
+		slug = r.uniqueSlug(product.Name, p.ID)
+		p.oldSlugs = append(retain(p.oldSlugs, func(old string) bool { return old != slug }), p.Slug)
+	}
+
+	createdAt := p.CreatedAt
+	p.Product = *product
+	p.Slug, p.CreatedAt, p.UpdatedAt = slug, createdAt, time.Now()
+	product.Slug = slug
+	return nil
+}
+
+func (r *products) Delete(productID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if r.s.product(productID) == nil {
+		return repository.ErrNotFound
+	}
+	r.s.products = retain(r.s.products, func(p *productRow) bool { return p.ID != productID })
+
+	// Its reviews and wishlist items go with it
+	r.s.reviews = retain(r.s.reviews, func(review *models.Review) bool { return review.ProductID != productID })
+	r.s.wishlist = retain(r.s.wishlist, func(item *models.WishlistItem) bool { return item.ProductID != productID })
+	return nil
+}
+
+func (r *products) AddColor(productID int64, name, hex string) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p := r.s.product(productID)
+	if p == nil {
+		return 0, repository.ErrNotFound
+	}
+	color := models.ProductColor{ID: r.s.nextID(), ProductID: productID, ColorName: name, ColorHex: hex, CreatedAt: time.Now()}
+	p.colors = append(p.colors, color)
+	return color.ID, nil
+}
+
+func (r *products) AddSize(productID int64, name string) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p := r.s.product(productID)
+	if p == nil {
+		return 0, repository.ErrNotFound
+	}
+	size := models.ProductSize{ID: r.s.nextID(), ProductID: productID, SizeName: name, CreatedAt: time.Now()}
+	p.sizes = append(p.sizes, size)
+	return size.ID, nil
+}
+
+func (r *products) AddImage(productID int64, imageURL string, primary bool) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p := r.s.product(productID)
+	if p == nil {
+		return 0, repository.ErrNotFound
+	}
+
+	// A new primary image replaces the old one
+	if primary {
+		for i := range p.images {
+			p.images[i].IsPrimary = false
+		}
+	}
+	image := models.ProductImage{ID: r.s.nextID(), ProductID: productID, ImageURL: imageURL, IsPrimary: primary, CreatedAt: time.Now()}
+	p.images = append(p.images, image)
+	return image.ID, nil
+}
+
+func (r *products) SetStock(productID, colorID, sizeID int64, quantity int) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p := r.s.product(productID)
+	if p == nil {
+		return 0, repository.ErrNotFound
+	}
+	for i, row := range p.stock {
+		if row.ColorID == colorID && row.SizeID == sizeID {
+			p.stock[i].Quantity, p.stock[i].UpdatedAt = quantity, time.Now()
+			return row.ID, nil
+		}
+	}
+	row := models.ProductInventory{ID: r.s.nextID(), ProductID: productID, ColorID: colorID, SizeID: sizeID, Quantity: quantity, UpdatedAt: time.Now()}
+	p.stock = append(p.stock, row)
+	return row.ID, nil
+}
+
+func (r *products) DeleteColor(productID, colorID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p := r.s.product(productID)
+	if p == nil || !slices.ContainsFunc(p.colors, func(c models.ProductColor) bool { return c.ID == colorID }) {
+		return repository.ErrNotFound
+	}
+
+	// The variants of the color go with it
+	p.colors = retain(p.colors, func(c models.ProductColor) bool { return c.ID != colorID })
+	p.stock = retain(p.stock, func(row models.ProductInventory) bool { return row.ColorID != colorID })
+	return nil
+}
+
+func (r *products) DeleteSize(productID, sizeID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p := r.s.product(productID)
+	if p == nil || !slices.ContainsFunc(p.sizes, func(s models.ProductSize) bool { return s.ID == sizeID }) {
+		return repository.ErrNotFound
+	}
+
+	// The variants of the size go with it
+	p.sizes = retain(p.sizes, func(s models.ProductSize) bool { return s.ID != sizeID })
+	p.stock = retain(p.stock, func(row models.ProductInventory) bool { return row.SizeID != sizeID })
+	return nil
+}
+
+func (r *products) DeleteImage(productID, imageID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p := r.s.product(productID)
+	if p == nil {
+		return repository.ErrNotFound
+	}
+	i := slices.IndexFunc(p.images, func(image models.ProductImage) bool { return image.ID == imageID })
+	if i < 0 {
+		return repository.ErrNotFound
+	}
+	primary := p.images[i].IsPrimary
+	p.images = slices.Delete(p.images, i, i+1)
+
+	// The oldest image left takes over from a deleted primary image
+	if primary && len(p.images) > 0 {
+		p.images[0].IsPrimary = true
+	}
+	return nil
+}
+
+func (r *products) Prices(productID int64) ([]models.ProductPrice, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	prices := []models.ProductPrice{}
+	if p := r.s.product(productID); p != nil {
+		for _, price := range p.prices {
+			prices = append(prices, price)
+		}
+	}
+	slices.SortFunc(prices, func(a, b models.ProductPrice) int { return strings.Compare(string(a.Currency), string(b.Currency)) })
+	return prices, nil
+}
+
+func (r *products) SetPrice(productID int64, currency money.Currency, basePrice money.Amount) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p := r.s.product(productID)
+	if p == nil {
+		return repository.ErrNotFound
+	}
+	p.prices[currency] = models.ProductPrice{Currency: currency, BasePrice: basePrice, UpdatedAt: time.Now()}
+	return nil
+}
+
+func (r *products) DeletePrice(productID int64, currency money.Currency) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p := r.s.product(productID)
+	if p == nil {
+		return repository.ErrNotFound
+	}
+	if _, ok := p.prices[currency]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(p.prices, currency)
+	return nil
+}
+
+// matches reports whether a product passes a filter. The caller holds the lock.
+func (r *products) matches(p *productRow, filter repository.ProductFilter) bool {
+	if filter.CategoryID != 0 && p.CategoryID != filter.CategoryID {
+		return false
+	}
+	_, unitPrice := p.unitPrice(filter.Exchange)
+	if (filter.MinPrice != nil && unitPrice < *filter.MinPrice) || (filter.MaxPrice != nil && unitPrice > *filter.MaxPrice) {
+		return false
+	}
+
+	// Colors and sizes match any of their names, ignoring case
+	if len(filter.Colors) > 0 && !slices.ContainsFunc(p.colors, func(c models.ProductColor) bool {
+		return slices.ContainsFunc(filter.Colors, func(name string) bool { return strings.EqualFold(name, c.ColorName) })
+	}) {
+		return false
+	}
+	if len(filter.Sizes) > 0 && !slices.ContainsFunc(p.sizes, func(s models.ProductSize) bool {
+		return slices.ContainsFunc(filter.Sizes, func(name string) bool { return strings.EqualFold(name, s.SizeName) })
+	}) {
+		return false
+	}
+
+	if filter.Featured && !p.Featured {
+		return false
+	}
+	if filter.InStock && !slices.ContainsFunc(p.stock, func(row models.ProductInventory) bool { return row.Quantity > 0 }) {
+		return false
+	}
+	if filter.OnSale && p.DiscountPercentage <= 0 {
+		return false
+	}
+
+	if filter.Query != "" {
+		query := strings.ToLower(filter.Query)
+		return strings.Contains(strings.ToLower(p.Name), query) ||
+			strings.Contains(strings.ToLower(p.Description), query) ||
+			strings.Contains(strings.ToLower(r.categoryName(p.CategoryID)), query)
+	}
+	return true
+}
+
+// categoryName returns the name of a product's category. The caller holds the lock.
+func (r *products) categoryName(categoryID int64) string {
+	if name, ok := r.s.categories[categoryID]; ok {
+		return name
+	}
+	return "Uncategorized"
+}
+
+// uniqueSlug builds a slug from name that no other product uses or used,
+// adding a numeric suffix on collision. The caller holds the lock.
+func (r *products) uniqueSlug(name string, productID int64) string {
+	slug, _ := utils.UniqueSlug(name, "products", func(slug string) (bool, error) {
+		return slices.ContainsFunc(r.s.products, func(p *productRow) bool {
+			return p.ID != productID && (p.Slug == slug || slices.Contains(p.oldSlugs, slug))
+		}), nil
+	})
+	return slug
+}
@@ -0,0 +1,127 @@
+// Package repotest implements the repositories in memory, for testing handlers
+// without a database. Stock is never held for a checkout, otherwise the
+// repositories behave like the SQL ones.
+package repotest
+
+import (
+	"backend/models"
+	"backend/repository"
+	"sync"
+	"time"
+)
+
+// Store holds the rows of the in-memory repositories. Its methods add the
+// rows that the repositories only read.
+type Store struct {
+	mu     sync.Mutex
+	lastID int64
+
+	categories map[int64]string
+	products   []*productRow
+	cart       []*models.CartItem
+	orders     []*order
+	users      []*models.User
+	addresses  []*models.Address
+	reviews    []*models.Review
+	wishlist   []*models.WishlistItem
+}
+
+// New returns an empty store
+func New() *Store {
+	return &Store{categories: map[int64]string{}}
+}
+
+// Repositories returns repositories reading and writing the store
+func (s *Store) Repositories() repository.Repositories {
+	return repository.Repositories{
+		Products:  &products{s},
+		Carts:     &carts{s},
+		Orders:    &orders{s},
+		Users:     &users{s},
+		Addresses: &addresses{s},
+		Reviews:   &reviews{s},
+		Wishlist:  &wishlist{s},
+	}
+}
+
+// AddCategory adds a category for products to belong to and returns its ID
+func (s *Store) AddCategory(name string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	categoryID := s.nextID()
+	s.categories[categoryID] = name
+	return categoryID
+}
+
+// AddOrder adds an order with its items and returns its ID. The IDs of the
+// order and the items are set to match.
+func (s *Store) AddOrder(o models.Order, items ...models.OrderItemResponse) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o.ID = s.nextID()
+	if o.CreatedAt.IsZero() {
+		o.CreatedAt, o.UpdatedAt = time.Now(), time.Now()
+	}
+	for i := range items {
+		items[i].ID = s.nextID()
+		items[i].SubTotal = items[i].PricePerUnit.Mul(items[i].Quantity)
+	}
+	s.orders = append(s.orders, &order{Order: o, items: items})
+	return o.ID
+}
+
+// nextID returns an ID no row of the store uses. The caller holds the lock.
+func (s *Store) nextID() int64 {
+	s.lastID++
+	return s.lastID
+}
+
+// user returns the account with an ID, nil when there is none. The caller holds the lock.
+func (s *Store) user(userID int64) *models.User {
+	for _, user := range s.users {
+		if user.ID == userID {
+			return user
+		}
+	}
+	return nil
+}
+
+// product returns the product with an ID, nil when there is none. The caller holds the lock.
+func (s *Store) product(productID int64) *productRow {
+	for _, p := range s.products {
+		if p.ID == productID {
+			return p
+		}
+	}
+	return nil
+}
+
+// rating returns the unrounded average rating of a product and its review
+// count. The caller holds the lock.
+func (s *Store) rating(productID int64) models.RatingSummary {
+	var summary models.RatingSummary
+	total := 0
+	for _, review := range s.reviews {
+		if review.ProductID == productID {
+			total += review.Rating
+			summary.ReviewCount++
+		}
+	}
+	if summary.ReviewCount > 0 {
+		summary.AverageRating = float64(total) / float64(summary.ReviewCount)
+	}
+	return summary
+}
+
+// retain keeps the elements of a slice that keep returns true for
+func retain[T any](items []T, keep func(T) bool) []T {
+	kept := items[:0]
+	for _, item := range items {
+		if keep(item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
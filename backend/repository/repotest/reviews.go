@@ -0,0 +1,149 @@
+package repotest
+
+import (
+	"backend/models"
+	"backend/repository"
+	"cmp"
+	"errors"
+	"slices"
+	"time"
+)
+
+// reviews is the in-memory ReviewRepository
+type reviews struct {
+	s *Store
+}
+
+func (r *reviews) Received(userID, productID int64) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, o := range r.s.orders {
+		if o.UserID != userID || o.OrderStatus != "delivered" {
+			continue
+		}
+		if slices.ContainsFunc(o.items, func(item models.OrderItemResponse) bool { return item.ProductID == productID }) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *reviews) Reviewed(userID, productID int64) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.find(func(review *models.Review) bool {
+		return review.UserID == userID && review.ProductID == productID
+	}) != nil, nil
+}
+
+func (r *reviews) List(productID int64, sort string, limit, offset int) ([]models.ReviewResponse, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	// Reviews of users that do not exist are left out
+	reviews := []models.ReviewResponse{}
+	for _, review := range slices.Backward(r.s.reviews) {
+		user := r.s.user(review.UserID)
+		if user == nil || review.ProductID != productID {
+			continue
+		}
+		reviews = append(reviews, models.ReviewResponse{
+			ID:        review.ID,
+			ProductID: review.ProductID,
+			UserID:    review.UserID,
+			UserName:  user.Name,
+			Rating:    review.Rating,
+			Comment:   review.Comment,
+			CreatedAt: review.CreatedAt,
+			UpdatedAt: review.UpdatedAt,
+		})
+	}
+
+	// Newest first, which the reviews already are, within the ratings asked for
+	slices.SortStableFunc(reviews, func(a, b models.ReviewResponse) int {
+		switch sort {
+		case "highest":
+			return cmp.Compare(b.Rating, a.Rating)
+		case "lowest":
+			return cmp.Compare(a.Rating, b.Rating)
+		}
+		return 0
+	})
+
+	start := min(offset, len(reviews))
+	end := min(start+limit, len(reviews))
+	return reviews[start:end], nil
+}
+
+func (r *reviews) Summary(productID int64) (models.RatingSummary, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.rating(productID), nil
+}
+
+func (r *reviews) Create(review *models.Review) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	// A user reviews a product once, as in the reviews table
+	if r.find(func(other *models.Review) bool {
+		return other.UserID == review.UserID && other.ProductID == review.ProductID
+	}) != nil {
+		return 0, errors.New("product already reviewed")
+	}
+
+	created := *review
+	created.ID = r.s.nextID()
+	created.CreatedAt, created.UpdatedAt = time.Now(), time.Now()
+	r.s.reviews = append(r.s.reviews, &created)
+	return created.ID, nil
+}
+
+func (r *reviews) Update(review *models.Review) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	current := r.find(func(other *models.Review) bool {
+		return other.ID == review.ID && other.ProductID == review.ProductID && other.UserID == review.UserID
+	})
+	if current == nil {
+		return repository.ErrNotFound
+	}
+	current.Rating, current.Comment, current.UpdatedAt = review.Rating, review.Comment, time.Now()
+	return nil
+}
+
+func (r *reviews) Author(productID, reviewID int64) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	review := r.find(func(review *models.Review) bool { return review.ID == reviewID && review.ProductID == productID })
+	if review == nil {
+		return 0, repository.ErrNotFound
+	}
+	return review.UserID, nil
+}
+
+func (r *reviews) Delete(reviewID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	count := len(r.s.reviews)
+	r.s.reviews = retain(r.s.reviews, func(review *models.Review) bool { return review.ID != reviewID })
+	if len(r.s.reviews) == count {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// find returns the first review that match returns true for, nil when there
+// is none. The caller holds the lock.
+func (r *reviews) find(match func(review *models.Review) bool) *models.Review {
+	for _, review := range r.s.reviews {
+		if match(review) {
+			return review
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package repotest
+
+import (
+	"backend/models"
+	"backend/repository"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// users is the in-memory UserRepository
+type users struct {
+	s *Store
+}
+
+func (r *users) EmailExists(email string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.byEmail(email) != nil, nil
+}
+
+func (r *users) Create(user *models.User) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	// Emails are unique, as in the users table
+	if r.byEmail(user.Email) != nil {
+		return 0, errors.New("email already in use")
+	}
+
+	created := models.User{Name: user.Name, Email: user.Email, Password: user.Password, Role: user.Role}
+	created.ID = r.s.nextID()
+	created.CreatedAt, created.UpdatedAt = time.Now(), time.Now()
+	r.s.users = append(r.s.users, &created)
+	return created.ID, nil
+}
+
+func (r *users) FindByEmail(email string) (*models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return found(r.byEmail(email))
+}
+
+func (r *users) FindByID(userID int64) (*models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return found(r.s.user(userID))
+}
+
+func (r *users) Search(query string, limit, offset int) ([]models.User, int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	query = strings.ToLower(strings.TrimSpace(query))
+	users := []models.User{}
+	for _, user := range slices.Backward(r.s.users) {
+		if strings.Contains(strings.ToLower(user.Name), query) || strings.Contains(strings.ToLower(user.Email), query) {
+			users = append(users, *user)
+		}
+	}
+
+	total := len(users)
+	start := min(offset, total)
+	end := min(start+limit, total)
+	return users[start:end], total, nil
+}
+
+func (r *users) SetPassword(userID int64, hash string) error {
+	return r.update(userID, func(user *models.User) {
+		user.Password, user.PasswordResetRequired = hash, false
+	})
+}
+
+func (r *users) MarkEmailVerified(userID int64, email string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user := r.s.user(userID)
+	if user == nil || user.Email != email {
+		return repository.ErrNotFound
+	}
+	now := time.Now()
+	user.EmailVerifiedAt, user.UpdatedAt = &now, now
+	return nil
+}
+
+func (r *users) UpdateProfile(userID int64, name, email string) error {
+	return r.update(userID, func(user *models.User) {
+		// A new email is unverified
+		if email != user.Email {
+			user.EmailVerifiedAt = nil
+		}
+		user.Name, user.Email = name, email
+	})
+}
+
+func (r *users) Anonymise(userID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user := r.s.user(userID)
+	if user == nil || user.DeletedAt != nil {
+		return repository.ErrNotFound
+	}
+
+	// Orders still being fulfilled need their delivery address
+	for _, o := range r.s.orders {
+		if o.UserID == userID && o.OrderStatus != "delivered" && o.OrderStatus != "cancelled" {
+			return repository.ErrOpenOrders
+		}
+	}
+
+	// Addresses that orders were shipped to keep only the region
+	shippedTo := map[int64]bool{}
+	for _, o := range r.s.orders {
+		if o.UserID == userID {
+			shippedTo[o.AddressID] = true
+		}
+	}
+	r.s.addresses = retain(r.s.addresses, func(address *models.Address) bool {
+		return address.UserID != userID || shippedTo[address.ID]
+	})
+	for _, address := range r.s.addresses {
+		if address.UserID == userID {
+			address.Name, address.Street, address.PostalCode, address.Phone = "", "", "", ""
+		}
+	}
+	r.s.cart = retain(r.s.cart, func(item *models.CartItem) bool { return item.UserID != userID })
+	r.s.wishlist = retain(r.s.wishlist, func(item *models.WishlistItem) bool { return item.UserID != userID })
+
+	// The account keeps its ID, with an email that frees the old one and a
+	// password that never matches
+	now := time.Now()
+	*user = models.User{
+		ID:         user.ID,
+		Name:       "Deleted user",
+		Email:      fmt.Sprintf("deleted-%d@deleted.invalid", userID),
+		Role:       "customer",
+		CreatedAt:  user.CreatedAt,
+		UpdatedAt:  now,
+		DisabledAt: &now,
+		DeletedAt:  &now,
+	}
+	return nil
+}
+
+func (r *users) SetRole(userID int64, role string) error {
+	return r.update(userID, func(user *models.User) {
+		user.Role = role
+	})
+}
+
+func (r *users) SetDisabled(userID int64, disabled bool) error {
+	return r.update(userID, func(user *models.User) {
+		if !disabled {
+			user.DisabledAt = nil
+		} else if user.DisabledAt == nil {
+			now := time.Now()
+			user.DisabledAt = &now
+		}
+	})
+}
+
+func (r *users) RequirePasswordReset(userID int64) error {
+	return r.update(userID, func(user *models.User) {
+		user.PasswordResetRequired = true
+	})
+}
+
+// update changes an account with change, ErrNotFound when there is none
+func (r *users) update(userID int64, change func(user *models.User)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user := r.s.user(userID)
+	if user == nil {
+		return repository.ErrNotFound
+	}
+	change(user)
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+// byEmail returns the account with an email address, nil when there is none.
+// The caller holds the lock.
+func (r *users) byEmail(email string) *models.User {
+	for _, user := range r.s.users {
+		if user.Email == email {
+			return user
+		}
+	}
+	return nil
+}
+
+// found returns a copy of a row, ErrNotFound when it is nil
+func found[T any](row *T) (*T, error) {
+	if row == nil {
+		return nil, repository.ErrNotFound
+	}
+	copied := *row
+	return &copied, nil
+}
@@ -0,0 +1,91 @@
+package repotest
+
+import (
+	"backend/models"
+	"backend/pricing"
+	"backend/repository"
+	"errors"
+	"slices"
+	"time"
+)
+
+// wishlist is the in-memory WishlistRepository
+type wishlist struct {
+	s *Store
+}
+
+func (r *wishlist) Items(userID int64, exchange *pricing.Exchange) ([]models.WishlistItemResponse, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var items []models.WishlistItemResponse
+	for _, line := range slices.Backward(r.s.wishlist) {
+		p := r.s.product(line.ProductID)
+		if line.UserID != userID || p == nil {
+			continue
+		}
+
+		item := models.WishlistItemResponse{
+			ID:                 line.ID,
+			ProductID:          p.ID,
+			ProductName:        p.Name,
+			ProductDescription: p.Description,
+			DiscountPercentage: p.DiscountPercentage,
+			InStock:            slices.ContainsFunc(p.stock, func(row models.ProductInventory) bool { return row.Quantity > 0 }),
+			CreatedAt:          line.CreatedAt,
+		}
+		if imageURL := p.primaryImage(); imageURL != nil {
+			item.ImageURL = *imageURL
+		}
+		item.BasePrice, item.FinalPrice = p.unitPrice(exchange)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (r *wishlist) Contains(userID, productID int64) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.contains(userID, productID), nil
+}
+
+func (r *wishlist) Add(userID, productID int64) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	// A product is on a wishlist once, as in the wishlist table
+	if r.contains(userID, productID) {
+		return 0, errors.New("product already in wishlist")
+	}
+
+	item := &models.WishlistItem{ID: r.s.nextID(), UserID: userID, ProductID: productID, CreatedAt: time.Now()}
+	r.s.wishlist = append(r.s.wishlist, item)
+	return item.ID, nil
+}
+
+func (r *wishlist) Remove(userID, itemID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	count := len(r.s.wishlist)
+	r.s.wishlist = retain(r.s.wishlist, func(item *models.WishlistItem) bool { return item.ID != itemID || item.UserID != userID })
+	if len(r.s.wishlist) == count {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *wishlist) Clear(userID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.wishlist = retain(r.s.wishlist, func(item *models.WishlistItem) bool { return item.UserID != userID })
+	return nil
+}
+
+// contains reports whether a product is on a user's wishlist. The caller holds the lock.
+func (r *wishlist) contains(userID, productID int64) bool {
+	return slices.ContainsFunc(r.s.wishlist, func(item *models.WishlistItem) bool {
+		return item.UserID == userID && item.ProductID == productID
+	})
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"backend/models"
+	"database/sql"
+	"time"
+)
+
+// sqlReviews is the SQL ReviewRepository
+type sqlReviews struct {
+	db *sql.DB
+}
+
+// reviewSortOptions maps the sorts of ReviewRepository.List to an ORDER BY clause
+var reviewSortOptions = map[string]string{
+	"newest":  "r.created_at DESC, r.id DESC",
+	"highest": "r.rating DESC, r.created_at DESC",
+	"lowest":  "r.rating ASC, r.created_at DESC",
+}
+
+// IsReviewSort reports whether a sort is one ReviewRepository.List accepts
+func IsReviewSort(sort string) bool {
+	_, ok := reviewSortOptions[sort]
+	return ok
+}
+
+func (r *sqlReviews) Received(userID, productID int64) (bool, error) {
+	var received bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM order_items oi
+			JOIN orders o ON oi.order_id = o.id
+			WHERE o.user_id = ? AND oi.product_id = ? AND o.order_status = 'delivered'
+		)`,
+		userID, productID).Scan(&received)
+	return received, err
+}
+
+func (r *sqlReviews) Reviewed(userID, productID int64) (bool, error) {
+	var reviewed bool
+	err := r.db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM reviews WHERE user_id = ? AND product_id = ?)",
+		userID, productID).Scan(&reviewed)
+	return reviewed, err
+}
+
+func (r *sqlReviews) List(productID int64, sort string, limit, offset int) ([]models.ReviewResponse, error) {
+	orderBy, ok := reviewSortOptions[sort]
+	if !ok {
+		orderBy = reviewSortOptions["newest"]
+	}
+
+	rows, err := r.db.Query(`
+		SELECT r.id, r.product_id, r.user_id, u.name, r.rating, COALESCE(r.comment, ''), r.created_at, r.updated_at
+		FROM reviews r
+		JOIN users u ON r.user_id = u.id
+		WHERE r.product_id = ?
+		ORDER BY `+orderBy+`
+		LIMIT ? OFFSET ?`,
+		productID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []models.ReviewResponse{}
+	for rows.Next() {
+		var review models.ReviewResponse
+		err := rows.Scan(
+			&review.ID, &review.ProductID, &review.UserID, &review.UserName,
+			&review.Rating, &review.Comment, &review.CreatedAt, &review.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
+
+func (r *sqlReviews) Summary(productID int64) (models.RatingSummary, error) {
+	var summary models.RatingSummary
+	err := r.db.QueryRow(
+		"SELECT COALESCE(AVG(rating), 0), COUNT(*) FROM reviews WHERE product_id = ?",
+		productID).Scan(&summary.AverageRating, &summary.ReviewCount)
+	return summary, err
+}
+
+func (r *sqlReviews) Create(review *models.Review) (int64, error) {
+	var reviewID int64
+	err := r.db.QueryRow(
+		"INSERT INTO reviews (user_id, product_id, rating, comment, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING id",
+		review.UserID, review.ProductID, review.Rating, review.Comment, time.Now(), time.Now()).Scan(&reviewID)
+	return reviewID, err
+}
+
+func (r *sqlReviews) Update(review *models.Review) error {
+	return affectedOne(r.db.Exec(
+		"UPDATE reviews SET rating = ?, comment = ?, updated_at = ? WHERE id = ? AND product_id = ? AND user_id = ?",
+		review.Rating, review.Comment, time.Now(), review.ID, review.ProductID, review.UserID))
+}
+
+func (r *sqlReviews) Author(productID, reviewID int64) (int64, error) {
+	var userID int64
+	err := r.db.QueryRow(
+		"SELECT user_id FROM reviews WHERE id = ? AND product_id = ?",
+		reviewID, productID).Scan(&userID)
+	return userID, notFound(err)
+}
+
+func (r *sqlReviews) Delete(reviewID int64) error {
+	return affectedOne(r.db.Exec("DELETE FROM reviews WHERE id = ?", reviewID))
+}
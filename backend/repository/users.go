@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sqlUsers is the SQL UserRepository
+type sqlUsers struct {
+	db *sql.DB
+}
+
+const userColumns = `id, name, email, password, role, created_at, updated_at, email_verified_at, disabled_at, password_reset_required, deleted_at,
+	EXISTS(SELECT 1 FROM user_two_factor t WHERE t.user_id = users.id AND t.enabled_at IS NOT NULL)`
+
+func (r *sqlUsers) EmailExists(email string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)", email).Scan(&exists)
+	return exists, err
+}
+
+func (r *sqlUsers) Create(user *models.User) (int64, error) {
+	var userID int64
+	err := r.db.QueryRow(
+		"INSERT INTO users (name, email, password, role, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING id",
+		user.Name, user.Email, user.Password, user.Role, time.Now(), time.Now()).Scan(&userID)
+	return userID, err
+}
+
+func (r *sqlUsers) FindByEmail(email string) (*models.User, error) {
+	user, err := scanUser(r.db.QueryRow("SELECT "+userColumns+" FROM users WHERE email = ?", email))
+	return user, notFound(err)
+}
+
+func (r *sqlUsers) FindByID(userID int64) (*models.User, error) {
+	user, err := scanUser(r.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", userID))
+	return user, notFound(err)
+}
+
+func (r *sqlUsers) Search(query string, limit, offset int) ([]models.User, int, error) {
+	where := ""
+	var args []interface{}
+	if query = strings.TrimSpace(query); query != "" {
+		pattern := "%" + strings.ToLower(query) + "%"
+		where = " WHERE LOWER(name) LIKE ? OR LOWER(email) LIKE ?"
+		args = append(args, pattern, pattern)
+	}
+
+	// Count every match for pagination
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM users"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query("SELECT "+userColumns+" FROM users"+where+" ORDER BY id DESC LIMIT ? OFFSET ?",
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, *user)
+	}
+	return users, total, rows.Err()
+}
+
+func (r *sqlUsers) SetPassword(userID int64, hash string) error {
+	return affectedOne(r.db.Exec(
+		"UPDATE users SET password = ?, password_reset_required = FALSE, updated_at = ? WHERE id = ?",
+		hash, time.Now(), userID))
+}
+
+func (r *sqlUsers) MarkEmailVerified(userID int64, email string) error {
+	return affectedOne(r.db.Exec(
+		"UPDATE users SET email_verified_at = CURRENT_TIMESTAMP, updated_at = ? WHERE id = ? AND email = ?",
+		time.Now(), userID, email))
+}
+
+func (r *sqlUsers) SetRole(userID int64, role string) error {
+	return affectedOne(r.db.Exec("UPDATE users SET role = ?, updated_at = ? WHERE id = ?", role, time.Now(), userID))
+}
+
+func (r *sqlUsers) UpdateProfile(userID int64, name, email string) error {
+	return inTransaction(r.db, func(tx *sql.Tx) error {
+		// A new email is unverified, and links mailed to the old one stop working
+		err := affectedOne(tx.Exec(`
+			UPDATE users SET name = ?, email = ?,
+				email_verified_at = CASE WHEN email = ? THEN email_verified_at ELSE NULL END, updated_at = ?
+			WHERE id = ?`,
+			name, email, email, time.Now(), userID))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"UPDATE account_tokens SET used_at = CURRENT_TIMESTAMP WHERE user_id = ? AND email <> ? AND used_at IS NULL",
+			userID, email)
+		return err
+	})
+}
+
+func (r *sqlUsers) Anonymise(userID int64) error {
+	return inTransaction(r.db, func(tx *sql.Tx) error {
+		// Orders still being fulfilled need their delivery address
+		var open int
+		err := tx.QueryRow(
+			"SELECT COUNT(*) FROM orders WHERE user_id = ? AND order_status NOT IN ('delivered', 'cancelled')",
+			userID).Scan(&open)
+		if err != nil {
+			return err
+		}
+		if open > 0 {
+			return ErrOpenOrders
+		}
+
+		// Addresses that orders were shipped to keep only the region
+		_, err = tx.Exec(`
+			UPDATE addresses SET name = '', street = '', postal_code = '', phone = '', updated_at = ?
+			WHERE user_id = ? AND id IN (SELECT address_id FROM orders WHERE user_id = ?)`,
+			time.Now(), userID, userID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"DELETE FROM addresses WHERE user_id = ? AND id NOT IN (SELECT address_id FROM orders WHERE user_id = ?)",
+			userID, userID)
+		if err != nil {
+			return err
+		}
+
+		// Everything else the account owned goes
+		for _, table := range []string{"cart", "cart_coupons", "wishlist", "inventory_reservations", "account_tokens", "login_attempts",
+			"user_two_factor", "two_factor_recovery_codes"} {
+			if _, err := tx.Exec("DELETE FROM "+table+" WHERE user_id = ?", userID); err != nil {
+				return err
+			}
+		}
+
+		// The account keeps its ID for its orders, returns and reviews, with
+		// an email that frees the old one and a password that never matches
+		return affectedOne(tx.Exec(`
+			UPDATE users SET name = 'Deleted user', email = ?, password = '', role = 'customer',
+				email_verified_at = NULL, disabled_at = CURRENT_TIMESTAMP, deleted_at = CURRENT_TIMESTAMP, updated_at = ?
+			WHERE id = ? AND deleted_at IS NULL`,
+			fmt.Sprintf("deleted-%d@deleted.invalid", userID), time.Now(), userID))
+	})
+}
+
+func (r *sqlUsers) SetDisabled(userID int64, disabled bool) error {
+	if disabled {
+		return affectedOne(r.db.Exec(
+			"UPDATE users SET disabled_at = COALESCE(disabled_at, CURRENT_TIMESTAMP), updated_at = ? WHERE id = ?",
+			time.Now(), userID))
+	}
+	return affectedOne(r.db.Exec("UPDATE users SET disabled_at = NULL, updated_at = ? WHERE id = ?", time.Now(), userID))
+}
+
+func (r *sqlUsers) RequirePasswordReset(userID int64) error {
+	return affectedOne(r.db.Exec(
+		"UPDATE users SET password_reset_required = TRUE, updated_at = ? WHERE id = ?",
+		time.Now(), userID))
+}
+
+// scanUser reads a user selected with userColumns
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.EmailVerifiedAt, &user.DisabledAt, &user.PasswordResetRequired, &user.DeletedAt, &user.TwoFactorEnabled)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
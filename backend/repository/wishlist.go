@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"backend/inventory"
+	"backend/models"
+	"backend/money"
+	"backend/pricing"
+	"database/sql"
+	"time"
+)
+
+// sqlWishlist is the SQL WishlistRepository
+type sqlWishlist struct {
+	db *sql.DB
+}
+
+func (r *sqlWishlist) Items(userID int64, exchange *pricing.Exchange) ([]models.WishlistItemResponse, error) {
+	rows, err := r.db.Query(`
+		SELECT
+			w.id, w.product_id, w.created_at,
+			p.name, p.description, p.base_price, `+exchange.OverrideSQL()+`, p.discount_percentage,
+			COALESCE(`+PrimaryImageSQL+`, '') as image_url,
+			(SELECT COUNT(*) > 0 FROM product_inventory pi
+				JOIN product_colors pc ON pi.color_id = pc.id
+				JOIN product_sizes ps ON pi.size_id = ps.id
+				WHERE pi.product_id = p.id AND `+inventory.Available+` > 0) as in_stock
+		FROM wishlist w
+		JOIN products p ON w.product_id = p.id
+		WHERE w.user_id = ?
+		ORDER BY w.id DESC`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.WishlistItemResponse
+	for rows.Next() {
+		var item models.WishlistItemResponse
+		var basePrice money.Amount
+		var override *money.Amount
+
+		err := rows.Scan(
+			&item.ID, &item.ProductID, &item.CreatedAt,
+			&item.ProductName, &item.ProductDescription, &basePrice, &override, &item.DiscountPercentage,
+			&item.ImageURL, &item.InStock)
+		if err != nil {
+			return nil, err
+		}
+
+		// Price the item in the exchange currency
+		item.BasePrice, item.FinalPrice = exchange.Price(basePrice, override, item.DiscountPercentage)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *sqlWishlist) Contains(userID, productID int64) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM wishlist WHERE user_id = ? AND product_id = ?)",
+		userID, productID).Scan(&exists)
+	return exists, err
+}
+
+func (r *sqlWishlist) Add(userID, productID int64) (int64, error) {
+	var itemID int64
+	err := r.db.QueryRow(
+		"INSERT INTO wishlist (user_id, product_id, created_at) VALUES (?, ?, ?) RETURNING id",
+		userID, productID, time.Now()).Scan(&itemID)
+	return itemID, err
+}
+
+func (r *sqlWishlist) Remove(userID, itemID int64) error {
+	return affectedOne(r.db.Exec("DELETE FROM wishlist WHERE id = ? AND user_id = ?", itemID, userID))
+}
+
+func (r *sqlWishlist) Clear(userID int64) error {
+	_, err := r.db.Exec("DELETE FROM wishlist WHERE user_id = ?", userID)
+	return err
+}
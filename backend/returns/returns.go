@@ -0,0 +1,373 @@
+package returns
+
+import (
+	"backend/database"
+	"backend/inventory"
+	"backend/models"
+	"backend/money"
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Return statuses
+const (
+	StatusRequested = "requested"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusReceived  = "received"
+)
+
+// Refund states of a received return
+const (
+	RefundInProgress = "in_progress"
+	RefundFailed     = "failed"
+	RefundDone       = "refunded"
+)
+
+// defaultWindowDays is how long after delivery items can be returned
+const defaultWindowDays = 30
+
+// ErrNotFound is returned when a return does not exist
+var ErrNotFound = errors.New("return not found")
+
+// RuleError is a return request or review the return does not allow
+type RuleError string
+
+func (e RuleError) Error() string {
+	return string(e)
+}
+
+// Window returns how long after delivery returns are accepted, configured by RETURN_WINDOW_DAYS
+func Window() time.Duration {
+	days := defaultWindowDays
+	if value, err := strconv.Atoi(os.Getenv("RETURN_WINDOW_DAYS")); err == nil && value >= 0 {
+		days = value
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// DeliveredAt returns when an order was delivered according to its status
+// history, or when it was last updated for orders delivered before it was kept
+func DeliveredAt(q database.Querier, orderID int64) (time.Time, error) {
+	var deliveredAt time.Time
+	err := q.QueryRow(`
+		SELECT created_at FROM order_status_history
+		WHERE order_id = ? AND field = 'order_status' AND to_status = 'delivered'
+		ORDER BY created_at DESC LIMIT 1`,
+		orderID).Scan(&deliveredAt)
+	if err == sql.ErrNoRows {
+		err = q.QueryRow("SELECT updated_at FROM orders WHERE id = ?", orderID).Scan(&deliveredAt)
+	}
+	return deliveredAt, err
+}
+
+// Create records a return request for items of a delivered order. Items that
+// are part of another open or completed return cannot be returned again.
+func Create(q database.Querier, orderID, userID int64, reason string, items []models.ReturnItemRequest) (int64, error) {
+	if len(items) == 0 {
+		return 0, RuleError("At least one item is required")
+	}
+
+	returnable, err := returnable(q, orderID)
+	if err != nil {
+		return 0, err
+	}
+	for _, item := range items {
+		left, ok := returnable[item.OrderItemID]
+		if !ok {
+			return 0, RuleError("Item is not part of this order")
+		}
+		if item.Quantity <= 0 {
+			return 0, RuleError("Quantity must be positive")
+		}
+		if item.Quantity > left {
+			return 0, RuleError("Quantity exceeds what can be returned")
+		}
+		returnable[item.OrderItemID] -= item.Quantity
+	}
+
+	var returnID int64
+	err = q.QueryRow(
+		"INSERT INTO returns (order_id, user_id, status, reason, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING id",
+		orderID, userID, StatusRequested, reason, time.Now(), time.Now()).Scan(&returnID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, item := range items {
+		_, err := q.Exec(
+			"INSERT INTO return_items (return_id, order_item_id, quantity) VALUES (?, ?, ?)",
+			returnID, item.OrderItemID, item.Quantity)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return returnID, nil
+}
+
+// Review approves or rejects a requested return
+func Review(q database.Querier, returnID int64, approve bool, note string) error {
+	status := StatusRejected
+	if approve {
+		status = StatusApproved
+	}
+
+	current, err := statusOf(q, returnID)
+	if err != nil {
+		return err
+	}
+	if current != StatusRequested {
+		return RuleError("Return is already " + current)
+	}
+
+	_, err = q.Exec(
+		"UPDATE returns SET status = ?, admin_note = ?, updated_at = ? WHERE id = ?",
+		status, note, time.Now(), returnID)
+	return err
+}
+
+// Receive marks the goods of an approved return as received, optionally
+// putting them back into stock
+func Receive(q database.Querier, returnID int64, restock bool, note string) error {
+	current, err := statusOf(q, returnID)
+	if err != nil {
+		return err
+	}
+	if current != StatusApproved {
+		return RuleError("Only approved returns can be received")
+	}
+
+	if restock {
+		rows, err := q.Query(`
+			SELECT oi.product_id, oi.color_id, oi.size_id, ri.quantity
+			FROM return_items ri
+			JOIN order_items oi ON ri.order_item_id = oi.id
+			WHERE ri.return_id = ?`,
+			returnID)
+		if err != nil {
+			return err
+		}
+		var items []models.OrderItem
+		for rows.Next() {
+			var item models.OrderItem
+			if err := rows.Scan(&item.ProductID, &item.ColorID, &item.SizeID, &item.Quantity); err != nil {
+				rows.Close()
+				return err
+			}
+			items = append(items, item)
+		}
+		rows.Close()
+
+		for _, item := range items {
+			if err := inventory.Restock(q, item.ProductID, item.ColorID, item.SizeID, item.Quantity); err != nil {
+				return err
+			}
+		}
+	}
+
+	query := "UPDATE returns SET status = ?, restocked = ?, received_at = ?, updated_at = ?"
+	args := []interface{}{StatusReceived, restock, time.Now(), time.Now()}
+	if note != "" {
+		query += ", admin_note = ?"
+		args = append(args, note)
+	}
+	_, err = q.Exec(query+" WHERE id = ?", append(args, returnID)...)
+	return err
+}
+
+// Value is what the customer paid for the returned items: their price less
+// their share of the order discount, plus tax that was charged on top
+func Value(q database.Querier, returnID int64) (money.Amount, error) {
+	rows, err := q.Query(`
+		SELECT ri.quantity, oi.quantity, oi.price_per_unit, oi.discount_amount, oi.tax_amount, oi.tax_included
+		FROM return_items ri
+		JOIN order_items oi ON ri.order_item_id = oi.id
+		WHERE ri.return_id = ?`,
+		returnID)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var value money.Amount
+	for rows.Next() {
+		var returned, ordered int64
+		var pricePerUnit, discount, taxAmount money.Amount
+		var taxIncluded bool
+		if err := rows.Scan(&returned, &ordered, &pricePerUnit, &discount, &taxAmount, &taxIncluded); err != nil {
+			return 0, err
+		}
+
+		value += pricePerUnit.Mul(int(returned)) - discount.Share(returned, ordered)
+		if !taxIncluded {
+			value += taxAmount.Share(returned, ordered)
+		}
+	}
+	return value, rows.Err()
+}
+
+// ClaimRefund marks the refund of a received return in progress, unless it is
+// already in progress or done, so that only one request refunds the customer
+func ClaimRefund(q database.Querier, returnID int64) error {
+	result, err := q.Exec(
+		`UPDATE returns SET refund_state = ?, updated_at = ?
+		WHERE id = ? AND status = ? AND refunded_at IS NULL AND (refund_state IS NULL OR refund_state = ?)`,
+		RefundInProgress, time.Now(), returnID, StatusReceived, RefundFailed)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		if err != nil {
+			return err
+		}
+		return RuleError("Return is already refunded or being refunded")
+	}
+	return nil
+}
+
+// ReleaseRefund marks a claimed refund failed, so that it can be retried
+func ReleaseRefund(q database.Querier, returnID int64) error {
+	_, err := q.Exec(
+		"UPDATE returns SET refund_state = ?, updated_at = ? WHERE id = ? AND refund_state = ?",
+		RefundFailed, time.Now(), returnID, RefundInProgress)
+	return err
+}
+
+// RecordRefund stores the amount refunded for a return, which closes it
+func RecordRefund(q database.Querier, returnID int64, amount money.Amount) error {
+	_, err := q.Exec(
+		"UPDATE returns SET refund_state = ?, refund_amount = ?, refunded_at = ?, updated_at = ? WHERE id = ?",
+		RefundDone, amount, time.Now(), time.Now(), returnID)
+	return err
+}
+
+// Get returns a return with its items
+func Get(q database.Querier, returnID int64) (*models.Return, error) {
+	list, err := find(q, "WHERE r.id = ?", returnID)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	return &list[0], nil
+}
+
+// ForOrder returns the returns of an order, oldest first
+func ForOrder(q database.Querier, orderID int64) ([]models.Return, error) {
+	return find(q, "WHERE r.order_id = ?", orderID)
+}
+
+// List returns every return, optionally only those with a status
+func List(q database.Querier, status string) ([]models.Return, error) {
+	if status != "" {
+		return find(q, "WHERE r.status = ?", status)
+	}
+	return find(q, "")
+}
+
+// find selects returns matching a condition and loads their items
+func find(q database.Querier, condition string, args ...interface{}) ([]models.Return, error) {
+	rows, err := q.Query(`
+		SELECT r.id, r.order_id, r.user_id, r.status, COALESCE(r.reason, ''), COALESCE(r.admin_note, ''),
+			r.restocked, r.refund_amount, COALESCE(r.refund_state, ''), r.created_at, r.updated_at, r.received_at, r.refunded_at
+		FROM returns r
+		`+condition+`
+		ORDER BY r.id`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []models.Return{}
+	for rows.Next() {
+		var r models.Return
+		err := rows.Scan(&r.ID, &r.OrderID, &r.UserID, &r.Status, &r.Reason, &r.AdminNote,
+			&r.Restocked, &r.RefundAmount, &r.RefundState, &r.CreatedAt, &r.UpdatedAt, &r.ReceivedAt, &r.RefundedAt)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range list {
+		if list[i].Items, err = items(q, list[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// items returns the order items of a return
+func items(q database.Querier, returnID int64) ([]models.ReturnItem, error) {
+	rows, err := q.Query(`
+		SELECT ri.order_item_id, oi.product_id, p.name, ri.quantity
+		FROM return_items ri
+		JOIN order_items oi ON ri.order_item_id = oi.id
+		JOIN products p ON oi.product_id = p.id
+		WHERE ri.return_id = ?
+		ORDER BY ri.id`,
+		returnID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []models.ReturnItem{}
+	for rows.Next() {
+		var item models.ReturnItem
+		if err := rows.Scan(&item.OrderItemID, &item.ProductID, &item.ProductName, &item.Quantity); err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	return result, rows.Err()
+}
+
+// statusOf returns the status of a return
+func statusOf(q database.Querier, returnID int64) (string, error) {
+	var status string
+	err := q.QueryRow("SELECT status FROM returns WHERE id = ?", returnID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	return status, err
+}
+
+// returnable returns the quantity of each order item not yet part of a return
+// that was requested, approved or received
+func returnable(q database.Querier, orderID int64) (map[int64]int, error) {
+	rows, err := q.Query(`
+		SELECT oi.id, oi.quantity - COALESCE((
+			SELECT SUM(ri.quantity)
+			FROM return_items ri
+			JOIN returns r ON ri.return_id = r.id
+			WHERE ri.order_item_id = oi.id AND r.status != ?
+		), 0)
+		FROM order_items oi
+		WHERE oi.order_id = ?`,
+		StatusRejected, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[int64]int{}
+	for rows.Next() {
+		var id int64
+		var left int
+		if err := rows.Scan(&id, &left); err != nil {
+			return nil, err
+		}
+		result[id] = left
+	}
+	return result, rows.Err()
+}
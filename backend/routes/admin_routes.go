@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"backend/controllers"
+	"backend/middlewares"
+	"backend/permissions"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetupAdminRoutes sets up the role and user administration routes
+func SetupAdminRoutes(app *fiber.App, h *controllers.Handler) {
+	adminRoutes := app.Group("/api/admin")
+
+	// Role endpoints
+	manageRoles := middlewares.RequirePermission(h.DB, permissions.RolesManage)
+	adminRoutes.Get("/permissions", manageRoles, h.GetPermissions)
+	adminRoutes.Get("/roles", manageRoles, h.GetAllRoles)
+	adminRoutes.Post("/roles", manageRoles, h.CreateRole)
+	adminRoutes.Put("/roles/:id", manageRoles, h.UpdateRole)
+	adminRoutes.Delete("/roles/:id", manageRoles, h.DeleteRole)
+	adminRoutes.Put("/roles/:id/two-factor", manageRoles, h.SetRoleTwoFactor)
+
+	// User endpoints
+	readUsers := middlewares.RequirePermission(h.DB, permissions.UsersRead)
+	adminRoutes.Get("/users", readUsers, h.GetUsers)
+	adminRoutes.Get("/users/:id", readUsers, h.GetUser)
+	adminRoutes.Get("/users/:id/orders", readUsers, h.GetUserOrders)
+	adminRoutes.Get("/users/:id/addresses", readUsers, h.GetUserAddresses)
+
+	manageUsers := middlewares.RequirePermission(h.DB, permissions.UsersManage)
+	adminRoutes.Put("/users/:id/role", manageUsers, h.AssignUserRole)
+	adminRoutes.Post("/users/:id/disable", manageUsers, h.DisableUser)
+	adminRoutes.Post("/users/:id/enable", manageUsers, h.EnableUser)
+	adminRoutes.Post("/users/:id/reset-password", manageUsers, h.ForcePasswordReset)
+	adminRoutes.Post("/users/:id/unlock", manageUsers, h.UnlockUser)
+	adminRoutes.Post("/users/:id/reset-two-factor", manageUsers, h.ResetUserTwoFactor)
+}
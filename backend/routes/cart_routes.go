@@ -3,59 +3,147 @@ package routes
 import (
 	"backend/controllers"
 	"backend/middlewares"
+	"backend/permissions"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // SetupCartRoutes sets up all cart routes
-func SetupCartRoutes(app *fiber.App) {
+func SetupCartRoutes(app *fiber.App, h *controllers.Handler) {
 	// All cart routes require authentication
-	cartRoutes := app.Group("/api/cart", middlewares.Protected())
+	cartRoutes := app.Group("/api/cart", middlewares.Protected(h.DB))
 
 	// Cart endpoints
-	cartRoutes.Get("/", controllers.GetCart)
-	cartRoutes.Post("/", controllers.AddToCart)
-	cartRoutes.Put("/:id", controllers.UpdateCartItem)
-	cartRoutes.Delete("/:id", controllers.RemoveFromCart)
-	cartRoutes.Delete("/", controllers.ClearCart)
+	cartRoutes.Get("/", h.GetCart)
+	cartRoutes.Post("/", h.AddToCart)
+	cartRoutes.Get("/shipping-options", h.GetShippingOptions)
+	cartRoutes.Post("/checkout", h.StartCheckout)
+	cartRoutes.Post("/coupon", h.ApplyCoupon)
+	cartRoutes.Delete("/coupon", h.RemoveCoupon)
+	cartRoutes.Put("/:id", h.UpdateCartItem)
+	cartRoutes.Delete("/:id", h.RemoveFromCart)
+	cartRoutes.Delete("/", h.ClearCart)
 }
 
 // SetupWishlistRoutes sets up all wishlist routes
-func SetupWishlistRoutes(app *fiber.App) {
+func SetupWishlistRoutes(app *fiber.App, h *controllers.Handler) {
 	// All wishlist routes require authentication
-	wishlistRoutes := app.Group("/api/wishlist", middlewares.Protected())
+	wishlistRoutes := app.Group("/api/wishlist", middlewares.Protected(h.DB))
 
 	// Wishlist endpoints
-	wishlistRoutes.Get("/", controllers.GetWishlist)
-	wishlistRoutes.Post("/", controllers.AddToWishlist)
-	wishlistRoutes.Delete("/:id", controllers.RemoveFromWishlist)
-	wishlistRoutes.Delete("/", controllers.ClearWishlist)
+	wishlistRoutes.Get("/", h.GetWishlist)
+	wishlistRoutes.Post("/", h.AddToWishlist)
+	wishlistRoutes.Delete("/:id", h.RemoveFromWishlist)
+	wishlistRoutes.Delete("/", h.ClearWishlist)
 }
 
 // SetupAddressRoutes sets up all address routes
-func SetupAddressRoutes(app *fiber.App) {
+func SetupAddressRoutes(app *fiber.App, h *controllers.Handler) {
 	// All address routes require authentication
-	addressRoutes := app.Group("/api/addresses", middlewares.Protected())
+	addressRoutes := app.Group("/api/addresses", middlewares.Protected(h.DB))
 
 	// Address endpoints
-	addressRoutes.Get("/", controllers.GetAllAddresses)
-	addressRoutes.Get("/:id", controllers.GetAddress)
-	addressRoutes.Post("/", controllers.CreateAddress)
-	addressRoutes.Put("/:id", controllers.UpdateAddress)
-	addressRoutes.Delete("/:id", controllers.DeleteAddress)
+	addressRoutes.Get("/", h.GetAllAddresses)
+	addressRoutes.Get("/:id", h.GetAddress)
+	addressRoutes.Post("/", h.CreateAddress)
+	addressRoutes.Put("/:id", h.UpdateAddress)
+	addressRoutes.Delete("/:id", h.DeleteAddress)
 }
 
 // SetupOrderRoutes sets up all order routes
-func SetupOrderRoutes(app *fiber.App) {
+func SetupOrderRoutes(app *fiber.App, h *controllers.Handler) {
 	// All order routes require authentication
-	orderRoutes := app.Group("/api/orders", middlewares.Protected())
+	orderRoutes := app.Group("/api/orders", middlewares.Protected(h.DB))
 
 	// Order endpoints for all users
-	orderRoutes.Post("/", controllers.PlaceOrder)
-	orderRoutes.Get("/", controllers.GetAllOrders)
-	orderRoutes.Get("/:id", controllers.GetOrderByID)
+	orderRoutes.Post("/", h.PlaceOrder)
+	orderRoutes.Get("/", h.GetAllOrders)
+	orderRoutes.Get("/:id", h.GetOrderByID)
+	orderRoutes.Post("/:id/pay", h.PayOrder)
+	orderRoutes.Post("/:id/cancel", h.CancelOrder)
+	orderRoutes.Get("/:id/shipments", h.GetOrderShipments)
+	orderRoutes.Get("/:id/returns", h.GetOrderReturns)
+	orderRoutes.Post("/:id/returns", h.RequestReturn)
 
-	// Admin only endpoints
-	adminRoutes := orderRoutes.Use(middlewares.AdminOnly())
-	adminRoutes.Put("/:id/status", controllers.UpdateOrderStatus)
+	// Fulfilment endpoints
+	update := middlewares.RequirePermission(h.DB, permissions.OrdersUpdate)
+	orderRoutes.Put("/:id/status", update, h.UpdateOrderStatus)
+	orderRoutes.Post("/:id/shipments", update, h.CreateShipment)
+	orderRoutes.Post("/:id/shipments/:shipmentId/deliver", update, h.DeliverShipment)
+}
+
+// SetupPaymentRoutes sets up the payment provider callbacks
+func SetupPaymentRoutes(app *fiber.App, h *controllers.Handler) {
+	// Webhooks are authenticated by their signature, not a user token
+	paymentRoutes := app.Group("/api/payments")
+	paymentRoutes.Post("/webhooks/:provider", h.PaymentWebhook)
+}
+
+// SetupPromotionRoutes sets up the promotion management routes
+func SetupPromotionRoutes(app *fiber.App, h *controllers.Handler) {
+	// All promotion routes need the promotions:write permission
+	promotionRoutes := app.Group("/api/promotions", middlewares.RequirePermission(h.DB, permissions.PromotionsWrite))
+
+	// Promotion endpoints
+	promotionRoutes.Get("/", h.GetAllPromotions)
+	promotionRoutes.Get("/:id", h.GetPromotion)
+	promotionRoutes.Post("/", h.CreatePromotion)
+	promotionRoutes.Put("/:id", h.UpdatePromotion)
+	promotionRoutes.Delete("/:id", h.DeletePromotion)
+}
+
+// SetupTaxRoutes sets up the tax rule management routes
+func SetupTaxRoutes(app *fiber.App, h *controllers.Handler) {
+	// All tax rule routes need the tax:write permission
+	taxRoutes := app.Group("/api/tax-rules", middlewares.RequirePermission(h.DB, permissions.TaxWrite))
+
+	// Tax rule endpoints
+	taxRoutes.Get("/", h.GetAllTaxRules)
+	taxRoutes.Post("/", h.CreateTaxRule)
+	taxRoutes.Put("/:id", h.UpdateTaxRule)
+	taxRoutes.Delete("/:id", h.DeleteTaxRule)
+}
+
+// SetupShippingRoutes sets up the shipping zone management routes
+func SetupShippingRoutes(app *fiber.App, h *controllers.Handler) {
+	// All shipping routes need the shipping:write permission
+	shippingRoutes := app.Group("/api/shipping", middlewares.RequirePermission(h.DB, permissions.ShippingWrite))
+
+	// Shipping zone endpoints
+	shippingRoutes.Get("/zones", h.GetAllShippingZones)
+	shippingRoutes.Post("/zones", h.CreateShippingZone)
+	shippingRoutes.Put("/zones/:id", h.UpdateShippingZone)
+	shippingRoutes.Delete("/zones/:id", h.DeleteShippingZone)
+	shippingRoutes.Post("/zones/:id/methods", h.CreateShippingMethod)
+
+	// Shipping method endpoints
+	shippingRoutes.Put("/methods/:id", h.UpdateShippingMethod)
+	shippingRoutes.Delete("/methods/:id", h.DeleteShippingMethod)
+}
+
+// SetupReturnRoutes sets up the return management routes
+func SetupReturnRoutes(app *fiber.App, h *controllers.Handler) {
+	// Return management for staff, customers use /api/orders/:id/returns
+	returnRoutes := app.Group("/api/returns")
+	manage := middlewares.RequirePermission(h.DB, permissions.ReturnsManage)
+
+	// Return endpoints
+	read := middlewares.RequirePermission(h.DB, permissions.ReturnsManage, permissions.ReturnsReceive)
+	returnRoutes.Get("/", read, h.GetAllReturns)
+	returnRoutes.Get("/:id", read, h.GetReturn)
+	returnRoutes.Post("/:id/approve", manage, h.ApproveReturn)
+	returnRoutes.Post("/:id/reject", manage, h.RejectReturn)
+	returnRoutes.Post("/:id/receive", middlewares.RequirePermission(h.DB, permissions.ReturnsReceive), h.ReceiveReturn)
+}
+
+// SetupCurrencyRoutes sets up the currency and exchange rate routes
+func SetupCurrencyRoutes(app *fiber.App, h *controllers.Handler) {
+	currencyRoutes := app.Group("/api/currencies")
+
+	// Public route
+	currencyRoutes.Get("/", h.GetCurrencies)
+
+	// Exchange rate endpoints
+	currencyRoutes.Put("/:currency", middlewares.RequirePermission(h.DB, permissions.CurrenciesWrite), h.SetExchangeRate)
+	currencyRoutes.Delete("/:currency", middlewares.RequirePermission(h.DB, permissions.CurrenciesWrite), h.DeleteExchangeRate)
 }
@@ -3,46 +3,68 @@ package routes
 import (
 	"backend/controllers"
 	"backend/middlewares"
+	"backend/permissions"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // SetupProductRoutes sets up all product routes
-func SetupProductRoutes(app *fiber.App) {
+func SetupProductRoutes(app *fiber.App, h *controllers.Handler) {
 	// Product endpoints
 	productRoutes := app.Group("/api/products")
 	
 	// Public routes
-	productRoutes.Get("/", controllers.GetAllProducts)
-	productRoutes.Get("/:id", controllers.GetProductByID)
-	
-	// Protected routes (admin only)
-	admin := productRoutes.Use(middlewares.AdminOnly())
-	admin.Post("/", controllers.CreateProduct)
-	admin.Put("/:id", controllers.UpdateProduct)
-	admin.Delete("/:id", controllers.DeleteProduct)
-	
-	// Product inventory management (admin only)
-	admin.Post("/:id/colors", controllers.AddProductColor)
-	admin.Post("/:id/sizes", controllers.AddProductSize)
-	admin.Post("/:id/inventory", controllers.UpdateInventory)
-	admin.Post("/:id/images", controllers.AddProductImage)
-	
-	// Delete product attributes (admin only)
-	admin.Delete("/:id/colors/:colorId", controllers.DeleteProductColor)
-	admin.Delete("/:id/sizes/:sizeId", controllers.DeleteProductSize)
-	admin.Delete("/:id/images/:imageId", controllers.DeleteProductImage)
+	productRoutes.Get("/", h.GetAllProducts)
+	productRoutes.Get("/slug/:slug", h.GetProductBySlug)
+	productRoutes.Get("/:id", h.GetProductByID)
+	productRoutes.Get("/:id/reviews", h.GetProductReviews)
+	
+	// Review routes (authenticated users)
+	productRoutes.Post("/:id/reviews", middlewares.Protected(h.DB), h.CreateReview)
+	productRoutes.Put("/:id/reviews/:reviewId", middlewares.Protected(h.DB), h.UpdateReview)
+	productRoutes.Delete("/:id/reviews/:reviewId", middlewares.Protected(h.DB), h.DeleteReview)
+	
+	// Catalog management
+	write := middlewares.RequirePermission(h.DB, permissions.ProductsWrite)
+	productRoutes.Post("/", write, h.CreateProduct)
+	productRoutes.Put("/:id", write, h.UpdateProduct)
+	productRoutes.Delete("/:id", write, h.DeleteProduct)
+	
+	// Product attributes and stock
+	productRoutes.Post("/:id/colors", write, h.AddProductColor)
+	productRoutes.Post("/:id/sizes", write, h.AddProductSize)
+	productRoutes.Post("/:id/inventory", middlewares.RequirePermission(h.DB, permissions.InventoryWrite), h.UpdateInventory)
+	productRoutes.Post("/:id/images", write, h.AddProductImage)
+	
+	// Product prices in other currencies
+	productRoutes.Get("/:id/prices", write, h.GetProductPrices)
+	productRoutes.Put("/:id/prices", write, h.SetProductPrice)
+	productRoutes.Delete("/:id/prices/:currency", write, h.DeleteProductPrice)
+	
+	// Delete product attributes
+	productRoutes.Delete("/:id/colors/:colorId", write, h.DeleteProductColor)
+	productRoutes.Delete("/:id/sizes/:sizeId", write, h.DeleteProductSize)
+	productRoutes.Delete("/:id/images/:imageId", write, h.DeleteProductImage)
 	
 	// Category endpoints
 	categoryRoutes := app.Group("/api/categories")
 	
 	// Public routes
-	categoryRoutes.Get("/", controllers.GetAllCategories)
-	categoryRoutes.Get("/:id", controllers.GetCategoryByID)
-	
-	// Protected routes (admin only)
-	adminCategory := categoryRoutes.Use(middlewares.AdminOnly())
-	adminCategory.Post("/", controllers.CreateCategory)
-	adminCategory.Put("/:id", controllers.UpdateCategory)
-	adminCategory.Delete("/:id", controllers.DeleteCategory)
-} 
\ No newline at end of file
+	categoryRoutes.Get("/", h.GetAllCategories)
+	categoryRoutes.Get("/slug/:slug", h.GetCategoryBySlug)
+	categoryRoutes.Get("/:id", h.GetCategoryByID)
+	
+	// Category management
+	categoryRoutes.Post("/", write, h.CreateCategory)
+	categoryRoutes.Put("/:id", write, h.UpdateCategory)
+	categoryRoutes.Delete("/:id", write, h.DeleteCategory)
+}
+
+// SetupSearchRoutes sets up the full-text search routes
+func SetupSearchRoutes(app *fiber.App, h *controllers.Handler) {
+	// Public search endpoint
+	app.Get("/api/search", h.SearchProducts)
+
+	// Rebuild the search index
+	app.Post("/api/search/reindex", middlewares.RequirePermission(h.DB, permissions.ProductsWrite), h.RebuildSearchIndex)
+}
@@ -8,11 +8,31 @@ import (
 )
 
 // SetupUserRoutes sets up all the user routes
-func SetupUserRoutes(app *fiber.App) {
+func SetupUserRoutes(app *fiber.App, h *controllers.Handler) {
 	// Public routes
-	app.Post("/api/auth/register", controllers.RegisterUser)
-	app.Post("/api/auth/login", controllers.LoginUser)
+	app.Post("/api/auth/register", h.RegisterUser)
+	app.Post("/api/auth/login", h.LoginUser)
+	app.Post("/api/auth/refresh", h.RefreshToken)
+	app.Post("/api/auth/forgot-password", h.ForgotPassword)
+	app.Post("/api/auth/reset-password", h.ResetPassword)
+	app.Post("/api/auth/verify-email", h.VerifyEmail)
+	app.Post("/api/auth/2fa/verify", h.VerifyTwoFactor)
 
 	// Protected routes
-	app.Get("/api/auth/me", middlewares.Protected(), controllers.GetCurrentUser)
+	app.Get("/api/auth/me", middlewares.Enrolling(h.DB), h.GetCurrentUser)
+	app.Put("/api/auth/me", middlewares.Protected(h.DB), h.UpdateProfile)
+	app.Delete("/api/auth/me", middlewares.Protected(h.DB), h.DeleteAccount)
+	app.Post("/api/auth/change-password", middlewares.Protected(h.DB), h.ChangePassword)
+	app.Get("/api/auth/me/sessions", middlewares.Protected(h.DB), h.GetSessions)
+	app.Delete("/api/auth/me/sessions/:id", middlewares.Protected(h.DB), h.RevokeSession)
+	app.Post("/api/auth/logout", middlewares.Enrolling(h.DB), h.Logout)
+	app.Post("/api/auth/logout-all", middlewares.Enrolling(h.DB), h.LogoutAll)
+	app.Post("/api/auth/verify-email/resend", middlewares.Protected(h.DB), h.ResendVerification)
+
+	// Two-factor routes, the setup is open to users whose role requires it before they have
+	app.Get("/api/auth/2fa", middlewares.Enrolling(h.DB), h.GetTwoFactor)
+	app.Post("/api/auth/2fa/setup", middlewares.Enrolling(h.DB), h.SetupTwoFactor)
+	app.Post("/api/auth/2fa/confirm", middlewares.Enrolling(h.DB), h.ConfirmTwoFactor)
+	app.Post("/api/auth/2fa/disable", middlewares.Protected(h.DB), h.DisableTwoFactor)
+	app.Post("/api/auth/2fa/recovery-codes", middlewares.Protected(h.DB), h.RegenerateRecoveryCodes)
 } 
\ No newline at end of file
@@ -0,0 +1,354 @@
+package search
+
+import (
+	"backend/database"
+	"backend/dialect"
+	"backend/models"
+	"backend/pricing"
+	"backend/repository"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"unicode"
+)
+
+var (
+	// ErrUnavailable is returned when the database is not SQLite or SQLite
+	// was built without the FTS5 module
+	ErrUnavailable = errors.New("full-text search is not available, it needs SQLite built with -tags sqlite_fts5")
+
+	// ErrEmptyQuery is returned when the query contains no searchable terms
+	ErrEmptyQuery = errors.New("search query is required")
+)
+
+// enabled reports whether the FTS5 index could be created
+var enabled bool
+
+// indexSelect selects the searchable text of products, joined with their category
+const indexSelect = `
+	INSERT INTO product_search (rowid, name, description, category_name)
+	SELECT p.id, p.name, COALESCE(p.description, ''), COALESCE(c.name, '')
+	FROM products p
+	LEFT JOIN categories c ON p.category_id = c.id`
+
+// Options narrows down a search
+type Options struct {
+	Query      string
+	CategoryID int64
+	Limit      int
+	Offset     int
+}
+
+// Results holds one page of search results together with facets and suggestions
+type Results struct {
+	Products       []models.SearchResult
+	Total          int
+	Facets         models.SearchFacets
+	Suggestion     string
+	CorrectedQuery string
+}
+
+// Init creates the FTS5 index and rebuilds it from the products table.
+// Products written outside the API, such as by the seeder, are picked up here.
+// The index is an SQLite virtual table, so search is disabled on PostgreSQL.
+func Init(db *sql.DB, d dialect.Dialect) {
+	if d != dialect.SQLite {
+		log.Printf("Full-text search disabled: not supported on %s", d)
+		return
+	}
+
+	_, err := db.Exec(`
+	CREATE VIRTUAL TABLE IF NOT EXISTS product_search USING fts5(
+		name,
+		description,
+		category_name,
+		tokenize = 'unicode61 remove_diacritics 2',
+		prefix = '2 3'
+	);`)
+	if err != nil {
+		log.Printf("Full-text search disabled: %v (build with -tags sqlite_fts5)", err)
+		return
+	}
+
+	// Vocabulary of indexed terms, used for typo suggestions
+	_, err = db.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS product_search_vocab USING fts5vocab(product_search, 'row');")
+	if err != nil {
+		log.Printf("Full-text search disabled: %v", err)
+		return
+	}
+
+	enabled = true
+	if err := Rebuild(db); err != nil {
+		log.Printf("Failed to build search index: %v", err)
+		return
+	}
+
+	log.Println("Search index built successfully")
+}
+
+// Enabled reports whether full-text search is available
+func Enabled() bool {
+	return enabled
+}
+
+// Rebuild re-indexes every product
+func Rebuild(db *sql.DB) error {
+	if !enabled {
+		return ErrUnavailable
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM product_search"); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(indexSelect); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// IndexProduct adds or refreshes a product in the index
+func IndexProduct(db *sql.DB, productID int64) error {
+	if !enabled {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM product_search WHERE rowid = ?", productID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(indexSelect+" WHERE p.id = ?", productID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveProduct removes a product from the index
+func RemoveProduct(q database.Querier, productID int64) error {
+	if !enabled {
+		return nil
+	}
+
+	_, err := q.Exec("DELETE FROM product_search WHERE rowid = ?", productID)
+	return err
+}
+
+// IndexCategory refreshes every product of a category, e.g. after it was renamed
+func IndexCategory(db *sql.DB, categoryID int64) error {
+	if !enabled {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM product_search WHERE rowid IN (SELECT id FROM products WHERE category_id = ?)", categoryID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(indexSelect+" WHERE p.category_id = ?", categoryID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Search runs a relevance ranked search. When nothing matches and a spelling
+// correction is found, the corrected query is searched instead.
+func Search(q database.Querier, opts Options) (*Results, error) {
+	if !enabled {
+		return nil, ErrUnavailable
+	}
+
+	terms := Terms(opts.Query)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	results := &Results{Suggestion: suggest(q, terms)}
+	match := matchQuery(terms)
+
+	total, err := count(q, match, opts.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fall back to the suggested spelling if the original query finds nothing
+	if total == 0 && results.Suggestion != "" {
+		corrected := matchQuery(Terms(results.Suggestion))
+		correctedTotal, err := count(q, corrected, opts.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		if correctedTotal > 0 {
+			match, total = corrected, correctedTotal
+			results.CorrectedQuery = results.Suggestion
+		}
+	}
+
+	results.Total = total
+	if results.Products, err = find(q, match, opts); err != nil {
+		return nil, err
+	}
+	if results.Facets, err = facets(q, match, opts.CategoryID); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Terms splits free text into lowercase search terms
+func Terms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// matchQuery builds an FTS5 query that requires every term, matching term prefixes
+func matchQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = `"` + term + `"*`
+	}
+	return strings.Join(parts, " ")
+}
+
+// categoryFilter returns the optional category condition and its arguments
+func categoryFilter(match string, categoryID int64) (string, []interface{}) {
+	if categoryID > 0 {
+		return " AND p.category_id = ?", []interface{}{match, categoryID}
+	}
+	return "", []interface{}{match}
+}
+
+// count returns the number of products matching the query
+func count(q database.Querier, match string, categoryID int64) (int, error) {
+	filter, args := categoryFilter(match, categoryID)
+
+	var total int
+	err := q.QueryRow(`
+		SELECT COUNT(*)
+		FROM product_search
+		JOIN products p ON p.id = product_search.rowid
+		WHERE product_search MATCH ?`+filter,
+		args...).Scan(&total)
+	return total, err
+}
+
+// find returns one page of matching products ordered by relevance
+func find(q database.Querier, match string, opts Options) ([]models.SearchResult, error) {
+	filter, args := categoryFilter(match, opts.CategoryID)
+	args = append(args, opts.Limit, opts.Offset)
+
+	// Name matches weigh most, followed by the category and the description
+	rows, err := q.Query(`
+		SELECT p.id, p.name, COALESCE(p.slug, ''), COALESCE(p.description, ''), COALESCE(p.category_id, 0),
+			COALESCE(c.name, 'Uncategorized'), p.base_price, p.discount_percentage,
+			`+repository.PrimaryImageSQL+`,
+			highlight(product_search, 0, '<mark>', '</mark>'),
+			snippet(product_search, 1, '<mark>', '</mark>', '...', 16),
+			bm25(product_search, 10.0, 1.0, 4.0) AS rank
+		FROM product_search
+		JOIN products p ON p.id = product_search.rowid
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE product_search MATCH ?`+filter+`
+		ORDER BY rank
+		LIMIT ? OFFSET ?`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []models.SearchResult{}
+	for rows.Next() {
+		var result models.SearchResult
+		var rank float64
+		err := rows.Scan(
+			&result.ID, &result.Name, &result.Slug, &result.Description, &result.CategoryID,
+			&result.CategoryName, &result.BasePrice, &result.DiscountPercentage,
+			&result.PrimaryImage, &result.HighlightedName, &result.Snippet, &rank)
+		if err != nil {
+			return nil, err
+		}
+
+		// bm25 scores are negative, lower is more relevant
+		result.FinalPrice = pricing.UnitPrice(result.BasePrice, result.DiscountPercentage)
+		result.Score = -rank
+		products = append(products, result)
+	}
+
+	return products, rows.Err()
+}
+
+// facets counts the matching products per category, color and size
+func facets(q database.Querier, match string, categoryID int64) (models.SearchFacets, error) {
+	var result models.SearchFacets
+	var err error
+	filter, args := categoryFilter(match, categoryID)
+
+	result.Categories, err = facetCounts(q, `
+		SELECT COALESCE(p.category_id, 0), COALESCE(c.name, 'Uncategorized'), COUNT(*)
+		FROM product_search
+		JOIN products p ON p.id = product_search.rowid
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE product_search MATCH ?`+filter+`
+		GROUP BY p.category_id, c.name
+		ORDER BY COUNT(*) DESC, c.name`, args...)
+	if err != nil {
+		return result, err
+	}
+
+	result.Colors, err = facetCounts(q, `
+		SELECT 0, MIN(pc.color_name), COUNT(DISTINCT p.id)
+		FROM product_search
+		JOIN products p ON p.id = product_search.rowid
+		JOIN product_colors pc ON pc.product_id = p.id
+		WHERE product_search MATCH ?`+filter+`
+		GROUP BY LOWER(pc.color_name)
+		ORDER BY COUNT(DISTINCT p.id) DESC, MIN(pc.color_name)`, args...)
+	if err != nil {
+		return result, err
+	}
+
+	result.Sizes, err = facetCounts(q, `
+		SELECT 0, MIN(ps.size_name), COUNT(DISTINCT p.id)
+		FROM product_search
+		JOIN products p ON p.id = product_search.rowid
+		JOIN product_sizes ps ON ps.product_id = p.id
+		WHERE product_search MATCH ?`+filter+`
+		GROUP BY LOWER(ps.size_name)
+		ORDER BY COUNT(DISTINCT p.id) DESC, MIN(ps.size_name)`, args...)
+	return result, err
+}
+
+// facetCounts runs a facet query returning id, name and count columns
+func facetCounts(q database.Querier, query string, args ...interface{}) ([]models.FacetCount, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []models.FacetCount{}
+	for rows.Next() {
+		var facet models.FacetCount
+		if err := rows.Scan(&facet.ID, &facet.Name, &facet.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, facet)
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,137 @@
+package search_test
+
+import (
+	"backend/database/dbtest"
+	"backend/dialect"
+	"backend/migrations"
+	"backend/models"
+	"backend/money"
+	"backend/search"
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestTerms(t *testing.T) {
+	tests := map[string][]string{
+		"Linen Shirt":       {"linen", "shirt"},
+		"  t-shirt, white ": {"t", "shirt", "white"},
+		"Café 2024":         {"café", "2024"},
+		"--":                {},
+	}
+	for text, want := range tests {
+		if got := search.Terms(text); len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+			t.Errorf("Terms(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	db := openIndex(t)
+	shirts := createCategory(t, db, "Shirts")
+	dresses := createCategory(t, db, "Dresses")
+	createProduct(t, db, shirts, "Linen Shirt", "A light summer shirt", "White", "M")
+	createProduct(t, db, shirts, "Oxford Shirt", "A classic cotton shirt", "Blue", "L")
+	createProduct(t, db, dresses, "Shirt Dress", "A summer dress cut like a shirt", "Blue", "S")
+	if err := search.Rebuild(db); err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+
+	results, err := search.Search(db, search.Options{Query: "shirt", Limit: 10})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if results.Total != 3 || len(results.Products) != 3 {
+		t.Fatalf("Search found %d products, %d on the page, want 3", results.Total, len(results.Products))
+	}
+	if results.Products[0].Name == "Shirt Dress" {
+		t.Errorf("Search ranked %q first, want a name match on its own to weigh most", results.Products[0].Name)
+	}
+	assertFacets(t, "categories", results.Facets.Categories, map[string]int{"Shirts": 2, "Dresses": 1})
+	assertFacets(t, "colors", results.Facets.Colors, map[string]int{"Blue": 2, "White": 1})
+
+	// The facets count only the products of the category searched in
+	results, err = search.Search(db, search.Options{Query: "shirt", CategoryID: shirts, Limit: 10})
+	if err != nil {
+		t.Fatalf("Search in a category: %v", err)
+	}
+	if results.Total != 2 {
+		t.Errorf("Search in a category found %d products, want 2", results.Total)
+	}
+	assertFacets(t, "categories", results.Facets.Categories, map[string]int{"Shirts": 2})
+	assertFacets(t, "colors", results.Facets.Colors, map[string]int{"Blue": 1, "White": 1})
+	assertFacets(t, "sizes", results.Facets.Sizes, map[string]int{"L": 1, "M": 1})
+
+	// A misspelt query is searched as corrected when it finds nothing
+	results, err = search.Search(db, search.Options{Query: "lnen", Limit: 10})
+	if err != nil {
+		t.Fatalf("Search with a typo: %v", err)
+	}
+	if results.CorrectedQuery != "linen" || results.Total != 1 {
+		t.Errorf("Search with a typo = %q with %d products, want linen with 1", results.CorrectedQuery, results.Total)
+	}
+
+	if _, err := search.Search(db, search.Options{Query: "!?"}); err != search.ErrEmptyQuery {
+		t.Errorf("Search without terms = %v, want ErrEmptyQuery", err)
+	}
+}
+
+// openIndex opens a migrated SQLite database with the search index, and skips
+// the test when SQLite was built without FTS5
+func openIndex(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db := dbtest.SQLite(t)
+	if _, err := migrations.Up(db, dialect.SQLite); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	search.Init(db, dialect.SQLite)
+	if !search.Enabled() {
+		t.Skip("full-text search needs -tags sqlite_fts5")
+	}
+	return db
+}
+
+// createCategory creates a category with a name
+func createCategory(t *testing.T, db *sql.DB, name string) int64 {
+	t.Helper()
+
+	var id int64
+	if err := db.QueryRow("INSERT INTO categories (name) VALUES (?) RETURNING id", name).Scan(&id); err != nil {
+		t.Fatalf("creating category: %v", err)
+	}
+	return id
+}
+
+// createProduct creates a product of a category in one color and size
+func createProduct(t *testing.T, db *sql.DB, categoryID int64, name, description, color, size string) {
+	t.Helper()
+
+	var id int64
+	err := db.QueryRow(
+		"INSERT INTO products (name, description, category_id, base_price, discount_percentage, featured) VALUES (?, ?, ?, ?, ?, ?) RETURNING id",
+		name, description, categoryID, money.Amount(3000), 0.0, false).Scan(&id)
+	if err == nil {
+		_, err = db.Exec("INSERT INTO product_colors (product_id, color_name, color_hex) VALUES (?, ?, ?)", id, color, "#000000")
+	}
+	if err == nil {
+		_, err = db.Exec("INSERT INTO product_sizes (product_id, size_name) VALUES (?, ?)", id, size)
+	}
+	if err != nil {
+		t.Fatalf("creating product: %v", err)
+	}
+}
+
+// assertFacets compares facet counts by name
+func assertFacets(t *testing.T, kind string, got []models.FacetCount, want map[string]int) {
+	t.Helper()
+
+	counts := map[string]int{}
+	for _, facet := range got {
+		counts[facet.Name] = facet.Count
+	}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("%s facets = %v, want %v", kind, counts, want)
+	}
+}
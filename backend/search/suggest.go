@@ -0,0 +1,100 @@
+package search
+
+import (
+	"backend/database"
+	"strings"
+	"unicode/utf8"
+)
+
+// suggest returns a corrected query when some terms match nothing in the index,
+// or an empty string when every term is known
+func suggest(q database.Querier, terms []string) string {
+	corrected := make([]string, len(terms))
+	changed := false
+
+	for i, term := range terms {
+		corrected[i] = term
+		if isKnownPrefix(q, term) {
+			continue
+		}
+		if closest := closestTerm(q, term); closest != "" {
+			corrected[i] = closest
+			changed = true
+		}
+	}
+
+	if !changed {
+		return ""
+	}
+	return strings.Join(corrected, " ")
+}
+
+// isKnownPrefix reports whether any indexed term starts with the given term
+func isKnownPrefix(q database.Querier, term string) bool {
+	var exists bool
+	q.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM product_search_vocab WHERE term GLOB ?)",
+		term+"*").Scan(&exists)
+	return exists
+}
+
+// closestTerm finds the indexed term with the smallest edit distance,
+// preferring terms that appear in more products
+func closestTerm(q database.Querier, term string) string {
+	length := utf8.RuneCountInString(term)
+	maxDistance := 1
+	if length > 4 {
+		maxDistance = 2
+	}
+
+	rows, err := q.Query(
+		"SELECT term, doc FROM product_search_vocab WHERE length(term) BETWEEN ? AND ?",
+		length-maxDistance, length+maxDistance)
+	if err != nil {
+		return ""
+	}
+	defer rows.Close()
+
+	best, bestDistance, bestDocs := "", maxDistance+1, 0
+	for rows.Next() {
+		var candidate string
+		var docs int
+		if err := rows.Scan(&candidate, &docs); err != nil {
+			continue
+		}
+
+		distance := levenshtein(term, candidate)
+		if distance > maxDistance {
+			continue
+		}
+		if distance < bestDistance || (distance == bestDistance && docs > bestDocs) {
+			best, bestDistance, bestDocs = candidate, distance, docs
+		}
+	}
+
+	return best
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	source, target := []rune(a), []rune(b)
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(target)]
+}
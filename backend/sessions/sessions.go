@@ -0,0 +1,190 @@
+package sessions
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned for refresh tokens that are unknown, expired or revoked
+	ErrInvalidToken = errors.New("invalid or expired refresh token")
+
+	// ErrReused is returned when a refresh token that was already rotated is
+	// presented again. The whole session family is revoked by then.
+	ErrReused = errors.New("refresh token reuse detected, session revoked")
+)
+
+// Token is a newly issued refresh token and the session it belongs to
+type Token struct {
+	SessionID    string
+	UserID       int64
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// Client identifies where a session is used from
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+// RefreshTTL returns how long a refresh token stays valid, configured by REFRESH_TOKEN_TTL_DAYS
+func RefreshTTL() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("REFRESH_TOKEN_TTL_DAYS"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Start opens a new session for a user and returns its first refresh token
+func Start(q database.Querier, userID int64, client Client) (*Token, error) {
+	return issue(q, utils.RandomToken(16), userID, client)
+}
+
+// Rotate exchanges a refresh token for a new one in the same session. A token
+// can be rotated once; presenting it again revokes its whole family.
+func Rotate(db *sql.DB, refreshToken string, client Client) (*Token, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id, userID int64
+	var familyID string
+	var rotated, revoked, live bool
+	err = tx.QueryRow(`
+		SELECT id, family_id, user_id, rotated_at IS NOT NULL, revoked_at IS NOT NULL, expires_at > CURRENT_TIMESTAMP
+		FROM sessions WHERE token_hash = ?`,
+		utils.HashToken(refreshToken)).Scan(&id, &familyID, &userID, &rotated, &revoked, &live)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if revoked || !live {
+		return nil, ErrInvalidToken
+	}
+
+	// Only one request can rotate a token, any other is treated as reuse
+	result, err := tx.Exec("UPDATE sessions SET rotated_at = CURRENT_TIMESTAMP WHERE id = ? AND rotated_at IS NULL", id)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rotated || affected == 0 {
+		if err := revokeFamily(tx, familyID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, ErrReused
+	}
+
+	token, err := issue(tx, familyID, userID, client)
+	if err != nil {
+		return nil, err
+	}
+	return token, tx.Commit()
+}
+
+// Active reports whether a session exists, has not been revoked or expired,
+// and belongs to an account that is not disabled
+func Active(q database.Querier, sessionID string) (bool, error) {
+	var count int
+	err := q.QueryRow(`
+		SELECT COUNT(*) FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.family_id = ? AND s.revoked_at IS NULL AND s.expires_at > CURRENT_TIMESTAMP AND u.disabled_at IS NULL`,
+		sessionID).Scan(&count)
+	return count > 0, err
+}
+
+// List returns the sessions a user is signed in with, most recently used
+// first, marking the one with ID currentID as current
+func List(q database.Querier, userID int64, currentID string) ([]models.Session, error) {
+	rows, err := q.Query(`
+		SELECT family_id, COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at, expires_at
+		FROM sessions
+		WHERE user_id = ? AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		ORDER BY id DESC`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Each session's newest token tells when and where it was last used
+	list := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		err := rows.Scan(&session.ID, &session.IPAddress, &session.UserAgent, &session.LastActiveAt, &session.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		session.Current = session.ID == currentID
+		list = append(list, session)
+	}
+	return list, rows.Err()
+}
+
+// Revoke ends one session of a user
+func Revoke(q database.Querier, userID int64, sessionID string) error {
+	_, err := q.Exec(
+		"UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = ? AND user_id = ? AND revoked_at IS NULL",
+		sessionID, userID)
+	return err
+}
+
+// RevokeAll ends every session of a user
+func RevokeAll(q database.Querier, userID int64) error {
+	_, err := q.Exec(
+		"UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL",
+		userID)
+	return err
+}
+
+// RevokeOthers ends every session of a user except the one in use
+func RevokeOthers(q database.Querier, userID int64, sessionID string) error {
+	_, err := q.Exec(
+		"UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND family_id <> ? AND revoked_at IS NULL",
+		userID, sessionID)
+	return err
+}
+
+// revokeFamily ends the session a token belongs to
+func revokeFamily(db database.Querier, familyID string) error {
+	_, err := db.Exec("UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = ? AND revoked_at IS NULL", familyID)
+	return err
+}
+
+// issue stores a new refresh token in a session family. Only its hash is kept.
+func issue(db database.Querier, familyID string, userID int64, client Client) (*Token, error) {
+	token := &Token{
+		SessionID:    familyID,
+		UserID:       userID,
+		RefreshToken: utils.RandomToken(32),
+		ExpiresAt:    time.Now().UTC().Add(RefreshTTL()).Truncate(time.Second),
+	}
+
+	_, err := db.Exec(
+		`INSERT INTO sessions (family_id, user_id, token_hash, user_agent, ip_address, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		familyID, userID, utils.HashToken(token.RefreshToken), client.UserAgent, client.IP, token.ExpiresAt.Format(database.TimestampFormat))
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
@@ -0,0 +1,85 @@
+package sessions_test
+
+import (
+	"backend/database/dbtest"
+	"backend/dialect"
+	"backend/sessions"
+	"database/sql"
+	"testing"
+)
+
+func TestRotateDetectsReuse(t *testing.T) {
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		userID := dbtest.CreateUser(t, db, "customer").ID
+
+		first, err := sessions.Start(db, userID, sessions.Client{UserAgent: "test"})
+		if err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+		second, err := sessions.Rotate(db, first.RefreshToken, sessions.Client{})
+		if err != nil {
+			t.Fatalf("Rotate: %v", err)
+		}
+		if second.SessionID != first.SessionID || second.UserID != userID || second.RefreshToken == first.RefreshToken {
+			t.Errorf("Rotate = %+v, want a new token in session %s", second, first.SessionID)
+		}
+		assertActive(t, db, first.SessionID, true)
+
+		// The first token was replaced, using it again ends the session
+		if _, err := sessions.Rotate(db, first.RefreshToken, sessions.Client{}); err != sessions.ErrReused {
+			t.Errorf("Rotate of a used token = %v, want ErrReused", err)
+		}
+		assertActive(t, db, first.SessionID, false)
+		if _, err := sessions.Rotate(db, second.RefreshToken, sessions.Client{}); err != sessions.ErrInvalidToken {
+			t.Errorf("Rotate in a revoked session = %v, want ErrInvalidToken", err)
+		}
+
+		if _, err := sessions.Rotate(db, "unknown", sessions.Client{}); err != sessions.ErrInvalidToken {
+			t.Errorf("Rotate of an unknown token = %v, want ErrInvalidToken", err)
+		}
+	})
+}
+
+func TestRevoke(t *testing.T) {
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		userID := dbtest.CreateUser(t, db, "customer").ID
+
+		one, err := sessions.Start(db, userID, sessions.Client{})
+		if err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+		two, err := sessions.Start(db, userID, sessions.Client{})
+		if err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+
+		if err := sessions.Revoke(db, userID, one.SessionID); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+		assertActive(t, db, one.SessionID, false)
+		assertActive(t, db, two.SessionID, true)
+
+		three, err := sessions.Start(db, userID, sessions.Client{})
+		if err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+		if err := sessions.RevokeOthers(db, userID, three.SessionID); err != nil {
+			t.Fatalf("RevokeOthers: %v", err)
+		}
+		assertActive(t, db, two.SessionID, false)
+		assertActive(t, db, three.SessionID, true)
+
+		if err := sessions.RevokeAll(db, userID); err != nil {
+			t.Fatalf("RevokeAll: %v", err)
+		}
+		assertActive(t, db, three.SessionID, false)
+	})
+}
+
+func assertActive(t *testing.T, db *sql.DB, sessionID string, want bool) {
+	t.Helper()
+
+	if active, err := sessions.Active(db, sessionID); err != nil || active != want {
+		t.Errorf("Active(%s) = %v, %v, want %v", sessionID, active, err, want)
+	}
+}
@@ -0,0 +1,254 @@
+package shipments
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/orderstatus"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Shipment statuses
+const (
+	StatusShipped   = "shipped"
+	StatusDelivered = "delivered"
+)
+
+// ErrNotFound is returned when a shipment does not belong to the order
+var ErrNotFound = errors.New("shipment not found")
+
+// RuleError is a shipment request the order cannot fulfil
+type RuleError string
+
+func (e RuleError) Error() string {
+	return string(e)
+}
+
+// Plan is a validated shipment: the quantities to ship per order item and the
+// status the order moves to once they are shipped
+type Plan struct {
+	Items       []models.ShipmentItemRequest
+	OrderStatus string
+}
+
+// PlanShipment checks requested quantities against what is left to ship.
+// Without items, everything left is shipped.
+func PlanShipment(q database.Querier, orderID int64, items []models.ShipmentItemRequest) (*Plan, error) {
+	remaining, order, err := unshipped(q, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	if len(items) == 0 {
+		for _, id := range order {
+			if remaining[id] > 0 {
+				plan.Items = append(plan.Items, models.ShipmentItemRequest{OrderItemID: id, Quantity: remaining[id]})
+				remaining[id] = 0
+			}
+		}
+		if len(plan.Items) == 0 {
+			return nil, RuleError("All items have already been shipped")
+		}
+	} else {
+		// Several entries for the same item add up
+		requested := map[int64]int{}
+		for _, item := range items {
+			left, ok := remaining[item.OrderItemID]
+			if !ok {
+				return nil, RuleError("Item is not part of this order")
+			}
+			if item.Quantity <= 0 {
+				return nil, RuleError("Quantity must be positive")
+			}
+			if item.Quantity > left {
+				return nil, RuleError("Quantity exceeds what is left to ship")
+			}
+			remaining[item.OrderItemID] -= item.Quantity
+			requested[item.OrderItemID] += item.Quantity
+		}
+		for _, id := range order {
+			if requested[id] > 0 {
+				plan.Items = append(plan.Items, models.ShipmentItemRequest{OrderItemID: id, Quantity: requested[id]})
+			}
+		}
+	}
+
+	plan.OrderStatus = "shipped"
+	for _, left := range remaining {
+		if left > 0 {
+			plan.OrderStatus = "partially_shipped"
+			break
+		}
+	}
+	return plan, nil
+}
+
+// Create records a planned shipment and moves the order to shipped or
+// partially shipped. Run it in the transaction that planned it.
+func Create(q database.Querier, orderID int64, carrier, trackingNumber string, plan *Plan, actor orderstatus.Actor, note string) (int64, error) {
+	var shipmentID int64
+	err := q.QueryRow(
+		`INSERT INTO shipments (order_id, carrier, tracking_number, status, shipped_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		orderID, carrier, trackingNumber, StatusShipped, time.Now(), time.Now(), time.Now()).Scan(&shipmentID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, item := range plan.Items {
+		_, err := q.Exec(
+			"INSERT INTO shipment_items (shipment_id, order_item_id, quantity) VALUES (?, ?, ?)",
+			shipmentID, item.OrderItemID, item.Quantity)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// A further partial shipment leaves the order where it is
+	var current string
+	if err := q.QueryRow("SELECT order_status FROM orders WHERE id = ?", orderID).Scan(&current); err != nil {
+		return 0, err
+	}
+	if current != plan.OrderStatus {
+		if err := orderstatus.SetOrder(q, orderID, plan.OrderStatus, actor, note); err != nil {
+			return 0, err
+		}
+	}
+	return shipmentID, nil
+}
+
+// CompletesOrder reports whether delivering a shipment delivers the whole order:
+// everything has shipped and every other shipment has arrived
+func CompletesOrder(q database.Querier, orderID, shipmentID int64) (bool, error) {
+	var status string
+	var open int
+	err := q.QueryRow(`
+		SELECT o.order_status,
+			(SELECT COUNT(*) FROM shipments s WHERE s.order_id = o.id AND s.id != ? AND s.status != ?)
+		FROM orders o
+		WHERE o.id = ?`,
+		shipmentID, StatusDelivered, orderID).Scan(&status, &open)
+	if err != nil {
+		return false, err
+	}
+	return status == "shipped" && open == 0, nil
+}
+
+// Deliver marks a shipment of an order as delivered
+func Deliver(q database.Querier, orderID, shipmentID int64) error {
+	var status string
+	err := q.QueryRow("SELECT status FROM shipments WHERE id = ? AND order_id = ?", shipmentID, orderID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status == StatusDelivered {
+		return RuleError("Shipment is already delivered")
+	}
+
+	_, err = q.Exec(
+		"UPDATE shipments SET status = ?, delivered_at = ?, updated_at = ? WHERE id = ?",
+		StatusDelivered, time.Now(), time.Now(), shipmentID)
+	return err
+}
+
+// DeliverAll marks every shipment of an order that is still on its way as delivered
+func DeliverAll(q database.Querier, orderID int64) error {
+	_, err := q.Exec(
+		"UPDATE shipments SET status = ?, delivered_at = ?, updated_at = ? WHERE order_id = ? AND status != ?",
+		StatusDelivered, time.Now(), time.Now(), orderID, StatusDelivered)
+	return err
+}
+
+// ForOrder returns the shipments of an order with their items, oldest first
+func ForOrder(q database.Querier, orderID int64) ([]models.Shipment, error) {
+	rows, err := q.Query(`
+		SELECT id, order_id, COALESCE(carrier, ''), COALESCE(tracking_number, ''), status, shipped_at, delivered_at
+		FROM shipments
+		WHERE order_id = ?
+		ORDER BY id`,
+		orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []models.Shipment{}
+	for rows.Next() {
+		var shipment models.Shipment
+		err := rows.Scan(&shipment.ID, &shipment.OrderID, &shipment.Carrier, &shipment.TrackingNumber,
+			&shipment.Status, &shipment.ShippedAt, &shipment.DeliveredAt)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		list = append(list, shipment)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range list {
+		if list[i].Items, err = items(q, list[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// items returns the order items packed in a shipment
+func items(q database.Querier, shipmentID int64) ([]models.ShipmentItem, error) {
+	rows, err := q.Query(`
+		SELECT si.order_item_id, oi.product_id, p.name, si.quantity
+		FROM shipment_items si
+		JOIN order_items oi ON si.order_item_id = oi.id
+		JOIN products p ON oi.product_id = p.id
+		WHERE si.shipment_id = ?
+		ORDER BY si.id`,
+		shipmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []models.ShipmentItem{}
+	for rows.Next() {
+		var item models.ShipmentItem
+		if err := rows.Scan(&item.OrderItemID, &item.ProductID, &item.ProductName, &item.Quantity); err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	return result, rows.Err()
+}
+
+// unshipped returns the quantity left to ship per order item, with the item IDs in order
+func unshipped(q database.Querier, orderID int64) (map[int64]int, []int64, error) {
+	rows, err := q.Query(`
+		SELECT oi.id, oi.quantity - COALESCE((SELECT SUM(si.quantity) FROM shipment_items si WHERE si.order_item_id = oi.id), 0)
+		FROM order_items oi
+		WHERE oi.order_id = ?
+		ORDER BY oi.id`,
+		orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	remaining := map[int64]int{}
+	var order []int64
+	for rows.Next() {
+		var id int64
+		var left int
+		if err := rows.Scan(&id, &left); err != nil {
+			return nil, nil, err
+		}
+		remaining[id] = left
+		order = append(order, id)
+	}
+	return remaining, order, rows.Err()
+}
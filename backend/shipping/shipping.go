@@ -0,0 +1,307 @@
+package shipping
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/money"
+	"backend/pricing"
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Shipping methods
+const (
+	MethodStandard = "standard"
+	MethodExpress  = "express"
+	MethodPickup   = "pickup"
+)
+
+// Pricing types
+const (
+	PricingFlat     = "flat"
+	PricingWeight   = "weight"
+	PricingFreeOver = "free_over"
+)
+
+// BillableWeight is the SQL expression for a product's shipping weight: the
+// larger of its actual weight in kilograms and its volumetric weight, the
+// volume in cubic centimeters divided by 5000. It expects products aliased as p.
+const BillableWeight = `CASE WHEN p.weight > p.length * p.width * p.height / 5000.0
+	THEN p.weight ELSE p.length * p.width * p.height / 5000.0 END`
+
+var (
+	// ErrNoZone is returned when no shipping zone covers an address
+	ErrNoZone = errors.New("no shipping zone covers this address")
+
+	// ErrMethodUnavailable is returned when a zone does not offer a method
+	ErrMethodUnavailable = errors.New("shipping method is not available for this address")
+)
+
+// RuleError is a shipping method that fails validation
+type RuleError string
+
+func (e RuleError) Error() string {
+	return string(e)
+}
+
+// Parcel is what is being shipped: its weight in kilograms and the merchandise
+// value after discounts, which decides free-over-threshold pricing. Value and
+// the resulting costs are in the currency of Exchange, nil for the store currency.
+type Parcel struct {
+	Weight       float64
+	Value        money.Amount
+	FreeShipping bool
+	Exchange     *pricing.Exchange
+}
+
+// NormalizePostalCode returns the canonical form of a postal code or prefix
+func NormalizePostalCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '-' {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, code)
+}
+
+// FindZone returns the zone covering an address, preferring the longest matching postal prefix
+func FindZone(q database.Querier, country, postalCode string) (*models.ShippingZone, error) {
+	var zone models.ShippingZone
+	err := q.QueryRow(`
+		SELECT id, name, country, postal_prefix, created_at, updated_at
+		FROM shipping_zones
+		WHERE country = ? AND SUBSTR(?, 1, LENGTH(postal_prefix)) = postal_prefix
+		ORDER BY LENGTH(postal_prefix) DESC
+		LIMIT 1`,
+		strings.ToUpper(strings.TrimSpace(country)), NormalizePostalCode(postalCode)).Scan(
+		&zone.ID, &zone.Name, &zone.Country, &zone.PostalPrefix, &zone.CreatedAt, &zone.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoZone
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &zone, nil
+}
+
+// Options prices every active method of the zone covering an address. Weight
+// tiered methods that cannot carry the parcel are left out.
+func Options(q database.Querier, country, postalCode string, parcel Parcel) ([]models.ShippingOption, error) {
+	zone, err := FindZone(q, country, postalCode)
+	if err != nil {
+		return nil, err
+	}
+	methods, err := Methods(q, zone.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []models.ShippingOption{}
+	for _, method := range methods {
+		if !method.IsActive {
+			continue
+		}
+		cost, ok := price(method, parcel)
+		if !ok {
+			continue
+		}
+		options = append(options, models.ShippingOption{
+			MethodID: method.ID,
+			Method:   method.Code,
+			Name:     method.Name,
+			Cost:     cost,
+			MinDays:  method.MinDays,
+			MaxDays:  method.MaxDays,
+		})
+	}
+	return options, nil
+}
+
+// Quote prices one method for an address
+func Quote(q database.Querier, country, postalCode, method string, parcel Parcel) (*models.ShippingOption, error) {
+	options, err := Options(q, country, postalCode, parcel)
+	if err != nil {
+		return nil, err
+	}
+	for i := range options {
+		if options[i].Method == method {
+			return &options[i], nil
+		}
+	}
+	return nil, ErrMethodUnavailable
+}
+
+// CartWeight returns the billable weight of a user's cart
+func CartWeight(q database.Querier, userID int64) (float64, error) {
+	var weight float64
+	err := q.QueryRow(`
+		SELECT COALESCE(SUM(c.quantity * `+BillableWeight+`), 0)
+		FROM cart c
+		JOIN products p ON c.product_id = p.id
+		WHERE c.user_id = ?`,
+		userID).Scan(&weight)
+	return math.Round(weight*1000) / 1000, err
+}
+
+// Zones returns every zone with its methods
+func Zones(q database.Querier) ([]models.ShippingZone, error) {
+	rows, err := q.Query(`
+		SELECT id, name, country, postal_prefix, created_at, updated_at
+		FROM shipping_zones
+		ORDER BY country, postal_prefix`)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := []models.ShippingZone{}
+	for rows.Next() {
+		var zone models.ShippingZone
+		if err := rows.Scan(&zone.ID, &zone.Name, &zone.Country, &zone.PostalPrefix, &zone.CreatedAt, &zone.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		zones = append(zones, zone)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range zones {
+		if zones[i].Methods, err = Methods(q, zones[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return zones, nil
+}
+
+// Methods returns the methods of a zone with their weight tiers
+func Methods(q database.Querier, zoneID int64) ([]models.ShippingMethod, error) {
+	rows, err := q.Query(`
+		SELECT id, zone_id, code, name, pricing, rate, free_threshold, min_days, max_days, is_active
+		FROM shipping_methods
+		WHERE zone_id = ?
+		ORDER BY id`,
+		zoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	methods := []models.ShippingMethod{}
+	for rows.Next() {
+		var method models.ShippingMethod
+		err := rows.Scan(&method.ID, &method.ZoneID, &method.Code, &method.Name, &method.Pricing, &method.Rate,
+			&method.FreeThreshold, &method.MinDays, &method.MaxDays, &method.IsActive)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		methods = append(methods, method)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range methods {
+		if methods[i].Tiers, err = tiers(q, methods[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return methods, nil
+}
+
+// tiers returns the weight tiers of a method, lightest first
+func tiers(q database.Querier, methodID int64) ([]models.WeightTier, error) {
+	rows, err := q.Query("SELECT max_weight, rate FROM shipping_weight_tiers WHERE method_id = ? ORDER BY max_weight", methodID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []models.WeightTier{}
+	for rows.Next() {
+		var tier models.WeightTier
+		if err := rows.Scan(&tier.MaxWeight, &tier.Rate); err != nil {
+			return nil, err
+		}
+		result = append(result, tier)
+	}
+	return result, rows.Err()
+}
+
+// price returns the cost of shipping a parcel with a method, and false when
+// the parcel is heavier than the method's last weight tier
+func price(method models.ShippingMethod, parcel Parcel) (money.Amount, bool) {
+	exchange := parcel.Exchange
+	if exchange == nil {
+		exchange = pricing.Store()
+	}
+
+	cost := method.Rate
+	switch method.Pricing {
+	case PricingWeight:
+		tier := sort.Search(len(method.Tiers), func(i int) bool {
+			return method.Tiers[i].MaxWeight >= parcel.Weight
+		})
+		if tier == len(method.Tiers) {
+			return 0, false
+		}
+		cost = method.Tiers[tier].Rate
+	case PricingFreeOver:
+		if parcel.Value >= exchange.Convert(method.FreeThreshold) {
+			cost = 0
+		}
+	}
+
+	if parcel.FreeShipping {
+		cost = 0
+	}
+	return exchange.Convert(cost), true
+}
+
+// ValidateMethod checks a shipping method request, normalizing its code and
+// sorting its weight tiers
+func ValidateMethod(req *models.ShippingMethodRequest) error {
+	req.Code = strings.ToLower(strings.TrimSpace(req.Code))
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Code != MethodStandard && req.Code != MethodExpress && req.Code != MethodPickup {
+		return RuleError("Method must be standard, express or pickup")
+	}
+	if req.Name == "" {
+		return RuleError("Name is required")
+	}
+	if req.Rate < 0 || req.FreeThreshold < 0 {
+		return RuleError("Rate and free threshold cannot be negative")
+	}
+	if req.MinDays < 0 || req.MaxDays < req.MinDays {
+		return RuleError("Delivery days must be a valid range")
+	}
+
+	switch req.Pricing {
+	case PricingFlat:
+	case PricingFreeOver:
+		if req.FreeThreshold <= 0 {
+			return RuleError("Free threshold is required for free-over pricing")
+		}
+	case PricingWeight:
+		if len(req.Tiers) == 0 {
+			return RuleError("Weight tiers are required for weight pricing")
+		}
+		for _, tier := range req.Tiers {
+			if tier.MaxWeight <= 0 || tier.Rate < 0 {
+				return RuleError("Weight tiers need a positive weight and a rate")
+			}
+		}
+		sort.Slice(req.Tiers, func(i, j int) bool {
+			return req.Tiers[i].MaxWeight < req.Tiers[j].MaxWeight
+		})
+	default:
+		return RuleError("Pricing must be flat, weight or free_over")
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package tax
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/money"
+	"database/sql"
+	"strings"
+)
+
+// Line is a priced cart or order line to be taxed
+type Line struct {
+	CategoryID int64
+	Quantity   int
+	UnitPrice  money.Amount
+	Discount   money.Amount
+}
+
+// LineTax is the tax on one line
+type LineTax struct {
+	Rate     float64
+	Amount   money.Amount
+	Included bool
+}
+
+// Result is the tax on a set of lines. Added is charged on top of the
+// prices, Included is already contained in tax-inclusive prices.
+type Result struct {
+	Lines    []LineTax
+	Added    money.Amount
+	Included money.Amount
+}
+
+// NormalizeRegion returns the canonical form of a country or state code
+func NormalizeRegion(region string) string {
+	return strings.ToUpper(strings.TrimSpace(region))
+}
+
+// Calculate taxes lines shipped to a destination. A category rule beats a
+// general one, and a state rule beats a country-wide one; lines without any
+// matching rule are not taxed.
+func Calculate(q database.Querier, country, state string, lines []Line) (*Result, error) {
+	rules, err := rulesFor(q, NormalizeRegion(country), NormalizeRegion(state))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Lines: make([]LineTax, len(lines))}
+	for i, line := range lines {
+		rule := match(rules, line.CategoryID)
+		if rule == nil {
+			continue
+		}
+
+		taxable := line.UnitPrice.Mul(line.Quantity) - line.Discount
+		lineTax := LineTax{Rate: rule.Rate, Included: rule.PriceIncludesTax}
+		if rule.PriceIncludesTax {
+			lineTax.Amount = taxable.IncludedPercent(rule.Rate)
+			result.Included += lineTax.Amount
+		} else {
+			lineTax.Amount = taxable.Percent(rule.Rate)
+			result.Added += lineTax.Amount
+		}
+		result.Lines[i] = lineTax
+	}
+
+	return result, nil
+}
+
+// rulesFor returns the rules of a country that apply to a state, most specific first
+func rulesFor(q database.Querier, country, state string) ([]models.TaxRule, error) {
+	rows, err := q.Query(`
+		SELECT id, country, state, category_id, rate, price_includes_tax
+		FROM tax_rules
+		WHERE country = ? AND (state = '' OR state = ?)
+		ORDER BY CASE WHEN category_id IS NULL THEN 1 ELSE 0 END, CASE WHEN state = '' THEN 1 ELSE 0 END`,
+		country, state)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.TaxRule
+	for rows.Next() {
+		var rule models.TaxRule
+		var categoryID sql.NullInt64
+		err := rows.Scan(&rule.ID, &rule.Country, &rule.State, &categoryID, &rule.Rate, &rule.PriceIncludesTax)
+		if err != nil {
+			return nil, err
+		}
+		if categoryID.Valid {
+			rule.CategoryID = &categoryID.Int64
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// match returns the first rule that covers a category
+func match(rules []models.TaxRule, categoryID int64) *models.TaxRule {
+	for i, rule := range rules {
+		if rule.CategoryID == nil || *rule.CategoryID == categoryID {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// List returns every tax rule ordered by region
+func List(q database.Querier) ([]models.TaxRule, error) {
+	rows, err := q.Query(`
+		SELECT id, COALESCE(name, ''), country, state, category_id, rate, price_includes_tax, created_at, updated_at
+		FROM tax_rules
+		ORDER BY country, state, category_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.TaxRule{}
+	for rows.Next() {
+		var rule models.TaxRule
+		var categoryID sql.NullInt64
+		err := rows.Scan(&rule.ID, &rule.Name, &rule.Country, &rule.State, &categoryID, &rule.Rate,
+			&rule.PriceIncludesTax, &rule.CreatedAt, &rule.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if categoryID.Valid {
+			rule.CategoryID = &categoryID.Int64
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
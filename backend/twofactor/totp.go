@@ -0,0 +1,93 @@
+package twofactor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters (RFC 6238) understood by every authenticator app
+const (
+	period = 30 // seconds per time step
+	digits = 6
+	modulo = 1000000 // 10^digits
+	skew   = 1       // time steps of clock drift accepted either way
+)
+
+// secretEncoding is unpadded base32, the format authenticator apps expect
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random 160-bit TOTP secret in base32
+func NewSecret() string {
+	key := make([]byte, 20)
+	rand.Read(key)
+	return secretEncoding.EncodeToString(key)
+}
+
+// ProvisioningURI returns the otpauth:// URI that authenticator apps read,
+// usually from a QR code, to add an account
+func ProvisioningURI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(digits))
+	query.Set("period", fmt.Sprint(period))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Code returns the code of a secret at a time
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return code(key, timeStep(t)), nil
+}
+
+// match returns the time step of a code that is valid at a time, allowing for clock drift
+func match(secret, given string, t time.Time) (int64, bool) {
+	key, err := decodeSecret(secret)
+	if err != nil || len(given) != digits {
+		return 0, false
+	}
+
+	now := timeStep(t)
+	for step := now - skew; step <= now+skew; step++ {
+		if hmac.Equal([]byte(code(key, step)), []byte(given)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// code computes the HOTP value (RFC 4226) of a key for a counter
+func code(key []byte, counter int64) string {
+	mac := hmac.New(sha1.New, key)
+	binary.Write(mac, binary.BigEndian, counter)
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation picks 31 bits at an offset given by the last nibble
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, value%modulo)
+}
+
+// timeStep returns the number of periods since the Unix epoch
+func timeStep(t time.Time) int64 {
+	return t.Unix() / period
+}
+
+// decodeSecret reads a base32 secret, ignoring case, spaces and padding
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	return secretEncoding.DecodeString(strings.TrimRight(secret, "="))
+}
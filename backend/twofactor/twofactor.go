@@ -0,0 +1,263 @@
+// Package twofactor implements two-factor authentication with time-based
+// one-time passwords (TOTP) from an authenticator app, and single-use recovery
+// codes for when the app is lost.
+package twofactor
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrAlreadyEnabled is returned when setting up two-factor authentication twice
+	ErrAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrNotEnabled is returned when a user has not enabled two-factor authentication
+	ErrNotEnabled = errors.New("two-factor authentication is not enabled")
+	// ErrNotStarted is returned when confirming a setup that was never started
+	ErrNotStarted = errors.New("two-factor setup has not been started")
+	// ErrInvalidCode is returned for wrong, expired and already used codes
+	ErrInvalidCode = errors.New("invalid two-factor code")
+)
+
+// RecoveryCodeCount is how many recovery codes a user gets at a time
+const RecoveryCodeCount = 10
+
+// recoveryAlphabet has 32 characters and leaves out i, l, o and 1, which are easily confused
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz023456789"
+
+// Issuer returns the name authenticator apps show for the account, configured by TWO_FACTOR_ISSUER
+func Issuer() string {
+	if issuer := os.Getenv("TWO_FACTOR_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "StyleSpace"
+}
+
+// Begin starts setting up two-factor authentication and returns a new secret,
+// replacing the secret of a setup that was never confirmed
+func Begin(q database.Querier, userID int64) (string, error) {
+	secret := NewSecret()
+	err := q.QueryRow(`
+		INSERT INTO user_two_factor (user_id, secret) VALUES (?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+		WHERE user_two_factor.enabled_at IS NULL
+		RETURNING user_id`,
+		userID, secret).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrAlreadyEnabled
+	}
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Confirm enables two-factor authentication with a first code from the
+// authenticator app, proving it was set up, and returns recovery codes
+func Confirm(db *sql.DB, userID int64, given string) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var secret string
+	var enabledAt *time.Time
+	err = tx.QueryRow("SELECT secret, enabled_at FROM user_two_factor WHERE user_id = ?", userID).Scan(&secret, &enabledAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotStarted
+	}
+	if err != nil {
+		return nil, err
+	}
+	if enabledAt != nil {
+		return nil, ErrAlreadyEnabled
+	}
+
+	step, ok := match(secret, strings.TrimSpace(given), time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	_, err = tx.Exec(
+		"UPDATE user_two_factor SET enabled_at = CURRENT_TIMESTAMP, last_used_step = ? WHERE user_id = ?",
+		step, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// Verify checks a code from the authenticator app, or uses up a recovery code.
+// Every code works only once.
+func Verify(q database.Querier, userID int64, given string) error {
+	given = strings.TrimSpace(given)
+	if len(given) != digits || strings.Trim(given, "0123456789") != "" {
+		return useRecoveryCode(q, userID, given)
+	}
+
+	var secret string
+	err := q.QueryRow(
+		"SELECT secret FROM user_two_factor WHERE user_id = ? AND enabled_at IS NOT NULL",
+		userID).Scan(&secret)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotEnabled
+	}
+	if err != nil {
+		return err
+	}
+
+	step, ok := match(secret, given, time.Now())
+	if !ok {
+		return ErrInvalidCode
+	}
+
+	// Moving past the step also stops a concurrent login with the same code
+	result, err := q.Exec(
+		"UPDATE user_two_factor SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?",
+		step, userID, step)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		if err != nil {
+			return err
+		}
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces a user's recovery codes with new ones
+func RegenerateRecoveryCodes(db *sql.DB, userID int64) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var enabled bool
+	err = tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM user_two_factor WHERE user_id = ? AND enabled_at IS NOT NULL)",
+		userID).Scan(&enabled)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrNotEnabled
+	}
+
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// Disable turns off two-factor authentication, deleting the secret and recovery codes
+func Disable(db *sql.DB, userID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"user_two_factor", "two_factor_recovery_codes"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Status returns whether a user has enabled two-factor authentication, whether
+// their role requires it and how many recovery codes are left
+func Status(q database.Querier, userID int64) (models.TwoFactorStatus, error) {
+	var status models.TwoFactorStatus
+	err := q.QueryRow(`
+		SELECT
+			EXISTS(SELECT 1 FROM user_two_factor t WHERE t.user_id = u.id AND t.enabled_at IS NOT NULL),
+			COALESCE((SELECT r.require_two_factor FROM roles r WHERE r.name = u.role), FALSE),
+			(SELECT COUNT(*) FROM two_factor_recovery_codes c WHERE c.user_id = u.id AND c.used_at IS NULL)
+		FROM users u
+		WHERE u.id = ?`,
+		userID).Scan(&status.Enabled, &status.Required, &status.RecoveryCodesLeft)
+	return status, err
+}
+
+// SetupPending reports whether a user's role requires two-factor
+// authentication that the user has not enabled yet. It is read from the
+// database on every check, so policy changes apply to tokens already issued.
+func SetupPending(q database.Querier, userID int64) (bool, error) {
+	status, err := Status(q, userID)
+	return status.Required && !status.Enabled, err
+}
+
+// replaceRecoveryCodes deletes a user's recovery codes and creates new ones
+func replaceRecoveryCodes(tx *sql.Tx, userID int64) ([]string, error) {
+	if _, err := tx.Exec("DELETE FROM two_factor_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		codes[i] = newRecoveryCode()
+		_, err := tx.Exec(
+			"INSERT INTO two_factor_recovery_codes (user_id, code_hash) VALUES (?, ?)",
+			userID, hashRecoveryCode(codes[i]))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// useRecoveryCode marks an unused recovery code of a user as used
+func useRecoveryCode(q database.Querier, userID int64, given string) error {
+	result, err := q.Exec(
+		"UPDATE two_factor_recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+		userID, hashRecoveryCode(given))
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		if err != nil {
+			return err
+		}
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// newRecoveryCode returns a random code of 50 bits, such as k7m2p-x9cqa
+func newRecoveryCode() string {
+	buf := make([]byte, 10)
+	rand.Read(buf)
+
+	code := make([]byte, 0, 11)
+	for i, b := range buf {
+		if i == 5 {
+			code = append(code, '-')
+		}
+		code = append(code, recoveryAlphabet[b%32])
+	}
+	return string(code)
+}
+
+// hashRecoveryCode hashes a recovery code the way it is stored, ignoring case,
+// spaces and dashes
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return utils.HashToken(code)
+}
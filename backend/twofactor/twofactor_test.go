@@ -0,0 +1,104 @@
+package twofactor_test
+
+import (
+	"backend/database/dbtest"
+	"backend/dialect"
+	"backend/twofactor"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCode(t *testing.T) {
+	// The SHA-1 test vectors of RFC 6238, cut to six digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1234567890:  "005924",
+		20000000000: "353130",
+	}
+	for unix, want := range vectors {
+		got, err := twofactor.Code(secret, time.Unix(unix, 0))
+		if err != nil || got != want {
+			t.Errorf("Code at %d = %q, %v, want %q", unix, got, err, want)
+		}
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := twofactor.ProvisioningURI("StyleSpace", "ada@example.com", "JBSWY3DPEHPK3PXP")
+	want := "otpauth://totp/StyleSpace:ada@example.com?algorithm=SHA1&digits=6&issuer=StyleSpace&period=30&secret=JBSWY3DPEHPK3PXP"
+	if uri != want {
+		t.Errorf("ProvisioningURI = %q, want %q", uri, want)
+	}
+}
+
+func TestEnrolment(t *testing.T) {
+	dbtest.EachMigrated(t, func(t *testing.T, db *sql.DB, d dialect.Dialect) {
+		userID := dbtest.CreateUser(t, db, "admin").ID
+
+		// The admin role is made to require two-factor authentication
+		if _, err := db.Exec("UPDATE roles SET require_two_factor = TRUE WHERE name = 'admin'"); err != nil {
+			t.Fatalf("requiring two-factor: %v", err)
+		}
+		assertPending(t, db, userID, true)
+
+		secret, err := twofactor.Begin(db, userID)
+		if err != nil {
+			t.Fatalf("Begin: %v", err)
+		}
+		if _, err := twofactor.Confirm(db, userID, "000000"); err != twofactor.ErrInvalidCode {
+			t.Errorf("Confirm with a wrong code = %v, want ErrInvalidCode", err)
+		}
+		code, _ := twofactor.Code(secret, time.Now())
+		recovery, err := twofactor.Confirm(db, userID, code)
+		if err != nil || len(recovery) != twofactor.RecoveryCodeCount {
+			t.Fatalf("Confirm = %d codes, %v, want %d codes", len(recovery), err, twofactor.RecoveryCodeCount)
+		}
+		assertPending(t, db, userID, false)
+		if _, err := twofactor.Begin(db, userID); err != twofactor.ErrAlreadyEnabled {
+			t.Errorf("Begin when enabled = %v, want ErrAlreadyEnabled", err)
+		}
+
+		// The code that confirmed the setup cannot log in as well
+		if err := twofactor.Verify(db, userID, code); err != twofactor.ErrInvalidCode {
+			t.Errorf("Verify of a used code = %v, want ErrInvalidCode", err)
+		}
+		next, _ := twofactor.Code(secret, time.Now().Add(30*time.Second))
+		if err := twofactor.Verify(db, userID, next); err != nil {
+			t.Errorf("Verify of the next code = %v, want nil", err)
+		}
+
+		// Recovery codes work once, in any case and without the dash
+		given := strings.ToUpper(strings.ReplaceAll(recovery[0], "-", ""))
+		if err := twofactor.Verify(db, userID, given); err != nil {
+			t.Errorf("Verify of a recovery code = %v, want nil", err)
+		}
+		if err := twofactor.Verify(db, userID, recovery[0]); err != twofactor.ErrInvalidCode {
+			t.Errorf("Verify of a used recovery code = %v, want ErrInvalidCode", err)
+		}
+
+		status, err := twofactor.Status(db, userID)
+		if err != nil || !status.Enabled || !status.Required || status.RecoveryCodesLeft != twofactor.RecoveryCodeCount-1 {
+			t.Errorf("Status = %+v, %v, want enabled, required and one code used", status, err)
+		}
+
+		if err := twofactor.Disable(db, userID); err != nil {
+			t.Fatalf("Disable: %v", err)
+		}
+		if err := twofactor.Verify(db, userID, recovery[1]); err != twofactor.ErrInvalidCode {
+			t.Errorf("Verify of a recovery code after Disable = %v, want ErrInvalidCode", err)
+		}
+		assertPending(t, db, userID, true)
+	})
+}
+
+func assertPending(t *testing.T, db *sql.DB, userID int64, want bool) {
+	t.Helper()
+
+	if pending, err := twofactor.SetupPending(db, userID); err != nil || pending != want {
+		t.Errorf("SetupPending = %v, %v, want %v", pending, err, want)
+	}
+}
@@ -1,8 +1,13 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -24,16 +29,40 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
+// RandomToken returns a URL-safe token of n random bytes
+func RandomToken(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// HashToken returns the SHA-256 of a random token, which is how tokens are
+// stored. Unlike passwords, random tokens need no slow hash.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // Claims represents JWT claims
 type Claims struct {
-	UserID int64  `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID    int64  `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a JWT token
-func GenerateToken(userID int64, email, role string) (string, error) {
+// AccessTokenTTL returns how long an access token is valid, configured by ACCESS_TOKEN_TTL_MINUTES
+func AccessTokenTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("ACCESS_TOKEN_TTL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// GenerateToken generates a short-lived JWT access token for a session
+func GenerateToken(userID int64, email, role, sessionID string) (string, error) {
 	// Get the JWT key from environment
 	jwtKey := os.Getenv("JWT_SECRET")
 	if jwtKey == "" {
@@ -42,11 +71,12 @@ func GenerateToken(userID int64, email, role string) (string, error) {
 
 	// Create claims
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // Token expires in 24 hours
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL())),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Slugify converts a name into a lowercase, hyphen separated URL slug
+func Slugify(name string) string {
+	var builder strings.Builder
+	pendingHyphen := false
+
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r == '\'' || r == '’':
+			// Drop apostrophes so "Men's" becomes "mens"
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if pendingHyphen && builder.Len() > 0 {
+				builder.WriteRune('-')
+			}
+			builder.WriteRune(r)
+			pendingHyphen = false
+		default:
+			pendingHyphen = true
+		}
+	}
+
+	return builder.String()
+}
+
+// UniqueSlug builds a slug from name, or from fallback when name has no
+// letters or digits, adding a numeric suffix for as long as taken reports the
+// slug in use
+func UniqueSlug(name, fallback string, taken func(slug string) (bool, error)) (string, error) {
+	base := Slugify(name)
+	if base == "" {
+		base = fallback
+	}
+
+	slug := base
+	for suffix := 2; ; suffix++ {
+		inUse, err := taken(slug)
+		if err != nil {
+			return "", err
+		}
+		if !inUse {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, suffix)
+	}
+}
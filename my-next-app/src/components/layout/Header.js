@@ -9,6 +9,7 @@ import { fetchCart } from "@/redux/slices/cartSlice";
 import { fetchWishlist } from "@/redux/slices/wishlistSlice";
 import { logout, fetchCurrentUser } from "@/redux/slices/authSlice";
 import { isAuthenticated } from "@/services/auth-helper";
+import { authAPI } from "@/services/api";
 import toast from "react-hot-toast";
 
 export default function Header() {
@@ -42,7 +43,9 @@ export default function Header() {
     }
   };
   
-  const handleLogout = () => {
+  const handleLogout = async () => {
+    // Revoke the session on the server, the local tokens are dropped either way
+    await authAPI.logout().catch(() => {});
     dispatch(logout());
     toast.success("Logged out successfully");
     router.push('/');
@@ -63,8 +63,8 @@ export default function FeaturedProducts() {
   const [featuredProducts, setFeaturedProducts] = useState([]);
 
   useEffect(() => {
-    // Fetch products with isFeatured=true parameter
-    dispatch(fetchProducts({ isFeatured: true }));
+    // Fetch products with featured=true parameter
+    dispatch(fetchProducts({ featured: true }));
   }, [dispatch]);
 
   useEffect(() => {
@@ -64,9 +64,10 @@ export const authSlice = createSlice({
       state.loading = false;
       state.error = null;
       
-      // Remove token from localStorage
+      // Remove tokens from localStorage
       if (typeof window !== 'undefined') {
         localStorage.removeItem('token');
+        localStorage.removeItem('refreshToken');
       }
     },
     
@@ -109,6 +110,7 @@ export const authSlice = createSlice({
         // Save to localStorage for persistence
         if (typeof window !== 'undefined') {
           localStorage.setItem('token', action.payload.token);
+          localStorage.setItem('refreshToken', action.payload.refresh_token);
         }
       })
       .addCase(loginUser.rejected, (state, action) => {
@@ -135,9 +137,10 @@ export const authSlice = createSlice({
         state.isAuthenticated = false;
         state.error = action.payload;
         
-        // Remove invalid token
+        // Remove invalid tokens
         if (typeof window !== 'undefined') {
           localStorage.removeItem('token');
+          localStorage.removeItem('refreshToken');
         }
       });
   },
@@ -21,12 +21,37 @@ const initialState = {
   },
 };
 
+// Map the UI sort options onto the API sort values
+const sortParams = {
+  'newest': 'newest',
+  'price-asc': 'price_asc',
+  'price-desc': 'price_desc',
+  'popularity': 'rating',
+};
+
+// Build the product list query from the current filters and pagination
+const buildProductParams = ({ filters, pagination }) => {
+  const params = {
+    page: pagination.page,
+    limit: pagination.limit,
+    sort: sortParams[filters.sortBy] || 'newest',
+  };
+
+  if (filters.category) params.category_id = filters.category;
+  if (filters.minPrice !== null) params.min_price = filters.minPrice;
+  if (filters.maxPrice !== null) params.max_price = filters.maxPrice;
+
+  return params;
+};
+
 // Product thunks
 export const fetchProducts = createAsyncThunk(
   'products/fetchAll',
-  async (params, { rejectWithValue }) => {
+  async (params, { getState, rejectWithValue }) => {
     try {
-      const response = await productsAPI.getAll(params);
+      // Filtering, sorting and pagination happen on the server
+      const query = params || buildProductParams(getState().products);
+      const response = await productsAPI.getAll(query);
       return response.data;
     } catch (error) {
       return rejectWithValue(error.response?.data?.message || 'Failed to fetch products');
@@ -119,41 +144,6 @@ const productSlice = createSlice({
       state.pagination = { ...state.pagination, ...action.payload };
     },
     
-    applyFilters: (state) => {
-      let filtered = [...state.products];
-      
-      // Apply category filter
-      if (state.filters.category) {
-        filtered = filtered.filter(product => 
-          product.category === state.filters.category || 
-          product.categoryId === state.filters.category
-        );
-      }
-      
-      // Apply price filter
-      if (state.filters.minPrice !== null) {
-        filtered = filtered.filter(product => product.price >= state.filters.minPrice);
-      }
-      
-      if (state.filters.maxPrice !== null) {
-        filtered = filtered.filter(product => product.price <= state.filters.maxPrice);
-      }
-      
-      // Apply sorting
-      if (state.filters.sortBy === 'price-asc') {
-        filtered.sort((a, b) => a.price - b.price);
-      } else if (state.filters.sortBy === 'price-desc') {
-        filtered.sort((a, b) => b.price - a.price);
-      } else if (state.filters.sortBy === 'newest') {
-        filtered.sort((a, b) => new Date(b.createdAt) - new Date(a.createdAt));
-      } else if (state.filters.sortBy === 'popularity') {
-        filtered.sort((a, b) => b.popularity - a.popularity);
-      }
-      
-      state.filteredProducts = filtered;
-      state.pagination.total = filtered.length;
-    },
-    
     clearProductState: (state) => {
       state.product = null;
     },
@@ -174,15 +164,14 @@ const productSlice = createSlice({
         state.products = action.payload.products || action.payload;
         state.filteredProducts = action.payload.products || action.payload;
         
-        if (action.payload.pagination) {
+        if (action.payload.meta) {
           state.pagination = {
             ...state.pagination,
-            ...action.payload.pagination
+            page: action.payload.meta.page,
+            limit: action.payload.meta.limit,
+            total: action.payload.meta.total,
           };
         }
-        
-        // Apply filters when products are fetched
-        productSlice.caseReducers.applyFilters(state);
       })
       .addCase(fetchProducts.rejected, (state, action) => {
         state.loading = false;
@@ -211,7 +200,7 @@ const productSlice = createSlice({
       .addCase(createProduct.fulfilled, (state, action) => {
         state.loading = false;
         state.products = [action.payload, ...state.products];
-        productSlice.caseReducers.applyFilters(state);
+        state.filteredProducts = state.products;
       })
       .addCase(createProduct.rejected, (state, action) => {
         state.loading = false;
@@ -231,7 +220,7 @@ const productSlice = createSlice({
         if (state.product && state.product.id === action.payload.id) {
           state.product = action.payload;
         }
-        productSlice.caseReducers.applyFilters(state);
+        state.filteredProducts = state.products;
       })
       .addCase(updateProduct.rejected, (state, action) => {
         state.loading = false;
@@ -246,7 +235,7 @@ const productSlice = createSlice({
       .addCase(deleteProduct.fulfilled, (state, action) => {
         state.loading = false;
         state.products = state.products.filter(p => p.id !== action.payload);
-        productSlice.caseReducers.applyFilters(state);
+        state.filteredProducts = state.products;
       })
       .addCase(deleteProduct.rejected, (state, action) => {
         state.loading = false;
@@ -277,7 +266,6 @@ const productSlice = createSlice({
 export const { 
   setFilters, 
   setPagination, 
-  applyFilters, 
   clearProductState, 
   clearError 
 } = productSlice.actions;
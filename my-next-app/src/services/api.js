@@ -22,17 +22,66 @@ api.interceptors.request.use(
   (error) => Promise.reject(error)
 );
 
+// Response interceptor that renews an expired access token once with the refresh token
+let refreshing = null;
+api.interceptors.response.use(
+  (response) => response,
+  async (error) => {
+    const original = error.config;
+    const refreshToken = localStorage.getItem('refreshToken');
+    if (error.response?.status !== 401 || !refreshToken || original._retried || original.url === '/auth/refresh') {
+      return Promise.reject(error);
+    }
+    original._retried = true;
+
+    try {
+      // Concurrent requests share one refresh, a refresh token can only be used once
+      refreshing = refreshing || api.post('/auth/refresh', { refresh_token: refreshToken });
+      const { data } = await refreshing;
+      localStorage.setItem('token', data.token);
+      localStorage.setItem('refreshToken', data.refresh_token);
+      return api(original);
+    } catch (refreshError) {
+      localStorage.removeItem('token');
+      localStorage.removeItem('refreshToken');
+      return Promise.reject(error);
+    } finally {
+      refreshing = null;
+    }
+  }
+);
+
 // Auth API
 export const authAPI = {
   register: (userData) => api.post('/auth/register', userData),
   login: (credentials) => api.post('/auth/login', credentials),
   getCurrentUser: () => api.get('/auth/me'),
+  updateProfile: (profile) => api.put('/auth/me', profile),
+  changePassword: (currentPassword, newPassword) =>
+    api.post('/auth/change-password', { current_password: currentPassword, new_password: newPassword }),
+  deleteAccount: (password) => api.delete('/auth/me', { data: { password } }),
+  getSessions: () => api.get('/auth/me/sessions'),
+  revokeSession: (id) => api.delete(`/auth/me/sessions/${id}`),
+  logout: () => api.post('/auth/logout'),
+  logoutAll: () => api.post('/auth/logout-all'),
+  verifyEmail: (token) => api.post('/auth/verify-email', { token }),
+  resendVerification: () => api.post('/auth/verify-email/resend'),
+  forgotPassword: (email) => api.post('/auth/forgot-password', { email }),
+  resetPassword: (token, password) => api.post('/auth/reset-password', { token, password }),
+  verifyTwoFactor: (challengeToken, code) =>
+    api.post('/auth/2fa/verify', { challenge_token: challengeToken, code }),
+  getTwoFactor: () => api.get('/auth/2fa'),
+  setupTwoFactor: () => api.post('/auth/2fa/setup'),
+  confirmTwoFactor: (code) => api.post('/auth/2fa/confirm', { code }),
+  regenerateRecoveryCodes: (password) => api.post('/auth/2fa/recovery-codes', { password }),
+  disableTwoFactor: (password, code) => api.post('/auth/2fa/disable', { password, code }),
 };
 
 // Products API
 export const productsAPI = {
   getAll: (params) => api.get('/products', { params }),
   getById: (id) => api.get(`/products/${id}`),
+  getBySlug: (slug) => api.get(`/products/slug/${slug}`),
   create: (productData) => api.post('/products', productData),
   update: (id, productData) => api.put(`/products/${id}`, productData),
   delete: (id) => api.delete(`/products/${id}`),
@@ -47,10 +96,24 @@ export const productsAPI = {
   deleteImage: (id, imageId) => api.delete(`/products/${id}/images/${imageId}`),
 };
 
+// Search API
+export const searchAPI = {
+  search: (params) => api.get('/search', { params }),
+};
+
+// Reviews API
+export const reviewsAPI = {
+  getAll: (productId, params) => api.get(`/products/${productId}/reviews`, { params }),
+  create: (productId, reviewData) => api.post(`/products/${productId}/reviews`, reviewData),
+  update: (productId, reviewId, reviewData) => api.put(`/products/${productId}/reviews/${reviewId}`, reviewData),
+  delete: (productId, reviewId) => api.delete(`/products/${productId}/reviews/${reviewId}`),
+};
+
 // Categories API
 export const categoriesAPI = {
   getAll: () => api.get('/categories'),
   getById: (id) => api.get(`/categories/${id}`),
+  getBySlug: (slug) => api.get(`/categories/slug/${slug}`),
   create: (categoryData) => api.post('/categories', categoryData),
   update: (id, categoryData) => api.put(`/categories/${id}`, categoryData),
   delete: (id) => api.delete(`/categories/${id}`),